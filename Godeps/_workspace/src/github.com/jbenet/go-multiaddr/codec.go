@@ -31,7 +31,7 @@ func stringToBytes(s string) ([]byte, error) {
 		b = append(b, CodeToVarint(p.Code)...)
 		sp = sp[1:]
 
-		if p.Size > 0 {
+		if p.Size != 0 {
 			if len(sp) < 1 {
 				return nil, fmt.Errorf("protocol requires address, none given: %s", p.Name)
 			}
@@ -39,6 +39,9 @@ func stringToBytes(s string) ([]byte, error) {
 			if err != nil {
 				return nil, fmt.Errorf("failed to parse %s: %s %s", p.Name, sp[0], err)
 			}
+			if p.Size == LengthPrefixedVarSize {
+				b = append(b, CodeToVarint(len(a))...)
+			}
 			b = append(b, a...)
 			sp = sp[1:]
 		}
@@ -67,12 +70,20 @@ func bytesToString(b []byte) (ret string, err error) {
 		}
 		s = strings.Join([]string{s, "/", p.Name}, "")
 
-		if p.Size > 0 {
-			a := addressBytesToString(p, b[:(p.Size/8)])
+		if p.Size != 0 {
+			skip, size, err := sizeForAddr(p, b)
+			if err != nil {
+				return "", err
+			}
+			b = b[skip:]
+			a, err := addressBytesToString(p, b[:size])
+			if err != nil {
+				return "", err
+			}
 			if len(a) > 0 {
 				s = strings.Join([]string{s, "/", a}, "")
 			}
-			b = b[(p.Size / 8):]
+			b = b[size:]
 		}
 	}
 
@@ -96,7 +107,11 @@ func bytesSplit(b []byte) (ret [][]byte, err error) {
 			return [][]byte{}, fmt.Errorf("no protocol with code %d", b[0])
 		}
 
-		length := n + (p.Size / 8)
+		skip, size, err := sizeForAddr(p, b[n:])
+		if err != nil {
+			return [][]byte{}, err
+		}
+		length := n + skip + size
 		ret = append(ret, b[:length])
 		b = b[length:]
 	}
@@ -135,21 +150,27 @@ func addressStringToBytes(p Protocol, s string) ([]byte, error) {
 		return b, nil
 	}
 
+	if t := transcoders[p.Code]; t != nil {
+		return t.StringToBytes(s)
+	}
 	return []byte{}, fmt.Errorf("failed to parse %s addr: unknown", p.Name)
 }
 
-func addressBytesToString(p Protocol, b []byte) string {
+func addressBytesToString(p Protocol, b []byte) (string, error) {
 	switch p.Code {
 
 	// ipv4,6
 	case P_IP4, P_IP6:
-		return net.IP(b).String()
+		return net.IP(b).String(), nil
 
 	// tcp udp dccp sctp
 	case P_TCP, P_UDP, P_DCCP, P_SCTP:
 		i := binary.BigEndian.Uint16(b)
-		return strconv.Itoa(int(i))
+		return strconv.Itoa(int(i)), nil
 	}
 
-	return ""
+	if t := transcoders[p.Code]; t != nil {
+		return t.BytesToString(b)
+	}
+	return "", nil
 }
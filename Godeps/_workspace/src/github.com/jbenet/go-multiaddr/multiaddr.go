@@ -75,7 +75,13 @@ func (m *multiaddr) Protocols() []Protocol {
 			panic(fmt.Errorf("no protocol with code %d", b[0]))
 		}
 		ps = append(ps, p)
-		b = b[n+(p.Size/8):]
+		skip, size, err := sizeForAddr(p, b[n:])
+		if err != nil {
+			// the bytes were checked on constructing the Multiaddr, so
+			// this cannot happen. stop at what we could read.
+			return ps
+		}
+		b = b[n+skip+size:]
 	}
 	return ps
 }
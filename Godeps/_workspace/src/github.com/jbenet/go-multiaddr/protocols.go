@@ -29,6 +29,10 @@ const (
 	P_UDT  = 302
 )
 
+// LengthPrefixedVarSize is the Size of protocols whose address is prefixed
+// with its length, as a varint.
+const LengthPrefixedVarSize = -1
+
 // Protocols is the list of multiaddr protocols supported by this module.
 var Protocols = []Protocol{
 	Protocol{P_IP4, 32, "ip4", CodeToVarint(P_IP4)},
@@ -44,6 +48,38 @@ var Protocols = []Protocol{
 	// {443, 0, "https"},
 }
 
+// Transcoder converts the addresses of a protocol added with AddProtocol
+// between their string and byte forms.
+type Transcoder interface {
+	StringToBytes(string) ([]byte, error)
+	BytesToString([]byte) (string, error)
+}
+
+// transcoders of the protocols added with AddProtocol, by code.
+var transcoders = map[int]Transcoder{}
+
+// AddProtocol adds p to the Protocols. Protocols with addresses (a Size
+// other than 0) need the Transcoder t of their addresses.
+func AddProtocol(p Protocol, t Transcoder) error {
+	for _, o := range Protocols {
+		if o.Code == p.Code || o.Name == p.Name {
+			return fmt.Errorf("protocol %s (%d) already exists", o.Name, o.Code)
+		}
+	}
+	if p.Size != 0 && t == nil {
+		return fmt.Errorf("protocol %s has addresses, but no transcoder", p.Name)
+	}
+	if p.VCode == nil {
+		p.VCode = CodeToVarint(p.Code)
+	}
+
+	Protocols = append(Protocols, p)
+	if t != nil {
+		transcoders[p.Code] = t
+	}
+	return nil
+}
+
 // ProtocolWithName returns the Protocol description with given string name.
 func ProtocolWithName(s string) Protocol {
 	for _, p := range Protocols {
@@ -105,3 +141,21 @@ func ReadVarintCode(buf []byte) (int, int) {
 	}
 	return int(num), n
 }
+
+// sizeForAddr returns the number of bytes of the length prefix, and of the
+// address itself, for the address of p at the beginning of b.
+func sizeForAddr(p Protocol, b []byte) (skip, size int, err error) {
+	if p.Size == LengthPrefixedVarSize {
+		l, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, 0, fmt.Errorf("invalid length prefix for %s address", p.Name)
+		}
+		skip, size = n, int(l)
+	} else {
+		size = p.Size / 8
+	}
+	if size < 0 || len(b) < skip+size {
+		return 0, 0, fmt.Errorf("%s address too short", p.Name)
+	}
+	return skip, size, nil
+}
@@ -0,0 +1,152 @@
+// Package client is a Go client of the HTTP API of an ipfs daemon. Its
+// methods call the daemon's commands, and return their output as structs,
+// without having to know the command tree.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+	files "github.com/jbenet/go-ipfs/commands/files"
+	cmdsHttp "github.com/jbenet/go-ipfs/commands/http"
+)
+
+// ndjsonType is the Content-Type of ndjson output.
+const ndjsonType = "application/x-ndjson"
+
+// Client calls the commands of a daemon.
+type Client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+// New returns a Client of the daemon API at addr, either a multiaddr like
+// "/ip4/127.0.0.1/tcp/5001" or a "host:port". It authenticates with the
+// token in IPFS_API_TOKEN, if set.
+func New(addr string) (*Client, error) {
+	if strings.HasPrefix(addr, "/") {
+		m, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		if _, addr, err = manet.DialArgs(m); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Client{
+		url:   "http://" + addr + cmdsHttp.ApiPath,
+		token: os.Getenv(cmdsHttp.TokenEnvVar),
+		http:  http.DefaultClient,
+	}, nil
+}
+
+// SetToken sets the API token to authenticate with.
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// Error is an error of a command.
+type Error struct {
+	Command string // the command path, like "pin/add"
+	Status  int    // the HTTP status of the response
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Command, e.Message)
+}
+
+// request is a call of a command.
+type request struct {
+	command string
+	args    []string
+	opts    url.Values
+	file    io.Reader // sent as the command's file argument, if not nil
+}
+
+// send calls the command, and returns the response if the command did not
+// fail.
+func (c *Client) send(req *request) (*http.Response, error) {
+	query := url.Values{}
+	for k, v := range req.opts {
+		query[k] = v
+	}
+	query["arg"] = req.args
+	query.Set(cmds.EncShort, cmds.JSON)
+	query.Set(cmds.ChanOpt, "true")
+
+	var body io.Reader = strings.NewReader("")
+	contentType := "application/octet-stream"
+	if req.file != nil {
+		f := files.NewReaderFile("", ioutil.NopCloser(req.file), nil)
+		mfr := cmdsHttp.NewMultiFileReader(files.NewSliceFile("", []files.File{f}), true)
+		body = mfr
+		contentType = "multipart/form-data; boundary=" + mfr.Boundary()
+	}
+
+	hreq, err := http.NewRequest("POST", c.url+"/"+req.command+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", contentType)
+	// daemons which know ndjson end the output of commands failing midway
+	// with an error trailer. the others send the json values alone.
+	hreq.Header.Set("Accept", ndjsonType)
+	if c.token != "" {
+		hreq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.http.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+		return nil, responseError(req.command, res)
+	}
+	return res, nil
+}
+
+// call calls the command, and decodes its output into out.
+func (c *Client) call(req *request, out interface{}) error {
+	res, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: bad output: %s", req.command, err)
+	}
+	return nil
+}
+
+// responseError returns the error of the command in res.
+func responseError(command string, res *http.Response) error {
+	e := &Error{Command: command, Status: res.StatusCode}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var ce cmds.Error
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") && json.Unmarshal(body, &ce) == nil {
+		e.Message = ce.Message
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
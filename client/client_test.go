@@ -0,0 +1,211 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	datastore "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	syncds "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+
+	blockstore "github.com/jbenet/go-ipfs/blocks/blockstore"
+	cmds "github.com/jbenet/go-ipfs/commands"
+	core "github.com/jbenet/go-ipfs/core"
+	corehttp "github.com/jbenet/go-ipfs/core/corehttp"
+	offline "github.com/jbenet/go-ipfs/exchange/offline"
+	namesys "github.com/jbenet/go-ipfs/namesys"
+	mocknet "github.com/jbenet/go-ipfs/p2p/net/mock"
+	repo "github.com/jbenet/go-ipfs/repo"
+	config "github.com/jbenet/go-ipfs/repo/config"
+	mockrouting "github.com/jbenet/go-ipfs/routing/mock"
+	ds2 "github.com/jbenet/go-ipfs/util/datastore2"
+	testutil "github.com/jbenet/go-ipfs/util/testutil"
+)
+
+// testDaemon serves the API of a node, connected to another peer, and
+// returns a Client of it.
+func testDaemon(t *testing.T, ctx context.Context) *Client {
+	ident, err := testutil.RandIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mn := mocknet.New(ctx)
+	h, err := mn.AddPeer(ident.PrivateKey(), ident.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mn.LinkPeers(h.ID(), other.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mn.ConnectPeers(h.ID(), other.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := core.NewIPFSNode(ctx, func(ctx context.Context) (*core.IpfsNode, error) {
+		r := &repo.Mock{D: ds2.CloserWrap(syncds.MutexWrap(datastore.NewMapDatastore()))}
+		bs := blockstore.NewBlockstore(r.Datastore())
+		rt := mockrouting.NewServer().Client(ident)
+		return &core.IpfsNode{
+			Repo:       r,
+			Peerstore:  h.Peerstore(),
+			PeerHost:   h,
+			Identity:   h.ID(),
+			PrivateKey: h.Peerstore().PrivKey(h.ID()),
+			Blockstore: bs,
+			Exchange:   offline.Exchange(bs),
+			Routing:    rt,
+			Namesys:    namesys.NewNameSystem(rt),
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cctx := cmds.Context{
+		Context: ctx,
+		Online:  true,
+		LoadConfig: func(string) (*config.Config, error) {
+			return node.Repo.Config(), nil
+		},
+		ConstructNode: func() (*core.IpfsNode, error) {
+			return node, nil
+		},
+	}
+	mux := http.NewServeMux()
+	if err := corehttp.CommandsOption(cctx)(node, mux); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(mux)
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	c, err := New(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := testDaemon(t, ctx)
+
+	data := []byte("hello, typed world")
+	hash, err := c.Add(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := c.Cat(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("cat returned %q, expected %q", got, data)
+	}
+
+	// a directory linking to the file.
+	empty, err := c.Add(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := c.ObjectPatch(empty, "add-link", "hello.txt", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dir.Links) != 1 || dir.Links[0].Name != "hello.txt" || dir.Links[0].Hash != hash {
+		t.Fatalf("patched object has links %v", dir.Links)
+	}
+	obj, err := c.ObjectGet(dir.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obj.Links) != 1 || obj.Links[0].Hash != hash || len(obj.Data) == 0 {
+		t.Fatalf("got object with links %v and data %q", obj.Links, obj.Data)
+	}
+	obj, err = c.ObjectLinks(dir.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Hash != dir.Hash || len(obj.Links) != 1 || obj.Data != nil {
+		t.Fatalf("got links of %s: %v, and data %q", obj.Hash, obj.Links, obj.Data)
+	}
+	if _, err := c.ObjectPatch(dir.Hash, "no-such-change"); err == nil {
+		t.Fatal("patched the object with an unknown change")
+	}
+
+	// patched objects are pinned recursively.
+	if err := c.Unpin(dir.Hash, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Pin(dir.Hash, false); err != nil {
+		t.Fatal(err)
+	}
+	pins, err := c.Pins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(pins, dir.Hash) {
+		t.Fatalf("%s not in pins %v", dir.Hash, pins)
+	}
+
+	entry, err := c.NamePublish(dir.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := c.NameResolve(entry.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != dir.Hash {
+		t.Fatalf("%s resolved to %s, expected %s", entry.Name, resolved, dir.Hash)
+	}
+
+	peers, err := c.SwarmPeers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers[0].Addr == nil {
+		t.Fatalf("expected one peer, got %v", peers)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := testDaemon(t, ctx)
+
+	_, err := c.Cat("not-a-hash")
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	if e.Command != "cat" || e.Status != http.StatusInternalServerError || e.Message == "" {
+		t.Fatalf("unexpected error %#v", e)
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+)
+
+// Object is a DAG object: its links, and the data of ObjectGet.
+type Object struct {
+	Hash  string
+	Links []Link
+	Data  []byte `json:",omitempty"`
+}
+
+// Link is a link of an Object.
+type Link struct {
+	Name string
+	Hash string
+	Size uint64
+}
+
+// NameEntry is a published IPNS name, and what it points to.
+type NameEntry struct {
+	Name  string
+	Value string
+}
+
+// SwarmPeer is a peer the daemon is connected to.
+type SwarmPeer struct {
+	ID   peer.ID
+	Addr ma.Multiaddr
+}
+
+// PeerInfo identifies a peer.
+type PeerInfo struct {
+	ID              string
+	PublicKey       string
+	Addresses       []string
+	AgentVersion    string
+	ProtocolVersion string
+	Protocols       []string
+}
+
+// Version returns the version of the daemon.
+func (c *Client) Version() (string, error) {
+	var out struct{ Version string }
+	if err := c.call(&request{command: "version"}, &out); err != nil {
+		return "", err
+	}
+	return out.Version, nil
+}
+
+// ID returns the identity of the daemon's node.
+func (c *Client) ID() (*PeerInfo, error) {
+	var out PeerInfo
+	if err := c.call(&request{command: "id"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Add adds the content of r as a file, and returns its hash. The file is
+// pinned.
+func (c *Client) Add(r io.Reader) (string, error) {
+	res, err := c.send(&request{command: "add", file: r})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	// one object per file added, the last of which is the file itself.
+	ndjson := strings.Split(res.Header.Get("Content-Type"), ";")[0] == ndjsonType
+	var hash string
+	dec := json.NewDecoder(res.Body)
+	for {
+		var out struct {
+			Hash string
+			cmds.ErrorTrailer
+		}
+		if err := dec.Decode(&out); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if ndjson && out.IsTrailer() {
+			return "", &Error{Command: "add", Status: res.StatusCode, Message: out.Message}
+		}
+		if out.Hash != "" {
+			hash = out.Hash
+		}
+	}
+	if hash == "" {
+		return "", errors.New("add: no hash in the output")
+	}
+	return hash, nil
+}
+
+// Cat returns a reader of the file at path, to close once read.
+func (c *Client) Cat(path string) (io.ReadCloser, error) {
+	res, err := c.send(&request{command: "cat", args: []string{path}})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// Pin pins the object at path, and the objects it links to if recursive.
+func (c *Client) Pin(path string, recursive bool) error {
+	return c.pin("pin/add", path, recursive)
+}
+
+// Unpin unpins the object at path, pinned with recursive.
+func (c *Client) Unpin(path string, recursive bool) error {
+	return c.pin("pin/rm", path, recursive)
+}
+
+func (c *Client) pin(command, path string, recursive bool) error {
+	opts := url.Values{"recursive": {strconv.FormatBool(recursive)}}
+	var out struct{ Pinned []string }
+	return c.call(&request{command: command, args: []string{path}, opts: opts}, &out)
+}
+
+// Pins returns the keys pinned directly.
+func (c *Client) Pins() ([]string, error) {
+	var out struct{ Keys []string }
+	if err := c.call(&request{command: "pin/ls"}, &out); err != nil {
+		return nil, err
+	}
+	return out.Keys, nil
+}
+
+// NamePublish publishes path under the daemon's IPNS name.
+func (c *Client) NamePublish(path string) (*NameEntry, error) {
+	var out NameEntry
+	if err := c.call(&request{command: "name/publish", args: []string{path}}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// NameResolve returns what the IPNS name points to.
+func (c *Client) NameResolve(name string) (string, error) {
+	var out string
+	if err := c.call(&request{command: "name/resolve", args: []string{name}}, &out); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// ObjectGet returns the links and data of the object at path. The daemon
+// does not return its Hash, which ObjectLinks does.
+func (c *Client) ObjectGet(path string) (*Object, error) {
+	var out Object
+	if err := c.call(&request{command: "object/get", args: []string{path}}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ObjectLinks returns the hash and links of the object at path, without
+// its data.
+func (c *Client) ObjectLinks(path string) (*Object, error) {
+	var out Object
+	if err := c.call(&request{command: "object/links", args: []string{path}}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ObjectPatch stores a changed copy of the object at root, and returns it.
+// command is "add-link", with the name and path of the link to add, or
+// "rm-link", with the name of the link to remove.
+func (c *Client) ObjectPatch(root, command string, args ...string) (*Object, error) {
+	req := &request{command: "object/patch", args: append([]string{root, command}, args...)}
+	var out Object
+	if err := c.call(req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SwarmPeers returns the peers the daemon is connected to.
+func (c *Client) SwarmPeers() ([]SwarmPeer, error) {
+	var out struct {
+		Peers []struct{ Addr, Peer string }
+	}
+	if err := c.call(&request{command: "swarm/peers"}, &out); err != nil {
+		return nil, err
+	}
+
+	peers := make([]SwarmPeer, len(out.Peers))
+	for i, p := range out.Peers {
+		addr, err := ma.NewMultiaddr(p.Addr)
+		if err != nil {
+			return nil, err
+		}
+		id, err := peer.IDB58Decode(p.Peer)
+		if err != nil {
+			return nil, err
+		}
+		peers[i] = SwarmPeer{ID: id, Addr: addr}
+	}
+	return peers, nil
+}
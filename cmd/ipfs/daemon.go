@@ -165,6 +165,8 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		corehttp.CommandsOption(*req.Context()),
 		corehttp.WebUIOption,
 		corehttp.GatewayOption,
+		corehttp.MetricsOption,
+		corehttp.ProfileOption,
 	}
 	if err := corehttp.ListenAndServe(node, apiMaddr.String(), opts...); err != nil {
 		res.SetError(err, cmds.ErrNormal)
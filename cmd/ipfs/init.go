@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"strings"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	cmds "github.com/jbenet/go-ipfs/commands"
@@ -31,6 +32,7 @@ var initCmd = &cmds.Command{
 		cmds.IntOption("bits", "b", "Number of bits to use in the generated RSA private key (defaults to 4096)"),
 		cmds.StringOption("passphrase", "p", "Passphrase for encrypting the private key"),
 		cmds.BoolOption("force", "f", "Overwrite existing config (if it exists)"),
+		cmds.StringOption("profile", "Apply config profiles, separated by commas (see 'ipfs config profile ls')"),
 
 		// TODO need to decide whether to expose the override as a file or a
 		// directory. That is: should we allow the user to also specify the
@@ -54,7 +56,12 @@ var initCmd = &cmds.Command{
 			nBitsForKeypair = nBitsForKeypairDefault
 		}
 
-		output, err := doInit(req.Context().ConfigRoot, force, nBitsForKeypair)
+		var profiles []string
+		if p, found, _ := req.Option("profile").String(); found && p != "" {
+			profiles = strings.Split(p, ",")
+		}
+
+		output, err := doInit(req.Context().ConfigRoot, force, nBitsForKeypair, profiles...)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -88,7 +95,7 @@ func initWithDefaults(repoRoot string) error {
 	return debugerror.Wrap(err)
 }
 
-func doInit(repoRoot string, force bool, nBitsForKeypair int) (interface{}, error) {
+func doInit(repoRoot string, force bool, nBitsForKeypair int, profiles ...string) (interface{}, error) {
 
 	u.POut("initializing ipfs node at %s\n", repoRoot)
 
@@ -100,6 +107,9 @@ func doInit(repoRoot string, force bool, nBitsForKeypair int) (interface{}, erro
 	if err != nil {
 		return nil, err
 	}
+	if err := config.ApplyProfiles(conf, profiles...); err != nil {
+		return nil, err
+	}
 	if fsrepo.IsInitialized(repoRoot) {
 		if err := fsrepo.Remove(repoRoot); err != nil {
 			return nil, err
@@ -208,9 +218,39 @@ func initConfig(nBitsForKeypair int) (*config.Config, error) {
 			API: "/ip4/127.0.0.1/tcp/5001",
 		},
 
+		// run a full dht node by default.
+		Routing: config.Routing{
+			Type: config.RoutingTypeDHT,
+		},
+
 		Bootstrap: bootstrapPeers,
 		Datastore: *ds,
-		Identity:  identity,
+		Discovery: config.Discovery{
+			MDNS: config.MDNS{
+				Enabled:  true,
+				Interval: 10,
+			},
+		},
+		Identity: identity,
+		Swarm: config.SwarmConfig{
+			ConnMgr: config.ConnMgr{
+				LowWater:    600,
+				HighWater:   900,
+				GracePeriod: 20,
+			},
+			PrivateNetwork: config.PrivateNetwork{
+				DisablePublicBootstrap: true,
+			},
+		},
+
+		// pages of any origin may read the gateway's content.
+		Gateway: config.Gateway{
+			HTTPHeaders: map[string][]string{
+				"Access-Control-Allow-Origin":  {"*"},
+				"Access-Control-Allow-Methods": {"GET"},
+				"Access-Control-Allow-Headers": {"X-Requested-With", "Range"},
+			},
+		},
 
 		// setup the node mount points.
 		Mounts: config.Mounts{
@@ -102,7 +102,7 @@ var cmdDetailsMap = map[*cmds.Command]cmdDetails{
 	// daemonCmd allows user to initialize the config. Thus, it may be called
 	// without using the config as input
 	daemonCmd:                  cmdDetails{doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
-	commandsClientCmd:          cmdDetails{doesNotUseRepo: true},
+	commandsClientCmd:          cmdDetails{doesNotUseConfigAsInput: true, doesNotUseRepo: true},
 	commands.CommandsDaemonCmd: cmdDetails{doesNotUseRepo: true},
 	commands.DiagCmd:           cmdDetails{cannotRunOnClient: true},
 	commands.VersionCmd:        cmdDetails{doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
@@ -110,4 +110,5 @@ var cmdDetailsMap = map[*cmds.Command]cmdDetails{
 	commands.UpdateCheckCmd:    cmdDetails{preemptsAutoUpdate: true},
 	commands.UpdateLogCmd:      cmdDetails{preemptsAutoUpdate: true},
 	commands.LogCmd:            cmdDetails{cannotRunOnClient: true},
+	commands.ConfigEditCmd:     cmdDetails{cannotRunOnDaemon: true}, // runs the editor of the user
 }
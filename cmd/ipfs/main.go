@@ -45,6 +45,9 @@ type cmdInvocation struct {
 	cmd  *cmds.Command
 	req  cmds.Request
 	node *core.IpfsNode
+
+	// cancel ends the context of the command.
+	cancel func()
 }
 
 // main roadmap:
@@ -130,10 +133,27 @@ func main() {
 	}
 
 	// everything went better than expected :)
-	io.Copy(os.Stdout, output)
+	if _, err := io.Copy(os.Stdout, output); err != nil {
+		// the command failed after it started to output.
+		printErr(err)
+		os.Exit(1)
+	}
 }
 
 func (i *cmdInvocation) Run(ctx context.Context) (output io.Reader, err error) {
+	// the command runs until it is done, interrupted, or timed out.
+	ctx, cancel := context.WithCancel(ctx)
+	i.cancel = cancel
+	i.req.Context().Context = ctx
+	cancelTimeout, err := cmds.SetTimeout(i.req)
+	if err != nil {
+		return nil, err
+	}
+	i.cancel = func() {
+		cancelTimeout()
+		cancel()
+	}
+
 	// setup our global interrupt handler.
 	i.setupInterruptHandler()
 
@@ -166,7 +186,28 @@ func (i *cmdInvocation) Run(ctx context.Context) (output io.Reader, err error) {
 		return nil, err
 	}
 
-	return res.Reader()
+	out, err := res.Reader()
+	if err != nil {
+		return nil, err
+	}
+	return &errorAtEOFReader{out, res}, nil
+}
+
+// errorAtEOFReader reads the output of a command, then returns the error
+// the command set once it started to output, if any.
+type errorAtEOFReader struct {
+	io.Reader
+	res cmds.Response
+}
+
+func (r *errorAtEOFReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		if e := r.res.Error(); e != nil {
+			return n, e
+		}
+	}
+	return n, err
 }
 
 func (i *cmdInvocation) constructNodeFunc(ctx context.Context) func() (*core.IpfsNode, error) {
@@ -197,6 +238,10 @@ func (i *cmdInvocation) constructNodeFunc(ctx context.Context) func() (*core.Ipf
 }
 
 func (i *cmdInvocation) close() {
+	if i.cancel != nil {
+		i.cancel()
+	}
+
 	// let's not forget teardown. If a node was initialized, we must close it.
 	// Note that this means the underlying req.Context().Node variable is exposed.
 	// this is gross, and should be changed when we extract out the exec Context.
@@ -505,6 +550,7 @@ func (i *cmdInvocation) setupInterruptHandler() {
 			switch count {
 			case 0:
 				log.Critical("Received interrupt signal, shutting down...")
+				i.cancel()
 				go func() {
 					n.Close()
 					log.Info("Gracefully shut down.")
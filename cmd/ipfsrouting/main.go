@@ -0,0 +1,77 @@
+// ipfsrouting serves the routing system of an ipfs node as a delegated
+// routing service, for the nodes which cannot run a full dht themselves,
+// like those of browsers and embedded devices. They are configured with
+// the "delegated" routing type, and the address it listens on.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	homedir "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/mitchellh/go-homedir"
+	core "github.com/jbenet/go-ipfs/core"
+	corehttp "github.com/jbenet/go-ipfs/core/corehttp"
+	fsrepo "github.com/jbenet/go-ipfs/repo/fsrepo"
+)
+
+var repoPath = flag.String("repo", os.Getenv("IPFS_PATH"), "IPFS_PATH to use")
+var addr = flag.String("addr", "/ip4/127.0.0.1/tcp/5002", "the address to serve delegated routing at")
+
+func main() {
+	flag.Parse()
+
+	// precedence
+	// 1. --repo flag
+	// 2. IPFS_PATH environment variable
+	// 3. default repo path
+	var ipfsPath string
+	if *repoPath != "" {
+		ipfsPath = *repoPath
+	} else {
+		var err error
+		ipfsPath, err = fsrepo.BestKnownPath()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := run(ipfsPath, *addr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ipfsPath, addr string) error {
+	ipfsPath, err := homedir.Expand(ipfsPath)
+	if err != nil {
+		return err
+	}
+
+	// the node is our own, so the daemon cannot run on the same repo.
+	r := fsrepo.At(ipfsPath)
+	if err := r.Open(); err != nil {
+		return err
+	}
+	node, err := core.NewIPFSNode(context.Background(), core.Online(r))
+	if err != nil {
+		return err
+	}
+	defer node.Close()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- corehttp.ListenAndServe(node, addr, corehttp.RoutingOption(addr))
+	}()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
+	select {
+	case <-interrupts:
+		return nil
+	case err := <-served:
+		return err
+	}
+}
@@ -6,21 +6,44 @@ type ChannelMarshaler struct {
 	Channel   <-chan interface{}
 	Marshaler func(interface{}) (io.Reader, error)
 
+	// Res is the response of the Channel, if set. Once the Channel is
+	// closed, the error of Res, if any, is marshaled by Trailer, or
+	// returned by Read without one.
+	Res     Response
+	Trailer func(*Error) (io.Reader, error)
+
 	reader io.Reader
+	closed bool
 }
 
 func (cr *ChannelMarshaler) Read(p []byte) (int, error) {
 	if cr.reader == nil {
-		val, more := <-cr.Channel
-		if !more {
+		if cr.closed {
 			return 0, io.EOF
 		}
 
-		r, err := cr.Marshaler(val)
-		if err != nil {
-			return 0, err
+		val, more := <-cr.Channel
+		if !more {
+			cr.closed = true
+			if cr.Res == nil || cr.Res.Error() == nil {
+				return 0, io.EOF
+			}
+			if cr.Trailer == nil {
+				return 0, cr.Res.Error()
+			}
+
+			r, err := cr.Trailer(cr.Res.Error())
+			if err != nil {
+				return 0, err
+			}
+			cr.reader = r
+		} else {
+			r, err := cr.Marshaler(val)
+			if err != nil {
+				return 0, err
+			}
+			cr.reader = r
 		}
-		cr.reader = r
 	}
 
 	n, err := cr.reader.Read(p)
@@ -58,8 +58,26 @@ type Command struct {
 	// ie. If command Run returns &Block{}, then Command.Type == &Block{}
 	Type        interface{}
 	Subcommands map[string]*Command
+
+	// Scope is the access an API token needs to call the command over
+	// HTTP, one of the Scope constants. Commands without one have the scope
+	// of their parent; the root's default is ScopeAdmin.
+	Scope string
+
+	// Unsafe commands expose the node's identity, or control the host it
+	// runs on. The HTTP API refuses them to remote clients. Subcommands of
+	// an unsafe command are unsafe too.
+	Unsafe bool
 }
 
+// Scopes of access to commands, which API tokens are granted.
+const (
+	ScopeRead  = "read"  // commands that only read, like cat and ls
+	ScopePin   = "pin"   // commands that store or pin content, like add
+	ScopeName  = "name"  // commands that publish names
+	ScopeAdmin = "admin" // all commands
+)
+
 // ErrNotCallable signals a command that cannot be called.
 var ErrNotCallable = ClientError("This command can't be called directly. Try one of its subcommands.")
 
@@ -154,6 +172,23 @@ func (c *Command) Resolve(path []string) ([]*Command, error) {
 	return cmds, nil
 }
 
+// Access returns the scope of the command at path, and whether it is unsafe.
+func (c *Command) Access(path []string) (scope string, unsafe bool, err error) {
+	cmds, err := c.Resolve(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	scope = ScopeAdmin
+	for _, cmd := range cmds {
+		if cmd.Scope != "" {
+			scope = cmd.Scope
+		}
+		unsafe = unsafe || cmd.Unsafe
+	}
+	return scope, unsafe, nil
+}
+
 // Get resolves and returns the Command addressed by path
 func (c *Command) Get(path []string) (*Command, error) {
 	cmds, err := c.Resolve(path)
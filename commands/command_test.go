@@ -155,3 +155,43 @@ func TestResolving(t *testing.T) {
 		t.Error("Returned command path is different than expected", cmds)
 	}
 }
+
+func TestAccess(t *testing.T) {
+	cmdC := &Command{Scope: ScopePin}
+	cmdB := &Command{
+		Unsafe: true,
+		Subcommands: map[string]*Command{
+			"c": cmdC,
+		},
+	}
+	cmdA := &Command{
+		Scope: ScopeRead,
+		Subcommands: map[string]*Command{
+			"b": cmdB,
+		},
+	}
+	cmd := &Command{
+		Subcommands: map[string]*Command{
+			"a": cmdA,
+		},
+	}
+
+	for _, c := range []struct {
+		path   []string
+		scope  string
+		unsafe bool
+	}{
+		{[]string{}, ScopeAdmin, false},
+		{[]string{"a"}, ScopeRead, false},
+		{[]string{"a", "b"}, ScopeRead, true},
+		{[]string{"a", "b", "c"}, ScopePin, true},
+	} {
+		scope, unsafe, err := cmd.Access(c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scope != c.scope || unsafe != c.unsafe {
+			t.Errorf("%v: got %s, unsafe %v, expected %s, unsafe %v", c.path, scope, unsafe, c.scope, c.unsafe)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+)
+
+// TokenEnvVar is the environment variable clients take their API token
+// from, if any.
+const TokenEnvVar = "IPFS_API_TOKEN"
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// Token lets the clients bearing its Secret call the commands of its
+// Scopes. ScopeAdmin grants all scopes.
+type Token struct {
+	Name   string // to tell tokens apart in logs
+	Secret string
+	Scopes []string
+}
+
+func (t *Token) allows(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == cmds.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerConfig configures the Handler.
+type ServerConfig struct {
+	// Headers are set on the responses. Access-Control-Allow-Origin lists
+	// the origins browsers may make requests from, besides the API's own.
+	Headers map[string][]string
+
+	// Tokens authenticate clients. Local clients need none; once there
+	// are Tokens, remote clients do. Unsafe commands are refused to remote
+	// clients all the same.
+	Tokens []Token
+
+	// Hosts are the hosts, like "192.168.1.2:5001", the API answers to
+	// besides localhost, 127.0.0.1 and ::1. Requests to others are
+	// refused.
+	Hosts []string
+}
+
+var (
+	ErrUnauthorized = errors.New("401 unauthorized: a valid API token is required")
+	ErrForbidden    = errors.New("403 forbidden: the API token does not grant this command")
+	ErrUnsafe       = errors.New("403 forbidden: unsafe commands are only for local clients")
+)
+
+// authorize checks the client of r may call the command at path. It
+// returns the HTTP status to refuse the request with, and why.
+func (i Handler) authorize(r *http.Request, path []string) (int, error) {
+	scope, unsafe, err := i.root.Access(path)
+	if err != nil {
+		return http.StatusNotFound, ErrNotFound
+	}
+
+	if unsafe && !IsLocal(r.RemoteAddr) {
+		return http.StatusForbidden, ErrUnsafe
+	}
+	return i.cfg.authorize(r, scope)
+}
+
+// authorize checks the client of r has access to scope. It returns the
+// HTTP status to refuse the request with, and why.
+func (c *ServerConfig) authorize(r *http.Request, scope string) (int, error) {
+	secret := r.Header.Get(authorizationHeader)
+	if secret == "" {
+		if !IsLocal(r.RemoteAddr) && len(c.Tokens) > 0 {
+			return http.StatusUnauthorized, ErrUnauthorized
+		}
+		return http.StatusOK, nil
+	}
+
+	if !strings.HasPrefix(secret, bearerPrefix) {
+		return http.StatusUnauthorized, ErrUnauthorized
+	}
+	tok := c.token(strings.TrimPrefix(secret, bearerPrefix))
+	if tok == nil {
+		return http.StatusUnauthorized, ErrUnauthorized
+	}
+	if !tok.allows(scope) {
+		log.Infof("API token %q refused %s request %s", tok.Name, scope, r.URL.Path)
+		return http.StatusForbidden, ErrForbidden
+	}
+	return http.StatusOK, nil
+}
+
+// token returns the Token with secret, or nil.
+func (c *ServerConfig) token(secret string) *Token {
+	var found *Token
+	for j := range c.Tokens {
+		t := &c.Tokens[j]
+		// compare them all in constant time, not to leak the secrets.
+		if subtle.ConstantTimeCompare([]byte(t.Secret), []byte(secret)) == 1 && t.Secret != "" {
+			found = t
+		}
+	}
+	return found
+}
+
+// Guard serves h to the clients cfg grants the scope of their request to,
+// returned by scope, with the checks the Handler makes of the clients of
+// commands: of their token, and of the host they reach the server at.
+func Guard(cfg *ServerConfig, scope func(*http.Request) string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !HostAllowed(r, cfg.Hosts) {
+			log.Warningf("refused %s request to host %s", r.URL.Path, r.Host)
+			refuse(w, http.StatusForbidden, ErrHost)
+			return
+		}
+		if status, err := cfg.authorize(r, scope(r)); err != nil {
+			refuse(w, status, err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// refuse answers a request with status, and err as the reason.
+func refuse(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	w.Header().Set(contentTypeHeader, "text/plain")
+	w.WriteHeader(status)
+	w.Write([]byte(err.Error()))
+}
+
+// IsLocal tells whether the client at addr is on this host.
+func IsLocal(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
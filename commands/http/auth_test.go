@@ -0,0 +1,116 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+)
+
+func TestAuthorization(t *testing.T) {
+	run := func(req cmds.Request, res cmds.Response) {
+		res.SetOutput("ok")
+	}
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"cat":    {Scope: cmds.ScopeRead, Run: run},
+			"pin":    {Scope: cmds.ScopePin, Run: run},
+			"config": {Unsafe: true, Run: run},
+			"swarm":  {Run: run},
+		},
+	}
+	h := NewHandler(cmds.Context{}, root, &ServerConfig{
+		Tokens: []Token{
+			{Name: "reader", Secret: "r", Scopes: []string{cmds.ScopeRead}},
+			{Name: "admin", Secret: "a", Scopes: []string{cmds.ScopeAdmin}},
+		},
+	})
+
+	const local, remote = "127.0.0.1:1234", "10.0.0.1:1234"
+	for _, c := range []struct {
+		addr, cmd, token string
+		status           int
+		host             string // localhost:5001 if empty
+	}{
+		// local clients need no token.
+		{local, "cat", "", http.StatusOK, ""},
+		{local, "config", "", http.StatusOK, ""},
+		// remote ones do, once there are tokens.
+		{remote, "cat", "", http.StatusUnauthorized, ""},
+		{remote, "cat", "wrong", http.StatusUnauthorized, ""},
+		{remote, "cat", "r", http.StatusOK, ""},
+		{remote, "pin", "r", http.StatusForbidden, ""},
+		{remote, "pin", "a", http.StatusOK, ""},
+		{remote, "swarm", "r", http.StatusForbidden, ""},
+		{remote, "swarm", "a", http.StatusOK, ""},
+		// unsafe commands are only for local clients.
+		{remote, "config", "a", http.StatusForbidden, ""},
+		// a bad token is refused, even locally.
+		{local, "cat", "wrong", http.StatusUnauthorized, ""},
+		// the host is checked, whatever the token.
+		{remote, "cat", "r", http.StatusForbidden, "172.17.0.2:5001"},
+		{remote, "cat", "", http.StatusForbidden, "172.17.0.2:5001"},
+		{remote, "cat", "wrong", http.StatusForbidden, "172.17.0.2:5001"},
+	} {
+		r, err := http.NewRequest("POST", "http://localhost:5001"+ApiPath+"/"+c.cmd, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = c.addr
+		if c.host != "" {
+			r.Host = c.host
+		}
+		if c.token != "" {
+			r.Header.Set(authorizationHeader, bearerPrefix+c.token)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s from %s with token %q: got status %d, expected %d", c.cmd, c.addr, c.token, w.Code, c.status)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	cfg := &ServerConfig{
+		Tokens: []Token{{Name: "reader", Secret: "r", Scopes: []string{cmds.ScopeRead}}},
+		Hosts:  []string{"node.example"},
+	}
+	h := Guard(cfg, func(r *http.Request) string {
+		if r.Method == "GET" {
+			return cmds.ScopeRead
+		}
+		return cmds.ScopePin
+	}, ok)
+
+	const local, remote = "127.0.0.1:1234", "10.0.0.1:1234"
+	for _, c := range []struct {
+		method, addr, host, token string
+		status                    int
+	}{
+		{"GET", local, "localhost:4002", "", http.StatusOK},
+		{"GET", local, "evil.example:4002", "", http.StatusForbidden},
+		{"GET", remote, "node.example:4002", "", http.StatusUnauthorized},
+		{"GET", remote, "node.example:4002", "r", http.StatusOK},
+		{"GET", remote, "172.17.0.2:4002", "r", http.StatusForbidden},
+		{"POST", remote, "node.example:4002", "r", http.StatusForbidden},
+	} {
+		r, err := http.NewRequest(c.method, "http://"+c.host+"/routing/v0/providers/x", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = c.addr
+		if c.token != "" {
+			r.Header.Set(authorizationHeader, bearerPrefix+c.token)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s from %s to %s with token %q: got status %d, expected %d", c.method, c.addr, c.host, c.token, w.Code, c.status)
+		}
+	}
+}
@@ -3,10 +3,12 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
@@ -27,10 +29,13 @@ type Client interface {
 
 type client struct {
 	serverAddress string
+	token         string
 }
 
+// NewClient returns a Client of the API at address. It authenticates with
+// the token in TokenEnvVar, if set.
 func NewClient(address string) Client {
-	return &client{address}
+	return &client{address, os.Getenv(TokenEnvVar)}
 }
 
 func (c *client) Send(req cmds.Request) (cmds.Response, error) {
@@ -72,6 +77,10 @@ func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 		return nil, err
 	}
 
+	if c.token != "" {
+		httpReq.Header.Set(authorizationHeader, bearerPrefix+c.token)
+	}
+
 	// TODO extract string consts?
 	if fileReader != nil {
 		httpReq.Header.Set("Content-Type", "multipart/form-data; boundary="+fileReader.Boundary())
@@ -79,6 +88,9 @@ func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 	} else {
 		httpReq.Header.Set("Content-Type", "application/octet-stream")
 	}
+	// ask for ndjson, which ends the output of commands failing midway
+	// with an error trailer. daemons which do not know it send json.
+	httpReq.Header.Set(acceptHeader, applicationNDJSON)
 	version := config.CurrentVersionNumber
 	httpReq.Header.Set("User-Agent", fmt.Sprintf("/go-ipfs/%s/", version))
 
@@ -98,6 +110,8 @@ func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 		// NB: if user has provided an encoding but it is the empty string,
 		// still leave it as JSON.
 		req.SetOption(cmds.EncShort, previousUserProvidedEncoding)
+	} else {
+		req.SetOption(cmds.EncShort, cmds.JSON)
 	}
 
 	return res, nil
@@ -156,21 +170,17 @@ func getResponse(httpRes *http.Response, req cmds.Request) (cmds.Response, error
 		return res, nil
 
 	} else if len(httpRes.Header.Get(channelHeader)) > 0 {
-		// if output is coming from a channel, decode each chunk
+		// if output is coming from a channel, decode each chunk. only
+		// ndjson output may end with an error trailer.
+		ndjson := contentType == applicationNDJSON
 		outChan := make(chan interface{})
 		go func() {
 			dec := json.NewDecoder(httpRes.Body)
 			outputType := reflect.TypeOf(req.Command().Type)
 
 			for {
-				var v interface{}
-				var err error
-				if outputType != nil {
-					v = reflect.New(outputType).Interface()
-					err = dec.Decode(v)
-				} else {
-					err = dec.Decode(&v)
-				}
+				var raw json.RawMessage
+				err := dec.Decode(&raw)
 				if err != nil && err != io.EOF {
 					fmt.Println(err.Error())
 					return
@@ -179,6 +189,26 @@ func getResponse(httpRes *http.Response, req cmds.Request) (cmds.Response, error
 					close(outChan)
 					return
 				}
+
+				// the command failed after it started to output
+				var trailer cmds.ErrorTrailer
+				if ndjson && json.Unmarshal(raw, &trailer) == nil && trailer.IsTrailer() {
+					res.SetError(errors.New(trailer.Message), trailer.Code)
+					close(outChan)
+					return
+				}
+
+				var v interface{}
+				if outputType != nil {
+					v = reflect.New(outputType).Interface()
+					err = json.Unmarshal(raw, v)
+				} else {
+					err = json.Unmarshal(raw, &v)
+				}
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
 				outChan <- v
 			}
 		}()
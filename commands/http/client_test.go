@@ -0,0 +1,98 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+)
+
+type testValue struct {
+	N int
+}
+
+func TestClientErrorTrailer(t *testing.T) {
+	res, srv := sendRefs(t, func(h http.Handler) http.Handler { return h })
+	defer srv.Close()
+	got := readValues(t, res)
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("got values %v", got)
+	}
+	if res.Error() == nil || res.Error().Message != "lost the third" {
+		t.Errorf("got error %v after the output", res.Error())
+	}
+}
+
+func TestClientOldDaemon(t *testing.T) {
+	// daemons which do not know ndjson ignore the Accept header, and
+	// stream the json values of the channel.
+	res, srv := sendRefs(t, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Del(acceptHeader)
+			h.ServeHTTP(w, r)
+		})
+	})
+	defer srv.Close()
+	got := readValues(t, res)
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("got values %v", got)
+	}
+}
+
+// sendRefs sends a request of a command outputting two values then
+// failing, to its handler wrapped by wrap. The caller closes the server.
+func sendRefs(t *testing.T, wrap func(http.Handler) http.Handler) (cmds.Response, *httptest.Server) {
+	refs := &cmds.Command{
+		Run: func(req cmds.Request, res cmds.Response) {
+			out := make(chan interface{})
+			res.SetOutput((<-chan interface{})(out))
+			go func() {
+				defer close(out)
+				out <- &testValue{1}
+				out <- &testValue{2}
+				res.SetError(errors.New("lost the third"), cmds.ErrNormal)
+			}()
+		},
+		Type: testValue{},
+	}
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{"refs": refs},
+	}
+
+	opts, err := refs.GetOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := cmds.NewRequest([]string{"refs"}, nil, nil, nil, refs, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(wrap(NewHandler(cmds.Context{}, root, &ServerConfig{})))
+	res, err := NewClient(strings.TrimPrefix(srv.URL, "http://")).Send(req)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	if res.Error() != nil {
+		srv.Close()
+		t.Fatalf("failed before the output: %s", res.Error())
+	}
+	return res, srv
+}
+
+// readValues reads the values of the channel output of res.
+func readValues(t *testing.T, res cmds.Response) []int {
+	out, ok := res.Output().(<-chan interface{})
+	if !ok {
+		t.Fatalf("output is a %T, not a channel", res.Output())
+	}
+	var got []int
+	for v := range out {
+		got = append(got, v.(*testValue).N)
+	}
+	return got
+}
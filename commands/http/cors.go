@@ -0,0 +1,157 @@
+package http
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	originHeader        = "Origin"
+	refererHeader       = "Referer"
+	allowOriginHeader   = "Access-Control-Allow-Origin"
+	allowMethodsHeader  = "Access-Control-Allow-Methods"
+	allowHeadersHeader  = "Access-Control-Allow-Headers"
+	exposeHeadersHeader = "Access-Control-Expose-Headers"
+	requestMethodHeader = "Access-Control-Request-Method"
+)
+
+var (
+	ErrOrigin = errors.New("403 forbidden: requests from this origin are not allowed")
+	ErrHost   = errors.New("403 forbidden: requests to this host are not allowed")
+	ErrMethod = errors.New("405 method not allowed: commands are called with POST")
+)
+
+// localHosts are the hosts the API answers to, whatever its configuration.
+var localHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// defaultHeaders are set on responses, unless configured otherwise.
+var defaultHeaders = map[string][]string{
+	allowMethodsHeader:  {"POST", "GET", "OPTIONS"},
+	allowHeadersHeader:  {contentTypeHeader, authorizationHeader},
+	exposeHeadersHeader: {streamHeader, channelHeader, contentLengthHeader},
+}
+
+// cors sets the configured headers on responses, and checks the origin of
+// requests from browsers. Access-Control-Allow-Origin lists the origins
+// allowed, or "*" for all of them; pages served by the server itself are
+// always allowed.
+type cors struct {
+	headers map[string][]string
+
+	// anyOriginReads serves GET and HEAD requests whatever their origin,
+	// for servers of public content. Only allowed origins may read the
+	// responses from scripts.
+	anyOriginReads bool
+}
+
+func newCORS(headers map[string][]string, anyOriginReads bool) *cors {
+	c := &cors{headers: make(map[string][]string), anyOriginReads: anyOriginReads}
+	for k, v := range defaultHeaders {
+		c.headers[k] = v
+	}
+	for k, v := range headers {
+		c.headers[http.CanonicalHeaderKey(k)] = v
+	}
+	return c
+}
+
+// check sets the headers of the response to r, and tells whether to serve
+// it. It answers preflight requests, and refuses requests from origins not
+// allowed, itself.
+func (c *cors) check(w http.ResponseWriter, r *http.Request) bool {
+	h := w.Header()
+	for k, v := range c.headers {
+		if k != allowOriginHeader {
+			h.Set(k, strings.Join(v, ", "))
+		}
+	}
+
+	origin := requestOrigin(r)
+	if origin != "" {
+		if c.allowed(origin, r) {
+			h.Set(allowOriginHeader, origin)
+			h.Add("Vary", originHeader)
+		} else if !(c.anyOriginReads && (r.Method == "GET" || r.Method == "HEAD")) {
+			log.Warningf("refused %s request from origin %s", r.URL.Path, origin)
+			h.Set(contentTypeHeader, "text/plain")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(ErrOrigin.Error()))
+			return false
+		}
+	}
+
+	if r.Method == "OPTIONS" && r.Header.Get(requestMethodHeader) != "" {
+		w.WriteHeader(http.StatusOK)
+		return false
+	}
+	return true
+}
+
+// allowed tells whether requests from origin may be served.
+func (c *cors) allowed(origin string, r *http.Request) bool {
+	for _, o := range c.headers[allowOriginHeader] {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// HostAllowed tells whether r is to one of the hosts of the API: a local
+// one, or one of hosts, with or without a port. Pages of other origins
+// may resolve their own host names to the API's address (DNS rebinding),
+// so the Host of their requests is not the API's.
+func HostAllowed(r *http.Request, hosts []string) bool {
+	if r.Host == "" {
+		return false
+	}
+	name, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		name = strings.Trim(r.Host, "[]")
+	}
+	for _, h := range localHosts {
+		if strings.EqualFold(name, h) {
+			return true
+		}
+	}
+	for _, h := range hosts {
+		if strings.EqualFold(r.Host, h) || strings.EqualFold(name, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestOrigin returns the origin of the page r comes from, if any.
+// Browsers leave the Origin out of some requests, but not the Referer.
+func requestOrigin(r *http.Request) string {
+	if o := r.Header.Get(originHeader); o != "" {
+		return o
+	}
+	ref := r.Header.Get(refererHeader)
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		// not a page we can tell the origin of: refuse it.
+		return "null"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// CORSHandler serves h with the headers, and refuses browser requests
+// from other origins than the allowed ones, like the Handler of the API.
+// As for public content, GET and HEAD requests are served to any origin.
+func CORSHandler(headers map[string][]string, h http.Handler) http.Handler {
+	c := newCORS(headers, true)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.check(w, r) {
+			h.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+)
+
+func TestOrigins(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"cat": {Run: func(req cmds.Request, res cmds.Response) {
+				res.SetOutput("ok")
+			}},
+		},
+	}
+	h := NewHandler(cmds.Context{}, root, &ServerConfig{
+		Headers: map[string][]string{
+			"access-control-allow-origin": {"http://allowed.example"},
+		},
+		Hosts: []string{"192.168.1.2:5001", "node.example"},
+	})
+
+	for _, c := range []struct {
+		method, origin, referer string
+		status                  int
+		allowOrigin             string
+		host                    string // localhost:5001 if empty
+	}{
+		// not from a browser.
+		{"POST", "", "", http.StatusOK, "", ""},
+		{"POST", "http://allowed.example", "", http.StatusOK, "http://allowed.example", ""},
+		// the API's own pages, like the webui.
+		{"POST", "http://localhost:5001", "", http.StatusOK, "http://localhost:5001", ""},
+		{"POST", "", "", http.StatusOK, "", "127.0.0.1:5001"},
+		{"POST", "", "", http.StatusOK, "", "[::1]:5001"},
+		{"POST", "", "", http.StatusOK, "", "192.168.1.2:5001"},
+		{"POST", "", "", http.StatusOK, "", "node.example:5001"},
+		{"POST", "", "", http.StatusOK, "", "NODE.example"},
+		{"POST", "http://evil.example", "", http.StatusForbidden, "", ""},
+		{"GET", "", "http://evil.example/page", http.StatusForbidden, "", ""},
+		// commands are only called with POST, as pages of any origin may
+		// send GET requests without Origin or Referer, e.g. from
+		// <img referrerpolicy="no-referrer" src="...">.
+		{"GET", "", "", http.StatusMethodNotAllowed, "", ""},
+		{"GET", "", "http://allowed.example/page", http.StatusMethodNotAllowed, "http://allowed.example", ""},
+		// pages of other origins resolving their host to the API's address
+		// (DNS rebinding) are of the host they are requesting.
+		{"POST", "http://evil.example:5001", "", http.StatusForbidden, "", "evil.example:5001"},
+		{"POST", "", "", http.StatusForbidden, "", "evil.example:5001"},
+		{"POST", "", "", http.StatusForbidden, "", "192.168.1.2:8080"},
+		// preflight requests are answered without calling the command.
+		{"OPTIONS", "http://allowed.example", "", http.StatusOK, "http://allowed.example", ""},
+		{"OPTIONS", "http://evil.example", "", http.StatusForbidden, "", ""},
+	} {
+		r, err := http.NewRequest(c.method, "http://localhost:5001"+ApiPath+"/cat", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = "127.0.0.1:1234"
+		if c.host != "" {
+			r.Host = c.host
+		}
+		if c.origin != "" {
+			r.Header.Set(originHeader, c.origin)
+		}
+		if c.referer != "" {
+			r.Header.Set(refererHeader, c.referer)
+		}
+		if c.method == "OPTIONS" {
+			r.Header.Set(requestMethodHeader, "POST")
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s to %q from %q: got status %d, expected %d", c.method, c.host, c.origin+c.referer, w.Code, c.status)
+		}
+		if got := w.Header().Get(allowOriginHeader); got != c.allowOrigin {
+			t.Errorf("%s from %q: allowed origin %q, expected %q", c.method, c.origin+c.referer, got, c.allowOrigin)
+		}
+		if c.method == "OPTIONS" && w.Body.Len() > 0 && c.status == http.StatusOK {
+			t.Errorf("preflight request called the command")
+		}
+	}
+}
+
+func TestCORSHandlerReads(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := CORSHandler(nil, ok)
+
+	// pages of any origin may load public content, but not post to it.
+	for method, status := range map[string]int{"GET": http.StatusOK, "POST": http.StatusForbidden} {
+		r, err := http.NewRequest(method, "http://localhost:8080/ipfs/x", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set(originHeader, "http://other.example")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Errorf("%s: got status %d, expected %d", method, w.Code, status)
+		}
+		if w.Header().Get(allowOriginHeader) != "" {
+			t.Errorf("%s: origin allowed", method)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 
@@ -16,9 +17,10 @@ import (
 var log = u.Logger("commands/http")
 
 type Handler struct {
-	ctx    cmds.Context
-	root   *cmds.Command
-	origin string
+	ctx  cmds.Context
+	root *cmds.Command
+	cfg  *ServerConfig
+	cors *cors
 }
 
 var ErrNotFound = errors.New("404 page not found")
@@ -30,21 +32,29 @@ const (
 	contentLengthHeader    = "Content-Length"
 	transferEncodingHeader = "Transfer-Encoding"
 	applicationJson        = "application/json"
+	applicationNDJSON      = "application/x-ndjson"
+	acceptHeader           = "Accept"
 )
 
 var mimeTypes = map[string]string{
-	cmds.JSON: "application/json",
-	cmds.XML:  "application/xml",
-	cmds.Text: "text/plain",
+	cmds.JSON:   "application/json",
+	cmds.NDJSON: applicationNDJSON,
+	cmds.XML:    "application/xml",
+	cmds.Text:   "text/plain",
 }
 
-func NewHandler(ctx cmds.Context, root *cmds.Command, origin string) *Handler {
+func NewHandler(ctx cmds.Context, root *cmds.Command, cfg *ServerConfig) *Handler {
+	if cfg == nil {
+		cfg = &ServerConfig{}
+	}
+
 	// allow whitelisted origins (so we can make API requests from the browser)
-	if len(origin) > 0 {
+	c := newCORS(cfg.Headers, false)
+	for _, origin := range c.headers[allowOriginHeader] {
 		log.Info("Allowing API requests from origin: " + origin)
 	}
 
-	return &Handler{ctx, root, origin}
+	return &Handler{ctx, root, cfg, c}
 }
 
 func (i Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -55,10 +65,23 @@ func (i Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	log.Debug("Incoming API request: ", r.URL)
 
-	if len(i.origin) > 0 {
-		w.Header().Set("Access-Control-Allow-Origin", i.origin)
+	if !HostAllowed(r, i.cfg.Hosts) {
+		log.Warningf("refused %s request to host %s", r.URL.Path, r.Host)
+		refuse(w, http.StatusForbidden, ErrHost)
+		return
+	}
+
+	if !i.cors.check(w, r) {
+		return
+	}
+
+	// browsers send GET requests of other origins without asking, e.g.
+	// for images, and with neither Origin nor Referer at times.
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		refuse(w, http.StatusMethodNotAllowed, ErrMethod)
+		return
 	}
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 	req, err := Parse(r, i.root)
 	if err != nil {
@@ -70,11 +93,50 @@ func (i Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(err.Error()))
 		return
 	}
+
+	if status, err := i.authorize(r, req.Path()); err != nil {
+		refuse(w, status, err)
+		return
+	}
 	req.SetContext(i.ctx)
 
+	cancelTimeout, err := cmds.SetTimeout(req)
+	if err != nil {
+		w.Header().Set(contentTypeHeader, "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	defer cancelTimeout()
+
+	// cancel the command when the client goes away, not to keep working
+	// for nobody.
+	if cn, ok := w.(http.CloseNotifier); ok {
+		clientGone := cn.CloseNotify()
+		go func() {
+			select {
+			case <-clientGone:
+				log.Debug("API client went away, canceling ", r.URL)
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
 	// call the command
 	res := i.root.Call(req)
 
+	_, isChan := res.Output().(chan interface{})
+	if !isChan {
+		_, isChan = res.Output().(<-chan interface{})
+	}
+
+	// clients which can read ndjson ask for it, and still send enc=json
+	// for daemons which do not know it.
+	if enc, _, _ := req.Option(cmds.EncShort).String(); isChan && enc == cmds.JSON && acceptsNDJSON(r) {
+		req.SetOption(cmds.EncShort, cmds.NDJSON)
+	}
+
 	// set the Content-Type based on res output
 	if _, ok := res.Output().(io.Reader); ok {
 		// we don't set the Content-Type for streams, so that browsers can MIME-sniff the type themselves
@@ -117,9 +179,13 @@ func (i Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// if output is a channel and user requested streaming channels,
 	// use chunk copier for the output
-	_, isChan := res.Output().(chan interface{})
-	if !isChan {
-		_, isChan = res.Output().(<-chan interface{})
+	enc, _, _ := req.Option(cmds.EncShort).String()
+	if isChan && enc == cmds.NDJSON {
+		// each value is flushed as it comes. commands failing midway end
+		// the output with an error trailer.
+		w.Header().Set(channelHeader, "1")
+		flushCopy(w, out)
+		return
 	}
 
 	streamChans, _, _ := req.Option("stream-channels").Bool()
@@ -137,6 +203,18 @@ func (i Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	flushCopy(w, out)
 }
 
+// acceptsNDJSON tells whether the client of r asked for ndjson output.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range r.Header[acceptHeader] {
+		for _, mime := range strings.Split(accept, ",") {
+			if strings.TrimSpace(strings.Split(mime, ";")[0]) == applicationNDJSON {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // flushCopy Copies from an io.Reader to a http.ResponseWriter.
 // Flushes chunks over HTTP stream as they are read (if supported by transport).
 func flushCopy(w http.ResponseWriter, out io.Reader) error {
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+)
+
+// waitRoot has a command that runs until its context is done, and sends
+// why on done, or until stop is closed.
+func waitRoot(done chan<- error, stop <-chan struct{}) *cmds.Command {
+	return &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"wait": &cmds.Command{
+				Run: func(req cmds.Request, res cmds.Response) {
+					ctx := req.Context().Context
+					select {
+					case <-ctx.Done():
+					case <-stop:
+					}
+					done <- ctx.Err()
+					res.SetError(ctx.Err(), cmds.ErrNormal)
+				},
+			},
+		},
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	done := make(chan error, 1)
+	srv := httptest.NewServer(NewHandler(cmds.Context{}, waitRoot(done, nil), &ServerConfig{}))
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+ApiPath+"/wait?timeout=10ms", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got status %d", res.StatusCode)
+	}
+	if !strings.Contains(string(body), "deadline exceeded") {
+		t.Errorf("got body %q", body)
+	}
+
+	res, err = http.Post(srv.URL+ApiPath+"/wait?timeout=soon", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d for an invalid timeout", res.StatusCode)
+	}
+}
+
+func TestHandlerClientGone(t *testing.T) {
+	done := make(chan error, 1)
+	stop := make(chan struct{})
+	srv := httptest.NewServer(NewHandler(cmds.Context{}, waitRoot(done, stop), &ServerConfig{}))
+	defer srv.Close()
+	defer close(stop)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("POST " + ApiPath + "/wait HTTP/1.1\r\nHost: localhost\r\nContent-Length: 0\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	// let the request reach the command before going away.
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("the command ended without an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the command kept running after the client went away")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"time"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 
@@ -65,6 +66,28 @@ func (c *Context) NodeWithoutConstructing() *core.IpfsNode {
 	return c.node
 }
 
+// SetTimeout bounds the context of req by the duration of its timeout
+// option, if it has one. cancel releases the context: call it once the
+// response of req has been read.
+func SetTimeout(req Request) (cancel func(), err error) {
+	cancel = func() {}
+	timeout, found, err := req.Option(TimeoutOpt).String()
+	if err != nil || !found {
+		return cancel, err
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return cancel, ClientError(fmt.Sprintf("invalid timeout %q: %s", timeout, err))
+	}
+
+	ctx := req.Context()
+	if ctx.Context == nil {
+		ctx.Context = context.TODO()
+	}
+	ctx.Context, cancel = context.WithTimeout(ctx.Context, d)
+	return cancel, nil
+}
+
 // Request represents a call to a command from a consumer
 type Request interface {
 	Path() []string
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetTimeout(t *testing.T) {
+	cmd := &Command{}
+	opts, err := cmd.GetOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := NewRequest(nil, nil, nil, nil, cmd, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel, err := SetTimeout(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, ok := req.Context().Context.Deadline(); ok {
+		t.Error("set a deadline without a timeout option")
+	}
+
+	req.SetOption(TimeoutOpt, "1m")
+	cancel, err = SetTimeout(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline, ok := req.Context().Context.Deadline()
+	if !ok || deadline.After(time.Now().Add(time.Minute)) {
+		t.Errorf("got deadline %v, %v", deadline, ok)
+	}
+	cancel()
+	if req.Context().Context.Err() == nil {
+		t.Error("cancel left the context running")
+	}
+
+	req.SetOption(TimeoutOpt, "soon")
+	if _, err := SetTimeout(req); err == nil {
+		t.Error("accepted an invalid timeout")
+	} else if e, ok := err.(*Error); !ok || e.Code != ErrClient {
+		t.Errorf("got %#v, not a client error", err)
+	}
+}
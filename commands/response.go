@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 )
 
 // ErrorType signfies a category of errors
@@ -35,12 +36,30 @@ type EncodingType string
 
 // Supported EncodingType constants.
 const (
-	JSON = "json"
-	XML  = "xml"
-	Text = "text"
+	JSON   = "json"
+	NDJSON = "ndjson"
+	XML    = "xml"
+	Text   = "text"
 	// TODO: support more encoding types
 )
 
+// ErrorTrailer is the last value of the NDJSON output of a command that
+// failed after it started to output. Type is always "error", to tell it
+// from the values before.
+type ErrorTrailer struct {
+	Message string
+	Code    ErrorType
+	Type    string
+}
+
+// ErrorTrailerType is the Type of an ErrorTrailer.
+const ErrorTrailerType = "error"
+
+// IsTrailer tells whether the t decoded from a value is an ErrorTrailer.
+func (t *ErrorTrailer) IsTrailer() bool {
+	return t.Type == ErrorTrailerType && t.Message != ""
+}
+
 func marshalJson(value interface{}) (io.Reader, error) {
 	b, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
@@ -49,7 +68,37 @@ func marshalJson(value interface{}) (io.Reader, error) {
 	return bytes.NewReader(b), nil
 }
 
+// marshalNDJSON marshals value in one line.
+func marshalNDJSON(value interface{}) (io.Reader, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(append(b, '\n')), nil
+}
+
 var marshallers = map[EncodingType]Marshaler{
+	NDJSON: func(res Response) (io.Reader, error) {
+		ch, ok := res.Output().(<-chan interface{})
+		if ok {
+			return &ChannelMarshaler{
+				Channel:   ch,
+				Marshaler: marshalNDJSON,
+				Res:       res,
+				Trailer: func(e *Error) (io.Reader, error) {
+					return marshalNDJSON(&ErrorTrailer{e.Message, e.Code, ErrorTrailerType})
+				},
+			}, nil
+		}
+
+		var value interface{}
+		if res.Error() != nil {
+			value = res.Error()
+		} else {
+			value = res.Output()
+		}
+		return marshalNDJSON(value)
+	},
 	JSON: func(res Response) (io.Reader, error) {
 		ch, ok := res.Output().(<-chan interface{})
 		if ok {
@@ -88,7 +137,8 @@ var marshallers = map[EncodingType]Marshaler{
 type Response interface {
 	Request() Request
 
-	// Set/Return the response Error
+	// Set/Return the response Error. Commands with channel output may set
+	// it after they started to output, before they close the channel.
 	SetError(err error, code ErrorType)
 	Error() *Error
 
@@ -113,8 +163,13 @@ type Response interface {
 }
 
 type response struct {
-	req    Request
-	err    *Error
+	req Request
+
+	// errLk guards err, which commands streaming their output may set
+	// while it is read.
+	errLk sync.Mutex
+	err   *Error
+
 	value  interface{}
 	out    io.Reader
 	length uint64
@@ -143,15 +198,19 @@ func (r *response) SetLength(l uint64) {
 }
 
 func (r *response) Error() *Error {
+	r.errLk.Lock()
+	defer r.errLk.Unlock()
 	return r.err
 }
 
 func (r *response) SetError(err error, code ErrorType) {
+	r.errLk.Lock()
+	defer r.errLk.Unlock()
 	r.err = &Error{Message: err.Error(), Code: code}
 }
 
 func (r *response) Marshal() (io.Reader, error) {
-	if r.err == nil && r.value == nil {
+	if r.Error() == nil && r.value == nil {
 		return bytes.NewReader([]byte{}), nil
 	}
 
@@ -59,3 +59,37 @@ func removeWhitespace(input string) string {
 	input = strings.Replace(input, "\n", "", -1)
 	return strings.Replace(input, "\r", "", -1)
 }
+
+func TestNDJSONTrailer(t *testing.T) {
+	cmd := &Command{}
+	opts, _ := cmd.GetOptions(nil)
+	req, _ := NewRequest(nil, nil, nil, nil, nil, opts)
+	req.SetOption(EncShort, NDJSON)
+
+	res := NewResponse(req)
+	out := make(chan interface{})
+	res.SetOutput((<-chan interface{})(out))
+	go func() {
+		defer close(out)
+		out <- TestOutput{"beep", "boop", 1}
+		out <- TestOutput{"beep", "boop", 2}
+		res.SetError(fmt.Errorf("Oops!"), ErrNormal)
+	}()
+
+	reader, err := res.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(reader); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"Foo":"beep","Bar":"boop","Baz":1}
+{"Foo":"beep","Bar":"boop","Baz":2}
+{"Message":"Oops!","Code":0,"Type":"error"}
+`
+	if buf.String() != expected {
+		t.Errorf("Incorrect NDJSON output:\n%s", buf.String())
+	}
+}
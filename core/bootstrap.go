@@ -28,6 +28,13 @@ import (
 // peers to bootstrap correctly.
 var ErrNotEnoughBootstrapPeers = errors.New("not enough bootstrap peers to bootstrap")
 
+// bootstrapTag tags the connections to bootstrap peers, for the connection
+// manager.
+const (
+	bootstrapTag      = "bootstrap"
+	bootstrapTagValue = 20
+)
+
 // BootstrapConfig specifies parameters used in an IpfsNode's network
 // bootstrapping process.
 type BootstrapConfig struct {
@@ -80,8 +87,8 @@ func Bootstrap(n *IpfsNode, cfg BootstrapConfig) (io.Closer, error) {
 	// continue connecting to our bootstrap peers, but for what purpose? for now
 	// simply exit without connecting to any of them. When we introduce another
 	// routing system that uses bootstrap peers we can change this.
-	thedht, ok := n.Routing.(*dht.IpfsDHT)
-	if !ok {
+	thedht := n.DHT()
+	if thedht == nil {
 		return ioutil.NopCloser(nil), nil
 	}
 
@@ -96,9 +103,19 @@ func Bootstrap(n *IpfsNode, cfg BootstrapConfig) (io.Closer, error) {
 		}
 	}
 
+	// the first round reconnects to the peers we knew before a restart, and
+	// only bootstraps if that is not enough.
+	first := func(worker goprocess.Process) {
+		ctx := procctx.WithProcessClosing(context.Background(), worker)
+		if err := reconnectRound(ctx, n.PeerHost, thedht, n.Peerstore, cfg); err != nil {
+			log.Debugf("%s reconnect error: %s", n.Identity, err)
+		}
+		periodic(worker)
+	}
+
 	// kick off the node's periodic bootstrapping
 	proc := periodicproc.Tick(cfg.Period, periodic)
-	proc.Go(periodic) // run one right now.
+	proc.Go(first) // run one right now.
 
 	// kick off dht bootstrapping.
 	dbproc, err := thedht.Bootstrap(dht.DefaultBootstrapConfig)
@@ -125,6 +142,8 @@ func bootstrapRound(ctx context.Context,
 	// sure we remain observant of changes to client configuration.
 	peers := cfg.BootstrapPeers()
 
+	tagBootstrapPeers(host, peers)
+
 	// determine how many bootstrap connections to open
 	connected := host.Network().Peers()
 	if len(connected) >= cfg.MinPeerThreshold {
@@ -154,10 +173,49 @@ func bootstrapRound(ctx context.Context,
 
 	defer log.EventBegin(ctx, "bootstrapStart", id).Done()
 	log.Debugf("%s bootstrapping to %d nodes: %s", id, numToDial, randSubset)
-	if err := bootstrapConnect(ctx, peerstore, route, randSubset); err != nil {
-		return err
+	err := bootstrapConnect(ctx, peerstore, route, randSubset)
+	tagBootstrapPeers(host, randSubset)
+	return err
+}
+
+// tagBootstrapPeers keeps the connections to the bootstrap peers we are
+// connected to, over most others. The tags go with the connections.
+func tagBootstrapPeers(host host.Host, peers []peer.PeerInfo) {
+	for _, p := range peers {
+		if host.Network().Connectedness(p.ID) == inet.Connected {
+			host.ConnManager().TagPeer(p.ID, bootstrapTag, bootstrapTagValue)
+		}
 	}
-	return nil
+}
+
+// reconnectRound connects to the peers whose addresses we knew before
+// starting, that is, the ones the peerstore saved while we were connected
+// to them.
+func reconnectRound(ctx context.Context,
+	host host.Host,
+	route *dht.IpfsDHT,
+	peerstore peer.Peerstore,
+	cfg BootstrapConfig) error {
+
+	ctx, _ = context.WithTimeout(ctx, cfg.ConnectionTimeout)
+	id := host.ID()
+
+	var known []peer.PeerInfo
+	for _, p := range peerstore.Peers() {
+		if p != id && len(peerstore.Addresses(p)) > 0 {
+			// their addresses are in the peerstore already.
+			known = append(known, peer.PeerInfo{ID: p})
+		}
+	}
+	if len(known) < 1 {
+		return nil
+	}
+
+	randSubset := randomSubsetOfPeers(known, cfg.MinPeerThreshold)
+
+	defer log.EventBegin(ctx, "reconnectStart", id).Done()
+	log.Debugf("%s reconnecting to %d known nodes: %s", id, len(randSubset), randSubset)
+	return bootstrapConnect(ctx, peerstore, route, randSubset)
 }
 
 func bootstrapConnect(ctx context.Context,
@@ -183,7 +241,7 @@ func bootstrapConnect(ctx context.Context,
 			defer log.EventBegin(ctx, "bootstrapDial", route.LocalPeer(), p.ID).Done()
 			log.Debugf("%s bootstrapping to %s", route.LocalPeer(), p.ID)
 
-			ps.AddAddresses(p.ID, p.Addrs)
+			ps.AddAddresses(p.ID, p.Addrs, peer.PermanentAddrTTL)
 			err := route.Connect(ctx, p.ID)
 			if err != nil {
 				log.Event(ctx, "bootstrapDialFailed", p.ID)
@@ -241,6 +299,25 @@ func toPeerInfo(bootstrap config.BootstrapPeer) (p peer.PeerInfo, err error) {
 	return
 }
 
+// withoutPublicBootstrap returns bpeers, without the default bootstrap peers
+// of the public network.
+func withoutPublicBootstrap(bpeers []config.BootstrapPeer) []config.BootstrapPeer {
+	public := make(map[string]bool)
+	for _, a := range config.DefaultBootstrapAddresses {
+		if bp, err := config.ParseBootstrapPeer(a); err == nil {
+			public[bp.PeerID] = true
+		}
+	}
+
+	var out []config.BootstrapPeer
+	for _, bp := range bpeers {
+		if !public[bp.PeerID] {
+			out = append(out, bp)
+		}
+	}
+	return out
+}
+
 func randomSubsetOfPeers(in []peer.PeerInfo, max int) []peer.PeerInfo {
 	n := math2.IntMin(max, len(in))
 	var out []peer.PeerInfo
@@ -2,8 +2,17 @@ package core
 
 import (
 	"testing"
+	"time"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ds "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	inet "github.com/jbenet/go-ipfs/p2p/net"
+	mocknet "github.com/jbenet/go-ipfs/p2p/net/mock"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	config "github.com/jbenet/go-ipfs/repo/config"
+	dht "github.com/jbenet/go-ipfs/routing/dht"
+	tiered "github.com/jbenet/go-ipfs/routing/tiered"
 	testutil "github.com/jbenet/go-ipfs/util/testutil"
 )
 
@@ -23,3 +32,60 @@ func TestSubsetWhenMaxIsGreaterThanLengthOfSlice(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestWithoutPublicBootstrap(t *testing.T) {
+	public, err := config.ParseBootstrapPeers(config.DefaultBootstrapAddresses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := config.ParseBootstrapPeer("/ip4/10.0.0.1/tcp/4001/QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := withoutPublicBootstrap(append(public, private))
+	if len(out) != 1 || out[0] != private {
+		t.Fatalf("expected only %s, got %v", private, out)
+	}
+}
+
+func TestBootstrapTieredRouting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	bootstrap, h := hosts[0], hosts[1]
+	dht.NewDHT(ctx, bootstrap, dssync.MutexWrap(ds.NewMapDatastore()))
+
+	tr, err := tiered.New(nil, dht.NewDHT(ctx, h, dssync.MutexWrap(ds.NewMapDatastore())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &IpfsNode{
+		Identity:  h.ID(),
+		Peerstore: h.Peerstore(),
+		PeerHost:  h,
+		Routing:   tr,
+	}
+	if n.DHT() == nil {
+		t.Fatal("no dht in the tiered routing")
+	}
+
+	cfg := BootstrapConfigWithPeers([]peer.PeerInfo{{ID: bootstrap.ID(), Addrs: bootstrap.Addrs()}})
+	closer, err := Bootstrap(n, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	for i := 0; h.Network().Connectedness(bootstrap.ID()) != inet.Connected; i++ {
+		if i > 100 {
+			t.Fatal("did not connect to the bootstrap peer")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -35,6 +35,7 @@ type AddedObject struct {
 }
 
 var AddCmd = &cmds.Command{
+	Scope: cmds.ScopePin,
 	Helptext: cmds.HelpText{
 		Tagline: "Add an object to ipfs.",
 		ShortDescription: `
@@ -93,12 +94,17 @@ remains to be implemented.
 
 			for {
 				file, err := req.Files().NextFile()
-				if (err != nil && err != io.EOF) || file == nil {
+				if err != nil && err != io.EOF {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+				if file == nil {
 					return
 				}
 
 				_, err = addFile(n, file, outChan, progress)
 				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
 					return
 				}
 			}
@@ -217,7 +223,7 @@ func addNode(n *core.IpfsNode, node *dag.Node) error {
 		return err
 	}
 
-	err = n.Pinning.Pin(node, true) // ensure we keep it
+	err = n.Pinning.Pin(n.Context(), node, true) // ensure we keep it
 	if err != nil {
 		return err
 	}
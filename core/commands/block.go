@@ -26,6 +26,7 @@ func (bs BlockStat) String() string {
 }
 
 var BlockCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "Manipulate raw IPFS blocks",
 		ShortDescription: `
@@ -43,6 +44,7 @@ multihash.
 }
 
 var blockStatCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "Print information of a raw IPFS block",
 		ShortDescription: `
@@ -80,6 +82,7 @@ on raw ipfs blocks. It outputs the following to stdout:
 }
 
 var blockGetCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "Get a raw IPFS block",
 		ShortDescription: `
@@ -103,6 +106,7 @@ It outputs to stdout, and <key> is a base58 encoded multihash.
 }
 
 var blockPutCmd = &cmds.Command{
+	Scope: cmds.ScopePin,
 	Helptext: cmds.HelpText{
 		Tagline: "Stores input as an IPFS block",
 		ShortDescription: `
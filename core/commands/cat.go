@@ -5,14 +5,17 @@ import (
 
 	cmds "github.com/jbenet/go-ipfs/commands"
 	core "github.com/jbenet/go-ipfs/core"
+	ft "github.com/jbenet/go-ipfs/unixfs"
 	uio "github.com/jbenet/go-ipfs/unixfs/io"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	"github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/cheggaaa/pb"
 )
 
 const progressBarMinSize = 1024 * 1024 * 8 // show progress bar for outputs > 8MiB
 
 var CatCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "Show IPFS object data",
 		ShortDescription: `
@@ -33,7 +36,7 @@ it contains.
 
 		readers := make([]io.Reader, 0, len(req.Arguments()))
 
-		readers, length, err := cat(node, req.Arguments())
+		readers, length, err := cat(node, req.Context().Context, req.Arguments())
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -58,16 +61,18 @@ it contains.
 	},
 }
 
-func cat(node *core.IpfsNode, paths []string) ([]io.Reader, uint64, error) {
+func cat(node *core.IpfsNode, ctx context.Context, paths []string) ([]io.Reader, uint64, error) {
 	readers := make([]io.Reader, 0, len(paths))
 	length := uint64(0)
 	for _, path := range paths {
-		dagnode, err := node.Resolver.ResolvePath(path)
+		dagnode, err := node.Resolver.ResolvePath(ctx, path)
 		if err != nil {
 			return nil, 0, err
 		}
 
-		nodeLength, err := dagnode.Size()
+		// the size of the file, not of the encoded dag, which would be
+		// sent as the Content-Length of the output.
+		nodeLength, err := ft.DataSize(dagnode.Data)
 		if err != nil {
 			return nil, 0, err
 		}
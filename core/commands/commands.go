@@ -17,11 +17,15 @@ type Command struct {
 // and returns a command that lists the subcommands in that root
 func CommandsCmd(root *cmds.Command) *cmds.Command {
 	return &cmds.Command{
+		Scope: cmds.ScopeRead,
 		Helptext: cmds.HelpText{
 			Tagline:          "List all available commands.",
 			ShortDescription: `Lists all available commands (and subcommands) and exits.`,
 		},
 
+		Subcommands: map[string]*cmds.Command{
+			"completion": completionCmd(root),
+		},
 		Run: func(req cmds.Request, res cmds.Response) {
 			root := cmd2outputCmd("ipfs", root)
 			res.SetOutput(&root)
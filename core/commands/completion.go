@@ -0,0 +1,314 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+)
+
+// completionShells write the completion scripts of the shells, by name.
+var completionShells = map[string]func(io.Writer, []completionEntry) error{
+	"bash": writeBashCompletion,
+	"zsh":  writeZshCompletion,
+	"fish": writeFishCompletion,
+}
+
+// completionCmd returns a command that writes a completion script of the
+// commands of root.
+func completionCmd(root *cmds.Command) *cmds.Command {
+	return &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: "Generate shell completion scripts",
+			ShortDescription: `
+Writes a script that completes the subcommands and options of ipfs in the
+given shell, one of: bash, zsh, fish. IPFS paths complete to the hashes of
+the local pins.
+`,
+			LongDescription: `
+Writes a script that completes the subcommands and options of ipfs in the
+given shell, one of: bash, zsh, fish. IPFS paths complete to the hashes of
+the local pins.
+
+To load the completion in the current shell:
+
+    source <(ipfs commands completion bash)
+
+Or save it where the shell loads completions from, e.g.:
+
+    ipfs commands completion fish > ~/.config/fish/completions/ipfs.fish
+`,
+		},
+
+		Arguments: []cmds.Argument{
+			cmds.StringArg("shell", true, false, "The shell to complete in: bash, zsh or fish"),
+		},
+		Run: func(req cmds.Request, res cmds.Response) {
+			write, ok := completionShells[req.Arguments()[0]]
+			if !ok {
+				res.SetError(fmt.Errorf("unknown shell %q, expected bash, zsh or fish", req.Arguments()[0]), cmds.ErrClient)
+				return
+			}
+
+			entries, err := completionEntries(root)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			var buf bytes.Buffer
+			if err := write(&buf, entries); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			res.SetOutput(&buf)
+		},
+	}
+}
+
+// completionEntry is what completes after the words of a command path.
+type completionEntry struct {
+	Path        string // the words of the path, separated by spaces
+	Subcommands []completionWord
+	Options     []completionWord // the flags, like "--recursive" and "-r"
+	IPFSPaths   bool             // whether the arguments are IPFS paths
+	Files       bool             // whether the arguments are files
+}
+
+// completionWord is a word to complete, and what it is for.
+type completionWord struct {
+	Word        string
+	Description string
+}
+
+// completionEntries returns the entries of root and of its subcommands,
+// ordered by path.
+func completionEntries(root *cmds.Command) ([]completionEntry, error) {
+	var entries []completionEntry
+
+	var walk func(path []string, cmd *cmds.Command) error
+	walk = func(path []string, cmd *cmds.Command) error {
+		e := completionEntry{Path: strings.Join(path, " ")}
+
+		var names []string
+		for name := range cmd.Subcommands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			e.Subcommands = append(e.Subcommands, completionWord{name, cmd.Subcommands[name].Helptext.Tagline})
+		}
+
+		opts, err := root.GetOptions(path)
+		if err != nil {
+			return err
+		}
+		for name, opt := range opts {
+			flag := "--" + name
+			if len(name) == 1 {
+				flag = "-" + name
+			}
+			e.Options = append(e.Options, completionWord{flag, opt.Description()})
+		}
+		sort.Sort(byWord(e.Options))
+
+		for _, arg := range cmd.Arguments {
+			if isIPFSPathArg(path, arg) {
+				e.IPFSPaths = true
+			}
+			if arg.Type == cmds.ArgFile {
+				e.Files = true
+			}
+		}
+
+		entries = append(entries, e)
+		for _, name := range names {
+			if err := walk(append(path[:len(path):len(path)], name), cmd.Subcommands[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(nil, root); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// isIPFSPathArg tells whether arg, of the command at path, is an IPFS path,
+// or the key of an object. The keys of config are not.
+func isIPFSPathArg(path []string, arg cmds.Argument) bool {
+	if arg.Type != cmds.ArgString {
+		return false
+	}
+	switch arg.Name {
+	case "ipfs-path":
+		return true
+	case "key":
+		return len(path) == 0 || path[0] != "config"
+	}
+	return false
+}
+
+type byWord []completionWord
+
+func (w byWord) Len() int           { return len(w) }
+func (w byWord) Less(i, j int) bool { return w[i].Word < w[j].Word }
+func (w byWord) Swap(i, j int)      { w[i], w[j] = w[j], w[i] }
+
+func words(ws []completionWord) string {
+	s := make([]string, len(ws))
+	for i, w := range ws {
+		s[i] = w.Word
+	}
+	return strings.Join(s, " ")
+}
+
+const bashCompletionTemplate = `# bash completion of ipfs, generated by 'ipfs commands completion bash'
+
+_ipfs_pins() {
+    ipfs pin ls --type=recursive 2>/dev/null
+    ipfs pin ls --type=direct 2>/dev/null
+}
+
+_ipfs() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" path="" subcommands options paths=0 w i
+    COMPREPLY=()
+
+    # the command path is the words that are subcommands, until an argument
+    for ((i=1; i<COMP_CWORD; i++)); do
+        w="${COMP_WORDS[i]}"
+        case "$w" in -*) continue;; esac
+        _ipfs_entry "$path"
+        case " $subcommands " in
+            *" $w "*) path="${path:+$path }$w";;
+            *) break;;
+        esac
+    done
+
+    _ipfs_entry "$path"
+    case "$cur" in
+        -*) COMPREPLY=($(compgen -W "$options" -- "$cur"));;
+        *)
+            if [ "$paths" = 1 ]; then
+                subcommands="$subcommands $(_ipfs_pins)"
+            fi
+            COMPREPLY=($(compgen -W "$subcommands" -- "$cur"))
+            ;;
+    esac
+}
+
+# _ipfs_entry sets the subcommands and options of a command path, and
+# whether its arguments are IPFS paths.
+_ipfs_entry() {
+    subcommands="" options="" paths=0
+    case "$1" in
+%s    esac
+}
+
+complete -o default -F _ipfs ipfs
+`
+
+func writeBashCompletion(w io.Writer, entries []completionEntry) error {
+	var cases bytes.Buffer
+	for _, e := range entries {
+		paths := 0
+		if e.IPFSPaths {
+			paths = 1
+		}
+		fmt.Fprintf(&cases, "        %q)\n", e.Path)
+		fmt.Fprintf(&cases, "            subcommands=%q\n", words(e.Subcommands))
+		fmt.Fprintf(&cases, "            options=%q\n", words(e.Options))
+		fmt.Fprintf(&cases, "            paths=%d\n", paths)
+		fmt.Fprintf(&cases, "            ;;\n")
+	}
+	_, err := fmt.Fprintf(w, bashCompletionTemplate, cases.String())
+	return err
+}
+
+// zsh completes with the bash script.
+func writeZshCompletion(w io.Writer, entries []completionEntry) error {
+	if _, err := io.WriteString(w, "#compdef ipfs\n\nautoload -U +X bashcompinit && bashcompinit\n\n"); err != nil {
+		return err
+	}
+	return writeBashCompletion(w, entries)
+}
+
+const fishCompletionHeader = `# fish completion of ipfs, generated by 'ipfs commands completion fish'
+
+# __ipfs_path prints the command path: the words that are subcommands,
+# until an argument.
+function __ipfs_path
+    set -l path ''
+    for w in (commandline -opc)[2..-1]
+        switch $w
+            case '-*'
+                continue
+        end
+        if contains -- $w (__ipfs_subcommands $path)
+            set path (string trim -- "$path $w")
+        else
+            break
+        end
+    end
+    echo $path
+end
+
+function __ipfs_at
+    set -l path (__ipfs_path)
+    test "$path" = "$argv[1]"
+end
+
+function __ipfs_pins
+    ipfs pin ls --type=recursive 2>/dev/null
+    ipfs pin ls --type=direct 2>/dev/null
+end
+`
+
+func writeFishCompletion(w io.Writer, entries []completionEntry) error {
+	var buf bytes.Buffer
+	buf.WriteString(fishCompletionHeader)
+
+	buf.WriteString("\nfunction __ipfs_subcommands\n    switch \"$argv[1]\"\n")
+	for _, e := range entries {
+		if len(e.Subcommands) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "        case %s\n            printf '%%s\\n' %s\n", fishQuote(e.Path), words(e.Subcommands))
+	}
+	buf.WriteString("    end\nend\n\n")
+
+	for _, e := range entries {
+		cond := fishQuote("__ipfs_at " + fishQuote(e.Path))
+		if !e.Files {
+			fmt.Fprintf(&buf, "complete -c ipfs -n %s -f\n", cond)
+		}
+		for _, s := range e.Subcommands {
+			fmt.Fprintf(&buf, "complete -c ipfs -n %s -a %s -d %s\n", cond, fishQuote(s.Word), fishQuote(s.Description))
+		}
+		for _, o := range e.Options {
+			flag := "-l " + strings.TrimPrefix(o.Word, "--")
+			if !strings.HasPrefix(o.Word, "--") {
+				flag = "-s " + strings.TrimPrefix(o.Word, "-")
+			}
+			fmt.Fprintf(&buf, "complete -c ipfs -n %s %s -d %s\n", cond, flag, fishQuote(o.Description))
+		}
+		if e.IPFSPaths {
+			fmt.Fprintf(&buf, "complete -c ipfs -n %s -a '(__ipfs_pins)'\n", cond)
+		}
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// fishQuote quotes s in single quotes, for fish.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+)
+
+func TestCompletionEntries(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"pin": {
+				Subcommands: map[string]*cmds.Command{
+					"add": {
+						Arguments: []cmds.Argument{cmds.StringArg("ipfs-path", true, true, "")},
+						Options:   []cmds.Option{cmds.BoolOption("recursive", "r", "")},
+					},
+				},
+			},
+			"config": {
+				Arguments: []cmds.Argument{cmds.StringArg("key", true, false, "")},
+			},
+		},
+	}
+
+	entries, err := completionEntries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	if strings.Join(paths, ",") != ",config,pin,pin add" {
+		t.Fatalf("got paths %q", paths)
+	}
+
+	pinAdd := entries[3]
+	if !pinAdd.IPFSPaths || entries[1].IPFSPaths {
+		t.Error("pin add should complete IPFS paths, and config not")
+	}
+	if words(entries[2].Subcommands) != "add" {
+		t.Errorf("pin has subcommands %q", words(entries[2].Subcommands))
+	}
+	if opts := " " + words(pinAdd.Options) + " "; !strings.Contains(opts, " --recursive ") || !strings.Contains(opts, " -r ") {
+		t.Errorf("pin add has options %q", words(pinAdd.Options))
+	}
+
+	for shell, write := range completionShells {
+		var buf bytes.Buffer
+		if err := write(&buf, entries); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "pin add") {
+			t.Errorf("%s script does not complete pin add", shell)
+		}
+	}
+}
+
+func TestFishQuote(t *testing.T) {
+	if q := fishQuote(`it's a \ path`); q != `'it\'s a \\ path'` {
+		t.Errorf("quoted as %s", q)
+	}
+}
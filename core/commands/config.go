@@ -9,11 +9,13 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 
 	cmds "github.com/jbenet/go-ipfs/commands"
 	repo "github.com/jbenet/go-ipfs/repo"
 	config "github.com/jbenet/go-ipfs/repo/config"
 	fsrepo "github.com/jbenet/go-ipfs/repo/fsrepo"
+	serialize "github.com/jbenet/go-ipfs/repo/fsrepo/serialize"
 	u "github.com/jbenet/go-ipfs/util"
 )
 
@@ -23,14 +25,16 @@ type ConfigField struct {
 }
 
 var ConfigCmd = &cmds.Command{
+	Unsafe: true,
 	Helptext: cmds.HelpText{
 		Tagline: "get and set IPFS config values",
 		Synopsis: `
-ipfs config <key>          - Get value of <key>
-ipfs config <key> <value>  - Set value of <key> to <value>
-ipfs config show           - Show config file
-ipfs config edit           - Edit config file in $EDITOR
-ipfs config replace <file> - Replaces the config file with <file>
+ipfs config <key>                  - Get value of <key>
+ipfs config <key> <value>          - Set value of <key> to <value>
+ipfs config show                   - Show config file
+ipfs config edit                   - Edit config file in $EDITOR
+ipfs config replace <file>         - Replaces the config file with <file>
+ipfs config profile apply <name>   - Applies the profile <name> to the config
 `,
 		ShortDescription: `
 ipfs config controls configuration variables. It works like 'git config'.
@@ -50,6 +54,13 @@ Get the value of the 'datastore.path' key:
 Set the value of the 'datastore.path' key:
 
   ipfs config datastore.path ~/.go-ipfs/datastore
+
+Set a value of another type than string, here a number:
+
+  ipfs config --json Swarm.ConnMgr.HighWater 100
+
+The config is checked before it is written: values of the wrong type for
+their key are refused.
 `,
 	},
 
@@ -57,6 +68,9 @@ Set the value of the 'datastore.path' key:
 		cmds.StringArg("key", true, false, "The key of the config entry (e.g. \"Addresses.API\")"),
 		cmds.StringArg("value", false, false, "The value to set the config entry to"),
 	},
+	Options: []cmds.Option{
+		cmds.BoolOption("json", "Parse the value as JSON"),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		args := req.Arguments()
 		key := args[0]
@@ -71,7 +85,13 @@ Set the value of the 'datastore.path' key:
 		var err error
 		var output *ConfigField
 		if len(args) == 2 {
-			value := args[1]
+			var value interface{} = args[1]
+			if isJSON, _, _ := req.Option("json").Bool(); isJSON {
+				if err := json.Unmarshal([]byte(args[1]), &value); err != nil {
+					res.SetError(fmt.Errorf("value is not JSON: %s", err), cmds.ErrClient)
+					return
+				}
+			}
 			output, err = setConfig(r, key, value)
 		} else {
 			output, err = getConfig(r, key)
@@ -109,8 +129,9 @@ Set the value of the 'datastore.path' key:
 	Type: ConfigField{},
 	Subcommands: map[string]*cmds.Command{
 		"show":    configShowCmd,
-		"edit":    configEditCmd,
+		"edit":    ConfigEditCmd,
 		"replace": configReplaceCmd,
+		"profile": configProfileCmd,
 	},
 }
 
@@ -118,8 +139,9 @@ var configShowCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Outputs the content of the config file",
 		ShortDescription: `
-WARNING: Your private key is stored in the config file, and it will be
-included in the output of this command.
+Your private key is stored in the config file too. It is left out of the
+output of this command, and 'ipfs config replace' keeps it when the new
+config has none.
 `,
 	},
 
@@ -139,23 +161,31 @@ included in the output of this command.
 	},
 }
 
-var configEditCmd = &cmds.Command{
+var ConfigEditCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Opens the config file for editing in $EDITOR",
 		ShortDescription: `
 To use 'ipfs config edit', you must have the $EDITOR environment
-variable set to your preferred text editor.
+variable set to your preferred text editor. The edited config is saved
+once the editor exits, if it is valid.
 `,
 	},
 
 	Run: func(req cmds.Request, res cmds.Response) {
+		r := fsrepo.At(req.Context().ConfigRoot)
+		if err := r.Open(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer r.Close()
+
 		filename, err := config.Filename(req.Context().ConfigRoot)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		err = editConfig(filename)
+		err = editConfig(r, filename)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 		}
@@ -164,10 +194,11 @@ variable set to your preferred text editor.
 
 var configReplaceCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline: "Replaces the config with `file>",
+		Tagline: "Replaces the config with <file>",
 		ShortDescription: `
 Make sure to back up the config file first if neccessary, this operation
-can't be undone.
+can't be undone. The private key is kept if <file> has none, as in the
+output of 'ipfs config show'.
 `,
 	},
 
@@ -197,6 +228,89 @@ can't be undone.
 	},
 }
 
+var configProfileCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Applies profiles of changes to the config",
+		ShortDescription: `
+Profiles change a set of config values at once, for a kind of use of the
+node, e.g. 'server' for nodes in data centers. 'ipfs config profile ls'
+lists them.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"apply": configProfileApplyCmd,
+		"ls":    configProfileLsCmd,
+	},
+}
+
+var configProfileApplyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Applies profiles to the config",
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("profile", true, true, "The profiles to apply, in order"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		r := fsrepo.At(req.Context().ConfigRoot)
+		if err := r.Open(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		filename, err := config.Filename(req.Context().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := applyProfiles(r, filename, req.Arguments()); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+		}
+	},
+}
+
+// ConfigProfile is a profile the config may be changed with.
+type ConfigProfile struct {
+	Name        string
+	Description string
+}
+
+// ConfigProfiles is the list of profiles.
+type ConfigProfiles struct {
+	Profiles []ConfigProfile
+}
+
+var configProfileLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Lists the profiles",
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		var out ConfigProfiles
+		for _, name := range config.ProfileNames() {
+			out.Profiles = append(out.Profiles, ConfigProfile{name, config.Profiles[name].Description})
+		}
+		res.SetOutput(&out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ConfigProfiles)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			var buf bytes.Buffer
+			for _, p := range out.Profiles {
+				fmt.Fprintf(&buf, "%s\n    %s\n", p.Name, p.Description)
+			}
+			return &buf, nil
+		},
+	},
+	Type: ConfigProfiles{},
+}
+
 func getConfig(r repo.Repo, key string) (*ConfigField, error) {
 	value, err := r.GetConfigKey(key)
 	if err != nil {
@@ -208,7 +322,7 @@ func getConfig(r repo.Repo, key string) (*ConfigField, error) {
 	}, nil
 }
 
-func setConfig(r repo.Repo, key, value string) (*ConfigField, error) {
+func setConfig(r repo.Repo, key string, value interface{}) (*ConfigField, error) {
 	err := r.SetConfigKey(key, value)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to set config value: %s", err)
@@ -216,33 +330,97 @@ func setConfig(r repo.Repo, key, value string) (*ConfigField, error) {
 	return getConfig(r, key)
 }
 
+// showConfig returns the config file, without the private key.
 func showConfig(filename string) (io.Reader, error) {
-	// TODO maybe we should omit privkey so we don't accidentally leak it?
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(filename, &mapconf); err != nil {
+		return nil, err
+	}
+	if identity, ok := mapconf["Identity"].(map[string]interface{}); ok {
+		delete(identity, "PrivKey")
+	}
 
-	data, err := ioutil.ReadFile(filename)
+	buf, err := config.Marshal(mapconf)
 	if err != nil {
 		return nil, err
 	}
-
-	return bytes.NewReader(data), nil
+	buf = append(buf, '\n')
+	return bytes.NewReader(buf), nil
 }
 
-func editConfig(filename string) error {
+// editConfig edits a copy of the config file, and saves it once the editor
+// exits, if it is a valid config. An invalid copy is left for the user to
+// fix.
+func editConfig(r repo.Repo, filename string) error {
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
 		return errors.New("ENV variable $EDITOR not set")
 	}
 
-	cmd := exec.Command("sh", "-c", editor+" "+filename)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "config-edit-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	cmd := exec.Command("sh", "-c", editor+" "+tmp.Name())
 	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
-	return cmd.Run()
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	edited, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	err = replaceConfig(r, edited)
+	edited.Close()
+	if err != nil {
+		return fmt.Errorf("%s. The edited config is kept in %s", err, tmp.Name())
+	}
+	return os.Remove(tmp.Name())
 }
 
+// replaceConfig replaces the config with the one in file, once it is known
+// to be valid.
 func replaceConfig(r repo.Repo, file io.Reader) error {
-	var cfg config.Config
-	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
-		return errors.New("Failed to decode file as config")
+	var mapconf map[string]interface{}
+	if err := json.NewDecoder(file).Decode(&mapconf); err != nil {
+		return fmt.Errorf("Failed to decode file as config: %s", err)
+	}
+	cfg, err := config.FromMap(mapconf)
+	if err != nil {
+		return err
 	}
 
+	// configs from 'ipfs config show' have no private key.
+	if cfg.Identity.PrivKey == "" {
+		cfg.Identity.PrivKey = r.Config().Identity.PrivKey
+	}
+	return r.SetConfig(cfg)
+}
+
+// applyProfiles applies the named profiles to the config in filename, as
+// written, and saves it.
+func applyProfiles(r repo.Repo, filename string, names []string) error {
+	var cfg config.Config
+	if err := serialize.ReadConfigFile(filename, &cfg); err != nil {
+		return err
+	}
+	if err := config.ApplyProfiles(&cfg, names...); err != nil {
+		return err
+	}
 	return r.SetConfig(&cfg)
 }
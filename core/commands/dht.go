@@ -10,13 +10,13 @@ import (
 	cmds "github.com/jbenet/go-ipfs/commands"
 	notif "github.com/jbenet/go-ipfs/notifications"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
-	ipdht "github.com/jbenet/go-ipfs/routing/dht"
 	u "github.com/jbenet/go-ipfs/util"
 )
 
 var ErrNotDHT = errors.New("routing service is not a DHT")
 
 var DhtCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline:          "Issue commands directly through the DHT",
 		ShortDescription: ``,
@@ -48,8 +48,8 @@ var queryDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -151,8 +151,8 @@ FindProviders will return a list of peers who are able to provide the value requ
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -264,8 +264,8 @@ var findPeerDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
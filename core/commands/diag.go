@@ -1,9 +1,14 @@
 package commands
 
 import (
+	"archive/tar"
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
 	"strings"
 	"text/template"
 	"time"
@@ -40,12 +45,14 @@ type DiagnosticOutput struct {
 var DefaultDiagnosticTimeout = time.Second * 20
 
 var DiagCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "Generates diagnostic reports",
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"net": diagNetCmd,
+		"net":     diagNetCmd,
+		"profile": diagProfileCmd,
 	},
 }
 
@@ -60,7 +67,6 @@ connected peers and latencies between them.
 	},
 
 	Options: []cmds.Option{
-		cmds.StringOption("timeout", "diagnostic timeout duration"),
 		cmds.StringOption("vis", "output vis. one of: "+strings.Join(visFmts, ", ")),
 	},
 
@@ -82,7 +88,8 @@ connected peers and latencies between them.
 			return
 		}
 
-		timeoutS, _, err := req.Option("timeout").String()
+		// the global timeout option bounds the diagnostic too.
+		timeoutS, _, err := req.Option(cmds.TimeoutOpt).String()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -126,6 +133,142 @@ connected peers and latencies between them.
 	},
 }
 
+var errProfilingDisabled = errors.New("profiling is disabled: set API.Profiling in the config to enable it")
+
+// DefaultProfileDuration is how long 'ipfs diag profile' profiles the CPU
+// for, by default.
+var DefaultProfileDuration = time.Second * 30
+
+var diagProfileCmd = &cmds.Command{
+	Scope:  cmds.ScopeAdmin,
+	Unsafe: true,
+	Helptext: cmds.HelpText{
+		Tagline: "Collects profiles of the daemon",
+		ShortDescription: `
+Profiles the CPU of the daemon for a while, then saves the profile, a heap
+profile and a dump of the goroutines in a TAR archive:
+
+    ipfs diag profile --cpu-profile-time=10s -o=profile.tar
+
+Read the profiles with 'go tool pprof <ipfs binary> cpu.pprof'. Like the
+profiles served at /debug/pprof, they are only taken when API.Profiling is
+set in the config, and only for local clients.
+`,
+	},
+
+	Options: []cmds.Option{
+		cmds.StringOption("output", "o", "The path where the archive should be stored"),
+		cmds.StringOption("cpu-profile-time", "How long to profile the CPU for (default 30s)"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		cfg, err := req.Context().GetConfig()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !cfg.API.Profiling {
+			res.SetError(errProfilingDisabled, cmds.ErrNormal)
+			return
+		}
+
+		d, _, err := req.Option("cpu-profile-time").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		duration := DefaultProfileDuration
+		if d != "" {
+			if duration, err = time.ParseDuration(d); err != nil {
+				res.SetError(errors.New("error parsing cpu-profile-time"), cmds.ErrClient)
+				return
+			}
+		}
+
+		// one CPU profile at a time: fail now if there is another one.
+		var cpu bytes.Buffer
+		if err := pprof.StartCPUProfile(&cpu); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		ctx := req.Context().Context
+		piper, pipew := io.Pipe()
+		go func() {
+			select {
+			case <-time.After(duration):
+			case <-ctx.Done():
+			}
+			pprof.StopCPUProfile()
+			pipew.CloseWithError(writeProfiles(pipew, cpu.Bytes()))
+		}()
+
+		res.SetOutput(piper)
+	},
+	PostRun: func(req cmds.Request, res cmds.Response) {
+		if res.Output() == nil {
+			return
+		}
+		outReader := res.Output().(io.Reader)
+		res.SetOutput(nil)
+
+		outPath, _, _ := req.Option("output").String()
+		if outPath == "" {
+			outPath = fmt.Sprintf("ipfs-profile-%s.tar", time.Now().Format("2006-01-02T15-04-05"))
+		}
+
+		file, err := os.Create(outPath)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		if _, err := io.Copy(file, outReader); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		fmt.Printf("Saved profiles to %s\n", outPath)
+	},
+}
+
+// writeProfiles writes a TAR archive of the cpu profile, and of a heap
+// profile and a dump of the goroutines taken now.
+func writeProfiles(w io.Writer, cpu []byte) error {
+	var heap, goroutines bytes.Buffer
+	runtime.GC() // for the heap profile to be up to date
+	if err := pprof.Lookup("heap").WriteTo(&heap, 0); err != nil {
+		return err
+	}
+	if err := pprof.Lookup("goroutine").WriteTo(&goroutines, 2); err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{"cpu.pprof", cpu},
+		{"heap.pprof", heap.Bytes()},
+		{"goroutines.txt", goroutines.Bytes()},
+	} {
+		hdr := &tar.Header{
+			Name:    f.name,
+			Mode:    0644,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
 func stdDiagOutputMarshal(output *DiagnosticOutput) (io.Reader, error) {
 	var buf bytes.Buffer
 	err := printDiagnostics(&buf, output)
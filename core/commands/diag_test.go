@@ -1,7 +1,11 @@
 package commands
 
 import (
+	"archive/tar"
 	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
 )
 
@@ -27,3 +31,37 @@ func TestPrintDiagnostics(t *testing.T) {
 	}
 	t.Log(buf.String())
 }
+
+func TestWriteProfiles(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeProfiles(&buf, []byte("cpu")); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(data)
+	}
+
+	if files["cpu.pprof"] != "cpu" {
+		t.Errorf("cpu profile is %q", files["cpu.pprof"])
+	}
+	if len(files["heap.pprof"]) == 0 {
+		t.Error("empty heap profile")
+	}
+	if !strings.Contains(files["goroutines.txt"], "TestWriteProfiles") {
+		t.Error("goroutine dump lacks the test's goroutine")
+	}
+}
@@ -13,12 +13,14 @@ import (
 	tar "github.com/jbenet/go-ipfs/thirdparty/tar"
 	utar "github.com/jbenet/go-ipfs/unixfs/tar"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	"github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/cheggaaa/pb"
 )
 
 var ErrInvalidCompressionLevel = errors.New("Compression level must be between 1 and 9")
 
 var GetCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "Download IPFS objects",
 		ShortDescription: `
@@ -60,7 +62,7 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			return
 		}
 
-		reader, err := get(node, req.Arguments()[0], cmplvl)
+		reader, err := get(node, req.Context().Context, req.Arguments()[0], cmplvl)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -162,6 +164,6 @@ func getCompressOptions(req cmds.Request) (int, error) {
 	return gzip.NoCompression, nil
 }
 
-func get(node *core.IpfsNode, path string, compression int) (io.Reader, error) {
-	return utar.NewReader(path, node.DAG, node.Resolver, compression)
+func get(node *core.IpfsNode, ctx context.Context, path string, compression int) (io.Reader, error) {
+	return utar.NewReader(ctx, path, node.DAG, node.Resolver, compression)
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"sort"
 	"time"
 
 	"github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
@@ -13,8 +14,11 @@ import (
 	b58 "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-base58"
 
 	cmds "github.com/jbenet/go-ipfs/commands"
+	core "github.com/jbenet/go-ipfs/core"
 	ic "github.com/jbenet/go-ipfs/p2p/crypto"
+	inet "github.com/jbenet/go-ipfs/p2p/net"
 	"github.com/jbenet/go-ipfs/p2p/peer"
+	identify "github.com/jbenet/go-ipfs/p2p/protocol/identify"
 	kb "github.com/jbenet/go-ipfs/routing/kbucket"
 	u "github.com/jbenet/go-ipfs/util"
 )
@@ -32,14 +36,36 @@ type IdOutput struct {
 	Addresses       []string
 	AgentVersion    string
 	ProtocolVersion string
+	Protocols       []string
+
+	// for remote peers we are connected to.
+	Latency string         `json:",omitempty"`
+	Conns   []IdConnOutput `json:",omitempty"`
+}
+
+// IdConnOutput describes a connection to the peer.
+type IdConnOutput struct {
+	Addr      string
+	Direction string
+	Age       string
+	Streams   int
 }
 
 var IDCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "Show IPFS Node ID info",
 		ShortDescription: `
 Prints out information about the specified peer,
 if no peer is specified, prints out local peers info.
+`,
+		LongDescription: `
+Prints out information about the specified peer,
+if no peer is specified, prints out local peers info.
+
+The agent and protocol versions, and the protocols, of remote peers are the
+ones they told us when identified. If we are connected to the peer, its
+latency and our connections to it are listed too.
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -53,7 +79,7 @@ if no peer is specified, prints out local peers info.
 		}
 
 		if len(req.Arguments()) == 0 {
-			output, err := printPeer(node.Peerstore, node.Identity)
+			output, err := printSelf(node)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
@@ -92,6 +118,7 @@ if no peer is specified, prints out local peers info.
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		addConns(output, node.PeerHost.Network(), p.ID)
 		res.SetOutput(output)
 	},
 	Marshalers: cmds.MarshalerMap{
@@ -111,7 +138,47 @@ if no peer is specified, prints out local peers info.
 	Type: IdOutput{},
 }
 
-func printPeer(ps peer.Peerstore, p peer.ID) (interface{}, error) {
+// printSelf describes the local node. Its versions are this code's.
+func printSelf(node *core.IpfsNode) (*IdOutput, error) {
+	info, err := printPeer(node.Peerstore, node.Identity)
+	if err != nil {
+		return nil, err
+	}
+	info.AgentVersion = identify.ClientVersion
+	info.ProtocolVersion = identify.IpfsVersion.String()
+	if node.PeerHost != nil {
+		for _, p := range node.PeerHost.Mux().Protocols() {
+			info.Protocols = append(info.Protocols, string(p))
+		}
+		sort.Strings(info.Protocols)
+	}
+	return info, nil
+}
+
+// addConns adds the latency of p, and our connections to it, to info.
+func addConns(info *IdOutput, n inet.Network, p peer.ID) {
+	conns := n.ConnsToPeer(p)
+	if len(conns) == 0 {
+		return
+	}
+	if l := n.Peerstore().LatencyEWMA(p); l > 0 {
+		info.Latency = roundDuration(l).String()
+	}
+	for _, c := range conns {
+		st := c.Stat()
+		co := IdConnOutput{
+			Addr:      c.RemoteMultiaddr().String(),
+			Direction: st.Direction.String(),
+			Streams:   st.Streams,
+		}
+		if !st.Opened.IsZero() {
+			co.Age = roundDuration(time.Since(st.Opened)).String()
+		}
+		info.Conns = append(info.Conns, co)
+	}
+}
+
+func printPeer(ps peer.Peerstore, p peer.ID) (*IdOutput, error) {
 	if p == "" {
 		return nil, errors.New("Attempted to print nil peer!")
 	}
@@ -131,15 +198,11 @@ func printPeer(ps peer.Peerstore, p peer.ID) (interface{}, error) {
 		info.Addresses = append(info.Addresses, a.String())
 	}
 
-	if v, err := ps.Get(p, "ProtocolVersion"); err == nil {
-		if vs, ok := v.(string); ok {
-			info.AgentVersion = vs
-		}
-	}
-	if v, err := ps.Get(p, "AgentVersion"); err == nil {
-		if vs, ok := v.(string); ok {
-			info.ProtocolVersion = vs
-		}
+	if v, found := ps.Version(p); found {
+		info.AgentVersion = v.AgentVersion
+		info.ProtocolVersion = v.ProtocolVersion
+		info.Protocols = append([]string(nil), v.Protocols...)
+		sort.Strings(info.Protocols)
 	}
 
 	return info, nil
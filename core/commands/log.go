@@ -1,14 +1,17 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
+	logging "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-logging"
+
 	cmds "github.com/jbenet/go-ipfs/commands"
+	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
 	u "github.com/jbenet/go-ipfs/util"
-
-	tail "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/ActiveState/tail"
 )
 
 // Golang os.Args overrides * and replaces the character argument with
@@ -71,41 +74,88 @@ output of a running daemon.
 	Type: MessageOutput{},
 }
 
+// LogEntry is an entry of the event log: an event, or a message of a
+// subsystem, with its fields.
+type LogEntry map[string]interface{}
+
 var logTailCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Read the logs",
 		ShortDescription: `
 'ipfs log tail' is a utility command used to read log output as it is written.
+`,
+		LongDescription: `
+'ipfs log tail' streams the entries of the event log of the daemon as they
+are logged: events, and the messages of subsystems at the levels enabled
+with 'ipfs log level'. Filter them by subsystem, event name and level:
+
+    ipfs log tail --system=dht --level=warning
+    ipfs log tail --event=handleFindPeer --enc=ndjson
+
+Entries are dropped while the output falls behind.
 `,
 	},
 
+	Options: []cmds.Option{
+		cmds.StringOption("system", "s", "Only output entries of the given subsystem"),
+		cmds.StringOption("event", "e", "Only output events of the given name"),
+		cmds.StringOption("level", "l", "Only output entries of the given level or above, one of: debug, info, notice, warning, error, critical"),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		path := fmt.Sprintf("%s/logs/events.log", req.Context().ConfigRoot)
+		system, _, err := req.Option("system").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		event, _, err := req.Option("event").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		level, _, err := req.Option("level").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		minLevel := logging.DEBUG
+		if level != "" {
+			if minLevel, err = logging.LogLevel(level); err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		}
 
+		ctx := req.Context().Context
+		entries, cancel := eventlog.Subscribe(logTailBuffer)
 		outChan := make(chan interface{})
 
 		go func() {
 			defer close(outChan)
+			defer cancel()
 
-			t, err := tail.TailFile(path, tail.Config{
-				Location:  &tail.SeekInfo{0, 2},
-				Follow:    true,
-				MustExist: true,
-				Logger:    tail.DiscardingLogger,
-			})
-			if err != nil {
-				fmt.Println(err.Error())
-				return
-			}
-			defer t.Stop()
-
-			for line := range t.Lines {
-				if line.Err != nil {
-					fmt.Println(err.Error())
+			for {
+				select {
+				case <-ctx.Done():
 					return
+				case e := <-entries:
+					if system != "" && e[eventlog.KeySystem] != system {
+						continue
+					}
+					if event != "" && e[eventlog.KeyEvent] != event {
+						continue
+					}
+					l, _ := e[eventlog.KeyLevel].(string)
+					if lvl, err := logging.LogLevel(l); err == nil && lvl > minLevel {
+						continue
+					}
+
+					entry := LogEntry(e)
+					select {
+					case outChan <- &entry:
+					case <-ctx.Done():
+						return
+					}
 				}
-				// TODO: unpack the line text into a struct and output that
-				outChan <- &MessageOutput{line.Text}
 			}
 		}()
 
@@ -121,11 +171,56 @@ var logTailCmd = &cmds.Command{
 			return &cmds.ChannelMarshaler{
 				Channel: outChan,
 				Marshaler: func(v interface{}) (io.Reader, error) {
-					output := v.(*MessageOutput)
-					return strings.NewReader(output.Message + "\n"), nil
+					entry, ok := v.(*LogEntry)
+					if !ok {
+						return nil, u.ErrCast()
+					}
+					return strings.NewReader(entry.String() + "\n"), nil
 				},
 			}, nil
 		},
 	},
-	Type: MessageOutput{},
+	Type: LogEntry{},
+}
+
+// logTailBuffer is the number of entries buffered for a tail.
+const logTailBuffer = 256
+
+// String formats the entry in one line: time, level, subsystem, the event
+// name or the message, then the other fields in order.
+func (e LogEntry) String() string {
+	reserved := []string{eventlog.KeyTime, eventlog.KeyLevel, eventlog.KeySystem, eventlog.KeyEvent, eventlog.KeyMessage}
+
+	var fields []string
+	for _, k := range reserved {
+		if v, ok := e[k]; ok && v != "" {
+			fields = append(fields, fmt.Sprint(v))
+		}
+	}
+
+	var keys []string
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if isReserved(k, reserved) {
+			continue
+		}
+		v, err := json.Marshal(e[k])
+		if err != nil {
+			v = []byte(fmt.Sprint(e[k]))
+		}
+		fields = append(fields, k+"="+string(v))
+	}
+	return strings.Join(fields, " ")
+}
+
+func isReserved(k string, reserved []string) bool {
+	for _, r := range reserved {
+		if k == r {
+			return true
+		}
+	}
+	return false
 }
@@ -24,6 +24,7 @@ type LsOutput struct {
 }
 
 var LsCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "List links from an object.",
 		ShortDescription: `
@@ -48,7 +49,7 @@ it contains, with the following format:
 
 		dagnodes := make([]*merkledag.Node, 0)
 		for _, path := range paths {
-			dagnode, err := node.Resolver.ResolvePath(path)
+			dagnode, err := node.Resolver.ResolvePath(req.Context().Context, path)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
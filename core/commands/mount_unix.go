@@ -24,6 +24,7 @@ const mountTimeout = time.Second
 const fuseNoDirectory = "fusermount: failed to access mountpoint"
 
 var MountCmd = &cmds.Command{
+	Unsafe: true,
 	Helptext: cmds.HelpText{
 		Tagline: "Mounts IPFS to the filesystem (read-only)",
 		Synopsis: `
@@ -8,6 +8,7 @@ import (
 )
 
 var MountCmd = &cmds.Command{
+	Unsafe: true,
 	Helptext: cmds.HelpText{
 		Tagline:          "Not yet implemented on Windows",
 		ShortDescription: "Not yet implemented on Windows. :(",
@@ -8,6 +8,7 @@ type IpnsEntry struct {
 }
 
 var NameCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "IPFS namespace (IPNS) tool",
 		Synopsis: `
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"strings"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	mh "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 
 	cmds "github.com/jbenet/go-ipfs/commands"
@@ -27,6 +28,7 @@ type Node struct {
 }
 
 var ObjectCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "Interact with ipfs objects",
 		ShortDescription: `
@@ -38,6 +40,7 @@ ipfs object put <data> <encoding> - Stores input, outputs its key
 ipfs object data <key>            - Outputs raw bytes in an object
 ipfs object links <key>           - Outputs links pointed to by object
 ipfs object stat <key>            - Outputs statistics of object
+ipfs object patch <key> <command> - Changes the object, outputs its new key
 `,
 	},
 
@@ -47,6 +50,7 @@ ipfs object stat <key>            - Outputs statistics of object
 		"get":   objectGetCmd,
 		"put":   objectPutCmd,
 		"stat":  objectStatCmd,
+		"patch": objectPatchCmd,
 	},
 }
 
@@ -79,7 +83,7 @@ output is the raw data of the object.
 		}
 
 		key := req.Arguments()[0]
-		output, err := objectData(n, key)
+		output, err := objectData(n, req.Context().Context, key)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -109,7 +113,7 @@ multihash.
 		}
 
 		key := req.Arguments()[0]
-		output, err := objectLinks(n, key)
+		output, err := objectLinks(n, req.Context().Context, key)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -158,7 +162,7 @@ This command outputs data in the following encodings:
 
 		key := req.Arguments()[0]
 
-		object, err := objectGet(n, key)
+		object, err := objectGet(n, req.Context().Context, key)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -224,7 +228,7 @@ var objectStatCmd = &cmds.Command{
 
 		key := req.Arguments()[0]
 
-		object, err := objectGet(n, key)
+		object, err := objectGet(n, req.Context().Context, key)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -259,6 +263,7 @@ var objectStatCmd = &cmds.Command{
 }
 
 var objectPutCmd = &cmds.Command{
+	Scope: cmds.ScopePin,
 	Helptext: cmds.HelpText{
 		Tagline: "Stores input as a DAG object, outputs its key",
 		ShortDescription: `
@@ -316,9 +321,64 @@ Data should be in the format specified by <encoding>.
 	Type: Object{},
 }
 
+var objectPatchCmd = &cmds.Command{
+	Scope: cmds.ScopePin,
+	Helptext: cmds.HelpText{
+		Tagline: "Creates a new DAG object from a change to another",
+		ShortDescription: `
+'ipfs object patch' is a plumbing command for changing DAG nodes. The
+object at <key> is left as it is: the changed object is stored as a new
+one, and the output is its base58 encoded multihash.
+`,
+		LongDescription: `
+'ipfs object patch' is a plumbing command for changing DAG nodes. The
+object at <key> is left as it is: the changed object is stored as a new
+one, and the output is its base58 encoded multihash.
+
+<command> is one of:
+	* "add-link <name> <ref>" adds a link to <ref>, replacing the link
+	  named <name> if there is one
+	* "rm-link <name>" removes the link named <name>
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("key", true, false, "The key of the object to change"),
+		cmds.StringArg("command", true, false, "The change to make, \"add-link\" or \"rm-link\""),
+		cmds.StringArg("args", false, true, "The arguments of the change"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		args := req.Arguments()
+		output, err := objectPatch(n, req.Context().Context, args[0], args[1], args[2:])
+		if err != nil {
+			errType := cmds.ErrNormal
+			if err == ErrPatchUsage {
+				errType = cmds.ErrClient
+			}
+			res.SetError(err, errType)
+			return
+		}
+
+		res.SetOutput(output)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			object := res.Output().(*Object)
+			return strings.NewReader(object.Hash + "\n"), nil
+		},
+	},
+	Type: Object{},
+}
+
 // objectData takes a key string and writes out the raw bytes of that node (if there is one)
-func objectData(n *core.IpfsNode, key string) (io.Reader, error) {
-	dagnode, err := n.Resolver.ResolvePath(key)
+func objectData(n *core.IpfsNode, ctx context.Context, key string) (io.Reader, error) {
+	dagnode, err := n.Resolver.ResolvePath(ctx, key)
 	if err != nil {
 		return nil, err
 	}
@@ -329,8 +389,8 @@ func objectData(n *core.IpfsNode, key string) (io.Reader, error) {
 }
 
 // objectLinks takes a key string and lists the links it points to
-func objectLinks(n *core.IpfsNode, key string) (*Object, error) {
-	dagnode, err := n.Resolver.ResolvePath(key)
+func objectLinks(n *core.IpfsNode, ctx context.Context, key string) (*Object, error) {
+	dagnode, err := n.Resolver.ResolvePath(ctx, key)
 	if err != nil {
 		return nil, err
 	}
@@ -341,8 +401,8 @@ func objectLinks(n *core.IpfsNode, key string) (*Object, error) {
 }
 
 // objectGet takes a key string from args and a format option and serializes the dagnode to that format
-func objectGet(n *core.IpfsNode, key string) (*dag.Node, error) {
-	dagnode, err := n.Resolver.ResolvePath(key)
+func objectGet(n *core.IpfsNode, ctx context.Context, key string) (*dag.Node, error) {
+	dagnode, err := n.Resolver.ResolvePath(ctx, key)
 	if err != nil {
 		return nil, err
 	}
@@ -401,6 +461,45 @@ func objectPut(n *core.IpfsNode, input io.Reader, encoding string) (*Object, err
 	return getOutput(dagnode)
 }
 
+// ErrPatchUsage is returned for patch commands that do not exist, or have
+// the wrong arguments
+var ErrPatchUsage = errors.New("usage: add-link <name> <ref>, or rm-link <name>")
+
+// objectPatch makes the change of command to the node at key, and adds the
+// changed node to the dag
+func objectPatch(n *core.IpfsNode, ctx context.Context, key string, command string, args []string) (*Object, error) {
+	dagnode, err := n.Resolver.ResolvePath(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	dagnode = dagnode.Copy()
+
+	switch {
+	case command == "add-link" && len(args) == 2:
+		child, err := n.Resolver.ResolvePath(ctx, args[1])
+		if err != nil {
+			return nil, err
+		}
+		dagnode.RemoveNodeLink(args[0]) // replaced, if there
+		if err := dagnode.AddNodeLinkClean(args[0], child); err != nil {
+			return nil, err
+		}
+
+	case command == "rm-link" && len(args) == 1:
+		if err := dagnode.RemoveNodeLink(args[0]); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, ErrPatchUsage
+	}
+
+	if err := addNode(n, dagnode); err != nil {
+		return nil, err
+	}
+	return getOutput(dagnode)
+}
+
 // ErrUnknownObjectEnc is returned if a invalid encoding is supplied
 var ErrUnknownObjectEnc = errors.New("unknown object encoding")
 
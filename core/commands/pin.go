@@ -11,6 +11,7 @@ import (
 )
 
 var PinCmd = &cmds.Command{
+	Scope: cmds.ScopePin,
 	Helptext: cmds.HelpText{
 		Tagline: "Pin (and unpin) objects to local storage",
 	},
@@ -59,7 +60,7 @@ on disk.
 			recursive = false
 		}
 
-		added, err := corerepo.Pin(n, req.Arguments(), recursive)
+		added, err := corerepo.Pin(n, req.Context().Context, req.Arguments(), recursive)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -124,7 +125,7 @@ collected if needed.
 			recursive = false // default
 		}
 
-		removed, err := corerepo.Unpin(n, req.Arguments(), recursive)
+		removed, err := corerepo.Unpin(n, req.Context().Context, req.Arguments(), recursive)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -149,6 +150,7 @@ collected if needed.
 }
 
 var listPinCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "List objects pinned to local storage",
 		ShortDescription: `
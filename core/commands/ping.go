@@ -26,6 +26,7 @@ type PingResult struct {
 }
 
 var PingCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "send echo request packets to IPFS hosts",
 		Synopsis: `
@@ -95,7 +96,7 @@ trip latency information.
 		}
 
 		if addr != nil {
-			n.Peerstore.AddAddress(peerID, addr)
+			n.Peerstore.AddAddress(peerID, addr, peer.TempAddrTTL)
 		}
 
 		// Set up number of pings
@@ -132,7 +133,7 @@ func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int)
 				outChan <- &PingResult{Text: fmt.Sprintf("Peer lookup error: %s", err)}
 				return
 			}
-			n.Peerstore.AddPeerInfo(p)
+			n.Peerstore.AddPeerInfo(p, peer.TempAddrTTL)
 		}
 
 		outChan <- &PingResult{Text: fmt.Sprintf("PING %s.", pid.Pretty())}
@@ -16,6 +16,7 @@ import (
 var errNotOnline = errors.New("This command must be run in online mode. Try running 'ipfs daemon' first.")
 
 var publishCmd = &cmds.Command{
+	Scope: cmds.ScopeName,
 	Helptext: cmds.HelpText{
 		Tagline: "Publish an object to IPNS",
 		ShortDescription: `
@@ -29,7 +29,34 @@ func KeyListTextMarshaler(res cmds.Response) (io.Reader, error) {
 	return &buf, nil
 }
 
+// RefWrapper is a ref in the output of refs, formatted as asked.
+type RefWrapper struct {
+	Ref string
+}
+
+// RefsTextMarshaler outputs refs as plaintext, one ref per line
+func RefsTextMarshaler(res cmds.Response) (io.Reader, error) {
+	outChan, ok := res.Output().(<-chan interface{})
+	if !ok {
+		return nil, u.ErrCast()
+	}
+
+	marshal := func(v interface{}) (io.Reader, error) {
+		obj, ok := v.(*RefWrapper)
+		if !ok {
+			return nil, u.ErrCast()
+		}
+		return strings.NewReader(obj.Ref + "\n"), nil
+	}
+
+	return &cmds.ChannelMarshaler{
+		Channel:   outChan,
+		Marshaler: marshal,
+	}, nil
+}
+
 var RefsCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "Lists links (references) from an object",
 		ShortDescription: `
@@ -85,20 +112,20 @@ Note: list all refs recursively with -r.
 			return
 		}
 
-		objs, err := objectsForPaths(n, req.Arguments())
+		objs, err := objectsForPaths(n, req.Context().Context, req.Arguments())
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		piper, pipew := io.Pipe()
-		eptr := &ErrPassThroughReader{R: piper}
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
 
 		go func() {
-			defer pipew.Close()
+			defer close(out)
 
 			rw := RefWriter{
-				W:         pipew,
+				Out:       out,
 				DAG:       n.DAG,
 				Ctx:       ctx,
 				Unique:    unique,
@@ -110,14 +137,16 @@ Note: list all refs recursively with -r.
 			for _, o := range objs {
 				if _, err := rw.WriteRefs(o); err != nil {
 					log.Error(err)
-					eptr.SetError(err)
+					res.SetError(err, cmds.ErrNormal)
 					return
 				}
 			}
 		}()
-
-		res.SetOutput(eptr)
 	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: RefsTextMarshaler,
+	},
+	Type: RefWrapper{},
 }
 
 var RefsLocalCmd = &cmds.Command{
@@ -143,30 +172,31 @@ Displays the hashes of all local objects.
 			return
 		}
 
-		piper, pipew := io.Pipe()
-		eptr := &ErrPassThroughReader{R: piper}
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
 
 		go func() {
-			defer pipew.Close()
+			defer close(out)
 
 			for k := range allKeys {
-				s := k.Pretty() + "\n"
-				if _, err := pipew.Write([]byte(s)); err != nil {
-					log.Error(err)
-					eptr.SetError(err)
+				select {
+				case out <- &RefWrapper{Ref: k.Pretty()}:
+				case <-ctx.Done():
 					return
 				}
 			}
 		}()
-
-		res.SetOutput(eptr)
 	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: RefsTextMarshaler,
+	},
+	Type: RefWrapper{},
 }
 
-func objectsForPaths(n *core.IpfsNode, paths []string) ([]*dag.Node, error) {
+func objectsForPaths(n *core.IpfsNode, ctx context.Context, paths []string) ([]*dag.Node, error) {
 	objects := make([]*dag.Node, len(paths))
 	for i, p := range paths {
-		o, err := n.Resolver.ResolvePath(p)
+		o, err := n.Resolver.ResolvePath(ctx, p)
 		if err != nil {
 			return nil, err
 		}
@@ -214,7 +244,7 @@ func (r *ErrPassThroughReader) Close() error {
 }
 
 type RefWriter struct {
-	W   io.Writer
+	Out chan interface{}
 	DAG dag.DAGService
 	Ctx context.Context
 
@@ -226,7 +256,7 @@ type RefWriter struct {
 	seen map[u.Key]struct{}
 }
 
-// WriteRefs writes refs of the given object to the underlying writer.
+// WriteRefs sends refs of the given object to the output channel.
 func (rw *RefWriter) WriteRefs(n *dag.Node) (int, error) {
 	nkey, err := n.Key()
 	if err != nil {
@@ -254,7 +284,7 @@ func (rw *RefWriter) WriteRefs(n *dag.Node) (int, error) {
 			continue
 		}
 
-		child, err := l.GetNode(rw.DAG)
+		child, err := l.GetNode(rw.Ctx, rw.DAG)
 		if err != nil {
 			return count, err
 		}
@@ -307,10 +337,15 @@ func (rw *RefWriter) WriteEdge(from, to u.Key, linkname string) error {
 	default:
 		s += to.Pretty()
 	}
-	s += "\n"
 
-	if _, err := rw.W.Write([]byte(s)); err != nil {
-		return err
+	if rw.Ctx == nil {
+		rw.Out <- &RefWrapper{Ref: s}
+		return nil
+	}
+	select {
+	case rw.Out <- &RefWrapper{Ref: s}:
+		return nil
+	case <-rw.Ctx.Done():
+		return rw.Ctx.Err()
 	}
-	return nil
 }
@@ -11,6 +11,7 @@ import (
 )
 
 var RepoCmd = &cmds.Command{
+	Scope: cmds.ScopePin,
 	Helptext: cmds.HelpText{
 		Tagline: "Manipulate the IPFS repo",
 		ShortDescription: `
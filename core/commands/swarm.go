@@ -6,8 +6,13 @@ import (
 	"io"
 	"path"
 	"sort"
+	"time"
 
 	cmds "github.com/jbenet/go-ipfs/commands"
+	core "github.com/jbenet/go-ipfs/core"
+	inet "github.com/jbenet/go-ipfs/p2p/net"
+	filter "github.com/jbenet/go-ipfs/p2p/net/filter"
+	swarm "github.com/jbenet/go-ipfs/p2p/net/swarm"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	errors "github.com/jbenet/go-ipfs/util/debugerror"
 
@@ -24,7 +29,10 @@ var SwarmCmd = &cmds.Command{
 		Tagline: "swarm inspection tool",
 		Synopsis: `
 ipfs swarm peers             - List peers with open connections
+ipfs swarm addrs             - List known addresses of peers
 ipfs swarm connect <address> - Open connection to a given peer
+ipfs swarm disconnect <address> - Close connection to a given peer
+ipfs swarm filters           - Manipulate address and peer filters
 `,
 		ShortDescription: `
 ipfs swarm is a tool to manipulate the network swarm. The swarm is the
@@ -33,18 +41,38 @@ ipfs peers in the internet.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"peers":   swarmPeersCmd,
-		"connect": swarmConnectCmd,
+		"peers":      swarmPeersCmd,
+		"addrs":      swarmAddrsCmd,
+		"connect":    swarmConnectCmd,
+		"disconnect": swarmDisconnectCmd,
+		"filters":    swarmFiltersCmd,
 	},
 }
 
 var swarmPeersCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline: "List peers with open connections",
 		ShortDescription: `
 ipfs swarm peers lists the set of peers this node is connected to.
+`,
+		LongDescription: `
+ipfs swarm peers lists the set of peers this node is connected to, one
+connection per line.
+
+With --verbose, each line also shows the peer's latency, whether we or
+they opened the connection, its age, its open streams, and the client
+the peer runs, as told by identify:
+
+  /ip4/1.2.3.4/tcp/4001/QmPeer  12ms  outbound  3m20s  2 streams  go-ipfs/0.3.0
+
+With --enc=json, they are fields of each of the Peers, with the latency
+and age in nanoseconds.
 `,
 	},
+	Options: []cmds.Option{
+		cmds.BoolOption("verbose", "v", "Show latency, direction, age, streams and agent of connections"),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
 		log.Debug("ipfs swarm peers")
@@ -59,21 +87,196 @@ ipfs swarm peers lists the set of peers this node is connected to.
 			return
 		}
 
+		verbose, _, err := req.Option("verbose").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		conns := n.PeerHost.Network().Conns()
-		addrs := make([]string, len(conns))
+		out := &connInfos{
+			Strings: make([]string, len(conns)),
+			Peers:   make([]connInfo, len(conns)),
+		}
 		for i, c := range conns {
-			pid := c.RemotePeer()
-			addr := c.RemoteMultiaddr()
-			addrs[i] = fmt.Sprintf("%s/%s", addr, pid.Pretty())
+			out.Peers[i] = connInfo{
+				Addr: c.RemoteMultiaddr().String(),
+				Peer: c.RemotePeer().Pretty(),
+			}
+			if verbose {
+				out.Peers[i].setDetails(n.Peerstore, c)
+			}
 		}
 
-		sort.Sort(sort.StringSlice(addrs))
-		res.SetOutput(&stringList{addrs})
+		sort.Sort(byAddr(out.Peers))
+		for i, c := range out.Peers {
+			out.Strings[i] = c.Addr + "/" + c.Peer
+		}
+		res.SetOutput(out)
 	},
 	Marshalers: cmds.MarshalerMap{
-		cmds.Text: stringListMarshaler,
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, ok := res.Output().(*connInfos)
+			if !ok {
+				return nil, errors.New("failed to cast connInfos")
+			}
+			verbose, _, _ := res.Request().Option("verbose").Bool()
+
+			var buf bytes.Buffer
+			for _, c := range list.Peers {
+				fmt.Fprintf(&buf, "%s/%s", c.Addr, c.Peer)
+				if verbose {
+					fmt.Fprintf(&buf, "  %s", c.details())
+				}
+				buf.WriteString("\n")
+			}
+			return &buf, nil
+		},
 	},
-	Type: stringList{},
+	Type: connInfos{},
+}
+
+// connInfos is the output of swarm peers. Strings lists the connections
+// as address/peer id, like older versions did.
+type connInfos struct {
+	Strings []string
+	Peers   []connInfo
+}
+
+// connInfo describes a connection to a peer. The fields after Peer are
+// only set with --verbose, and are zero where unknown.
+type connInfo struct {
+	Addr      string
+	Peer      string
+	Latency   time.Duration `json:",omitempty"`
+	Direction string        `json:",omitempty"`
+	Age       time.Duration `json:",omitempty"`
+	Streams   int           `json:",omitempty"`
+	Agent     string        `json:",omitempty"`
+}
+
+// setDetails sets the details of c, and of its remote peer.
+func (ci *connInfo) setDetails(ps peer.Peerstore, c inet.Conn) {
+	st := c.Stat()
+	ci.Latency = ps.LatencyEWMA(c.RemotePeer())
+	ci.Direction = st.Direction.String()
+	if !st.Opened.IsZero() {
+		ci.Age = time.Since(st.Opened)
+	}
+	ci.Streams = st.Streams
+	if v, found := ps.Version(c.RemotePeer()); found {
+		ci.Agent = v.AgentVersion
+	}
+}
+
+// details formats the details of the connection for reading, with "-"
+// for the unknown ones.
+func (ci *connInfo) details() string {
+	latency, age, agent := "-", "-", "-"
+	if ci.Latency > 0 {
+		latency = roundDuration(ci.Latency).String()
+	}
+	if ci.Age > 0 {
+		age = roundDuration(ci.Age).String()
+	}
+	if ci.Agent != "" {
+		agent = ci.Agent
+	}
+	return fmt.Sprintf("%s  %s  %s  %d streams  %s", latency, ci.Direction, age, ci.Streams, agent)
+}
+
+type byAddr []connInfo
+
+func (s byAddr) Len() int      { return len(s) }
+func (s byAddr) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byAddr) Less(i, j int) bool {
+	if s[i].Addr != s[j].Addr {
+		return s[i].Addr < s[j].Addr
+	}
+	return s[i].Peer < s[j].Peer
+}
+
+// roundDuration rounds d to a precision fit for reading.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d < time.Millisecond:
+		return d - d%time.Microsecond
+	case d < time.Second:
+		return d - d%time.Millisecond
+	default:
+		return d - d%time.Second
+	}
+}
+
+type addrMap struct {
+	Addrs map[string][]string
+}
+
+var swarmAddrsCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
+	Helptext: cmds.HelpText{
+		Tagline: "List known addresses of peers",
+		ShortDescription: `
+ipfs swarm addrs lists the addresses this node knows for each peer, whether
+or not it is connected to them. Addresses are forgotten after a while,
+unless we connect to the peer at them.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if n.PeerHost == nil {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		out := make(map[string][]string)
+		for _, p := range n.Peerstore.Peers() {
+			addrs := n.Peerstore.Addresses(p)
+			if len(addrs) == 0 {
+				continue
+			}
+			s := make([]string, len(addrs))
+			for i, a := range addrs {
+				s[i] = a.String()
+			}
+			sort.Strings(s)
+			out[p.Pretty()] = s
+		}
+
+		res.SetOutput(&addrMap{Addrs: out})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			m, ok := res.Output().(*addrMap)
+			if !ok {
+				return nil, errors.New("failed to cast map[string][]string")
+			}
+
+			// sort the ids first
+			ids := make([]string, 0, len(m.Addrs))
+			for p := range m.Addrs {
+				ids = append(ids, p)
+			}
+			sort.Strings(ids)
+
+			var buf bytes.Buffer
+			for _, p := range ids {
+				paddrs := m.Addrs[p]
+				fmt.Fprintf(&buf, "%s (%d)\n", p, len(paddrs))
+				for _, addr := range paddrs {
+					buf.WriteString("\t" + addr + "\n")
+				}
+			}
+			return &buf, nil
+		},
+	},
+	Type: addrMap{},
 }
 
 var swarmConnectCmd = &cmds.Command{
@@ -132,6 +335,271 @@ ipfs swarm connect /ip4/104.131.131.82/tcp/4001/QmaCpDMGvV2BGHeYERUEnRQAwe3N8Szb
 	Type: stringList{},
 }
 
+var swarmDisconnectCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Close connection to a given peer",
+		ShortDescription: `
+'ipfs swarm disconnect' closes the connections to a peer address. The address
+format is an ipfs multiaddr:
+
+ipfs swarm disconnect /ip4/104.131.131.82/tcp/4001/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+
+The peer may connect again, and so may this node, when it needs the peer.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("address", true, true, "address of peer to disconnect from").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if n.PeerHost == nil {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		maddrs, pids, err := splitAddresses(req.Arguments())
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		output := make([]string, len(pids))
+		for i, p := range pids {
+			output[i] = "disconnect " + p.Pretty()
+
+			var conn inet.Conn
+			for _, c := range n.PeerHost.Network().ConnsToPeer(p) {
+				if c.RemoteMultiaddr().Equal(maddrs[i]) {
+					conn = c
+					break
+				}
+			}
+			if conn == nil {
+				output[i] += " failure: not connected at " + maddrs[i].String()
+				continue
+			}
+
+			// only the connection at that address; others to p stay open.
+			if err := conn.Close(); err != nil {
+				output[i] += " failure: " + err.Error()
+			} else {
+				output[i] += " success"
+			}
+		}
+
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+var swarmFiltersCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
+	Helptext: cmds.HelpText{
+		Tagline: "Manipulate address and peer filters",
+		Synopsis: `
+ipfs swarm filters ls               - List the filters
+ipfs swarm filters add <filter>...  - Add filters
+ipfs swarm filters rm <filter>...   - Remove filters
+`,
+		ShortDescription: `
+'ipfs swarm filters' lists the filters of the peers and addresses the node
+connects to. Filters match peers, like /ipfs/<peer-id>, or address ranges,
+like /ip4/10.0.0.0/ipcidr/8. The node neither dials nor accepts connections
+from denied peers and addresses. In allowlist-only mode, set with
+Swarm.Filters.AllowlistOnly in the config, it only connects to allowed ones.
+
+Changes last until the daemon stops. To keep them, edit Swarm.Filters in
+the config.
+`,
+	},
+	Run:        swarmFiltersLsCmd.Run,
+	Marshalers: swarmFiltersLsCmd.Marshalers,
+	Type:       swarmFiltersLsCmd.Type,
+	Subcommands: map[string]*cmds.Command{
+		"ls":  swarmFiltersLsCmd,
+		"add": swarmFiltersAddCmd,
+		"rm":  swarmFiltersRmCmd,
+	},
+}
+
+var swarmFiltersLsCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
+	Helptext: cmds.HelpText{
+		Tagline: "List the address and peer filters",
+		ShortDescription: `
+'ipfs swarm filters ls' lists the filters, each after its action: allow or
+deny.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		fs, err := swarmFilters(n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var output []string
+		for _, e := range fs.Entries() {
+			output = append(output, e.Action.String()+" "+e.Filter.String())
+		}
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+var swarmFiltersAddCmd = &cmds.Command{
+	Scope: cmds.ScopeAdmin,
+	Helptext: cmds.HelpText{
+		Tagline: "Add address or peer filters",
+		ShortDescription: `
+'ipfs swarm filters add' denies the given peers and addresses, and closes
+the connections to them. With --allow, it allows them instead:
+
+ipfs swarm filters add /ip4/192.168.0.0/ipcidr/16
+ipfs swarm filters add --allow /ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("filter", true, true, "filter to add").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("allow", "Allow the filtered peers and addresses, instead of denying them."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		fs, err := swarmFilters(n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		allow, _, err := req.Option("allow").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		action := filter.Deny
+		if allow {
+			action = filter.Allow
+		}
+
+		filters, err := parseFilters(req.Arguments())
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		output := make([]string, len(filters))
+		for i, f := range filters {
+			fs.Add(f, action)
+			output[i] = "add " + action.String() + " " + f.String()
+		}
+
+		// the connections open already were not filtered.
+		net := n.PeerHost.Network()
+		for _, c := range net.Conns() {
+			if fs.ConnBlocked(c.RemotePeer(), c.RemoteMultiaddr()) {
+				net.ClosePeer(c.RemotePeer())
+			}
+		}
+
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+var swarmFiltersRmCmd = &cmds.Command{
+	Scope: cmds.ScopeAdmin,
+	Helptext: cmds.HelpText{
+		Tagline: "Remove address or peer filters",
+		ShortDescription: `
+'ipfs swarm filters rm' removes the given filters, whether they allow or
+deny.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("filter", true, true, "filter to remove").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		fs, err := swarmFilters(n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		filters, err := parseFilters(req.Arguments())
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		output := make([]string, len(filters))
+		for i, f := range filters {
+			output[i] = "rm " + f.String()
+			if !fs.Remove(f) {
+				output[i] += " failure: no such filter"
+			}
+		}
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+// swarmFilters returns the filters of the node's swarm.
+func swarmFilters(n *core.IpfsNode) (*filter.Filters, error) {
+	if n.PeerHost == nil {
+		return nil, errNotOnline
+	}
+	snet, ok := n.PeerHost.Network().(*swarm.Network)
+	if !ok {
+		return nil, errors.New("the network of this node has no filters")
+	}
+	return snet.Swarm().Filters(), nil
+}
+
+func parseFilters(args []string) ([]filter.Filter, error) {
+	filters := make([]filter.Filter, len(args))
+	for i, s := range args {
+		f, err := filter.ParseFilter(s)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = f
+	}
+	return filters, nil
+}
+
 func stringListMarshaler(res cmds.Response) (io.Reader, error) {
 	list, ok := res.Output().(*stringList)
 	if !ok {
@@ -176,7 +644,7 @@ func peersWithAddresses(ps peer.Peerstore, addrs []string) ([]peer.ID, error) {
 	}
 
 	for i, p := range pids {
-		ps.AddAddress(p, maddrs[i])
+		ps.AddAddress(p, maddrs[i], peer.TempAddrTTL)
 	}
 	return pids, nil
 }
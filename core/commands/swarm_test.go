@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	cmds "github.com/jbenet/go-ipfs/commands"
+)
+
+func TestSwarmFiltersScopes(t *testing.T) {
+	cases := []struct {
+		path  []string
+		scope string
+	}{
+		{[]string{"swarm", "filters"}, cmds.ScopeRead},
+		{[]string{"swarm", "filters", "ls"}, cmds.ScopeRead},
+		{[]string{"swarm", "filters", "add"}, cmds.ScopeAdmin},
+		{[]string{"swarm", "filters", "rm"}, cmds.ScopeAdmin},
+	}
+	for _, c := range cases {
+		scope, _, err := Root.Access(c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scope != c.scope {
+			t.Errorf("%v: got scope %q, expected %q", c.path, scope, c.scope)
+		}
+	}
+}
+
+func TestSwarmPeersMarshaler(t *testing.T) {
+	out := &connInfos{
+		Strings: []string{"/ip4/1.2.3.4/tcp/4001/QmA"},
+		Peers: []connInfo{{
+			Addr:      "/ip4/1.2.3.4/tcp/4001",
+			Peer:      "QmA",
+			Latency:   12345678 * time.Nanosecond,
+			Direction: "outbound",
+			Streams:   2,
+		}},
+	}
+
+	for verbose, expected := range map[bool]string{
+		false: "/ip4/1.2.3.4/tcp/4001/QmA\n",
+		true:  "/ip4/1.2.3.4/tcp/4001/QmA  12ms  outbound  -  2 streams  -\n",
+	} {
+		opts, err := swarmPeersCmd.GetOptions(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := cmds.NewRequest([]string{"swarm", "peers"}, nil, nil, nil, swarmPeersCmd, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetOption("verbose", verbose)
+		res := cmds.NewResponse(req)
+		res.SetOutput(out)
+
+		r, err := swarmPeersCmd.Marshalers[cmds.Text](res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != expected {
+			t.Errorf("verbose %v: got %q, expected %q", verbose, got, expected)
+		}
+	}
+}
@@ -17,6 +17,7 @@ type UpdateOutput struct {
 }
 
 var UpdateCmd = &cmds.Command{
+	Unsafe: true,
 	Helptext: cmds.HelpText{
 		Tagline:          "Downloads and installs updates for IPFS",
 		ShortDescription: "ipfs update is a utility command used to check for updates and apply them.",
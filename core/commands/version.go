@@ -14,6 +14,7 @@ type VersionOutput struct {
 }
 
 var VersionCmd = &cmds.Command{
+	Scope: cmds.ScopeRead,
 	Helptext: cmds.HelpText{
 		Tagline:          "Shows ipfs version information",
 		ShortDescription: "Returns the current version of ipfs and exits.",
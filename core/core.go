@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"time"
@@ -10,21 +11,30 @@ import (
 	ctxgroup "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-ctxgroup"
 	datastore "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
 
 	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
 	debugerror "github.com/jbenet/go-ipfs/util/debugerror"
 
 	diag "github.com/jbenet/go-ipfs/diagnostics"
 	ic "github.com/jbenet/go-ipfs/p2p/crypto"
+	discovery "github.com/jbenet/go-ipfs/p2p/discovery"
 	p2phost "github.com/jbenet/go-ipfs/p2p/host"
 	p2pbhost "github.com/jbenet/go-ipfs/p2p/host/basic"
+	connmgr "github.com/jbenet/go-ipfs/p2p/net/connmgr"
+	filter "github.com/jbenet/go-ipfs/p2p/net/filter"
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
 	swarm "github.com/jbenet/go-ipfs/p2p/net/swarm"
 	addrutil "github.com/jbenet/go-ipfs/p2p/net/swarm/addr"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	relay "github.com/jbenet/go-ipfs/p2p/protocol/relay"
 
 	routing "github.com/jbenet/go-ipfs/routing"
+	delegated "github.com/jbenet/go-ipfs/routing/delegated"
 	dht "github.com/jbenet/go-ipfs/routing/dht"
 	offroute "github.com/jbenet/go-ipfs/routing/offline"
+	record "github.com/jbenet/go-ipfs/routing/record"
+	tiered "github.com/jbenet/go-ipfs/routing/tiered"
 
 	bstore "github.com/jbenet/go-ipfs/blocks/blockstore"
 	bserv "github.com/jbenet/go-ipfs/blockservice"
@@ -46,6 +56,8 @@ import (
 const IpnsValidatorTag = "ipns"
 const kSizeBlockstoreWriteCache = 100
 const kReprovideFrequency = time.Hour * 12
+const kDefaultMDNSInterval = time.Second * 10
+const discoveryConnTimeout = time.Second * 30
 
 var log = eventlog.Logger("core")
 
@@ -86,6 +98,11 @@ type IpfsNode struct {
 	Namesys      namesys.NameSystem  // the name system, resolves paths to hashes
 	Diagnostics  *diag.Diagnostics   // the diagnostics service
 	Reprovider   *rp.Reprovider      // the value reprovider system
+	Discovery    discovery.Service   // the local network discovery service
+
+	// PNetFingerprint identifies the private network the node is in, if it
+	// is not in the public one.
+	PNetFingerprint []byte
 
 	ctxgroup.ContextGroup
 
@@ -177,8 +194,11 @@ func Standard(r repo.Repo, online bool) ConfigOption {
 			Repo: r,
 		}
 
-		// setup Peerstore
-		n.Peerstore = peer.NewPeerstore()
+		// setup Peerstore, with the peers we knew before.
+		n.Peerstore, err = peer.NewPeerstoreDatastore(n.Repo.Datastore())
+		if err != nil {
+			return nil, debugerror.Wrap(err)
+		}
 
 		// setup local peer ID (private key is loaded in online setup)
 		if err := n.loadID(); err != nil {
@@ -214,7 +234,12 @@ func (n *IpfsNode) StartOnlineServices(ctx context.Context) error {
 		return err
 	}
 
-	peerhost, err := constructPeerHost(ctx, n.Repo.Config(), n.Identity, n.Peerstore)
+	protec, err := n.loadSwarmKey()
+	if err != nil {
+		return err
+	}
+
+	peerhost, err := constructPeerHost(ctx, n.Repo.Config(), n.Identity, n.Peerstore, protec)
 	if err != nil {
 		return debugerror.Wrap(err)
 	}
@@ -224,11 +249,12 @@ func (n *IpfsNode) StartOnlineServices(ctx context.Context) error {
 	n.Diagnostics = diag.NewDiagnostics(n.Identity, n.PeerHost)
 
 	// setup routing service
-	dhtRouting, err := constructDHTRouting(ctx, n.PeerHost, n.Repo.Datastore())
+	cfg := n.Repo.Config()
+	r, err := constructRouting(ctx, cfg, cfg.Routing.Type, n.PeerHost, n.Repo.Datastore())
 	if err != nil {
 		return debugerror.Wrap(err)
 	}
-	n.Routing = dhtRouting
+	n.Routing = r
 
 	// setup exchange service
 	const alwaysSendToPeer = true // use YesManStrategy
@@ -242,9 +268,40 @@ func (n *IpfsNode) StartOnlineServices(ctx context.Context) error {
 	n.Reprovider = rp.NewReprovider(n.Routing, n.Blockstore)
 	go n.Reprovider.ProvideEvery(ctx, kReprovideFrequency)
 
+	// setup local discovery
+	if cfg.Discovery.MDNS.Enabled {
+		n.startMDNS(time.Second * time.Duration(cfg.Discovery.MDNS.Interval))
+	}
+
 	return n.Bootstrap(DefaultBootstrapConfig)
 }
 
+// startMDNS starts finding peers on the local network. Failing to do so is
+// not fatal: the node can still find peers through bootstrap and routing.
+func (n *IpfsNode) startMDNS(interval time.Duration) {
+	if interval <= 0 {
+		interval = kDefaultMDNSInterval
+	}
+
+	service, err := discovery.NewMdnsService(n.PeerHost, interval)
+	if err != nil {
+		log.Errorf("mdns discovery failed to start: %s", err)
+		return
+	}
+	service.RegisterNotifee(n)
+	n.Discovery = service
+}
+
+// HandlePeerFound connects to peers found by the discovery service.
+func (n *IpfsNode) HandlePeerFound(p peer.PeerInfo) {
+	log.Infof("discovered peer: %s", p.ID)
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryConnTimeout)
+	defer cancel()
+	if err := n.PeerHost.Connect(ctx, p); err != nil {
+		log.Warningf("failed to connect to discovered peer %s: %s", p.ID, err)
+	}
+}
+
 // teardown closes owned children. If any errors occur, this function returns
 // the first error.
 func (n *IpfsNode) teardown() error {
@@ -258,10 +315,16 @@ func (n *IpfsNode) teardown() error {
 	}
 
 	addCloser(n.Bootstrapper)
+	addCloser(n.Discovery)
 	addCloser(n.Repo)
 	addCloser(n.Blocks)
-	if dht, ok := n.Routing.(*dht.IpfsDHT); ok {
-		addCloser(dht)
+	if r, ok := n.Routing.(io.Closer); ok {
+		addCloser(r)
+	}
+	if n.PeerHost != nil {
+		if cm, ok := n.PeerHost.ConnManager().(io.Closer); ok {
+			addCloser(cm)
+		}
 	}
 	addCloser(n.PeerHost)
 
@@ -286,8 +349,22 @@ func (n *IpfsNode) OnlineMode() bool {
 	}
 }
 
-func (n *IpfsNode) Resolve(path string) (*merkledag.Node, error) {
-	return n.Resolver.ResolvePath(path)
+// Resolve returns the node at path, fetching the nodes until ctx is done.
+func (n *IpfsNode) Resolve(ctx context.Context, path string) (*merkledag.Node, error) {
+	return n.Resolver.ResolvePath(ctx, path)
+}
+
+// DHT returns the dht the node routes with, on its own or as one of the
+// tiered routers, or nil if it does not use one.
+func (n *IpfsNode) DHT() *dht.IpfsDHT {
+	switch r := n.Routing.(type) {
+	case *dht.IpfsDHT:
+		return r
+	case *tiered.Tiered:
+		return r.DHT()
+	default:
+		return nil
+	}
 }
 
 func (n *IpfsNode) Bootstrap(cfg BootstrapConfig) error {
@@ -306,6 +383,9 @@ func (n *IpfsNode) Bootstrap(cfg BootstrapConfig) error {
 	if cfg.BootstrapPeers == nil {
 		cfg.BootstrapPeers = func() []peer.PeerInfo {
 			bpeers := n.Repo.Config().Bootstrap
+			if n.PNetFingerprint != nil && n.Repo.Config().Swarm.PrivateNetwork.DisablePublicBootstrap {
+				bpeers = withoutPublicBootstrap(bpeers)
+			}
 			ps, err := toPeerInfos(bpeers)
 			if err != nil {
 				log.Error("failed to parse bootstrap peers from config: %s", bpeers)
@@ -357,6 +437,24 @@ func (n *IpfsNode) loadPrivateKey() error {
 	return nil
 }
 
+// loadSwarmKey returns the protector of the private network in the repo's
+// swarm.key, or nil if there is none.
+func (n *IpfsNode) loadSwarmKey() (pnet.Protector, error) {
+	b, err := n.Repo.SwarmKey()
+	if err != nil || b == nil {
+		return nil, err
+	}
+	psk, err := pnet.DecodeV1PSK(bytes.NewReader(b))
+	if err != nil {
+		return nil, debugerror.Errorf("failed to read swarm key: %s", err)
+	}
+
+	protec := pnet.NewProtector(psk)
+	n.PNetFingerprint = protec.Fingerprint()
+	log.Infof("swarm key found, joining private network %x", n.PNetFingerprint)
+	return protec, nil
+}
+
 // SetupOfflineRouting loads the local nodes private key and
 // uses it to instantiate a routing system in offline mode.
 // This is primarily used for offline ipns modifications.
@@ -404,7 +502,7 @@ func listenAddresses(cfg *config.Config) ([]ma.Multiaddr, error) {
 }
 
 // isolates the complex initialization steps
-func constructPeerHost(ctx context.Context, cfg *config.Config, id peer.ID, ps peer.Peerstore) (p2phost.Host, error) {
+func constructPeerHost(ctx context.Context, cfg *config.Config, id peer.ID, ps peer.Peerstore, protec pnet.Protector) (p2phost.Host, error) {
 	listenAddrs, err := listenAddresses(cfg)
 	if err != nil {
 		return nil, debugerror.Wrap(err)
@@ -418,12 +516,47 @@ func constructPeerHost(ctx context.Context, cfg *config.Config, id peer.ID, ps p
 		return nil, debugerror.Errorf("addresses in config not usable: %s", listenAddrs)
 	}
 
-	network, err := swarm.NewNetwork(ctx, filteredAddrs, id, ps)
+	// listen only once the filters are set up, so they apply to all
+	// connections accepted.
+	network, err := swarm.NewNetworkWithProtector(ctx, nil, id, ps, protec)
 	if err != nil {
 		return nil, debugerror.Wrap(err)
 	}
+	if err := setupSwarmFilters(network.Swarm().Filters(), cfg.Swarm.Filters); err != nil {
+		network.Close()
+		return nil, debugerror.Wrap(err)
+	}
+	network.Swarm().SetDialTLS(cfg.Swarm.DialTLS)
+	if err := network.Swarm().Listen(filteredAddrs...); err != nil {
+		network.Close()
+		return nil, debugerror.Wrap(err)
+	}
+
+	var hostOpts []p2pbhost.Option
+	if !cfg.Swarm.DisableNatPortMap {
+		hostOpts = append(hostOpts, p2pbhost.NATPortMap)
+	}
+
+	peerhost := p2pbhost.New(network, hostOpts...)
+	if cm := cfg.Swarm.ConnMgr; cm.HighWater > 0 {
+		grace := connmgr.DefaultGracePeriod
+		if cm.GracePeriod > 0 {
+			grace = time.Duration(cm.GracePeriod) * time.Second
+		}
+		peerhost.SetConnManager(connmgr.NewConnManager(network, cm.LowWater, cm.HighWater, grace))
+	}
+	if cfg.Swarm.EnableRelayHop {
+		limits := relay.DefaultLimits
+		if l := cfg.Swarm.RelayLimits; l.MaxCircuits > 0 || l.MaxCircuitsPerPeer > 0 || l.MaxBandwidth > 0 {
+			limits = relay.Limits{
+				MaxCircuits:        l.MaxCircuits,
+				MaxCircuitsPerPeer: l.MaxCircuitsPerPeer,
+				MaxBandwidth:       l.MaxBandwidth,
+			}
+		}
+		peerhost.Relay().EnableHop(limits)
+	}
 
-	peerhost := p2pbhost.New(network)
 	// explicitly set these as our listen addrs.
 	// (why not do it inside inet.NewNetwork? because this way we can
 	// listen on addresses without necessarily advertising those publicly.)
@@ -432,12 +565,98 @@ func constructPeerHost(ctx context.Context, cfg *config.Config, id peer.ID, ps p
 		return nil, debugerror.Wrap(err)
 	}
 	log.Info("Swarm listening at: %s", addrs)
-	ps.AddAddresses(id, addrs)
+	ps.AddAddresses(id, addrs, peer.PermanentAddrTTL)
 	return peerhost, nil
 }
 
-func constructDHTRouting(ctx context.Context, host p2phost.Host, ds datastore.ThreadSafeDatastore) (*dht.IpfsDHT, error) {
-	dhtRouting := dht.NewDHT(ctx, host, ds)
+func setupSwarmFilters(fs *filter.Filters, cfg config.SwarmFilters) error {
+	for _, a := range []struct {
+		filters []string
+		action  filter.Action
+	}{
+		{cfg.Allow, filter.Allow},
+		{cfg.Deny, filter.Deny},
+	} {
+		for _, s := range a.filters {
+			f, err := filter.ParseFilter(s)
+			if err != nil {
+				return fmt.Errorf("failure to parse config.Swarm.Filters: %s", err)
+			}
+			fs.Add(f, a.action)
+		}
+	}
+	fs.SetAllowlistOnly(cfg.AllowlistOnly)
+	return nil
+}
+
+func constructRouting(ctx context.Context, cfg *config.Config, typ string, host p2phost.Host, ds datastore.ThreadSafeDatastore) (routing.IpfsRouting, error) {
+	switch typ {
+	case "", config.RoutingTypeDHT:
+		return constructDHTRouting(ctx, host, ds, false)
+	case config.RoutingTypeDHTClient:
+		return constructDHTRouting(ctx, host, ds, true)
+	case config.RoutingTypeDelegated:
+		return constructDelegatedRouting(cfg, host)
+	case config.RoutingTypeTiered:
+		return constructTieredRouting(ctx, cfg, host, ds)
+	default:
+		return nil, debugerror.Errorf("unknown routing type in config: %q", typ)
+	}
+}
+
+func constructTieredRouting(ctx context.Context, cfg *config.Config, host p2phost.Host, ds datastore.ThreadSafeDatastore) (routing.IpfsRouting, error) {
+	var routers []routing.IpfsRouting
+	hasDHT := false
+	for _, typ := range cfg.Routing.Routers {
+		switch typ {
+		case config.RoutingTypeTiered:
+			return nil, debugerror.New("config.Routing.Routers cannot contain tiered routing")
+		case "", config.RoutingTypeDHT, config.RoutingTypeDHTClient:
+			// both would answer the same dht protocol.
+			if hasDHT {
+				return nil, debugerror.New("config.Routing.Routers can contain only one dht")
+			}
+			hasDHT = true
+		}
+
+		r, err := constructRouting(ctx, cfg, typ, host, ds)
+		if err != nil {
+			return nil, err
+		}
+		routers = append(routers, r)
+	}
+
+	return tiered.New(recordValidator(), routers...)
+}
+
+// recordValidator returns the validator of the values routers find, as
+// the dht checks them.
+func recordValidator() record.Validator {
+	return record.Validator{
+		"pk":             record.ValidatePublicKeyRecord,
+		IpnsValidatorTag: namesys.ValidateIpnsRecord,
+	}
+}
+
+func constructDHTRouting(ctx context.Context, host p2phost.Host, ds datastore.ThreadSafeDatastore, clientOnly bool) (*dht.IpfsDHT, error) {
+	var dhtRouting *dht.IpfsDHT
+	if clientOnly {
+		dhtRouting = dht.NewDHTClient(ctx, host, ds)
+	} else {
+		dhtRouting = dht.NewDHT(ctx, host, ds)
+	}
 	dhtRouting.Validator[IpnsValidatorTag] = namesys.ValidateIpnsRecord
 	return dhtRouting, nil
 }
+
+func constructDelegatedRouting(cfg *config.Config, host p2phost.Host) (routing.IpfsRouting, error) {
+	maddr, err := ma.NewMultiaddr(cfg.Routing.DelegateAddress)
+	if err != nil {
+		return nil, debugerror.Errorf("invalid config.Routing.DelegateAddress %q: %s", cfg.Routing.DelegateAddress, err)
+	}
+	_, addr, err := manet.DialArgs(maddr)
+	if err != nil {
+		return nil, debugerror.Wrap(err)
+	}
+	return delegated.NewClient(addr, cfg.Routing.DelegateToken, host.ID(), host.Peerstore(), recordValidator()), nil
+}
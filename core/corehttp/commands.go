@@ -4,10 +4,14 @@ import (
 	"net/http"
 	"os"
 
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+
 	commands "github.com/jbenet/go-ipfs/commands"
 	cmdsHttp "github.com/jbenet/go-ipfs/commands/http"
 	core "github.com/jbenet/go-ipfs/core"
 	corecommands "github.com/jbenet/go-ipfs/core/commands"
+	config "github.com/jbenet/go-ipfs/repo/config"
 )
 
 const (
@@ -17,9 +21,57 @@ const (
 
 func CommandsOption(cctx commands.Context) ServeOption {
 	return func(n *core.IpfsNode, mux *http.ServeMux) error {
-		origin := os.Getenv(originEnvKey)
-		cmdHandler := cmdsHttp.NewHandler(cctx, corecommands.Root, origin)
+		cfg := n.Repo.Config()
+		sc := serverConfig(cfg, cfg.Addresses.API)
+		cmdHandler := cmdsHttp.NewHandler(cctx, corecommands.Root, sc)
 		mux.Handle(cmdsHttp.ApiPath+"/", cmdHandler)
 		return nil
 	}
 }
+
+// apiHeaders returns the headers of the API, with the origin in
+// originEnvKey allowed too.
+func apiHeaders(headers map[string][]string) map[string][]string {
+	origin := os.Getenv(originEnvKey)
+	if origin == "" {
+		return headers
+	}
+
+	h := make(map[string][]string, len(headers)+1)
+	for k, v := range headers {
+		h[http.CanonicalHeaderKey(k)] = v
+	}
+	const allowOrigin = "Access-Control-Allow-Origin"
+	h[allowOrigin] = append(append([]string(nil), h[allowOrigin]...), origin)
+	return h
+}
+
+// serverConfig returns the configuration of the API's checks of its
+// clients, for a server listening at addr.
+func serverConfig(cfg *config.Config, addr string) *cmdsHttp.ServerConfig {
+	sc := &cmdsHttp.ServerConfig{Headers: apiHeaders(cfg.API.HTTPHeaders)}
+	for _, t := range cfg.API.Tokens {
+		sc.Tokens = append(sc.Tokens, cmdsHttp.Token{Name: t.Name, Secret: t.Secret, Scopes: t.Scopes})
+	}
+	if host, ok := apiHost(addr); ok {
+		sc.Hosts = append(sc.Hosts, host)
+	}
+	sc.Hosts = append(sc.Hosts, cfg.API.Hosts...)
+	return sc
+}
+
+// apiHosts returns the hosts the API answers to, besides the local ones.
+func apiHosts(cfg *config.Config) []string {
+	return serverConfig(cfg, cfg.Addresses.API).Hosts
+}
+
+// apiHost returns the host:port of the API address, for the API to answer
+// requests to it.
+func apiHost(addr string) (string, bool) {
+	maddr, err := ma.NewMultiaddr(addr)
+	if err != nil {
+		return "", false
+	}
+	_, host, err := manet.DialArgs(maddr)
+	return host, err == nil
+}
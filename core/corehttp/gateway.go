@@ -3,6 +3,7 @@ package corehttp
 import (
 	"net/http"
 
+	cmdsHttp "github.com/jbenet/go-ipfs/commands/http"
 	core "github.com/jbenet/go-ipfs/core"
 )
 
@@ -11,6 +12,7 @@ func GatewayOption(n *core.IpfsNode, mux *http.ServeMux) error {
 	if err != nil {
 		return err
 	}
-	mux.Handle("/ipfs/", gateway)
+	headers := n.Repo.Config().Gateway.HTTPHeaders
+	mux.Handle("/ipfs/", cmdsHttp.CORSHandler(headers, instrumentGateway(gateway)))
 	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"mime"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	mh "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
@@ -20,12 +21,15 @@ import (
 )
 
 type gateway interface {
-	ResolvePath(string) (*dag.Node, error)
+	ResolvePath(context.Context, string) (*dag.Node, error)
 	NewDagFromReader(io.Reader) (*dag.Node, error)
 	AddNodeToDAG(nd *dag.Node) (u.Key, error)
 	NewDagReader(nd *dag.Node) (io.Reader, error)
 }
 
+// resolveTimeout bounds the resolving of the path of a request.
+const resolveTimeout = time.Minute
+
 // shortcut for templating
 type webHandler map[string]interface{}
 
@@ -63,8 +67,8 @@ func (i *gatewayHandler) loadTemplate() error {
 	return nil
 }
 
-func (i *gatewayHandler) ResolvePath(path string) (*dag.Node, error) {
-	return i.node.Resolver.ResolvePath(path)
+func (i *gatewayHandler) ResolvePath(ctx context.Context, path string) (*dag.Node, error) {
+	return i.node.Resolver.ResolvePath(ctx, path)
 }
 
 func (i *gatewayHandler) NewDagFromReader(r io.Reader) (*dag.Node, error) {
@@ -83,7 +87,19 @@ func (i *gatewayHandler) NewDagReader(nd *dag.Node) (io.Reader, error) {
 func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path[5:]
 
-	nd, err := i.ResolvePath(path)
+	ctx, cancel := context.WithTimeout(i.node.Context(), resolveTimeout)
+	defer cancel()
+	if cn, ok := w.(http.CloseNotifier); ok {
+		go func() {
+			select {
+			case <-cn.CloseNotify():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	nd, err := i.ResolvePath(ctx, path)
 	if err != nil {
 		if err == routing.ErrNotFound {
 			w.WriteHeader(http.StatusNotFound)
@@ -135,7 +151,7 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			log.Debug("found index")
 			foundIndex = true
 			// return index page instead.
-			nd, err := i.ResolvePath(path + "/index.html")
+			nd, err := i.ResolvePath(ctx, path+"/index.html")
 			if err != nil {
 				internalWebError(w, err)
 				return
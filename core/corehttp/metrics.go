@@ -0,0 +1,198 @@
+package corehttp
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
+	core "github.com/jbenet/go-ipfs/core"
+	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+	metrics "github.com/jbenet/go-ipfs/thirdparty/metrics"
+)
+
+// MetricsPath is where MetricsOption serves the metrics.
+const MetricsPath = "/debug/metrics"
+
+// diskUsageInterval is how often the disk usage of the datastore is
+// measured, as it stats all of its files.
+const diskUsageInterval = time.Minute
+
+var (
+	gatewayDurations = metrics.NewHistogramVec("ipfs_gateway_request_duration_seconds",
+		"Durations of the gateway requests, by status.", metrics.DefaultBuckets, "status")
+
+	dhtDurations = metrics.NewHistogramVec("ipfs_dht_query_duration_seconds",
+		"Durations of the DHT queries and of the requests of peers, by operation.", metrics.DefaultBuckets, "op")
+	dhtMessages = metrics.NewCounterVec("ipfs_dht_messages_total",
+		"DHT messages, by direction.", "direction")
+)
+
+// eventCollector runs collectEventMetrics while there are nodes serving
+// the metrics, once whichever their number.
+var eventCollector struct {
+	sync.Mutex
+	nodes  int
+	cancel context.CancelFunc
+}
+
+// MetricsOption serves the metrics of the node and of its subsystems in
+// the Prometheus text format at MetricsPath, to local clients, if enabled
+// with API.Metrics in the config.
+func MetricsOption(n *core.IpfsNode, mux *http.ServeMux) error {
+	cfg := n.Repo.Config()
+	if !cfg.API.Metrics {
+		return nil
+	}
+
+	metrics.Default.GaugeFunc("ipfs_swarm_connections", "Connections to peers.", func() float64 {
+		if n.PeerHost == nil {
+			return 0
+		}
+		return float64(len(n.PeerHost.Network().Conns()))
+	})
+	metrics.Default.GaugeFunc("ipfs_swarm_peers", "Peers connected to.", func() float64 {
+		if n.PeerHost == nil {
+			return 0
+		}
+		return float64(len(n.PeerHost.Network().Peers()))
+	})
+
+	if du, ok := n.Repo.(diskUser); ok {
+		usage := measureDiskUsage(n, du)
+		metrics.Default.GaugeFunc("ipfs_datastore_disk_bytes", "Disk space taken by the datastore, blocks included, measured once a minute.", func() float64 {
+			return float64(atomic.LoadUint64(usage))
+		})
+	}
+
+	collectEventsWhileRunning(n)
+
+	mux.Handle(MetricsPath, localOnly(apiHosts(cfg), metrics.Default))
+	return nil
+}
+
+// collectEventsWhileRunning runs collectEventMetrics until n, and the
+// other nodes it runs for, are closed.
+func collectEventsWhileRunning(n *core.IpfsNode) {
+	eventCollector.Lock()
+	defer eventCollector.Unlock()
+	if eventCollector.nodes == 0 {
+		var ctx context.Context
+		ctx, eventCollector.cancel = context.WithCancel(context.Background())
+		go collectEventMetrics(ctx)
+	}
+	eventCollector.nodes++
+
+	go func() {
+		<-n.Context().Done()
+		eventCollector.Lock()
+		defer eventCollector.Unlock()
+		eventCollector.nodes--
+		if eventCollector.nodes == 0 {
+			eventCollector.cancel()
+		}
+	}()
+}
+
+// collectEventMetrics observes the events of the event log that have
+// metrics, until ctx is done.
+func collectEventMetrics(ctx context.Context) {
+	entries, cancel := eventlog.Subscribe(1024)
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-entries:
+			if e[eventlog.KeySystem] != "dht" {
+				continue
+			}
+			op, _ := e[eventlog.KeyEvent].(string)
+			switch op {
+			case "dhtSentMessage":
+				dhtMessages.With("sent").Inc()
+			case "dhtReceivedMessage":
+				dhtMessages.With("received").Inc()
+			default:
+				// the end of the events begun with EventBegin.
+				if d, ok := e["duration"].(time.Duration); ok {
+					dhtDurations.With(op).Observe(d.Seconds())
+				}
+			}
+		}
+	}
+}
+
+// diskUser is a repo which knows the disk space its datastore takes, like
+// the fsrepo.
+type diskUser interface {
+	DiskUsage() (uint64, error)
+}
+
+// measureDiskUsage measures the disk usage of du every diskUsageInterval
+// until n is closed, in the background. It returns where the last
+// measure is kept, to be read atomically.
+func measureDiskUsage(n *core.IpfsNode, du diskUser) *uint64 {
+	usage := new(uint64)
+	measure := func() {
+		size, err := du.DiskUsage()
+		if err != nil {
+			log.Errorf("measuring the disk usage of the datastore: %s", err)
+			return
+		}
+		atomic.StoreUint64(usage, size)
+	}
+
+	go func() {
+		measure()
+		t := time.NewTicker(diskUsageInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-n.Context().Done():
+				return
+			case <-t.C:
+				measure()
+			}
+		}
+	}()
+	return usage
+}
+
+// instrumentGateway records the durations of the requests to h.
+func instrumentGateway(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r)
+		gatewayDurations.With(strconv.Itoa(sw.status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusWriter keeps the status of the response it writes.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
@@ -0,0 +1,67 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	cmdsHttp "github.com/jbenet/go-ipfs/commands/http"
+	core "github.com/jbenet/go-ipfs/core"
+)
+
+// ProfilePath is where ProfileOption serves the profiles.
+const ProfilePath = "/debug/pprof"
+
+// ProfileOption serves the profiles of net/http/pprof, and a dump of the
+// stacks of all goroutines at ProfilePath/stacks, to local clients, if
+// enabled with API.Profiling in the config.
+func ProfileOption(n *core.IpfsNode, mux *http.ServeMux) error {
+	cfg := n.Repo.Config()
+	if !cfg.API.Profiling {
+		return nil
+	}
+	hosts := apiHosts(cfg)
+
+	mux.Handle(ProfilePath+"/", localOnly(hosts, http.HandlerFunc(pprof.Index)))
+	mux.Handle(ProfilePath+"/cmdline", localOnly(hosts, http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle(ProfilePath+"/profile", localOnly(hosts, http.HandlerFunc(pprof.Profile)))
+	mux.Handle(ProfilePath+"/symbol", localOnly(hosts, http.HandlerFunc(pprof.Symbol)))
+	mux.Handle(ProfilePath+"/stacks", localOnly(hosts, http.HandlerFunc(serveStacks)))
+	return nil
+}
+
+// serveStacks writes the stacks of all goroutines.
+func serveStacks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(stacks())
+}
+
+// stacks returns the stacks of all goroutines, as panics print them.
+func stacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// localOnly refuses the requests of remote clients to h, which serves
+// debugging data about the node, and the requests to other hosts than the
+// local ones and hosts, which local pages of other origins send when they
+// resolve their own names to the node's address.
+func localOnly(hosts []string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cmdsHttp.IsLocal(r.RemoteAddr) {
+			http.Error(w, "403 forbidden: debugging data is only for local clients", http.StatusForbidden)
+			return
+		}
+		if !cmdsHttp.HostAllowed(r, hosts) {
+			http.Error(w, cmdsHttp.ErrHost.Error(), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
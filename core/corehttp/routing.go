@@ -0,0 +1,46 @@
+package corehttp
+
+import (
+	"errors"
+	"net/http"
+
+	commands "github.com/jbenet/go-ipfs/commands"
+	cmdsHttp "github.com/jbenet/go-ipfs/commands/http"
+	core "github.com/jbenet/go-ipfs/core"
+	delegated "github.com/jbenet/go-ipfs/routing/delegated"
+)
+
+// RoutingOption serves the node's routing system as a delegated routing
+// service at addr, for nodes configured with the "delegated" routing type.
+// Its clients are checked as the API's, with the API's tokens.
+func RoutingOption(addr string) ServeOption {
+	return func(n *core.IpfsNode, mux *http.ServeMux) error {
+		if n.Routing == nil {
+			return errors.New("cannot serve delegated routing: node has no routing system")
+		}
+
+		// values, and the records of the clients, are served by the dht.
+		var records delegated.RecordRouter
+		if d := n.DHT(); d != nil {
+			records = d
+		}
+
+		sc := serverConfig(n.Repo.Config(), addr)
+		h := delegated.NewHandler(n.Context(), n.Routing, records)
+		mux.Handle(delegated.RoutingPath+"/", cmdsHttp.Guard(sc, routingScope, h))
+		return nil
+	}
+}
+
+// routingScope returns the scope of the API tokens a delegated routing
+// request needs: that of the commands doing the same.
+func routingScope(r *http.Request) string {
+	switch r.Method {
+	case "POST": // provide
+		return commands.ScopePin
+	case "PUT": // put value
+		return commands.ScopeName
+	default:
+		return commands.ScopeRead
+	}
+}
@@ -6,10 +6,16 @@ import (
 	u "github.com/jbenet/go-ipfs/util"
 
 	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+	metrics "github.com/jbenet/go-ipfs/thirdparty/metrics"
 )
 
 var log = eventlog.Logger("corerepo")
 
+var (
+	gcRuns        = metrics.NewCounter("ipfs_repo_gc_runs_total", "Garbage collections of the repo.")
+	gcKeysRemoved = metrics.NewCounter("ipfs_repo_gc_blocks_removed_total", "Blocks removed by garbage collections.")
+)
+
 type KeyRemoved struct {
 	Key u.Key
 }
@@ -21,12 +27,14 @@ func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	gcRuns.Inc()
 	for k := range keychan { // rely on AllKeysChan to close chan
 		if !n.Pinning.IsPinned(k) {
 			err := n.Blockstore.DeleteBlock(k)
 			if err != nil {
 				return err
 			}
+			gcKeysRemoved.Inc()
 		}
 	}
 	return nil
@@ -38,6 +46,7 @@ func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) (<-chan *KeyRemo
 	if err != nil {
 		return nil, err
 	}
+	gcRuns.Inc()
 
 	output := make(chan *KeyRemoved)
 	go func() {
@@ -54,6 +63,7 @@ func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) (<-chan *KeyRemo
 						log.Errorf("Error removing key from blockstore: %s", err)
 						continue
 					}
+					gcKeysRemoved.Inc()
 					select {
 					case output <- &KeyRemoved{k}:
 					case <-ctx.Done():
@@ -3,16 +3,18 @@ package corerepo
 import (
 	"fmt"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
 	"github.com/jbenet/go-ipfs/core"
 	"github.com/jbenet/go-ipfs/merkledag"
 	u "github.com/jbenet/go-ipfs/util"
 )
 
-func Pin(n *core.IpfsNode, paths []string, recursive bool) ([]u.Key, error) {
+func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]u.Key, error) {
 
 	dagnodes := make([]*merkledag.Node, 0)
 	for _, path := range paths {
-		dagnode, err := n.Resolver.ResolvePath(path)
+		dagnode, err := n.Resolver.ResolvePath(ctx, path)
 		if err != nil {
 			return nil, fmt.Errorf("pin: %s", err)
 		}
@@ -26,7 +28,7 @@ func Pin(n *core.IpfsNode, paths []string, recursive bool) ([]u.Key, error) {
 			return nil, err
 		}
 
-		err = n.Pinning.Pin(dagnode, recursive)
+		err = n.Pinning.Pin(ctx, dagnode, recursive)
 		if err != nil {
 			return nil, fmt.Errorf("pin: %s", err)
 		}
@@ -41,11 +43,11 @@ func Pin(n *core.IpfsNode, paths []string, recursive bool) ([]u.Key, error) {
 	return out, nil
 }
 
-func Unpin(n *core.IpfsNode, paths []string, recursive bool) ([]u.Key, error) {
+func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]u.Key, error) {
 
 	dagnodes := make([]*merkledag.Node, 0)
 	for _, path := range paths {
-		dagnode, err := n.Resolver.ResolvePath(path)
+		dagnode, err := n.Resolver.ResolvePath(ctx, path)
 		if err != nil {
 			return nil, err
 		}
@@ -55,7 +57,7 @@ func Unpin(n *core.IpfsNode, paths []string, recursive bool) ([]u.Key, error) {
 	var unpinned []u.Key
 	for _, dagnode := range dagnodes {
 		k, _ := dagnode.Key()
-		err := n.Pinning.Unpin(k, recursive)
+		err := n.Pinning.Unpin(ctx, k, recursive)
 		if err != nil {
 			return nil, err
 		}
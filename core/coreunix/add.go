@@ -82,7 +82,7 @@ func addNode(n *core.IpfsNode, node *merkledag.Node) error {
 	if err != nil {
 		return err
 	}
-	err = n.Pinning.Pin(node, true) // ensure we keep it
+	err = n.Pinning.Pin(n.Context(), node, true) // ensure we keep it
 	if err != nil {
 		return err
 	}
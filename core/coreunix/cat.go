@@ -3,12 +3,14 @@ package coreunix
 import (
 	"io"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
 	core "github.com/jbenet/go-ipfs/core"
 	uio "github.com/jbenet/go-ipfs/unixfs/io"
 )
 
-func Cat(n *core.IpfsNode, path string) (io.Reader, error) {
-	dagNode, err := n.Resolver.ResolvePath(path)
+func Cat(n *core.IpfsNode, ctx context.Context, path string) (io.Reader, error) {
+	dagNode, err := n.Resolver.ResolvePath(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -20,6 +20,7 @@ import (
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	"github.com/jbenet/go-ipfs/thirdparty/delay"
 	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+	metrics "github.com/jbenet/go-ipfs/thirdparty/metrics"
 	u "github.com/jbenet/go-ipfs/util"
 	errors "github.com/jbenet/go-ipfs/util/debugerror"
 	pset "github.com/jbenet/go-ipfs/util/peerset" // TODO move this to peerstore
@@ -38,10 +39,18 @@ const (
 	sizeBatchRequestChan   = 32
 	// kMaxPriority is the max priority as defined by the bitswap protocol
 	kMaxPriority = math.MaxInt32
+
+	// partnerTag tags the connections to peers that want blocks from us,
+	// for the connection manager.
+	partnerTag      = "bitswap"
+	partnerTagValue = 10
 )
 
 var (
 	rebroadcastDelay = delay.Fixed(time.Second * 10)
+
+	blocksReceived = metrics.NewCounter("ipfs_bitswap_blocks_received_total", "Blocks received from peers by bitswap.")
+	blocksSent     = metrics.NewCounter("ipfs_bitswap_blocks_sent_total", "Blocks sent to peers by bitswap.")
 )
 
 // New initializes a BitSwap instance that communicates over the provided
@@ -322,6 +331,8 @@ func (bs *bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 	bs.engine.MessageReceived(p, incoming)
 	// TODO: this is bad, and could be easily abused.
 	// Should only track *useful* messages in ledger
+	bs.tagPartner(p)
+	blocksReceived.Add(float64(len(incoming.Blocks())))
 
 	for _, block := range incoming.Blocks() {
 		hasBlockCtx, _ := context.WithTimeout(ctx, hasBlockTimeout)
@@ -385,9 +396,21 @@ func (bs *bitswap) send(ctx context.Context, p peer.ID, m bsmsg.BitSwapMessage)
 	if err := bs.network.SendMessage(ctx, p, m); err != nil {
 		return errors.Wrap(err)
 	}
+	blocksSent.Add(float64(len(m.Blocks())))
+	defer bs.tagPartner(p)
 	return bs.engine.MessageSent(p, m)
 }
 
+// tagPartner keeps the connection to p while p wants blocks from us.
+func (bs *bitswap) tagPartner(p peer.ID) {
+	cmgr := bs.network.ConnectionManager()
+	if bs.engine.WantCount(p) > 0 {
+		cmgr.TagPeer(p, partnerTag, partnerTagValue)
+	} else {
+		cmgr.UntagPeer(p, partnerTag)
+	}
+}
+
 func (bs *bitswap) Close() error {
 	bs.cancelFunc()
 	return nil // to conform to Closer interface
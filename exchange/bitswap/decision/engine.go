@@ -227,6 +227,18 @@ func (e *Engine) MessageSent(p peer.ID, m bsmsg.BitSwapMessage) error {
 	return nil
 }
 
+// WantCount returns the number of blocks p wants from us.
+func (e *Engine) WantCount(p peer.ID) int {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	l, found := e.ledgerMap[p]
+	if !found {
+		return 0
+	}
+	return len(l.wantList.Entries())
+}
+
 func (e *Engine) numBytesSentTo(p peer.ID) uint64 {
 	// NB not threadsafe
 	return e.findOrCreate(p).Accounting.BytesSent
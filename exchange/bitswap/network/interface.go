@@ -4,6 +4,7 @@ import (
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 
 	bsmsg "github.com/jbenet/go-ipfs/exchange/bitswap/message"
+	connmgr "github.com/jbenet/go-ipfs/p2p/net/connmgr"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
 	u "github.com/jbenet/go-ipfs/util"
@@ -30,6 +31,10 @@ type BitSwapNetwork interface {
 	// network.
 	SetDelegate(Receiver)
 
+	// ConnectionManager returns the manager to tell which peers we are
+	// exchanging blocks with.
+	ConnectionManager() connmgr.ConnManager
+
 	Routing
 }
 
@@ -6,6 +6,7 @@ import (
 	bsmsg "github.com/jbenet/go-ipfs/exchange/bitswap/message"
 	host "github.com/jbenet/go-ipfs/p2p/host"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	connmgr "github.com/jbenet/go-ipfs/p2p/net/connmgr"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	routing "github.com/jbenet/go-ipfs/routing"
 	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
@@ -97,6 +98,10 @@ func (bsnet *impl) SetDelegate(r Receiver) {
 	bsnet.receiver = r
 }
 
+func (bsnet *impl) ConnectionManager() connmgr.ConnManager {
+	return bsnet.host.ConnManager()
+}
+
 // FindProvidersAsync returns a channel of providers for the given key
 func (bsnet *impl) FindProvidersAsync(ctx context.Context, k util.Key, max int) <-chan peer.ID {
 	out := make(chan peer.ID)
@@ -105,7 +110,7 @@ func (bsnet *impl) FindProvidersAsync(ctx context.Context, k util.Key, max int)
 		providers := bsnet.routing.FindProvidersAsync(ctx, k, max)
 		for info := range providers {
 			if info.ID != bsnet.host.ID() { // dont add addrs for ourselves.
-				bsnet.host.Peerstore().AddAddresses(info.ID, info.Addrs)
+				bsnet.host.Peerstore().AddAddresses(info.ID, info.Addrs, peer.ProviderAddrTTL)
 			}
 			select {
 			case <-ctx.Done():
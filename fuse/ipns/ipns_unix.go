@@ -14,6 +14,7 @@ import (
 	proto "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
 
 	core "github.com/jbenet/go-ipfs/core"
+	mount "github.com/jbenet/go-ipfs/fuse/mount"
 	chunk "github.com/jbenet/go-ipfs/importer/chunk"
 	mdag "github.com/jbenet/go-ipfs/merkledag"
 	nsys "github.com/jbenet/go-ipfs/namesys"
@@ -42,7 +43,7 @@ func InitializeKeyspace(n *core.IpfsNode, key ci.PrivKey) error {
 		return err
 	}
 
-	err = n.Pinning.Pin(emptyDir, false)
+	err = n.Pinning.Pin(n.Context(), emptyDir, false)
 	if err != nil {
 		return err
 	}
@@ -128,7 +129,7 @@ func CreateRoot(n *core.IpfsNode, keys []ci.PrivKey, ipfsroot string) (*Root, er
 			return nil, nil
 		}
 
-		node, err := n.Resolver.ResolvePath(pointsTo)
+		node, err := n.Resolver.ResolvePath(n.Context(), pointsTo)
 		if err != nil {
 			log.Warning("Failed to resolve value from ipns entry in ipfs")
 			continue
@@ -288,7 +289,9 @@ func (s *Node) Attr() fuse.Attr {
 // Lookup performs a lookup under this node.
 func (s *Node) Lookup(name string, intr fs.Intr) (fs.Node, fuse.Error) {
 	log.Debugf("ipns: node[%s] Lookup '%s'", s.name, name)
-	nd, err := s.Ipfs.Resolver.ResolveLinks(s.Nd, []string{name})
+	ctx, cancel := mount.IntrContext(s.Ipfs.Context(), intr)
+	defer cancel()
+	nd, err := s.Ipfs.Resolver.ResolveLinks(ctx, s.Nd, []string{name})
 	if err != nil {
 		// todo: make this error more versatile.
 		return nil, fuse.ENOENT
@@ -360,7 +363,7 @@ func (n *Node) Write(req *fuse.WriteRequest, resp *fuse.WriteResponse, intr fs.I
 
 	if n.dagMod == nil {
 		// Create a DagModifier to allow us to change the existing dag node
-		dmod, err := uio.NewDagModifier(n.Nd, n.Ipfs.DAG, chunk.DefaultSplitter)
+		dmod, err := uio.NewDagModifier(n.Ipfs.Context(), n.Nd, n.Ipfs.DAG, chunk.DefaultSplitter)
 		if err != nil {
 			log.Errorf("Error creating dag modifier: %s", err)
 			return err
@@ -9,6 +9,7 @@ import (
 
 	fuse "github.com/jbenet/go-ipfs/Godeps/_workspace/src/bazil.org/fuse"
 	fs "github.com/jbenet/go-ipfs/Godeps/_workspace/src/bazil.org/fuse/fs"
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	ctxgroup "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-ctxgroup"
 
 	u "github.com/jbenet/go-ipfs/util"
@@ -192,3 +193,17 @@ func ForceUnmountManyTimes(m Mount, attempts int) error {
 	}
 	return fmt.Errorf("Unmount %s failed after 10 seconds of trying.", m.MountPoint())
 }
+
+// IntrContext returns a context of parent which is done when the kernel
+// interrupts the request of intr, and the function to release it with.
+func IntrContext(parent context.Context, intr fs.Intr) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-intr:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
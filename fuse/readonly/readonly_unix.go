@@ -13,6 +13,7 @@ import (
 	proto "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
 
 	core "github.com/jbenet/go-ipfs/core"
+	mount "github.com/jbenet/go-ipfs/fuse/mount"
 	mdag "github.com/jbenet/go-ipfs/merkledag"
 	uio "github.com/jbenet/go-ipfs/unixfs/io"
 	ftpb "github.com/jbenet/go-ipfs/unixfs/pb"
@@ -55,7 +56,9 @@ func (s *Root) Lookup(name string, intr fs.Intr) (fs.Node, fuse.Error) {
 		return nil, fuse.ENOENT
 	}
 
-	nd, err := s.Ipfs.Resolver.ResolvePath(name)
+	ctx, cancel := mount.IntrContext(s.Ipfs.Context(), intr)
+	defer cancel()
+	nd, err := s.Ipfs.Resolver.ResolvePath(ctx, name)
 	if err != nil {
 		// todo: make this error more versatile.
 		return nil, fuse.ENOENT
@@ -115,7 +118,9 @@ func (s *Node) Attr() fuse.Attr {
 // Lookup performs a lookup under this node.
 func (s *Node) Lookup(name string, intr fs.Intr) (fs.Node, fuse.Error) {
 	log.Debugf("Lookup '%s'", name)
-	nd, err := s.Ipfs.Resolver.ResolveLinks(s.Nd, []string{name})
+	ctx, cancel := mount.IntrContext(s.Ipfs.Context(), intr)
+	defer cancel()
+	nd, err := s.Ipfs.Resolver.ResolveLinks(ctx, s.Nd, []string{name})
 	if err != nil {
 		// todo: make this error more versatile.
 		return nil, fuse.ENOENT
@@ -4,6 +4,8 @@ package traverse
 import (
 	"errors"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
 	mdag "github.com/jbenet/go-ipfs/merkledag"
 )
 
@@ -33,6 +35,7 @@ type State struct {
 }
 
 type traversal struct {
+	ctx  context.Context // bounds the fetching of nodes
 	opts Options
 	seen map[string]struct{}
 }
@@ -64,7 +67,7 @@ func (t *traversal) callFunc(next State) error {
 func (t *traversal) getNode(link *mdag.Link) (*mdag.Node, error) {
 
 	getNode := func(l *mdag.Link) (*mdag.Node, error) {
-		next, err := l.GetNode(t.opts.DAG)
+		next, err := l.GetNode(t.ctx, t.opts.DAG)
 		if err != nil {
 			return nil, err
 		}
@@ -101,8 +104,10 @@ type Func func(current State) error
 //
 type ErrFunc func(err error) error
 
-func Traverse(root *mdag.Node, o Options) error {
+// Traverse walks the dag from root, fetching the nodes until ctx is done.
+func Traverse(ctx context.Context, root *mdag.Node, o Options) error {
 	t := traversal{
+		ctx:  ctx,
 		opts: o,
 		seen: map[string]struct{}{},
 	}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"testing"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
 	mdag "github.com/jbenet/go-ipfs/merkledag"
 )
 
@@ -326,7 +328,7 @@ func testWalkOutputs(t *testing.T, root *mdag.Node, opts Options, expect []byte)
 	}
 
 	opts.Func = walk
-	if err := Traverse(root, opts); err != nil {
+	if err := Traverse(context.Background(), root, opts); err != nil {
 		t.Error(err)
 		return
 	}
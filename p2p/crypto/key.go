@@ -158,9 +158,11 @@ func KeyStretcher(cipherType string, hashType string, secret []byte) (StretchedK
 	case "AES-256":
 		ivSize = 16
 		cipherKeySize = 32
-	case "Blowfish":
-		ivSize = 8
-		// Note: 24 arbitrarily selected, needs more thought
+	case "AES-128-GCM":
+		ivSize = 12
+		cipherKeySize = 16
+	case "AES-256-GCM", "ChaCha20-Poly1305":
+		ivSize = 12
 		cipherKeySize = 32
 	}
 
@@ -13,16 +13,15 @@ import (
 	"crypto/sha512"
 	"hash"
 
-	bfish "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.crypto/blowfish"
-
 	ci "github.com/jbenet/go-ipfs/p2p/crypto"
 )
 
 // List of supported ECDH curves
 var SupportedExchanges = "P-256,P-224,P-384,P-521"
 
-// List of supported Ciphers
-var SupportedCiphers = "AES-256,AES-128,Blowfish"
+// List of supported Ciphers. The AEAD ones come first: peers that do not
+// know them fall back to AES in CTR mode, with a separate HMAC.
+var SupportedCiphers = "AES-256-GCM,AES-128-GCM,ChaCha20-Poly1305,AES-256,AES-128"
 
 // List of supported Hashes
 var SupportedHashes = "SHA256,SHA512"
@@ -44,12 +43,22 @@ type encParams struct {
 	cipherT string
 	hashT   string
 
-	// cipher + mac
+	// cipher + mac, or aead
 	cipher cipher.Stream
 	mac    HMAC
+	aead   cipher.AEAD
 }
 
 func (e *encParams) makeMacAndCipher() error {
+	if isAEAD(e.cipherT) {
+		a, err := newAEAD(e.cipherT, e.keys.CipherKey)
+		if err != nil {
+			return err
+		}
+		e.aead = a
+		return nil
+	}
+
 	m, err := newMac(e.hashT, e.keys.MacKey)
 	if err != nil {
 		return err
@@ -82,8 +91,30 @@ func newBlockCipher(cipherT string, key []byte) (cipher.Block, error) {
 	switch cipherT {
 	case "AES-128", "AES-256":
 		return aes.NewCipher(key)
-	case "Blowfish":
-		return bfish.NewCipher(key)
+	default:
+		return nil, fmt.Errorf("Unrecognized cipher type: %s", cipherT)
+	}
+}
+
+// isAEAD returns whether cipherT authenticates by itself, without a MAC.
+func isAEAD(cipherT string) bool {
+	switch cipherT {
+	case "AES-128-GCM", "AES-256-GCM", "ChaCha20-Poly1305":
+		return true
+	}
+	return false
+}
+
+func newAEAD(cipherT string, key []byte) (cipher.AEAD, error) {
+	switch cipherT {
+	case "AES-128-GCM", "AES-256-GCM":
+		bc, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(bc)
+	case "ChaCha20-Poly1305":
+		return newChaCha20Poly1305(key)
 	default:
 		return nil, fmt.Errorf("Unrecognized cipher type: %s", cipherT)
 	}
@@ -0,0 +1,294 @@
+package secio
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// chacha20Poly1305 is the ChaCha20-Poly1305 AEAD of RFC 7539, for
+// machines without AES instructions, where it is faster than AES-GCM.
+// It is implemented here rather than vendored, as go.crypto has no
+// version of it building with the go releases we support.
+type chacha20Poly1305 struct {
+	key [8]uint32
+}
+
+const (
+	chachaKeySize   = 32
+	chachaNonceSize = 12
+	poly1305TagSize = 16
+)
+
+var errChachaKeySize = errors.New("chacha20poly1305: bad key length")
+
+func newChaCha20Poly1305(key []byte) (cipher.AEAD, error) {
+	if len(key) != chachaKeySize {
+		return nil, errChachaKeySize
+	}
+	c := &chacha20Poly1305{}
+	for i := range c.key {
+		c.key[i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+	return c, nil
+}
+
+func (c *chacha20Poly1305) NonceSize() int {
+	return chachaNonceSize
+}
+
+func (c *chacha20Poly1305) Overhead() int {
+	return poly1305TagSize
+}
+
+func (c *chacha20Poly1305) Seal(dst, nonce, plaintext, data []byte) []byte {
+	if len(nonce) != chachaNonceSize {
+		panic("chacha20poly1305: bad nonce length passed to Seal")
+	}
+	ret, out := sliceForAppend(dst, len(plaintext)+poly1305TagSize)
+	ct := out[:len(plaintext)]
+
+	var polyKey [32]byte
+	c.xorKeyStream(polyKey[:], polyKey[:], nonce, 0)
+	c.xorKeyStream(ct, plaintext, nonce, 1)
+
+	tag := authenticate(&polyKey, data, ct)
+	copy(out[len(plaintext):], tag[:])
+	return ret
+}
+
+func (c *chacha20Poly1305) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	if len(nonce) != chachaNonceSize {
+		panic("chacha20poly1305: bad nonce length passed to Open")
+	}
+	if len(ciphertext) < poly1305TagSize {
+		return nil, ErrMACInvalid
+	}
+	ct := ciphertext[:len(ciphertext)-poly1305TagSize]
+
+	var polyKey [32]byte
+	c.xorKeyStream(polyKey[:], polyKey[:], nonce, 0)
+
+	// check before decrypting: dst may be ciphertext itself.
+	tag := authenticate(&polyKey, data, ct)
+	if subtle.ConstantTimeCompare(tag[:], ciphertext[len(ct):]) != 1 {
+		return nil, ErrMACInvalid
+	}
+
+	ret, out := sliceForAppend(dst, len(ct))
+	c.xorKeyStream(out, ct, nonce, 1)
+	return ret, nil
+}
+
+// xorKeyStream xors src with the key stream of nonce from block counter on,
+// into dst.
+func (c *chacha20Poly1305) xorKeyStream(dst, src, nonce []byte, counter uint32) {
+	var state [16]uint32
+	state[0], state[1], state[2], state[3] = 0x61707865, 0x3320646e, 0x79622d32, 0x6b206574
+	copy(state[4:12], c.key[:])
+	state[13] = binary.LittleEndian.Uint32(nonce[0:])
+	state[14] = binary.LittleEndian.Uint32(nonce[4:])
+	state[15] = binary.LittleEndian.Uint32(nonce[8:])
+
+	var block [64]byte
+	for len(src) > 0 {
+		state[12] = counter
+		chachaBlock(&block, &state)
+		counter++
+
+		n := len(src)
+		if n > len(block) {
+			n = len(block)
+		}
+		for i := 0; i < n; i++ {
+			dst[i] = src[i] ^ block[i]
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// chachaBlock computes the 20 round block function of state into out.
+func chachaBlock(out *[64]byte, state *[16]uint32) {
+	x := *state
+	for i := 0; i < 10; i++ {
+		// columns
+		quarterRound(&x, 0, 4, 8, 12)
+		quarterRound(&x, 1, 5, 9, 13)
+		quarterRound(&x, 2, 6, 10, 14)
+		quarterRound(&x, 3, 7, 11, 15)
+		// diagonals
+		quarterRound(&x, 0, 5, 10, 15)
+		quarterRound(&x, 1, 6, 11, 12)
+		quarterRound(&x, 2, 7, 8, 13)
+		quarterRound(&x, 3, 4, 9, 14)
+	}
+	for i := range x {
+		binary.LittleEndian.PutUint32(out[4*i:], x[i]+state[i])
+	}
+}
+
+func quarterRound(x *[16]uint32, a, b, c, d int) {
+	x[a] += x[b]
+	x[d] ^= x[a]
+	x[d] = x[d]<<16 | x[d]>>16
+	x[c] += x[d]
+	x[b] ^= x[c]
+	x[b] = x[b]<<12 | x[b]>>20
+	x[a] += x[b]
+	x[d] ^= x[a]
+	x[d] = x[d]<<8 | x[d]>>24
+	x[c] += x[d]
+	x[b] ^= x[c]
+	x[b] = x[b]<<7 | x[b]>>25
+}
+
+// authenticate returns the Poly1305 tag of the additional data and the
+// ciphertext, each padded to 16 bytes, followed by their lengths.
+func authenticate(key *[32]byte, data, ct []byte) [poly1305TagSize]byte {
+	var p poly1305
+	p.init(key)
+	p.writePadded(data)
+	p.writePadded(ct)
+
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[0:], uint64(len(data)))
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(ct)))
+	p.blocks(lengths[:], 1<<24)
+	return p.sum()
+}
+
+// poly1305 is the one-time authenticator of RFC 7539, computed in 26 bit
+// limbs so the products fit in 64 bits.
+type poly1305 struct {
+	r   [5]uint32
+	h   [5]uint32
+	pad [4]uint32
+}
+
+const mask26 = 0x3ffffff
+
+func (p *poly1305) init(key *[32]byte) {
+	// r is clamped as it is split in limbs.
+	p.r[0] = binary.LittleEndian.Uint32(key[0:]) & 0x3ffffff
+	p.r[1] = binary.LittleEndian.Uint32(key[3:]) >> 2 & 0x3ffff03
+	p.r[2] = binary.LittleEndian.Uint32(key[6:]) >> 4 & 0x3ffc0ff
+	p.r[3] = binary.LittleEndian.Uint32(key[9:]) >> 6 & 0x3f03fff
+	p.r[4] = binary.LittleEndian.Uint32(key[12:]) >> 8 & 0x00fffff
+	for i := range p.pad {
+		p.pad[i] = binary.LittleEndian.Uint32(key[16+4*i:])
+	}
+}
+
+// writePadded adds b to the message, padded with zeros to 16 bytes.
+func (p *poly1305) writePadded(b []byte) {
+	n := len(b) &^ 15
+	p.blocks(b[:n], 1<<24)
+	if n < len(b) {
+		var last [16]byte
+		copy(last[:], b[n:])
+		p.blocks(last[:], 1<<24)
+	}
+}
+
+// blocks adds the 16 byte blocks of m to the message. hibit is the bit
+// set above each block.
+func (p *poly1305) blocks(m []byte, hibit uint32) {
+	r0, r1, r2, r3, r4 := uint64(p.r[0]), uint64(p.r[1]), uint64(p.r[2]), uint64(p.r[3]), uint64(p.r[4])
+	s1, s2, s3, s4 := r1*5, r2*5, r3*5, r4*5
+	h0, h1, h2, h3, h4 := p.h[0], p.h[1], p.h[2], p.h[3], p.h[4]
+
+	for ; len(m) >= 16; m = m[16:] {
+		h0 += binary.LittleEndian.Uint32(m[0:]) & mask26
+		h1 += binary.LittleEndian.Uint32(m[3:]) >> 2 & mask26
+		h2 += binary.LittleEndian.Uint32(m[6:]) >> 4 & mask26
+		h3 += binary.LittleEndian.Uint32(m[9:]) >> 6 & mask26
+		h4 += binary.LittleEndian.Uint32(m[12:])>>8 | hibit
+
+		// h *= r, modulo 2^130 - 5.
+		d0 := uint64(h0)*r0 + uint64(h1)*s4 + uint64(h2)*s3 + uint64(h3)*s2 + uint64(h4)*s1
+		d1 := uint64(h0)*r1 + uint64(h1)*r0 + uint64(h2)*s4 + uint64(h3)*s3 + uint64(h4)*s2
+		d2 := uint64(h0)*r2 + uint64(h1)*r1 + uint64(h2)*r0 + uint64(h3)*s4 + uint64(h4)*s3
+		d3 := uint64(h0)*r3 + uint64(h1)*r2 + uint64(h2)*r1 + uint64(h3)*r0 + uint64(h4)*s4
+		d4 := uint64(h0)*r4 + uint64(h1)*r3 + uint64(h2)*r2 + uint64(h3)*r1 + uint64(h4)*r0
+
+		c := d0 >> 26
+		h0 = uint32(d0) & mask26
+		d1 += c
+		c = d1 >> 26
+		h1 = uint32(d1) & mask26
+		d2 += c
+		c = d2 >> 26
+		h2 = uint32(d2) & mask26
+		d3 += c
+		c = d3 >> 26
+		h3 = uint32(d3) & mask26
+		d4 += c
+		c = d4 >> 26
+		h4 = uint32(d4) & mask26
+		h0 += uint32(c) * 5
+		h1 += h0 >> 26
+		h0 &= mask26
+	}
+	p.h[0], p.h[1], p.h[2], p.h[3], p.h[4] = h0, h1, h2, h3, h4
+}
+
+// sum returns the tag of the message.
+func (p *poly1305) sum() [poly1305TagSize]byte {
+	h0, h1, h2, h3, h4 := p.h[0], p.h[1], p.h[2], p.h[3], p.h[4]
+
+	// carry fully.
+	h2 += h1 >> 26
+	h1 &= mask26
+	h3 += h2 >> 26
+	h2 &= mask26
+	h4 += h3 >> 26
+	h3 &= mask26
+	h0 += (h4 >> 26) * 5
+	h4 &= mask26
+	h1 += h0 >> 26
+	h0 &= mask26
+
+	// g = h - (2^130 - 5), taken instead of h unless negative.
+	g0 := h0 + 5
+	g1 := h1 + g0>>26
+	g0 &= mask26
+	g2 := h2 + g1>>26
+	g1 &= mask26
+	g3 := h3 + g2>>26
+	g2 &= mask26
+	g4 := h4 + g3>>26 - 1<<26
+	g3 &= mask26
+
+	mask := g4>>31 - 1
+	h0 = h0&^mask | g0&mask
+	h1 = h1&^mask | g1&mask
+	h2 = h2&^mask | g2&mask
+	h3 = h3&^mask | g3&mask
+	h4 = h4&^mask | g4&mask
+
+	// h + pad, modulo 2^128.
+	f := uint64(h0|h1<<26) + uint64(p.pad[0])
+	var tag [poly1305TagSize]byte
+	binary.LittleEndian.PutUint32(tag[0:], uint32(f))
+	f = uint64(h1>>6|h2<<20) + uint64(p.pad[1]) + f>>32
+	binary.LittleEndian.PutUint32(tag[4:], uint32(f))
+	f = uint64(h2>>12|h3<<14) + uint64(p.pad[2]) + f>>32
+	binary.LittleEndian.PutUint32(tag[8:], uint32(f))
+	f = uint64(h3>>18|h4<<8) + uint64(p.pad[3]) + f>>32
+	binary.LittleEndian.PutUint32(tag[12:], uint32(f))
+	return tag
+}
+
+// sliceForAppend extends in by n bytes, and returns the whole slice and
+// the extension.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -12,6 +12,10 @@ import (
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 )
 
+// ID is the name of the security protocol, as negotiated with peers which
+// also speak TLS.
+const ID = "/secio/1.0.0"
+
 // SessionGenerator constructs secure communication sessions for a peer.
 type SessionGenerator struct {
 	LocalID    peer.ID
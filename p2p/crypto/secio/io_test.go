@@ -1 +1,196 @@
 package secio
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	testutil "github.com/jbenet/go-ipfs/util/testutil"
+)
+
+// tcpPair returns both ends of a loopback tcp connection.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+
+	c1, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c1, <-accepted
+}
+
+func newTestSession(t *testing.T) *SessionGenerator {
+	sk, pk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SessionGenerator{LocalID: id, PrivateKey: sk}
+}
+
+// testSessions handshakes over a fresh connection, and sends messages both
+// ways.
+func testSessions(t *testing.T) {
+	ctx := context.Background()
+	c1, c2 := tcpPair(t)
+	defer c1.Close()
+	defer c2.Close()
+
+	sg1 := newTestSession(t)
+	sg2 := newTestSession(t)
+
+	sessions := make(chan Session, 2)
+	errs := make(chan error, 2)
+	for _, x := range []struct {
+		sg *SessionGenerator
+		c  net.Conn
+	}{{sg1, c1}, {sg2, c2}} {
+		go func(sg *SessionGenerator, c net.Conn) {
+			s, err := sg.NewSession(ctx, c)
+			if err != nil {
+				errs <- err
+				return
+			}
+			sessions <- s
+		}(x.sg, x.c)
+	}
+
+	var ss []Session
+	for len(ss) < 2 {
+		select {
+		case err := <-errs:
+			t.Fatal(err)
+		case s := <-sessions:
+			ss = append(ss, s)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		msg := bytes.Repeat([]byte{byte(i)}, 100*i+1)
+		for _, dir := range [][2]Session{{ss[0], ss[1]}, {ss[1], ss[0]}} {
+			if err := dir[0].ReadWriter().WriteMsg(msg); err != nil {
+				t.Fatal(err)
+			}
+			got, err := dir[1].ReadWriter().ReadMsg()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, msg) {
+				t.Fatalf("message %d garbled", i)
+			}
+		}
+	}
+}
+
+func TestCiphers(t *testing.T) {
+	defer func(s string) { SupportedCiphers = s }(SupportedCiphers)
+
+	for _, c := range []string{"AES-256-GCM", "AES-128-GCM", "ChaCha20-Poly1305", "AES-256", "AES-128"} {
+		SupportedCiphers = c
+		t.Logf("testing %s", c)
+		testSessions(t)
+	}
+}
+
+func TestSelectCipherWithOlderPeers(t *testing.T) {
+	// what peers from before the AEAD ciphers propose.
+	older := "AES-256,AES-128,Blowfish"
+	for _, order := range []int{-1, 1} {
+		c, err := selectBest(order, SupportedCiphers, older)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != "AES-256" {
+			t.Fatalf("expected AES-256, got %s", c)
+		}
+	}
+
+	if _, err := selectBest(1, SupportedCiphers, "Blowfish"); err == nil {
+		t.Fatal("agreed on Blowfish")
+	}
+}
+
+func TestAEADTampering(t *testing.T) {
+	a, err := newAEAD("AES-128-GCM", make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, 12)
+
+	var buf bytes.Buffer
+	w := NewAEADWriter(&buf, a, iv)
+	for _, m := range []string{"hello", "world"} {
+		if err := w.WriteMsg([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// flip a bit of the second message's ciphertext.
+	wire := buf.Bytes()
+	wire[len(wire)-1] ^= 1
+
+	r := NewAEADReader(bytes.NewReader(wire), a, iv)
+	if m, err := r.ReadMsg(); err != nil || string(m) != "hello" {
+		t.Fatalf("first message: %q %v", m, err)
+	}
+	if _, err := r.ReadMsg(); err != ErrMACInvalid {
+		t.Fatalf("expected ErrMACInvalid, got %v", err)
+	}
+}
+
+func TestChaCha20Poly1305Vector(t *testing.T) {
+	// from RFC 7539, section 2.8.2.
+	hexes := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	key := hexes("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f")
+	nonce := hexes("070000004041424344454647")
+	data := hexes("50515253c0c1c2c3c4c5c6c7")
+	plaintext := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+	sealed := hexes("d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d6" +
+		"3dbea45e8ca9671282fafb69da92728b1a71de0a9e060b2905d6a5b67ecd3b36" +
+		"92ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc" +
+		"3ff4def08e4b7a9de576d26586cec64b6116" +
+		"1ae10b594f09e26a7e902ecbd0600691") // the tag
+
+	a, err := newAEAD("ChaCha20-Poly1305", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Seal(nil, nonce, plaintext, data); !bytes.Equal(got, sealed) {
+		t.Fatalf("sealed to %x", got)
+	}
+	got, err := a.Open(nil, nonce, sealed, data)
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("opened to %q, %v", got, err)
+	}
+
+	sealed[0] ^= 1
+	if _, err := a.Open(nil, nonce, sealed, data); err != ErrMACInvalid {
+		t.Fatalf("expected ErrMACInvalid, got %v", err)
+	}
+}
@@ -270,9 +270,16 @@ func (s *secureSession) handshake(ctx context.Context, insecure io.ReadWriter) e
 	// =============================================================================
 	// step 3. Finish -- send expected message (the nonces), verify encryption works
 
-	// setup ETM ReadWriter
-	w := NewETMWriter(s.insecure, s.local.cipher, s.local.mac)
-	r := NewETMReader(s.insecure, s.remote.cipher, s.remote.mac)
+	// setup AEAD or ETM ReadWriter
+	var w msgio.WriteCloser
+	var r msgio.ReadCloser
+	if s.local.aead != nil {
+		w = NewAEADWriter(s.insecure, s.local.aead, s.local.keys.IV)
+		r = NewAEADReader(s.insecure, s.remote.aead, s.remote.keys.IV)
+	} else {
+		w = NewETMWriter(s.insecure, s.local.cipher, s.local.mac)
+		r = NewETMReader(s.insecure, s.remote.cipher, s.remote.mac)
+	}
 	s.secure = msgio.Combine(w, r).(msgio.ReadWriteCloser)
 
 	// log.Debug("3.0 finish. sending: %v", proposeIn.GetRand())
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"sync"
 
 	"crypto/hmac"
@@ -20,14 +21,15 @@ var ErrMACInvalid = errors.New("MAC verification failed")
 
 // bufPool is a ByteSlicePool for messages. we need buffers because (sadly)
 // we cannot encrypt in place-- the user needs their buffer back.
-var bufPool = mpool.ByteSlicePool
+var bufPool = &mpool.ByteSlicePool
 
 type etmWriter struct {
 	// params
-	pool mpool.Pool        // for the buffers with encrypted data
+	pool *mpool.Pool       // for the buffers with encrypted data
 	msg  msgio.WriteCloser // msgio for knowing where boundaries lie
 	str  cipher.Stream     // the stream cipher to encrypt with
 	mac  HMAC              // the mac to authenticate data with
+	aead *aeadSealer       // or, the aead to do both with
 
 	sync.Mutex
 }
@@ -37,6 +39,11 @@ func NewETMWriter(w io.Writer, s cipher.Stream, mac HMAC) msgio.WriteCloser {
 	return &etmWriter{msg: msgio.NewWriter(w), str: s, mac: mac, pool: bufPool}
 }
 
+// NewAEADWriter seals each message with a, and a nonce derived from iv.
+func NewAEADWriter(w io.Writer, a cipher.AEAD, iv []byte) msgio.WriteCloser {
+	return &etmWriter{msg: msgio.NewWriter(w), aead: newAEADSealer(a, iv), pool: bufPool}
+}
+
 // Write writes passed in buffer as a single message.
 func (w *etmWriter) Write(b []byte) (int, error) {
 	if err := w.WriteMsg(b); err != nil {
@@ -50,6 +57,10 @@ func (w *etmWriter) WriteMsg(b []byte) error {
 	w.Lock()
 	defer w.Unlock()
 
+	if w.aead != nil {
+		return w.sealAndWrite(b)
+	}
+
 	// encrypt.
 	data := w.pool.Get(uint32(len(b))).([]byte)
 	data = data[:len(b)] // the pool's buffer may be larger
@@ -73,6 +84,18 @@ func (w *etmWriter) WriteMsg(b []byte) error {
 	return w.msg.WriteMsg(data)
 }
 
+// sealAndWrite encrypts and authenticates b in one go, with the aead.
+func (w *etmWriter) sealAndWrite(b []byte) error {
+	nonce, err := w.aead.nextNonce()
+	if err != nil {
+		return err
+	}
+
+	data := w.pool.Get(uint32(len(b) + w.aead.Overhead())).([]byte)
+	data = w.aead.Seal(data[:0], nonce, b, nil)
+	return w.msg.WriteMsg(data)
+}
+
 func (w *etmWriter) Close() error {
 	return w.msg.Close()
 }
@@ -85,9 +108,10 @@ type etmReader struct {
 	buf []byte
 
 	// params
-	msg msgio.ReadCloser // msgio for knowing where boundaries lie
-	str cipher.Stream    // the stream cipher to encrypt with
-	mac HMAC             // the mac to authenticate data with
+	msg  msgio.ReadCloser // msgio for knowing where boundaries lie
+	str  cipher.Stream    // the stream cipher to encrypt with
+	mac  HMAC             // the mac to authenticate data with
+	aead *aeadSealer      // or, the aead to do both with
 
 	sync.Mutex
 }
@@ -97,6 +121,12 @@ func NewETMReader(r io.Reader, s cipher.Stream, mac HMAC) msgio.ReadCloser {
 	return &etmReader{msg: msgio.NewReader(r), str: s, mac: mac}
 }
 
+// NewAEADReader opens the messages of a NewAEADWriter, with the same aead
+// and iv.
+func NewAEADReader(r io.Reader, a cipher.AEAD, iv []byte) msgio.ReadCloser {
+	return &etmReader{msg: msgio.NewReader(r), aead: newAEADSealer(a, iv)}
+}
+
 func (r *etmReader) NextMsgLen() (int, error) {
 	return r.msg.NextMsgLen()
 }
@@ -177,6 +207,10 @@ func (r *etmReader) ReadMsg() ([]byte, error) {
 }
 
 func (r *etmReader) macCheckThenDecrypt(m []byte) (int, error) {
+	if r.aead != nil {
+		return r.open(m)
+	}
+
 	l := len(m)
 	if l < r.mac.size {
 		return 0, fmt.Errorf("buffer (%d) shorter than MAC size (%d)", l, r.mac.size)
@@ -204,6 +238,21 @@ func (r *etmReader) macCheckThenDecrypt(m []byte) (int, error) {
 	return mark, nil
 }
 
+// open authenticates and decrypts m in place, with the aead.
+func (r *etmReader) open(m []byte) (int, error) {
+	nonce, err := r.aead.nextNonce()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := r.aead.Open(m[:0], nonce, m, nil)
+	if err != nil {
+		log.Error("AEAD open failed:", err)
+		return 0, ErrMACInvalid
+	}
+	return len(data), nil
+}
+
 func (w *etmReader) Close() error {
 	return w.msg.Close()
 }
@@ -213,6 +262,35 @@ func (r *etmReader) ReleaseMsg(b []byte) {
 	r.msg.ReleaseMsg(b)
 }
 
+// aeadSealer is an aead, and the nonces of one direction of a session. As
+// in TLS 1.3, the nonce of each message is the iv xored with the message's
+// sequence number, so nonces are never reused, nor sent.
+type aeadSealer struct {
+	cipher.AEAD
+	iv    []byte
+	nonce []byte
+	seq   uint64
+}
+
+func newAEADSealer(a cipher.AEAD, iv []byte) *aeadSealer {
+	return &aeadSealer{AEAD: a, iv: iv, nonce: make([]byte, a.NonceSize())}
+}
+
+// nextNonce returns the nonce of the next message. It is only valid until
+// the following call.
+func (s *aeadSealer) nextNonce() ([]byte, error) {
+	if s.seq == math.MaxUint64 {
+		return nil, errors.New("aead sequence number exhausted")
+	}
+	copy(s.nonce, s.iv)
+	l := len(s.nonce)
+	for i := 0; i < 8; i++ {
+		s.nonce[l-1-i] ^= byte(s.seq >> (8 * uint(i)))
+	}
+	s.seq++
+	return s.nonce, nil
+}
+
 // writeMsgCtx is used by the
 func writeMsgCtx(ctx context.Context, w msgio.Writer, msg proto.Message) ([]byte, error) {
 	enc, err := proto.Marshal(msg)
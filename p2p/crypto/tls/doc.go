@@ -0,0 +1,11 @@
+// Package tls secures connections with TLS 1.3, as an alternative to secio.
+// Each side presents a fresh self-signed certificate, carrying its ipfs
+// public key and the key's signature of the certificate's key, so peers are
+// authenticated by their ids rather than by certificate authorities.
+//
+// Certificates are made as in the libp2p TLS handshake, whose keys are
+// encoded like ours, so they can be checked by either.
+//
+// It needs go1.17 or later: built with older releases, the package is
+// empty, and connections are secured with secio only.
+package tls
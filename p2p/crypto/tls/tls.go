@@ -0,0 +1,206 @@
+//go:build go1.17
+// +build go1.17
+
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	gotls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
+	ci "github.com/jbenet/go-ipfs/p2p/crypto"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+)
+
+// ID is the name of the security protocol.
+const ID = "/tls/1.0.0"
+
+// alpn is offered during the handshake, so both sides know they are peers
+// rather than, say, browsers.
+const alpn = "libp2p"
+
+// signaturePrefix is signed by the ipfs key, together with the certificate
+// key, so the signature cannot be used for anything else.
+const signaturePrefix = "libp2p-tls-handshake:"
+
+// extensionID is the certificate extension holding the signedKey, from the
+// libp2p arc.
+var extensionID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 53594, 1, 1}
+
+// signedKey is the value of the certificate extension.
+type signedKey struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// ErrWrongPeer signals the remote peer is not the one we dialed.
+var ErrWrongPeer = errors.New("tls: remote peer is not the one dialed")
+
+// Client secures insecure as the dialing side, with the identity of sk. If
+// remote is not empty, the handshake fails unless the other side is remote.
+// It returns the secured connection and the remote peer's public key.
+func Client(ctx context.Context, sk ci.PrivKey, insecure net.Conn, remote peer.ID) (*gotls.Conn, ci.PubKey, error) {
+	conf, err := config(sk, remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	return handshake(ctx, gotls.Client(insecure, conf))
+}
+
+// Server secures insecure as the listening side, with the identity of sk.
+// It returns the secured connection and the remote peer's public key.
+func Server(ctx context.Context, sk ci.PrivKey, insecure net.Conn) (*gotls.Conn, ci.PubKey, error) {
+	conf, err := config(sk, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return handshake(ctx, gotls.Server(insecure, conf))
+}
+
+func handshake(ctx context.Context, tc *gotls.Conn) (*gotls.Conn, ci.PubKey, error) {
+	if err := tc.HandshakeContext(ctx); err != nil {
+		tc.Close()
+		return nil, nil, err
+	}
+
+	// verified during the handshake already.
+	pk, err := pubKeyFromCerts(tc.ConnectionState().PeerCertificates)
+	if err != nil {
+		tc.Close()
+		return nil, nil, err
+	}
+	return tc, pk, nil
+}
+
+func config(sk ci.PrivKey, remote peer.ID) (*gotls.Config, error) {
+	cert, err := makeCert(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gotls.Config{
+		MinVersion:   gotls.VersionTLS13,
+		Certificates: []gotls.Certificate{cert},
+		NextProtos:   []string{alpn},
+
+		// there is no chain to verify: VerifyPeerCertificate checks the
+		// certificate was signed by the peer's ipfs key instead.
+		InsecureSkipVerify: true,
+		ClientAuth:         gotls.RequireAnyClientCert,
+		VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+			certs := make([]*x509.Certificate, len(raw))
+			for i, r := range raw {
+				c, err := x509.ParseCertificate(r)
+				if err != nil {
+					return err
+				}
+				certs[i] = c
+			}
+			pk, err := pubKeyFromCerts(certs)
+			if err != nil {
+				return err
+			}
+			if remote != "" && !remote.MatchesPublicKey(pk) {
+				return ErrWrongPeer
+			}
+			return nil
+		},
+		SessionTicketsDisabled: true,
+	}, nil
+}
+
+// makeCert makes a certificate for sk, with a new key.
+func makeCert(sk ci.PrivKey) (gotls.Certificate, error) {
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return gotls.Certificate{}, err
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&certKey.PublicKey)
+	if err != nil {
+		return gotls.Certificate{}, err
+	}
+
+	pkBytes, err := sk.GetPublic().Bytes()
+	if err != nil {
+		return gotls.Certificate{}, err
+	}
+	sig, err := sk.Sign(append([]byte(signaturePrefix), spki...))
+	if err != nil {
+		return gotls.Certificate{}, err
+	}
+	ext, err := asn1.Marshal(signedKey{PubKey: pkBytes, Signature: sig})
+	if err != nil {
+		return gotls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return gotls.Certificate{}, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:    serial,
+		NotBefore:       now.Add(-time.Hour),
+		NotAfter:        now.Add(24 * time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: extensionID, Critical: true, Value: ext}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &certKey.PublicKey, certKey)
+	if err != nil {
+		return gotls.Certificate{}, err
+	}
+	return gotls.Certificate{Certificate: [][]byte{der}, PrivateKey: certKey}, nil
+}
+
+// pubKeyFromCerts returns the ipfs key that signed the certificate. The
+// certificates are made for each handshake, so their validity period is not
+// checked: peers' clocks need not agree.
+func pubKeyFromCerts(certs []*x509.Certificate) (ci.PubKey, error) {
+	if len(certs) != 1 {
+		return nil, fmt.Errorf("tls: expected one certificate, got %d", len(certs))
+	}
+	cert := certs[0]
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return nil, fmt.Errorf("tls: certificate not self-signed: %s", err)
+	}
+
+	var ext []byte
+	for _, e := range cert.Extensions {
+		if e.Id.Equal(extensionID) {
+			ext = e.Value
+		}
+	}
+	if ext == nil {
+		return nil, errors.New("tls: certificate carries no ipfs key")
+	}
+
+	var sk signedKey
+	if rest, err := asn1.Unmarshal(ext, &sk); err != nil {
+		return nil, fmt.Errorf("tls: bad ipfs key extension: %s", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("tls: trailing data after ipfs key extension")
+	}
+	pk, err := ci.UnmarshalPublicKey(sk.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := pk.Verify(append([]byte(signaturePrefix), cert.RawSubjectPublicKeyInfo...), sk.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("tls: certificate key not signed by the ipfs key")
+	}
+	return pk, nil
+}
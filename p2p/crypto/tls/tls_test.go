@@ -0,0 +1,77 @@
+//go:build go1.17
+// +build go1.17
+
+package tls
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+
+	tu "github.com/jbenet/go-ipfs/util/testutil"
+)
+
+func parseCert(t *testing.T, der []byte) *x509.Certificate {
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCertCarriesKey(t *testing.T) {
+	sk, pk, err := tu.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := makeCert(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := pubKeyFromCerts([]*x509.Certificate{parseCert(t, cert.Certificate[0])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equals(pk) {
+		t.Fatal("certificate carries the wrong key")
+	}
+}
+
+func TestCertKeySwapped(t *testing.T) {
+	sk1, _, err := tu.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk2, _, err := tu.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert1, err := makeCert(sk1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert2, err := makeCert(sk2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// put the extension of cert2 in cert1: its signature is of another
+	// certificate key. cert1 is signed again, so only that is wrong.
+	c1 := parseCert(t, cert1.Certificate[0])
+	c2 := parseCert(t, cert2.Certificate[0])
+	for i, e := range c1.Extensions {
+		if e.Id.Equal(extensionID) {
+			c1.Extensions[i] = c2.Extensions[i]
+		}
+	}
+	c1.ExtraExtensions = c1.Extensions
+	der, err := x509.CreateCertificate(rand.Reader, c1, c1, c1.PublicKey, cert1.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pubKeyFromCerts([]*x509.Certificate{parseCert(t, der)}); err == nil {
+		t.Fatal("accepted a certificate key signed for another certificate")
+	}
+}
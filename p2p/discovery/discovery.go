@@ -0,0 +1,28 @@
+// Package discovery implements services which find peers on the local
+// network, without going through bootstrap peers or the dht.
+package discovery
+
+import (
+	"io"
+
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+)
+
+var log = eventlog.Logger("p2p/discovery")
+
+// Service is a running discovery service.
+type Service interface {
+	io.Closer
+
+	// RegisterNotifee registers n to be told about every peer found.
+	RegisterNotifee(Notifee)
+
+	// UnregisterNotifee stops telling n about peers found.
+	UnregisterNotifee(Notifee)
+}
+
+// Notifee is told about the peers found by a discovery Service.
+type Notifee interface {
+	HandlePeerFound(peer.PeerInfo)
+}
@@ -0,0 +1,239 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// This file implements the small subset of the DNS wire format needed
+// for mDNS discovery: questions, and PTR and TXT records. Names are never
+// compressed when writing, but compression pointers are followed when
+// reading, as other mDNS responders on the network do use them.
+
+const (
+	dnsTypePTR = 12
+	dnsTypeTXT = 16
+	dnsClassIN = 1
+
+	dnsFlagResponse = 0x8400 // QR + AA
+	dnsHeaderLen    = 12
+	dnsMaxPointers  = 16 // bound on compression pointers followed per name
+)
+
+var errMalformedDNS = errors.New("malformed dns message")
+
+type dnsQuestion struct {
+	Name string
+	Type uint16
+}
+
+type dnsRecord struct {
+	Name string
+	Type uint16
+	TTL  uint32
+	Data []byte // raw rdata
+}
+
+type dnsMessage struct {
+	Response  bool
+	Questions []dnsQuestion
+	Answers   []dnsRecord
+	Extra     []dnsRecord
+}
+
+// Marshal encodes the message in the DNS wire format.
+func (m *dnsMessage) Marshal() ([]byte, error) {
+	buf := make([]byte, dnsHeaderLen)
+	if m.Response {
+		binary.BigEndian.PutUint16(buf[2:], dnsFlagResponse)
+	}
+	binary.BigEndian.PutUint16(buf[4:], uint16(len(m.Questions)))
+	binary.BigEndian.PutUint16(buf[6:], uint16(len(m.Answers)))
+	binary.BigEndian.PutUint16(buf[10:], uint16(len(m.Extra)))
+
+	var err error
+	for _, q := range m.Questions {
+		if buf, err = appendName(buf, q.Name); err != nil {
+			return nil, err
+		}
+		buf = appendUint16(buf, q.Type)
+		buf = appendUint16(buf, dnsClassIN)
+	}
+
+	for _, rrs := range [][]dnsRecord{m.Answers, m.Extra} {
+		for _, rr := range rrs {
+			if buf, err = appendName(buf, rr.Name); err != nil {
+				return nil, err
+			}
+			buf = appendUint16(buf, rr.Type)
+			buf = appendUint16(buf, dnsClassIN)
+			buf = appendUint32(buf, rr.TTL)
+			buf = appendUint16(buf, uint16(len(rr.Data)))
+			buf = append(buf, rr.Data...)
+		}
+	}
+	return buf, nil
+}
+
+// Unmarshal decodes a DNS message. Authority records are skipped.
+func (m *dnsMessage) Unmarshal(msg []byte) error {
+	if len(msg) < dnsHeaderLen {
+		return errMalformedDNS
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	m.Response = flags&0x8000 != 0
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	an := int(binary.BigEndian.Uint16(msg[6:]))
+	ns := int(binary.BigEndian.Uint16(msg[8:]))
+	ar := int(binary.BigEndian.Uint16(msg[10:]))
+
+	off := dnsHeaderLen
+	m.Questions = nil
+	for i := 0; i < qd; i++ {
+		name, n, err := readName(msg, off)
+		if err != nil {
+			return err
+		}
+		off = n
+		if off+4 > len(msg) {
+			return errMalformedDNS
+		}
+		m.Questions = append(m.Questions, dnsQuestion{
+			Name: name,
+			Type: binary.BigEndian.Uint16(msg[off:]),
+		})
+		off += 4
+	}
+
+	var err error
+	m.Answers, off, err = readRecords(msg, off, an)
+	if err != nil {
+		return err
+	}
+	_, off, err = readRecords(msg, off, ns)
+	if err != nil {
+		return err
+	}
+	m.Extra, _, err = readRecords(msg, off, ar)
+	return err
+}
+
+func readRecords(msg []byte, off, count int) ([]dnsRecord, int, error) {
+	var rrs []dnsRecord
+	for i := 0; i < count; i++ {
+		name, n, err := readName(msg, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		off = n
+		if off+10 > len(msg) {
+			return nil, 0, errMalformedDNS
+		}
+		rr := dnsRecord{
+			Name: name,
+			Type: binary.BigEndian.Uint16(msg[off:]),
+			TTL:  binary.BigEndian.Uint32(msg[off+4:]),
+		}
+		l := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+l > len(msg) {
+			return nil, 0, errMalformedDNS
+		}
+		rr.Data = msg[off : off+l]
+		off += l
+		rrs = append(rrs, rr)
+	}
+	return rrs, off, nil
+}
+
+// appendName appends name (dot separated, optionally dot terminated) as
+// a sequence of labels.
+func appendName(buf []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, errors.New("invalid dns label in " + name)
+			}
+			buf = append(buf, byte(len(label)))
+			buf = append(buf, label...)
+		}
+	}
+	return append(buf, 0), nil
+}
+
+// readName reads the name at off, following compression pointers. It
+// returns the name (dot terminated) and the offset right after it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1 // offset after the name, set when we follow the first pointer
+	for ptrs := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errMalformedDNS
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+
+		case l&0xC0 == 0xC0: // compression pointer
+			if off+1 >= len(msg) || ptrs >= dnsMaxPointers {
+				return "", 0, errMalformedDNS
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+			ptrs++
+
+		case l&0xC0 != 0:
+			return "", 0, errMalformedDNS
+
+		default:
+			if off+1+l > len(msg) {
+				return "", 0, errMalformedDNS
+			}
+			labels = append(labels, string(msg[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
+
+// txtData encodes strings as TXT record data.
+func txtData(strs []string) ([]byte, error) {
+	var buf []byte
+	for _, s := range strs {
+		if len(s) > 255 {
+			return nil, errors.New("txt string too long")
+		}
+		buf = append(buf, byte(len(s)))
+		buf = append(buf, s...)
+	}
+	return buf, nil
+}
+
+// parseTXT decodes TXT record data into its strings.
+func parseTXT(data []byte) ([]string, error) {
+	var strs []string
+	for len(data) > 0 {
+		l := int(data[0])
+		if 1+l > len(data) {
+			return nil, errMalformedDNS
+		}
+		strs = append(strs, string(data[1:1+l]))
+		data = data[1+l:]
+	}
+	return strs, nil
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v>>8), byte(v))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
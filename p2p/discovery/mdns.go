@@ -0,0 +1,291 @@
+package discovery
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+
+	host "github.com/jbenet/go-ipfs/p2p/host"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+)
+
+// ServiceTag is the mDNS (DNS-SD) service name ipfs nodes advertise.
+const ServiceTag = "_ipfs-discovery._udp.local."
+
+const (
+	mdnsTTL       = 120  // ttl of the records we advertise, in seconds
+	mdnsMaxPacket = 9000 // mDNS packets are limited to the link MTU
+)
+
+// mdnsGroup is the IPv4 mDNS multicast group.
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// transport sends and receives mDNS packets. It is the multicast socket
+// in practice, and a loopback stand-in in tests.
+type transport interface {
+	// Send sends the packet to all listeners.
+	Send([]byte) error
+
+	// Recv blocks until the next packet arrives.
+	Recv() ([]byte, error)
+
+	Close() error
+}
+
+// udpTransport is a transport over the mDNS multicast group.
+type udpTransport struct {
+	conn *net.UDPConn
+}
+
+func newUDPTransport() (*udpTransport, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, err
+	}
+	return &udpTransport{conn: conn}, nil
+}
+
+func (t *udpTransport) Send(pkt []byte) error {
+	_, err := t.conn.WriteToUDP(pkt, mdnsGroup)
+	return err
+}
+
+func (t *udpTransport) Recv() ([]byte, error) {
+	buf := make([]byte, mdnsMaxPacket)
+	n, _, err := t.conn.ReadFromUDP(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func (t *udpTransport) Close() error {
+	return t.conn.Close()
+}
+
+// mdnsService advertises the host's peer ID and listen addresses on the
+// local network, and reports the peers which advertise theirs.
+type mdnsService struct {
+	host     host.Host
+	trans    transport
+	interval time.Duration
+
+	lk       sync.Mutex
+	notifees []Notifee
+
+	closing chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewMdnsService starts advertising h on the local network, and queries
+// for other peers every interval.
+func NewMdnsService(h host.Host, interval time.Duration) (Service, error) {
+	t, err := newUDPTransport()
+	if err != nil {
+		return nil, err
+	}
+	return newMdnsService(h, interval, t), nil
+}
+
+func newMdnsService(h host.Host, interval time.Duration, t transport) *mdnsService {
+	s := &mdnsService{
+		host:     h,
+		trans:    t,
+		interval: interval,
+		closing:  make(chan struct{}),
+	}
+
+	s.wg.Add(2)
+	go s.pollForEntries()
+	go s.handlePackets()
+	return s
+}
+
+func (s *mdnsService) Close() error {
+	close(s.closing)
+	err := s.trans.Close() // unblocks Recv.
+	s.wg.Wait()
+	return err
+}
+
+func (s *mdnsService) RegisterNotifee(n Notifee) {
+	s.lk.Lock()
+	s.notifees = append(s.notifees, n)
+	s.lk.Unlock()
+}
+
+func (s *mdnsService) UnregisterNotifee(n Notifee) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for i, notif := range s.notifees {
+		if notif == n {
+			s.notifees = append(s.notifees[:i], s.notifees[i+1:]...)
+			return
+		}
+	}
+}
+
+// pollForEntries queries the network for peers every interval.
+func (s *mdnsService) pollForEntries() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.sendQuery()
+
+		select {
+		case <-ticker.C:
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+func (s *mdnsService) sendQuery() {
+	q := &dnsMessage{
+		Questions: []dnsQuestion{{Name: ServiceTag, Type: dnsTypePTR}},
+	}
+	s.send(q)
+}
+
+// sendAnnouncement tells the network who we are: a PTR record pointing to
+// our instance name, and the TXT record holding our id and addresses.
+func (s *mdnsService) sendAnnouncement() {
+	id := peer.IDB58Encode(s.host.ID())
+	instance := id + "." + ServiceTag
+
+	addrs, err := s.host.Network().InterfaceListenAddresses()
+	if err != nil {
+		log.Errorf("mdns: failed to get listen addresses: %s", err)
+		return
+	}
+
+	txt := []string{"id=" + id}
+	for _, a := range addrs {
+		txt = append(txt, "addr="+a.String())
+	}
+	txtd, err := txtData(txt)
+	if err != nil {
+		log.Errorf("mdns: failed to encode announcement: %s", err)
+		return
+	}
+	ptrd, err := appendName(nil, instance)
+	if err != nil {
+		log.Errorf("mdns: failed to encode announcement: %s", err)
+		return
+	}
+
+	s.send(&dnsMessage{
+		Response: true,
+		Answers:  []dnsRecord{{Name: ServiceTag, Type: dnsTypePTR, TTL: mdnsTTL, Data: ptrd}},
+		Extra:    []dnsRecord{{Name: instance, Type: dnsTypeTXT, TTL: mdnsTTL, Data: txtd}},
+	})
+}
+
+func (s *mdnsService) send(m *dnsMessage) {
+	pkt, err := m.Marshal()
+	if err != nil {
+		log.Errorf("mdns: failed to marshal message: %s", err)
+		return
+	}
+	if err := s.trans.Send(pkt); err != nil {
+		log.Debugf("mdns: failed to send message: %s", err)
+	}
+}
+
+// handlePackets answers queries for our service, and reports the peers
+// found in responses.
+func (s *mdnsService) handlePackets() {
+	defer s.wg.Done()
+
+	for {
+		pkt, err := s.trans.Recv()
+		if err != nil {
+			select {
+			case <-s.closing:
+			default:
+				log.Errorf("mdns: receive failed: %s", err)
+			}
+			return
+		}
+
+		var m dnsMessage
+		if err := m.Unmarshal(pkt); err != nil {
+			log.Debugf("mdns: dropping bad packet: %s", err)
+			continue
+		}
+
+		if !m.Response {
+			for _, q := range m.Questions {
+				if q.Type == dnsTypePTR && strings.EqualFold(q.Name, ServiceTag) {
+					s.sendAnnouncement()
+					break
+				}
+			}
+			continue
+		}
+
+		for _, rr := range append(m.Answers, m.Extra...) {
+			if rr.Type != dnsTypeTXT || !strings.HasSuffix(strings.ToLower(rr.Name), ServiceTag) {
+				continue
+			}
+			pi, err := parsePeerTXT(rr.Data)
+			if err != nil {
+				log.Debugf("mdns: bad peer record: %s", err)
+				continue
+			}
+			if pi.ID == s.host.ID() {
+				continue // ourselves.
+			}
+			s.peerFound(pi)
+		}
+	}
+}
+
+func (s *mdnsService) peerFound(pi peer.PeerInfo) {
+	log.Debugf("mdns: found peer %s at %s", pi.ID, pi.Addrs)
+
+	s.lk.Lock()
+	notifees := make([]Notifee, len(s.notifees))
+	copy(notifees, s.notifees)
+	s.lk.Unlock()
+
+	for _, n := range notifees {
+		go n.HandlePeerFound(pi)
+	}
+}
+
+// parsePeerTXT reads the peer info advertised in a TXT record.
+func parsePeerTXT(data []byte) (peer.PeerInfo, error) {
+	var pi peer.PeerInfo
+	strs, err := parseTXT(data)
+	if err != nil {
+		return pi, err
+	}
+
+	for _, s := range strs {
+		switch {
+		case strings.HasPrefix(s, "id="):
+			pi.ID, err = peer.IDB58Decode(strings.TrimPrefix(s, "id="))
+			if err != nil {
+				return pi, err
+			}
+		case strings.HasPrefix(s, "addr="):
+			addr, err := ma.NewMultiaddr(strings.TrimPrefix(s, "addr="))
+			if err != nil {
+				log.Debugf("mdns: bad address in peer record: %s", err)
+				continue
+			}
+			pi.Addrs = append(pi.Addrs, addr)
+		}
+	}
+
+	if pi.ID == "" {
+		return pi, errMalformedDNS
+	}
+	return pi, nil
+}
@@ -0,0 +1,224 @@
+package discovery
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
+	host "github.com/jbenet/go-ipfs/p2p/host"
+	inet "github.com/jbenet/go-ipfs/p2p/net"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	netutil "github.com/jbenet/go-ipfs/p2p/test/util"
+)
+
+// bus is a loopback-only stand-in for the multicast group: every packet
+// sent is delivered to all members, including the sender.
+type bus struct {
+	lk      sync.Mutex
+	members []*busTransport
+}
+
+func (b *bus) join() *busTransport {
+	t := &busTransport{
+		bus:    b,
+		in:     make(chan []byte, 16),
+		closed: make(chan struct{}),
+	}
+	b.lk.Lock()
+	b.members = append(b.members, t)
+	b.lk.Unlock()
+	return t
+}
+
+type busTransport struct {
+	bus    *bus
+	in     chan []byte
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (t *busTransport) Send(pkt []byte) error {
+	t.bus.lk.Lock()
+	defer t.bus.lk.Unlock()
+	for _, m := range t.bus.members {
+		select {
+		case m.in <- pkt:
+		default: // dropped, like udp.
+		}
+	}
+	return nil
+}
+
+func (t *busTransport) Recv() ([]byte, error) {
+	select {
+	case pkt := <-t.in:
+		return pkt, nil
+	case <-t.closed:
+		return nil, errors.New("transport closed")
+	}
+}
+
+func (t *busTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+// connector connects to every peer found, like the core node does.
+type connector struct {
+	h host.Host
+}
+
+func (c *connector) HandlePeerFound(pi peer.PeerInfo) {
+	ctx, _ := context.WithTimeout(context.Background(), time.Second)
+	c.h.Connect(ctx, pi)
+}
+
+func TestMdnsDiscovery(t *testing.T) {
+	ctx := context.Background()
+	b := &bus{}
+
+	a := netutil.GenHostSwarm(t, ctx)
+	c := netutil.GenHostSwarm(t, ctx)
+	defer a.Close()
+	defer c.Close()
+
+	sa := newMdnsService(a, 50*time.Millisecond, b.join())
+	sc := newMdnsService(c, 50*time.Millisecond, b.join())
+	defer sa.Close()
+	defer sc.Close()
+	sa.RegisterNotifee(&connector{a})
+
+	deadline := time.After(5 * time.Second)
+	for a.Network().Connectedness(c.ID()) != inet.Connected {
+		select {
+		case <-deadline:
+			t.Fatal("peers did not find each other")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// recorder records the peers found.
+type recorder chan peer.PeerInfo
+
+func (r recorder) HandlePeerFound(pi peer.PeerInfo) {
+	r <- pi
+}
+
+func TestMdnsAnnouncement(t *testing.T) {
+	ctx := context.Background()
+	b := &bus{}
+
+	a := netutil.GenHostSwarm(t, ctx)
+	c := netutil.GenHostSwarm(t, ctx)
+	defer a.Close()
+	defer c.Close()
+
+	// c only answers queries, it does not poll in the duration of the test.
+	sa := newMdnsService(a, time.Hour, b.join())
+	defer sa.Close()
+	found := make(recorder, 10)
+	sa.RegisterNotifee(found)
+
+	sc := newMdnsService(c, time.Hour, b.join())
+	defer sc.Close()
+
+	addrs, err := c.Network().InterfaceListenAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case pi := <-found:
+		if pi.ID != c.ID() {
+			t.Fatalf("found wrong peer: %s", pi.ID)
+		}
+		if len(pi.Addrs) != len(addrs) {
+			t.Fatalf("expected %d addresses, got %d", len(addrs), len(pi.Addrs))
+		}
+		for i := range addrs {
+			if !pi.Addrs[i].Equal(addrs[i]) {
+				t.Fatalf("expected address %s, got %s", addrs[i], pi.Addrs[i])
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("peer was not found")
+	}
+
+	// stop reporting peers after unregistering.
+	sa.UnregisterNotifee(found)
+	// c may have been found more than once before that.
+	for drained := false; !drained; {
+		select {
+		case <-found:
+		case <-time.After(50 * time.Millisecond):
+			drained = true
+		}
+	}
+	sa.sendQuery()
+	select {
+	case pi := <-found:
+		t.Fatalf("unregistered notifee found %s", pi.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDNSMessageRoundTrip(t *testing.T) {
+	txt, err := txtData([]string{"id=foo", "addr=/ip4/127.0.0.1/tcp/4001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &dnsMessage{
+		Response:  true,
+		Questions: []dnsQuestion{{Name: ServiceTag, Type: dnsTypePTR}},
+		Answers:   []dnsRecord{{Name: ServiceTag, Type: dnsTypePTR, TTL: 10, Data: []byte{0}}},
+		Extra:     []dnsRecord{{Name: "foo." + ServiceTag, Type: dnsTypeTXT, TTL: 10, Data: txt}},
+	}
+	pkt, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m2 dnsMessage
+	if err := m2.Unmarshal(pkt); err != nil {
+		t.Fatal(err)
+	}
+	if !m2.Response || len(m2.Questions) != 1 || len(m2.Answers) != 1 || len(m2.Extra) != 1 {
+		t.Fatal("message did not round trip")
+	}
+	if m2.Questions[0].Name != ServiceTag || m2.Extra[0].Name != "foo."+ServiceTag {
+		t.Fatal("names did not round trip")
+	}
+	strs, err := parseTXT(m2.Extra[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strs) != 2 || strs[1] != "addr=/ip4/127.0.0.1/tcp/4001" {
+		t.Fatalf("txt did not round trip: %v", strs)
+	}
+}
+
+func TestDNSCompressedName(t *testing.T) {
+	// "local." at offset 12, then "a" + pointer to it.
+	msg := make([]byte, dnsHeaderLen)
+	msg = append(msg, 5, 'l', 'o', 'c', 'a', 'l', 0)
+	msg = append(msg, 1, 'a', 0xC0, dnsHeaderLen)
+
+	name, end, err := readName(msg, dnsHeaderLen+7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "a.local." || end != len(msg) {
+		t.Fatalf("bad name %q or end %d", name, end)
+	}
+
+	// pointer loops must fail, not hang.
+	loop := make([]byte, dnsHeaderLen)
+	loop = append(loop, 0xC0, dnsHeaderLen)
+	if _, _, err := readName(loop, dnsHeaderLen); err == nil {
+		t.Fatal("expected pointer loop to fail")
+	}
+}
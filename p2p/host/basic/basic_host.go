@@ -2,10 +2,13 @@ package basichost
 
 import (
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 
 	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
 
+	nat "github.com/jbenet/go-ipfs/p2p/nat"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	connmgr "github.com/jbenet/go-ipfs/p2p/net/connmgr"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
 	identify "github.com/jbenet/go-ipfs/p2p/protocol/identify"
@@ -14,24 +17,43 @@ import (
 
 var log = eventlog.Logger("p2p/host/basic")
 
+// Option is a type used to pass in options to the host.
+type Option int
+
+const (
+	// NATPortMap makes the host ask the NAT gateway of the local network
+	// to forward the swarm's listen ports, and advertise the result.
+	NATPortMap Option = iota
+)
+
 type BasicHost struct {
 	network inet.Network
 	mux     *protocol.Mux
 	ids     *identify.IDService
 	relay   *relay.RelayService
+	natmgr  *natManager
+	cmgr    connmgr.ConnManager
 }
 
 // New constructs and sets up a new *BasicHost with given Network
-func New(net inet.Network) *BasicHost {
+func New(net inet.Network, opts ...Option) *BasicHost {
 	h := &BasicHost{
 		network: net,
 		mux:     protocol.NewMux(),
+		cmgr:    connmgr.NullConnManager{},
 	}
 
 	// setup host services
 	h.ids = identify.NewIDService(h)
 	h.relay = relay.NewRelayService(h, h.Mux().HandleSync)
 
+	for _, o := range opts {
+		switch o {
+		case NATPortMap:
+			h.natmgr = newNatManager(h, nat.DiscoverGateway)
+		}
+	}
+
 	net.SetConnHandler(h.newConnHandler)
 	net.SetStreamHandler(h.newStreamHandler)
 
@@ -80,9 +102,9 @@ func (h *BasicHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler
 	h.Mux().SetHandler(pid, handler)
 }
 
-// NewStream opens a new stream to given peer p, and writes a p2p/protocol
-// header with given protocol.ID. If there is no connection to p, attempts
-// to create one. If ProtocolID is "", writes no header.
+// NewStream opens a new stream to given peer p, and negotiates the given
+// protocol.ID on it. If there is no connection to p, attempts to create one.
+// If p does not handle the protocol, protocol.ErrNotSupported is returned.
 // (Threadsafe)
 func (h *BasicHost) NewStream(pid protocol.ID, p peer.ID) (inet.Stream, error) {
 	s, err := h.Network().NewStream(p)
@@ -90,7 +112,7 @@ func (h *BasicHost) NewStream(pid protocol.ID, p peer.ID) (inet.Stream, error) {
 		return nil, err
 	}
 
-	if err := protocol.WriteHeader(s, pid); err != nil {
+	if err := protocol.SelectProto(s, pid); err != nil {
 		s.Close()
 		return nil, err
 	}
@@ -106,7 +128,7 @@ func (h *BasicHost) NewStream(pid protocol.ID, p peer.ID) (inet.Stream, error) {
 func (h *BasicHost) Connect(ctx context.Context, pi peer.PeerInfo) error {
 
 	// absorb addresses into peerstore
-	h.Peerstore().AddPeerInfo(pi)
+	h.Peerstore().AddPeerInfo(pi, peer.TempAddrTTL)
 
 	cs := h.Network().ConnsToPeer(pi.ID)
 	if len(cs) > 0 {
@@ -143,7 +165,64 @@ func (h *BasicHost) dialPeer(ctx context.Context, p peer.ID) error {
 	return nil
 }
 
+// Addrs returns the addresses this host advertises to other peers: the
+// addresses it listens on, plus the external addresses the NAT gateway
+// forwards to them. Without port mappings, the addresses other peers
+// observed us at are included instead. Relayed connections are accepted
+// through each connected relay.
+func (h *BasicHost) Addrs() []ma.Multiaddr {
+	addrs, err := h.Network().InterfaceListenAddresses()
+	if err != nil {
+		log.Debug("error retrieving network interface addrs")
+	}
+	addrs = append(addrs, h.relay.RelayAddrs()...)
+
+	var extra []ma.Multiaddr
+	if h.natmgr != nil {
+		extra = h.natmgr.ExternalAddrs()
+	}
+	if len(extra) == 0 {
+		// peerstore self addrs include the observed ones.
+		extra = h.Peerstore().Addresses(h.ID())
+	}
+	return dedupAddrs(append(addrs, extra...))
+}
+
+// ConnManager returns the Host's connection manager.
+func (h *BasicHost) ConnManager() connmgr.ConnManager {
+	return h.cmgr
+}
+
+// SetConnManager makes cm the Host's connection manager. By default, the
+// Host does not manage its connections.
+func (h *BasicHost) SetConnManager(cm connmgr.ConnManager) {
+	h.cmgr = cm
+}
+
+// Relay returns the Host's relay service.
+func (h *BasicHost) Relay() *relay.RelayService {
+	return h.relay
+}
+
 // Close shuts down the Host's services (network, etc).
 func (h *BasicHost) Close() error {
+	if h.natmgr != nil {
+		if err := h.natmgr.Close(); err != nil {
+			log.Debugf("error removing NAT port mappings: %s", err)
+		}
+	}
 	return h.Network().Close()
 }
+
+func dedupAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
+	seen := make(map[string]struct{}, len(addrs))
+	out := addrs[:0]
+	for _, a := range addrs {
+		if _, found := seen[a.String()]; found {
+			continue
+		}
+		seen[a.String()] = struct{}{}
+		out = append(out, a)
+	}
+	return out
+}
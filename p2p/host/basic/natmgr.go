@@ -0,0 +1,200 @@
+package basichost
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+
+	nat "github.com/jbenet/go-ipfs/p2p/nat"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+)
+
+// MappingLifetime is the lifetime requested for NAT port mappings. The
+// mappings are renewed when half of it has passed.
+var MappingLifetime = time.Hour
+
+// errNotMappable is returned for addresses a gateway cannot forward to.
+var errNotMappable = errors.New("address cannot be mapped by a NAT gateway")
+
+// mappingDescription is shown in the gateway's list of port mappings.
+const mappingDescription = "ipfs"
+
+// natManager finds the NAT gateway, asks it to forward each of the host's
+// listen ports, and keeps the mappings alive. It is created by BasicHost
+// when the NATPortMap option is given.
+type natManager struct {
+	host     *BasicHost
+	discover func() (nat.NAT, error)
+
+	lk       sync.Mutex
+	nat      nat.NAT
+	mappings map[string]ma.Multiaddr // listen addr -> external addr
+
+	ready   chan struct{} // closed once discovery and first mapping end.
+	closing chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newNatManager(h *BasicHost, discover func() (nat.NAT, error)) *natManager {
+	nmgr := &natManager{
+		host:     h,
+		discover: discover,
+		mappings: make(map[string]ma.Multiaddr),
+		ready:    make(chan struct{}),
+		closing:  make(chan struct{}),
+	}
+
+	nmgr.wg.Add(1)
+	go nmgr.run()
+	return nmgr
+}
+
+// Ready returns a channel which is closed once the gateway was looked for,
+// and the first port mappings were made (or failed).
+func (nmgr *natManager) Ready() <-chan struct{} {
+	return nmgr.ready
+}
+
+// ExternalAddrs returns the external addresses the gateway forwards to us.
+func (nmgr *natManager) ExternalAddrs() []ma.Multiaddr {
+	nmgr.lk.Lock()
+	defer nmgr.lk.Unlock()
+
+	addrs := make([]ma.Multiaddr, 0, len(nmgr.mappings))
+	for _, a := range nmgr.mappings {
+		addrs = append(addrs, a)
+	}
+	return addrs
+}
+
+// Close stops renewing the mappings, and removes them from the gateway.
+func (nmgr *natManager) Close() error {
+	close(nmgr.closing)
+	nmgr.wg.Wait()
+
+	nmgr.lk.Lock()
+	n, mappings := nmgr.nat, nmgr.mappings
+	nmgr.mappings = make(map[string]ma.Multiaddr)
+	nmgr.lk.Unlock()
+	if n == nil {
+		return nil
+	}
+
+	var firstErr error
+	for laddr := range mappings {
+		_, port, err := natMappable(ma.StringCast(laddr))
+		if err != nil {
+			continue
+		}
+		if err := n.DeletePortMapping("tcp", port); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (nmgr *natManager) run() {
+	defer nmgr.wg.Done()
+
+	n, err := nmgr.discover()
+	if err != nil {
+		log.Infof("no NAT gateway found, not mapping ports: %s", err)
+		close(nmgr.ready)
+		return
+	}
+
+	nmgr.lk.Lock()
+	nmgr.nat = n
+	nmgr.lk.Unlock()
+
+	nmgr.mapAll()
+	close(nmgr.ready)
+
+	ticker := time.NewTicker(MappingLifetime / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			nmgr.mapAll()
+		case <-nmgr.closing:
+			return
+		}
+	}
+}
+
+// mapAll (re)maps all the host's listen addresses, and advertises the
+// resulting external addresses. The gateway is asked without holding lk,
+// so ExternalAddrs does not wait on it.
+func (nmgr *natManager) mapAll() {
+	nmgr.lk.Lock()
+	n := nmgr.nat
+	nmgr.lk.Unlock()
+
+	extIP, err := n.ExternalAddress()
+	if err != nil {
+		log.Errorf("failed to get NAT external address: %s", err)
+		return
+	}
+
+	mapped := make(map[string]ma.Multiaddr)
+	var failed []string
+	for _, laddr := range nmgr.host.Network().ListenAddresses() {
+		_, port, err := natMappable(laddr)
+		if err != nil {
+			log.Debugf("not mapping %s: %s", laddr, err)
+			continue
+		}
+
+		extPort, err := n.AddPortMapping("tcp", port, mappingDescription, MappingLifetime)
+		if err != nil {
+			log.Errorf("failed to map %s on the NAT: %s", laddr, err)
+			failed = append(failed, laddr.String())
+			continue
+		}
+
+		extAddr, err := manet.FromNetAddr(&net.TCPAddr{IP: extIP, Port: extPort})
+		if err != nil {
+			log.Errorf("bad NAT external address %s:%d: %s", extIP, extPort, err)
+			continue
+		}
+		mapped[laddr.String()] = extAddr
+	}
+
+	nmgr.lk.Lock()
+	for _, laddr := range failed {
+		delete(nmgr.mappings, laddr)
+	}
+	for laddr, extAddr := range mapped {
+		if old, found := nmgr.mappings[laddr]; !found || !old.Equal(extAddr) {
+			log.Infof("NAT mapped %s to %s", laddr, extAddr)
+		}
+		nmgr.mappings[laddr] = extAddr
+	}
+	nmgr.lk.Unlock()
+
+	// advertise them, like observed addresses.
+	for _, extAddr := range mapped {
+		nmgr.host.Peerstore().AddAddress(nmgr.host.ID(), extAddr, peer.OwnObservedAddrTTL)
+	}
+}
+
+// natMappable returns the tcp address of laddr, if a gateway can forward
+// a port to it: ipv4 tcp, and not loopback.
+func natMappable(laddr ma.Multiaddr) (net.IP, int, error) {
+	naddr, err := manet.ToNetAddr(laddr)
+	if err != nil {
+		return nil, 0, err
+	}
+	taddr, ok := naddr.(*net.TCPAddr)
+	if !ok || taddr.IP.To4() == nil {
+		return nil, 0, errNotMappable
+	}
+	if taddr.IP.IsLoopback() {
+		return nil, 0, errNotMappable
+	}
+	return taddr.IP, taddr.Port, nil
+}
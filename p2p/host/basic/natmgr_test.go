@@ -0,0 +1,202 @@
+package basichost
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+
+	nat "github.com/jbenet/go-ipfs/p2p/nat"
+	swarm "github.com/jbenet/go-ipfs/p2p/net/swarm"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	tu "github.com/jbenet/go-ipfs/util/testutil"
+)
+
+// fakeNAT is a gateway which maps internal port p to external port p+1.
+// If block is set, mappings wait for it to be closed.
+type fakeNAT struct {
+	sync.Mutex
+	mapped  map[int]int
+	deleted []int
+	block   chan struct{}
+}
+
+func newFakeNAT() *fakeNAT {
+	return &fakeNAT{mapped: make(map[int]int)}
+}
+
+func (f *fakeNAT) Type() string { return "fake" }
+
+func (f *fakeNAT) ExternalAddress() (net.IP, error) {
+	return net.IPv4(203, 0, 113, 7), nil
+}
+
+func (f *fakeNAT) AddPortMapping(protocol string, port int, desc string, lifetime time.Duration) (int, error) {
+	if f.block != nil {
+		<-f.block
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.mapped[port] = port + 1
+	return port + 1, nil
+}
+
+func (f *fakeNAT) DeletePortMapping(protocol string, port int) error {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.mapped, port)
+	f.deleted = append(f.deleted, port)
+	return nil
+}
+
+// genHost makes a host listening on all interfaces, like the default
+// config does. (p2p/test/util imports this package, so it can't be used.)
+func genHost(t *testing.T, ctx context.Context) *BasicHost {
+	p := tu.RandPeerNetParamsOrFatal(t)
+	ps := peer.NewPeerstore()
+	ps.AddPubKey(p.ID, p.PubKey)
+	ps.AddPrivKey(p.ID, p.PrivKey)
+
+	laddr := ma.StringCast("/ip4/0.0.0.0/tcp/0")
+	n, err := swarm.NewNetwork(ctx, []ma.Multiaddr{laddr}, p.ID, ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps.AddAddresses(p.ID, n.ListenAddresses(), peer.PermanentAddrTTL)
+	return New(n)
+}
+
+func waitReady(t *testing.T, nmgr *natManager) {
+	select {
+	case <-nmgr.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("NAT manager did not finish mapping")
+	}
+}
+
+func hasAddr(as []ma.Multiaddr, a ma.Multiaddr) bool {
+	for _, b := range as {
+		if a.Equal(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNATMapsListenAddrs(t *testing.T) {
+	ctx := context.Background()
+	h := genHost(t, ctx)
+
+	gw := newFakeNAT()
+	h.natmgr = newNatManager(h, func() (nat.NAT, error) { return gw, nil })
+	waitReady(t, h.natmgr)
+
+	_, port, err := natMappable(h.Network().ListenAddresses()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.Lock()
+	extPort, found := gw.mapped[port]
+	gw.Unlock()
+	if !found {
+		t.Fatalf("listen port %d was not mapped", port)
+	}
+
+	ext, err := ma.NewMultiaddr("/ip4/203.0.113.7/tcp/" + strconv.Itoa(extPort))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasAddr(h.Addrs(), ext) {
+		t.Errorf("host addrs %s lack external addr %s", h.Addrs(), ext)
+	}
+	if !hasAddr(h.Peerstore().Addresses(h.ID()), ext) {
+		t.Errorf("external addr %s not in peerstore", ext)
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gw.Lock()
+	defer gw.Unlock()
+	if len(gw.mapped) != 0 || len(gw.deleted) != 1 || gw.deleted[0] != port {
+		t.Errorf("mapping not removed on close: mapped %v, deleted %v", gw.mapped, gw.deleted)
+	}
+}
+
+func TestNATSlowGatewayDoesNotBlockAddrs(t *testing.T) {
+	ctx := context.Background()
+	h := genHost(t, ctx)
+	defer h.Close()
+
+	gw := newFakeNAT()
+	gw.block = make(chan struct{})
+	h.natmgr = newNatManager(h, func() (nat.NAT, error) { return gw, nil })
+
+	addrs := make(chan []ma.Multiaddr)
+	go func() {
+		time.Sleep(50 * time.Millisecond) // let the manager ask the gateway.
+		addrs <- h.Addrs()
+	}()
+	select {
+	case <-addrs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("host addrs waited on the gateway")
+	}
+
+	close(gw.block)
+	waitReady(t, h.natmgr)
+	if len(h.natmgr.ExternalAddrs()) != 1 {
+		t.Errorf("expected one mapping, got %s", h.natmgr.ExternalAddrs())
+	}
+}
+
+func TestNATAdvertisedViaIdentify(t *testing.T) {
+	ctx := context.Background()
+	h1 := genHost(t, ctx)
+	h2 := genHost(t, ctx)
+	defer h1.Close()
+	defer h2.Close()
+
+	h1.natmgr = newNatManager(h1, func() (nat.NAT, error) { return newFakeNAT(), nil })
+	waitReady(t, h1.natmgr)
+	ext := h1.natmgr.ExternalAddrs()
+	if len(ext) != 1 {
+		t.Fatalf("expected one external addr, got %s", ext)
+	}
+
+	// dial h1 through a loopback addr; identify should tell h2 the rest.
+	_, port, _ := natMappable(h1.Network().ListenAddresses()[0])
+	lo := ma.StringCast("/ip4/127.0.0.1/tcp/" + strconv.Itoa(port))
+	if err := h2.Connect(ctx, peer.PeerInfo{ID: h1.ID(), Addrs: []ma.Multiaddr{lo}}); err != nil {
+		t.Fatal(err)
+	}
+	if !hasAddr(h2.Peerstore().Addresses(h1.ID()), ext[0]) {
+		t.Errorf("h2 did not learn h1's external addr %s: %s", ext[0], h2.Peerstore().Addresses(h1.ID()))
+	}
+}
+
+func TestNATFallsBackToObservedAddrs(t *testing.T) {
+	ctx := context.Background()
+	h := genHost(t, ctx)
+	defer h.Close()
+
+	h.natmgr = newNatManager(h, func() (nat.NAT, error) {
+		return nil, errors.New("no gateway here")
+	})
+	waitReady(t, h.natmgr)
+
+	if len(h.natmgr.ExternalAddrs()) != 0 {
+		t.Fatal("expected no external addrs without a gateway")
+	}
+
+	observed := ma.StringCast("/ip4/198.51.100.3/tcp/4001")
+	h.Peerstore().AddAddress(h.ID(), observed, peer.PermanentAddrTTL)
+	if !hasAddr(h.Addrs(), observed) {
+		t.Errorf("host addrs %s lack observed addr %s", h.Addrs(), observed)
+	}
+}
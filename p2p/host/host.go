@@ -2,8 +2,10 @@ package host
 
 import (
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	connmgr "github.com/jbenet/go-ipfs/p2p/net/connmgr"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
 	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
@@ -23,12 +25,19 @@ type Host interface {
 	// Peerstore returns the Host's repository of Peer Addresses and Keys.
 	Peerstore() peer.Peerstore
 
+	// Addrs returns the addresses other peers can reach this Host at.
+	Addrs() []ma.Multiaddr
+
 	// Networks returns the Network interface of the Host
 	Network() inet.Network
 
 	// Mux returns the Mux multiplexing incoming streams to protocol handlers
 	Mux() *protocol.Mux
 
+	// ConnManager returns the Host's connection manager, which services
+	// tell about the peers they rely on.
+	ConnManager() connmgr.ConnManager
+
 	// Connect ensures there is a connection between this host and the peer with
 	// given peer.ID. Connect will absorb the addresses in pi into its internal
 	// peerstore. If there is not an active connection, Connect will issue a
@@ -42,10 +51,10 @@ type Host interface {
 	// (Threadsafe)
 	SetStreamHandler(pid protocol.ID, handler inet.StreamHandler)
 
-	// NewStream opens a new stream to given peer p, and writes a p2p/protocol
-	// header with given protocol.ID. If there is no connection to p, attempts
-	// to create one. If ProtocolID is "", writes no header.
-	// (Threadsafe)
+	// NewStream opens a new stream to given peer p, and negotiates the given
+	// protocol.ID on it. If there is no connection to p, attempts to create
+	// one. If p does not handle the protocol, protocol.ErrNotSupported is
+	// returned. (Threadsafe)
 	NewStream(pid protocol.ID, p peer.ID) (inet.Stream, error)
 
 	// Close shuts down the host, its Network, and services.
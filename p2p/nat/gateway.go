@@ -0,0 +1,81 @@
+package nat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"strings"
+)
+
+// gatewayCandidates returns the addresses the local gateway may be at: the
+// default route's gateway when the system exposes it, and otherwise the
+// first address of each private network we are on (usually the router).
+func gatewayCandidates() []net.IP {
+	if gw := linuxDefaultGateway(); gw != nil {
+		return []net.IP{gw}
+	}
+
+	var ips []net.IP
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP.To4()
+		if ip == nil || !isPrivateIPv4(ip) {
+			continue
+		}
+		gw := ip.Mask(ipnet.Mask)
+		gw[3] |= 1
+		if !gw.Equal(ip) {
+			ips = append(ips, gw)
+		}
+	}
+	return ips
+}
+
+// linuxDefaultGateway reads the default route from /proc/net/route.
+func linuxDefaultGateway() net.IP {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Scan() // skip the header line.
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		// Iface Destination Gateway ...; the default route has dest 0.
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		// the kernel prints the address in host (little endian) order.
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+		return ip
+	}
+	return nil
+}
+
+func isPrivateIPv4(ip net.IP) bool {
+	switch {
+	case ip[0] == 10:
+		return true
+	case ip[0] == 172 && ip[1]&0xf0 == 16:
+		return true
+	case ip[0] == 192 && ip[1] == 168:
+		return true
+	}
+	return false
+}
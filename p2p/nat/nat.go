@@ -0,0 +1,69 @@
+// Package nat talks to the NAT gateway of the local network, to map
+// ports so peers outside of it can dial us. It supports UPnP IGD and
+// NAT-PMP gateways.
+package nat
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+)
+
+var log = eventlog.Logger("p2p/nat")
+
+// ErrNoNATFound is returned when no gateway answers discovery.
+var ErrNoNATFound = errors.New("no NAT gateway found")
+
+// DiscoveryTimeout bounds the time spent looking for a gateway.
+var DiscoveryTimeout = time.Second * 10
+
+// NAT is a gateway able to forward external ports to this host.
+type NAT interface {
+	// Type returns the kind of gateway, e.g. "UPnP (IGDv1)" or "NAT-PMP".
+	Type() string
+
+	// ExternalAddress returns the gateway's external IP address.
+	ExternalAddress() (net.IP, error)
+
+	// AddPortMapping maps an external port to internalPort for lifetime,
+	// and returns the external port. protocol is "tcp" or "udp". The
+	// gateway may choose another external port than the internal one.
+	AddPortMapping(protocol string, internalPort int, description string, lifetime time.Duration) (int, error)
+
+	// DeletePortMapping removes the mapping of internalPort.
+	DeletePortMapping(protocol string, internalPort int) error
+}
+
+// DiscoverGateway looks for a UPnP or NAT-PMP gateway on the local network,
+// and returns the first one which answers.
+func DiscoverGateway() (NAT, error) {
+	found := make(chan NAT, 2)
+	failed := make(chan error, 2)
+
+	try := func(discover func() (NAT, error)) {
+		nat, err := discover()
+		if err != nil {
+			failed <- err
+			return
+		}
+		found <- nat
+	}
+	go try(discoverUPnP)
+	go try(discoverNATPMP)
+
+	timeout := time.After(DiscoveryTimeout)
+	for i := 0; i < 2; i++ {
+		select {
+		case nat := <-found:
+			log.Infof("found NAT gateway: %s", nat.Type())
+			return nat, nil
+		case err := <-failed:
+			log.Debugf("NAT discovery: %s", err)
+		case <-timeout:
+			return nil, ErrNoNATFound
+		}
+	}
+	return nil, ErrNoNATFound
+}
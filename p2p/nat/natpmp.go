@@ -0,0 +1,146 @@
+package nat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// NAT-PMP (RFC 6886) is a small udp protocol spoken by the gateway on port
+// 5351. Requests are retried with exponential backoff.
+const (
+	natpmpPort       = 5351
+	natpmpRetries    = 4
+	natpmpRetryStart = 250 * time.Millisecond
+
+	natpmpOpExternalAddr = 0
+	natpmpOpMapUDP       = 1
+	natpmpOpMapTCP       = 2
+)
+
+var natpmpResultErrors = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+type natpmpNAT struct {
+	gateway *net.UDPAddr
+}
+
+func discoverNATPMP() (NAT, error) {
+	candidates := gatewayCandidates()
+	if len(candidates) == 0 {
+		return nil, errors.New("nat-pmp: no gateway candidates")
+	}
+
+	found := make(chan NAT, len(candidates))
+	for _, ip := range candidates {
+		go func(ip net.IP) {
+			n := &natpmpNAT{gateway: &net.UDPAddr{IP: ip, Port: natpmpPort}}
+			if _, err := n.ExternalAddress(); err != nil {
+				found <- nil
+				return
+			}
+			found <- n
+		}(ip)
+	}
+
+	for _ = range candidates {
+		if n := <-found; n != nil {
+			return n, nil
+		}
+	}
+	return nil, errors.New("nat-pmp: no gateway answered")
+}
+
+func (n *natpmpNAT) Type() string {
+	return "NAT-PMP"
+}
+
+func (n *natpmpNAT) ExternalAddress() (net.IP, error) {
+	res, err := n.request([]byte{0, natpmpOpExternalAddr}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IPv4(res[8], res[9], res[10], res[11]), nil
+}
+
+func (n *natpmpNAT) AddPortMapping(protocol string, internalPort int, description string, lifetime time.Duration) (int, error) {
+	res, err := n.mapPort(protocol, internalPort, internalPort, uint32(lifetime/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(res[10:])), nil
+}
+
+func (n *natpmpNAT) DeletePortMapping(protocol string, internalPort int) error {
+	// a mapping request with a zero lifetime and external port deletes it.
+	_, err := n.mapPort(protocol, internalPort, 0, 0)
+	return err
+}
+
+func (n *natpmpNAT) mapPort(protocol string, internalPort, externalPort int, lifetime uint32) ([]byte, error) {
+	var op byte
+	switch strings.ToLower(protocol) {
+	case "tcp":
+		op = natpmpOpMapTCP
+	case "udp":
+		op = natpmpOpMapUDP
+	default:
+		return nil, fmt.Errorf("nat-pmp: unsupported protocol %s", protocol)
+	}
+
+	req := make([]byte, 12)
+	req[1] = op
+	binary.BigEndian.PutUint16(req[4:], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:], lifetime)
+	return n.request(req, 16)
+}
+
+// request sends req to the gateway and waits for a response of resLen
+// bytes to the same opcode.
+func (n *natpmpNAT) request(req []byte, resLen int) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	res := make([]byte, 16)
+	timeout := natpmpRetryStart
+	for i := 0; i < natpmpRetries; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		timeout *= 2
+
+		m, err := conn.Read(res)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+		if m < resLen || res[0] != 0 || res[1] != req[1]|0x80 {
+			continue // not the answer to our request.
+		}
+		if code := binary.BigEndian.Uint16(res[2:]); code != 0 {
+			msg, ok := natpmpResultErrors[code]
+			if !ok {
+				msg = fmt.Sprintf("result code %d", code)
+			}
+			return nil, errors.New("nat-pmp: " + msg)
+		}
+		return res[:resLen], nil
+	}
+	return nil, fmt.Errorf("nat-pmp: no response from %s", n.gateway)
+}
@@ -0,0 +1,103 @@
+package nat
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakePMPGateway answers NAT-PMP requests on a loopback udp socket. It maps
+// every internal port to internal port + 1000.
+func fakePMPGateway(t *testing.T) (*net.UDPAddr, map[int]uint32, func()) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mappings := make(map[int]uint32) // internal port -> lifetime
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 16)
+		for {
+			n, raddr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if n < 2 {
+				continue
+			}
+
+			var res []byte
+			switch buf[1] {
+			case natpmpOpExternalAddr:
+				res = make([]byte, 12)
+				copy(res[8:], net.IPv4(1, 2, 3, 4).To4())
+			case natpmpOpMapTCP, natpmpOpMapUDP:
+				internal := binary.BigEndian.Uint16(buf[4:])
+				lifetime := binary.BigEndian.Uint32(buf[8:])
+				res = make([]byte, 16)
+				binary.BigEndian.PutUint16(res[8:], internal)
+				if lifetime == 0 {
+					delete(mappings, int(internal))
+				} else {
+					mappings[int(internal)] = lifetime
+					binary.BigEndian.PutUint16(res[10:], internal+1000)
+				}
+				binary.BigEndian.PutUint32(res[12:], lifetime)
+			default:
+				res = make([]byte, 8)
+				binary.BigEndian.PutUint16(res[2:], 5) // unsupported opcode
+			}
+			res[1] = buf[1] | 0x80
+			conn.WriteToUDP(res, raddr)
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr), mappings, func() {
+		conn.Close()
+		<-done
+	}
+}
+
+func TestNATPMP(t *testing.T) {
+	gw, mappings, done := fakePMPGateway(t)
+	n := &natpmpNAT{gateway: gw}
+
+	ip, err := n.ExternalAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.IPv4(1, 2, 3, 4)) {
+		t.Fatalf("wrong external address %s", ip)
+	}
+
+	ext, err := n.AddPortMapping("tcp", 4001, "test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext != 5001 {
+		t.Fatalf("expected external port 5001, got %d", ext)
+	}
+
+	if err := n.DeletePortMapping("tcp", 4001); err != nil {
+		t.Fatal(err)
+	}
+
+	// the gateway goroutine owns mappings until it exits.
+	done()
+	if len(mappings) != 0 {
+		t.Fatal("mapping was not deleted")
+	}
+}
+
+func TestNATPMPNoGateway(t *testing.T) {
+	gw, _, done := fakePMPGateway(t)
+	done() // nobody listens there anymore.
+
+	n := &natpmpNAT{gateway: gw}
+	if _, err := n.ExternalAddress(); err == nil {
+		t.Fatal("expected request to fail")
+	}
+}
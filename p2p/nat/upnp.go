@@ -0,0 +1,290 @@
+package nat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UPnP IGD gateways are found with SSDP (an HTTP-like multicast udp
+// protocol), describe themselves in an xml document, and are driven with
+// SOAP calls to the WANIPConnection (or WANPPPConnection) service.
+
+const (
+	ssdpAddr    = "239.255.255.250:1900"
+	ssdpTimeout = 3 * time.Second
+	igdDevice   = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+	soapTimeout = 5 * time.Second
+)
+
+var igdServices = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+type upnpNAT struct {
+	controlURL  string
+	serviceType string
+	localIP     net.IP // our address, as seen by the gateway
+}
+
+func discoverUPnP() (NAT, error) {
+	location, err := ssdpSearch()
+	if err != nil {
+		return nil, err
+	}
+	return newUPnPNAT(location)
+}
+
+// newUPnPNAT reads the gateway's device description at location.
+func newUPnPNAT(location string) (*upnpNAT, error) {
+	client := &http.Client{Timeout: soapTimeout}
+	res, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var desc upnpRoot
+	if err := xml.NewDecoder(res.Body).Decode(&desc); err != nil {
+		return nil, fmt.Errorf("upnp: bad device description: %s", err)
+	}
+
+	svc := desc.Device.findService(igdServices)
+	if svc == nil {
+		return nil, errors.New("upnp: device has no WAN connection service")
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if desc.URLBase != "" {
+		if b, err := url.Parse(desc.URLBase); err == nil {
+			base = b
+		}
+	}
+	ctrl, err := base.Parse(svc.ControlURL)
+	if err != nil {
+		return nil, err
+	}
+
+	localIP, err := localIPFor(ctrl.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upnpNAT{
+		controlURL:  ctrl.String(),
+		serviceType: svc.ServiceType,
+		localIP:     localIP,
+	}, nil
+}
+
+func (n *upnpNAT) Type() string {
+	return "UPnP (IGDv1)"
+}
+
+func (n *upnpNAT) ExternalAddress() (net.IP, error) {
+	var res struct {
+		IP string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	if err := n.soapCall("GetExternalIPAddress", nil, &res); err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(strings.TrimSpace(res.IP))
+	if ip == nil {
+		return nil, fmt.Errorf("upnp: invalid external address %q", res.IP)
+	}
+	return ip, nil
+}
+
+func (n *upnpNAT) AddPortMapping(protocol string, internalPort int, description string, lifetime time.Duration) (int, error) {
+	// ask for the same external port. gateways which cannot give it to
+	// us answer with an error rather than another port.
+	args := []soapArg{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(internalPort)},
+		{"NewProtocol", strings.ToUpper(protocol)},
+		{"NewInternalPort", strconv.Itoa(internalPort)},
+		{"NewInternalClient", n.localIP.String()},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", description},
+		{"NewLeaseDuration", strconv.Itoa(int(lifetime / time.Second))},
+	}
+	if err := n.soapCall("AddPortMapping", args, nil); err != nil {
+		return 0, err
+	}
+	return internalPort, nil
+}
+
+func (n *upnpNAT) DeletePortMapping(protocol string, internalPort int) error {
+	args := []soapArg{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(internalPort)},
+		{"NewProtocol", strings.ToUpper(protocol)},
+	}
+	return n.soapCall("DeletePortMapping", args, nil)
+}
+
+type soapArg struct {
+	name, value string
+}
+
+// soapCall invokes action on the gateway's WAN connection service, and
+// decodes the response envelope into res, if not nil.
+func (n *upnpNAT) soapCall(action string, args []soapArg, res interface{}) error {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?>`)
+	body.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, n.serviceType)
+	for _, a := range args {
+		fmt.Fprintf(&body, "<%s>", a.name)
+		xml.EscapeText(&body, []byte(a.value))
+		fmt.Fprintf(&body, "</%s>", a.name)
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequest("POST", n.controlURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, n.serviceType, action))
+
+	client := &http.Client{Timeout: soapTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var fault struct {
+			Code int    `xml:"Body>Fault>detail>UPnPError>errorCode"`
+			Desc string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+		}
+		if xml.Unmarshal(data, &fault) == nil && fault.Code != 0 {
+			return fmt.Errorf("upnp: %s failed: %d %s", action, fault.Code, fault.Desc)
+		}
+		return fmt.Errorf("upnp: %s failed: %s", action, resp.Status)
+	}
+
+	if res != nil {
+		return xml.Unmarshal(data, res)
+	}
+	return nil
+}
+
+// ssdpSearch multicasts an M-SEARCH for gateways, and returns the location
+// of the description of the first one to answer.
+func ssdpSearch() (string, error) {
+	raddr, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	req := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"ST: " + igdDevice + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n\r\n"
+	if _, err := conn.WriteToUDP([]byte(req), raddr); err != nil {
+		return "", err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(ssdpTimeout))
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return "", fmt.Errorf("upnp: no gateway answered: %s", err)
+		}
+
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		res.Body.Close()
+		if !strings.Contains(res.Header.Get("St"), "InternetGatewayDevice") {
+			continue
+		}
+		if loc := res.Header.Get("Location"); loc != "" {
+			return loc, nil
+		}
+	}
+}
+
+// localIPFor returns the local address used to reach hostport.
+func localIPFor(hostport string) (net.IP, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = hostport, "80"
+	}
+	conn, err := net.Dial("udp4", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// device description document.
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+type upnpDevice struct {
+	DeviceType string        `xml:"deviceType"`
+	Services   []upnpService `xml:"serviceList>service"`
+	Devices    []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// findService looks for one of types in the device tree, in order of
+// preference.
+func (d *upnpDevice) findService(types []string) *upnpService {
+	for _, t := range types {
+		if s := d.findServiceType(t); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+func (d *upnpDevice) findServiceType(t string) *upnpService {
+	for i := range d.Services {
+		if d.Services[i].ServiceType == t {
+			return &d.Services[i]
+		}
+	}
+	for i := range d.Devices {
+		if s := d.Devices[i].findServiceType(t); s != nil {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package nat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const fakeIGDDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+// fakeIGD is a UPnP gateway serving its description and SOAP control
+// endpoint over http.
+type fakeIGD struct {
+	sync.Mutex
+	actions []string
+	bodies  []string
+}
+
+func (g *fakeIGD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/rootDesc.xml":
+		fmt.Fprint(w, fakeIGDDescription)
+	case "/ctl/IPConn":
+		body, _ := ioutil.ReadAll(r.Body)
+		action := r.Header.Get("SOAPAction")
+		g.Lock()
+		g.actions = append(g.actions, action)
+		g.bodies = append(g.bodies, string(body))
+		g.Unlock()
+
+		switch {
+		case strings.HasSuffix(action, `#GetExternalIPAddress"`):
+			fmt.Fprint(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+				`<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">`+
+				`<NewExternalIPAddress>5.6.7.8</NewExternalIPAddress>`+
+				`</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+		case strings.HasSuffix(action, `#AddPortMapping"`), strings.HasSuffix(action, `#DeletePortMapping"`):
+			fmt.Fprint(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body/></s:Envelope>`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><detail>`+
+				`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>401</errorCode><errorDescription>Invalid Action</errorDescription></UPnPError>`+
+				`</detail></s:Fault></s:Body></s:Envelope>`)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestUPnP(t *testing.T) {
+	igd := &fakeIGD{}
+	srv := httptest.NewServer(igd)
+	defer srv.Close()
+
+	n, err := newUPnPNAT(srv.URL + "/rootDesc.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.controlURL != srv.URL+"/ctl/IPConn" {
+		t.Fatalf("wrong control url %s", n.controlURL)
+	}
+
+	ip, err := n.ExternalAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.IPv4(5, 6, 7, 8)) {
+		t.Fatalf("wrong external address %s", ip)
+	}
+
+	ext, err := n.AddPortMapping("tcp", 4001, "ipfs", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext != 4001 {
+		t.Fatalf("expected external port 4001, got %d", ext)
+	}
+	if err := n.DeletePortMapping("tcp", 4001); err != nil {
+		t.Fatal(err)
+	}
+
+	igd.Lock()
+	defer igd.Unlock()
+	if len(igd.actions) != 3 {
+		t.Fatalf("expected 3 soap calls, got %d", len(igd.actions))
+	}
+	add := igd.bodies[1]
+	for _, s := range []string{"<NewProtocol>TCP</NewProtocol>", "<NewInternalPort>4001</NewInternalPort>", "<NewLeaseDuration>3600</NewLeaseDuration>", "<NewInternalClient>127.0.0.1</NewInternalClient>"} {
+		if !strings.Contains(add, s) {
+			t.Fatalf("AddPortMapping request is missing %s: %s", s, add)
+		}
+	}
+}
+
+func TestUPnPFault(t *testing.T) {
+	srv := httptest.NewServer(&fakeIGD{})
+	defer srv.Close()
+
+	n, err := newUPnPNAT(srv.URL + "/rootDesc.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.soapCall("Bogus", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected upnp error 401, got %v", err)
+	}
+}
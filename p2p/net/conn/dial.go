@@ -3,16 +3,15 @@ package conn
 import (
 	"fmt"
 	"math/rand"
-	"net"
 	"strings"
-	"syscall"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
-	reuseport "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-reuseport"
 
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
 	addrutil "github.com/jbenet/go-ipfs/p2p/net/swarm/addr"
+	transport "github.com/jbenet/go-ipfs/p2p/net/transport"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	debugerror "github.com/jbenet/go-ipfs/util/debugerror"
 )
@@ -31,6 +30,11 @@ func (d *Dialer) Dial(ctx context.Context, raddr ma.Multiaddr, remote peer.ID) (
 	if err != nil {
 		return nil, err
 	}
+	if d.Protector != nil {
+		if maconn, err = protect(d.Protector, maconn); err != nil {
+			return nil, err
+		}
+	}
 
 	var connOut Conn
 	var errOut error
@@ -40,6 +44,14 @@ func (d *Dialer) Dial(ctx context.Context, raddr ma.Multiaddr, remote peer.ID) (
 	go func() {
 		defer func() { done <- struct{}{} }()
 
+		if d.PrivateKey != nil && d.TLS {
+			connOut, errOut = dialNegotiated(ctx, d.PrivateKey, d.LocalPeer, remote, maconn)
+			if errOut != nil {
+				maconn.Close()
+			}
+			return
+		}
+
 		c, err := newSingleConn(ctx, d.LocalPeer, remote, maconn)
 		if err != nil {
 			errOut = err
@@ -69,6 +81,13 @@ func (d *Dialer) Dial(ctx context.Context, raddr ma.Multiaddr, remote peer.ID) (
 		// whew, finished.
 	}
 
+	if _, ok := errOut.(errNotNegotiated); ok {
+		// older listeners do not negotiate, and only speak secio.
+		log.Debugf("dialer %s: %s did not negotiate security, dialing secio: %s", d, remote, errOut)
+		legacy := *d
+		legacy.TLS = false
+		return legacy.Dial(ctx, raddr, remote)
+	}
 	return connOut, errOut
 }
 
@@ -77,7 +96,8 @@ func (d *Dialer) rawConnDial(ctx context.Context, raddr ma.Multiaddr, remote pee
 
 	// before doing anything, check we're going to be able to dial.
 	// we may not support the given address.
-	if _, _, err := manet.DialArgs(raddr); err != nil {
+	t, err := transport.Find(d.transports(), raddr)
+	if err != nil {
 		return nil, err
 	}
 
@@ -89,85 +109,28 @@ func (d *Dialer) rawConnDial(ctx context.Context, raddr ma.Multiaddr, remote pee
 	laddr := pickLocalAddr(d.LocalAddrs, raddr)
 	log.Debugf("%s dialing %s -- %s --> %s", d.LocalPeer, remote, laddr, raddr)
 
-	// make a copy of the manet.Dialer, we may need to change its timeout.
+	// make a copy of the manet.Dialer, the transport may dial from laddr.
 	madialer := d.Dialer
-
-	if laddr != nil && reuseport.Available() {
-		// we're perhaps going to dial twice. half the timeout, so we can afford to.
-		// otherwise our context would expire right after the first dial.
-		madialer.Dialer.Timeout = (madialer.Dialer.Timeout / 2)
-
-		// dial using reuseport.Dialer, because we're probably reusing addrs.
-		// this is optimistic, as the reuseDial may fail to bind the port.
-		if nconn, retry, reuseErr := reuseDial(madialer.Dialer, laddr, raddr); reuseErr == nil {
-			// if it worked, wrap the raw net.Conn with our manet.Conn
-			log.Debugf("%s reuse worked! %s %s %s", d.LocalPeer, laddr, nconn.RemoteAddr(), nconn)
-			return manet.WrapNetConn(nconn)
-		} else if !retry {
-			// reuseDial is sure this is a legitimate dial failure, not a reuseport failure.
-			return nil, reuseErr
-		} else {
-			// this is a failure to reuse port. log it.
-			log.Debugf("%s port reuse failed: %s --> %s -- %s", d.LocalPeer, laddr, raddr, reuseErr)
-		}
-	}
-
-	return madialer.Dial(raddr)
+	madialer.LocalAddr = laddr
+	return t.Dial(ctx, raddr, madialer)
 }
 
-func reuseDial(dialer net.Dialer, laddr, raddr ma.Multiaddr) (conn net.Conn, retry bool, err error) {
-	if laddr == nil {
-		// if we're given no local address no sense in using reuseport to dial, dial out as usual.
-		return nil, true, reuseport.ErrReuseFailed
-	}
-
-	// give reuse.Dialer the manet.Dialer's Dialer.
-	// (wow, Dialer should've so been an interface...)
-	rd := reuseport.Dialer{dialer}
-
-	// get the local net.Addr manually
-	rd.D.LocalAddr, err = manet.ToNetAddr(laddr)
+// protect wraps c with p, closing c if that fails.
+func protect(p pnet.Protector, c manet.Conn) (manet.Conn, error) {
+	pc, err := p.Protect(c)
 	if err != nil {
-		return nil, true, err // something wrong with laddr. retry without.
-	}
-
-	// get the raddr dial args for rd.dial
-	network, netraddr, err := manet.DialArgs(raddr)
-	if err != nil {
-		return nil, true, err // something wrong with laddr. retry without.
+		c.Close()
+		return nil, err
 	}
-
-	// rd.Dial gets us a net.Conn with SO_REUSEPORT and SO_REUSEADDR set.
-	conn, err = rd.Dial(network, netraddr)
-	return conn, reuseErrShouldRetry(err), err // hey! it worked!
+	return pc, nil
 }
 
-// reuseErrShouldRetry diagnoses whether to retry after a reuse error.
-// if we failed to bind, we should retry. if bind worked and this is a
-// real dial error (remote end didnt answer) then we should not retry.
-func reuseErrShouldRetry(err error) bool {
-	if err == nil {
-		return false // hey, it worked! no need to retry.
-	}
-
-	// if it's a network timeout error, it's a legitimate failure.
-	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
-		return true
-	}
-
-	errno, ok := err.(syscall.Errno)
-	if !ok { // not an errno? who knows what this is. retry.
-		return true
-	}
-
-	switch errno {
-	case syscall.EADDRINUSE, syscall.EADDRNOTAVAIL:
-		return true // failure to bind. retry.
-	case syscall.ECONNREFUSED:
-		return false // real dial error
-	default:
-		return true // optimistically default to retry.
+// transports returns the transports the Dialer dials with.
+func (d *Dialer) transports() []transport.Transport {
+	if d.Transports == nil {
+		return transport.Default()
 	}
+	return d.Transports
 }
 
 func pickLocalAddr(laddrs []ma.Multiaddr, raddr ma.Multiaddr) (laddr ma.Multiaddr) {
@@ -11,6 +11,7 @@ import (
 	tu "github.com/jbenet/go-ipfs/util/testutil"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
 )
 
 func echoListen(ctx context.Context, listener Listener) {
@@ -255,3 +256,60 @@ func TestDialerCloseEarlySecure(t *testing.T) {
 	// t.Skip("Skipping in favor of another test")
 	testDialerCloseEarly(t, true)
 }
+
+func TestListenerSilentConn(t *testing.T) {
+	defer func(d time.Duration) { HandshakeTimeout = d }(HandshakeTimeout)
+	HandshakeTimeout = 200 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p1 := tu.RandPeerNetParamsOrFatal(t)
+	p2 := tu.RandPeerNetParamsOrFatal(t)
+	l1, err := Listen(ctx, p1.Addr, p1.ID, p1.PrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l1.Close()
+
+	// a client which never says a word.
+	silent, err := manet.Dial(l1.Multiaddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	d2 := &Dialer{LocalPeer: p2.ID, PrivateKey: p2.PrivKey}
+	go func() {
+		c2, err := d2.Dial(ctx, l1.Multiaddr(), p1.ID)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c2.Close()
+		<-ctx.Done()
+	}()
+
+	accepted := make(chan error, 1)
+	go func() {
+		c1, err := l1.Accept()
+		if err == nil {
+			c1.Close()
+		}
+		accepted <- err
+	}()
+	select {
+	case err := <-accepted:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the silent conn held up the others")
+	}
+
+	// the silent conn is closed once its handshake times out.
+	silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := silent.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected the listener to close the silent conn, got %v", err)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	ic "github.com/jbenet/go-ipfs/p2p/crypto"
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
+	transport "github.com/jbenet/go-ipfs/p2p/net/transport"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	u "github.com/jbenet/go-ipfs/util"
 
@@ -65,6 +67,17 @@ type Dialer struct {
 	// PrivateKey used to initialize a secure connection.
 	// Warning: if PrivateKey is nil, connection will not be secured.
 	PrivateKey ic.PrivKey
+
+	// Transports dial the addresses. If nil, transport.Default() is used.
+	Transports []transport.Transport
+
+	// Protector, if set, protects the raw connections, for private networks.
+	Protector pnet.Protector
+
+	// TLS negotiates the security of the connections, to use TLS 1.3
+	// rather than secio where the listener supports it. Older listeners do
+	// not negotiate; they are dialed again, with secio.
+	TLS bool
 }
 
 // Listener is an object that can accept connections. It matches net.Listener
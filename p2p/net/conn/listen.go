@@ -1,18 +1,21 @@
 package conn
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"time"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	ctxgroup "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-ctxgroup"
 	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
-	reuseport "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-reuseport"
 	tec "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-temp-err-catcher"
 
 	ic "github.com/jbenet/go-ipfs/p2p/crypto"
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
+	transport "github.com/jbenet/go-ipfs/p2p/net/transport"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 )
 
@@ -23,9 +26,27 @@ type listener struct {
 	local peer.ID    // LocalPeer is the identity of the local Peer
 	privk ic.PrivKey // private key to use to initialize secure conns
 
+	protec  pnet.Protector          // protects the raw conns of private networks
+	refused func(ma.Multiaddr) bool // remote addrs closed before any handshake
+
+	accepted chan accepted // the connections set up
+
 	cg ctxgroup.ContextGroup
 }
 
+// accepted is a connection set up by a listener, or the error it stopped
+// accepting with.
+type accepted struct {
+	conn net.Conn
+	err  error
+}
+
+// HandshakeTimeout bounds the setup of the connections accepted: reading
+// from them, and their security handshakes.
+var HandshakeTimeout = 30 * time.Second
+
+var errListenerClosed = errors.New("listener closed")
+
 func (l *listener) teardown() error {
 	defer log.Debugf("listener closed: %s %s", l.local, l.Multiaddr())
 	return l.Listener.Close()
@@ -40,15 +61,20 @@ func (l *listener) String() string {
 	return fmt.Sprintf("<Listener %s %s>", l.local, l.Multiaddr())
 }
 
-// Accept waits for and returns the next connection to the listener.
-// Note that unfortunately this
+// Accept waits for and returns the next connection to the listener, once
+// it is set up.
 func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case a := <-l.accepted:
+		return a.conn, a.err
+	case <-l.cg.Closing():
+		return nil, errListenerClosed
+	}
+}
 
-	// listeners dont have contexts. given changes dont make sense here anymore
-	// note that the parent of listener will Close, which will interrupt all io.
-	// Contexts and io don't mix.
-	ctx := context.Background()
-
+// acceptLoop accepts the raw connections, and sets each up in its own
+// goroutine, not to hold the others up while its handshakes run.
+func (l *listener) acceptLoop(parent ctxgroup.ContextGroup) {
 	var catcher tec.TempErrCatcher
 
 	catcher.IsTemp = func(e error) bool {
@@ -72,29 +98,81 @@ func (l *listener) Accept() (net.Conn, error) {
 			if catcher.IsTemporary(err) {
 				continue
 			}
-			return nil, err
+			select {
+			case l.accepted <- accepted{err: err}:
+			case <-parent.Closing():
+			}
+			return
 		}
 
 		log.Debugf("listener %s got connection: %s <---> %s", l, maconn.LocalMultiaddr(), maconn.RemoteMultiaddr())
-		c, err := newSingleConn(ctx, l.local, "", maconn)
-		if err != nil {
-			if catcher.IsTemporary(err) {
-				continue
-			}
-			return nil, err
+		if l.refused != nil && l.refused(maconn.RemoteMultiaddr()) {
+			log.Debugf("listener %s refused connection from %s", l, maconn.RemoteMultiaddr())
+			maconn.Close()
+			continue
 		}
+		go func() {
+			c, err := l.setupConn(parent.Context(), maconn)
+			if err != nil {
+				log.Infof("ignoring conn we failed to set up: %s", err)
+				maconn.Close()
+				return
+			}
+			select {
+			case l.accepted <- accepted{conn: c}:
+			case <-parent.Closing():
+				c.Close()
+			}
+		}()
+	}
+}
+
+// setupConn protects and secures maconn, within HandshakeTimeout.
+func (l *listener) setupConn(ctx context.Context, maconn manet.Conn) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, HandshakeTimeout)
+	defer cancel()
+
+	// the handshakes read from maconn, which may never send anything.
+	raw := maconn
+	raw.SetDeadline(time.Now().Add(HandshakeTimeout))
 
-		if l.privk == nil {
-			log.Warning("listener %s listening INSECURELY!", l)
-			return c, nil
+	var err error
+	if l.protec != nil {
+		if maconn, err = protect(l.protec, maconn); err != nil {
+			return nil, fmt.Errorf("failed to protect: %s", err)
 		}
-		sc, err := newSecureConn(ctx, l.privk, c)
-		if err != nil {
-			log.Infof("ignoring conn we failed to secure: %s %s", err, c)
-			continue
+	}
+	if l.privk != nil {
+		var sec string
+		if maconn, sec, err = acceptSecurity(maconn); err != nil {
+			return nil, fmt.Errorf("failed to negotiate security: %s", err)
 		}
-		return sc, nil
+		if sec == tlsID {
+			tc, err := newTLSConn(ctx, l.privk, l.local, "", maconn, false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to secure: %s", err)
+			}
+			raw.SetDeadline(time.Time{})
+			return tc, nil
+		}
+	}
+
+	c, err := newSingleConn(ctx, l.local, "", maconn)
+	if err != nil {
+		return nil, err
 	}
+
+	if l.privk == nil {
+		log.Warning("listener %s listening INSECURELY!", l)
+		raw.SetDeadline(time.Time{})
+		return c, nil
+	}
+	sc, err := newSecureConn(ctx, l.privk, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to secure: %s %s", err, c)
+	}
+	raw.SetDeadline(time.Time{})
+	return sc, nil
 }
 
 func (l *listener) Addr() net.Addr {
@@ -124,8 +202,21 @@ func (l *listener) Loggable() map[string]interface{} {
 }
 
 // Listen listens on the particular multiaddr, with given peer and peerstore.
+// The transport is picked from the default ones, by the address.
 func Listen(ctx context.Context, addr ma.Multiaddr, local peer.ID, sk ic.PrivKey) (Listener, error) {
-	ml, err := manetListen(addr)
+	t, err := transport.Find(transport.Default(), addr)
+	if err != nil {
+		return nil, err
+	}
+	return ListenTransport(ctx, t, addr, local, sk, nil, nil)
+}
+
+// ListenTransport listens on the particular multiaddr with transport t.
+// If protec is not nil, it protects the connections accepted, for private
+// networks. If refused is not nil, connections from the remote addresses
+// it returns true for are closed before any handshake.
+func ListenTransport(ctx context.Context, t transport.Transport, addr ma.Multiaddr, local peer.ID, sk ic.PrivKey, protec pnet.Protector, refused func(ma.Multiaddr) bool) (Listener, error) {
+	ml, err := t.Listen(addr)
 	if err != nil {
 		return nil, err
 	}
@@ -134,31 +225,15 @@ func Listen(ctx context.Context, addr ma.Multiaddr, local peer.ID, sk ic.PrivKey
 		Listener: ml,
 		local:    local,
 		privk:    sk,
+		protec:   protec,
+		refused:  refused,
+		accepted: make(chan accepted),
 		cg:       ctxgroup.WithContext(ctx),
 	}
 	l.cg.SetTeardown(l.teardown)
+	l.cg.AddChildFunc(l.acceptLoop)
 
 	log.Debugf("Conn Listener on %s", l.Multiaddr())
 	log.Event(ctx, "swarmListen", l)
 	return l, nil
 }
-
-func manetListen(addr ma.Multiaddr) (manet.Listener, error) {
-	network, naddr, err := manet.DialArgs(addr)
-	if err != nil {
-		return nil, err
-	}
-
-	if reuseport.Available() {
-		nl, err := reuseport.Listen(network, naddr)
-		if err == nil {
-			// hey, it worked!
-			return manet.WrapNetListener(nl)
-		}
-		// reuseport is available, but we failed to listen. log debug, and retry normally.
-		log.Debugf("reuseport available, but failed to listen: %s %s, %s", network, naddr, err)
-	}
-
-	// either reuseport not available, or it failed. try normally.
-	return manet.Listen(addr)
-}
@@ -0,0 +1,30 @@
+//go:build !go1.17
+// +build !go1.17
+
+package conn
+
+import (
+	"errors"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+
+	ic "github.com/jbenet/go-ipfs/p2p/crypto"
+	secio "github.com/jbenet/go-ipfs/p2p/crypto/secio"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+)
+
+// TLS 1.3 needs go1.17. Built with older releases, connections are only
+// secured with secio, negotiated or not.
+
+// tlsID is never negotiated.
+const tlsID = ""
+
+// securityProtocols are negotiated on connections, by preference.
+var securityProtocols = []string{secio.ID}
+
+var errNoTLS = errors.New("TLS 1.3 needs go1.17 or later")
+
+func newTLSConn(ctx context.Context, sk ic.PrivKey, local, remote peer.ID, maconn manet.Conn, dialer bool) (Conn, error) {
+	return nil, errNoTLS
+}
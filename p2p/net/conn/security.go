@@ -0,0 +1,88 @@
+package conn
+
+import (
+	"io"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+
+	ic "github.com/jbenet/go-ipfs/p2p/crypto"
+	secio "github.com/jbenet/go-ipfs/p2p/crypto/secio"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	ms "github.com/jbenet/go-ipfs/p2p/protocol/multistream"
+)
+
+// errNotNegotiated is returned by dialNegotiated when the listener did not
+// negotiate the security protocol.
+type errNotNegotiated struct {
+	error
+}
+
+// dialNegotiated agrees with the listener on maconn to secure it with TLS,
+// or with secio if the listener does not support TLS.
+func dialNegotiated(ctx context.Context, sk ic.PrivKey, local, remote peer.ID, maconn manet.Conn) (Conn, error) {
+	sec, err := ms.SelectOneOf(maconn, securityProtocols...)
+	if err != nil {
+		return nil, errNotNegotiated{err}
+	}
+	if sec == tlsID {
+		return newTLSConn(ctx, sk, local, remote, maconn, true)
+	}
+
+	c, err := newSingleConn(ctx, local, remote, maconn)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := newSecureConn(ctx, sk, c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return sc, nil
+}
+
+// acceptSecurity returns the security protocol to use on c: the one
+// negotiated by the dialer, or secio for older dialers which start the
+// secio handshake right away. Those send the 4 byte length of their first
+// message, whose first byte is zero, while negotiations start with the
+// length of the multistream header. acceptSecurity closes c if it fails.
+func acceptSecurity(c manet.Conn) (manet.Conn, string, error) {
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(c, first); err != nil {
+		c.Close()
+		return nil, "", err
+	}
+	c = &sniffedConn{Conn: c, first: first}
+	if first[0] == 0 {
+		return c, secio.ID, nil
+	}
+
+	sec, err := ms.Negotiate(c, func(id string) bool {
+		for _, s := range securityProtocols {
+			if id == s {
+				return true
+			}
+		}
+		return false
+	}, func() []string { return securityProtocols })
+	if err != nil {
+		c.Close()
+		return nil, "", err
+	}
+	return c, sec, nil
+}
+
+// sniffedConn reads the bytes acceptSecurity looked at first.
+type sniffedConn struct {
+	manet.Conn
+	first []byte
+}
+
+func (c *sniffedConn) Read(buf []byte) (int, error) {
+	if len(c.first) > 0 && len(buf) > 0 {
+		n := copy(buf, c.first)
+		c.first = c.first[n:]
+		return n, nil
+	}
+	return c.Conn.Read(buf)
+}
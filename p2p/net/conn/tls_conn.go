@@ -0,0 +1,94 @@
+//go:build go1.17
+// +build go1.17
+
+package conn
+
+import (
+	gotls "crypto/tls"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+
+	ic "github.com/jbenet/go-ipfs/p2p/crypto"
+	secio "github.com/jbenet/go-ipfs/p2p/crypto/secio"
+	ptls "github.com/jbenet/go-ipfs/p2p/crypto/tls"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+)
+
+// tlsID is the security protocol of tlsConns.
+const tlsID = ptls.ID
+
+// securityProtocols are negotiated on connections, by preference.
+var securityProtocols = []string{ptls.ID, secio.ID}
+
+// tlsConn is a connection secured with TLS 1.3, rather than secio. It is a
+// singleConn over the TLS connection, with the keys of both sides.
+type tlsConn struct {
+	Conn
+
+	localKey  ic.PrivKey
+	remoteKey ic.PubKey
+}
+
+// newTLSConn secures maconn with TLS. The dialer is the TLS client, and
+// checks the listener is remote.
+func newTLSConn(ctx context.Context, sk ic.PrivKey, local, remote peer.ID, maconn manet.Conn, dialer bool) (Conn, error) {
+	var tc *gotls.Conn
+	var pk ic.PubKey
+	var err error
+	if dialer {
+		tc, pk, err = ptls.Client(ctx, sk, maconn, remote)
+	} else {
+		tc, pk, err = ptls.Server(ctx, sk, maconn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err = peer.IDFromPublicKey(pk)
+	if err != nil {
+		tc.Close()
+		return nil, err
+	}
+
+	c, err := newSingleConn(ctx, local, remote, &tlsMaConn{Conn: maconn, tc: tc})
+	if err != nil {
+		tc.Close()
+		return nil, err
+	}
+	log.Debugf("newTLSConn: %v to %v handshake success!", local, remote)
+	return &tlsConn{Conn: c, localKey: sk, remoteKey: pk}, nil
+}
+
+func (c *tlsConn) String() string {
+	return String(c, "tlsConn")
+}
+
+// LocalPrivateKey is the private key of the peer on this side
+func (c *tlsConn) LocalPrivateKey() ic.PrivKey {
+	return c.localKey
+}
+
+// RemotePublicKey is the public key of the peer on the remote side
+func (c *tlsConn) RemotePublicKey() ic.PubKey {
+	return c.remoteKey
+}
+
+// tlsMaConn reads and writes through the TLS connection, and keeps the
+// addresses of the raw one.
+type tlsMaConn struct {
+	manet.Conn
+	tc *gotls.Conn
+}
+
+func (c *tlsMaConn) Read(buf []byte) (int, error) {
+	return c.tc.Read(buf)
+}
+
+func (c *tlsMaConn) Write(buf []byte) (int, error) {
+	return c.tc.Write(buf)
+}
+
+func (c *tlsMaConn) Close() error {
+	return c.tc.Close()
+}
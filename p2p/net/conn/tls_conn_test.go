@@ -0,0 +1,135 @@
+//go:build go1.17
+// +build go1.17
+
+package conn
+
+import (
+	"testing"
+
+	ptls "github.com/jbenet/go-ipfs/p2p/crypto/tls"
+	tu "github.com/jbenet/go-ipfs/util/testutil"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+)
+
+func TestDialerTLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p1 := tu.RandPeerNetParamsOrFatal(t)
+	p2 := tu.RandPeerNetParamsOrFatal(t)
+	l1, err := Listen(ctx, p1.Addr, p1.ID, p1.PrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l1.Close()
+	p1.Addr = l1.Multiaddr()
+
+	// the listener negotiates TLS, and takes secio from older dialers.
+	for _, useTLS := range []bool{true, false} {
+		d2 := &Dialer{LocalPeer: p2.ID, PrivateKey: p2.PrivKey, TLS: useTLS}
+
+		done := make(chan error)
+		var c2 Conn
+		go func() {
+			var err error
+			c2, err = d2.Dial(ctx, p1.Addr, p1.ID)
+			done <- err
+		}()
+		c, err := l1.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c1 := c.(Conn)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := c1.(*tlsConn); ok != useTLS {
+			t.Fatalf("accepted a tlsConn: %v, dialed with TLS: %v", ok, useTLS)
+		}
+		if c1.RemotePeer() != p2.ID || c2.RemotePeer() != p1.ID {
+			t.Fatal("peers not identified")
+		}
+		if !c1.RemotePublicKey().Equals(p2.PubKey) || !c2.RemotePublicKey().Equals(p1.PubKey) {
+			t.Fatal("wrong public keys")
+		}
+
+		testOneSendRecv(t, c1, c2)
+		testOneSendRecv(t, c2, c1)
+		c1.Close()
+		c2.Close()
+	}
+}
+
+func TestDialerTLSWrongPeer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p1 := tu.RandPeerNetParamsOrFatal(t)
+	p2 := tu.RandPeerNetParamsOrFatal(t)
+	p3 := tu.RandPeerNetParamsOrFatal(t)
+	l1, err := Listen(ctx, p1.Addr, p1.ID, p1.PrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l1.Close()
+	go echoListen(ctx, l1)
+
+	d2 := &Dialer{LocalPeer: p2.ID, PrivateKey: p2.PrivKey, TLS: true}
+	if _, err := d2.Dial(ctx, l1.Multiaddr(), p3.ID); err != ptls.ErrWrongPeer {
+		t.Fatalf("expected ErrWrongPeer, got %v", err)
+	}
+}
+
+func TestDialerTLSFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p1 := tu.RandPeerNetParamsOrFatal(t)
+	p2 := tu.RandPeerNetParamsOrFatal(t)
+
+	// an older listener, which starts the secio handshake right away.
+	ml, err := manet.Listen(p1.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ml.Close()
+	accepted := make(chan Conn, 1)
+	go func() {
+		for {
+			maconn, err := ml.Accept()
+			if err != nil {
+				return
+			}
+			c, err := newSingleConn(ctx, p1.ID, "", maconn)
+			if err != nil {
+				maconn.Close()
+				continue
+			}
+			sc, err := newSecureConn(ctx, p1.PrivKey, c)
+			if err != nil {
+				c.Close()
+				continue
+			}
+			accepted <- sc
+			return
+		}
+	}()
+
+	d2 := &Dialer{LocalPeer: p2.ID, PrivateKey: p2.PrivKey, TLS: true}
+	c2, err := d2.Dial(ctx, ml.Multiaddr(), p1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1 := <-accepted
+	if _, ok := c2.(*secureConn); !ok {
+		t.Fatal("should have fallen back to secio")
+	}
+
+	testOneSendRecv(t, c1, c2)
+	testOneSendRecv(t, c2, c1)
+	c1.Close()
+	c2.Close()
+}
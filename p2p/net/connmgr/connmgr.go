@@ -0,0 +1,267 @@
+// Package connmgr keeps the number of open connections in check, by closing
+// the least valuable ones.
+package connmgr
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	inet "github.com/jbenet/go-ipfs/p2p/net"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+)
+
+var log = eventlog.Logger("p2p/net/connmgr")
+
+// ConnManager tracks how valuable the connections to peers are. Services
+// tag the peers they rely on, like the bootstrap peers, or the members of
+// a routing table; the connections to the peers with the highest total
+// value are the last to be closed. The tags of a peer are cleared when its
+// last connection closes.
+type ConnManager interface {
+	// TagPeer tags p with a value, replacing the previous value of tag.
+	TagPeer(p peer.ID, tag string, value int)
+
+	// UntagPeer removes tag from p.
+	UntagPeer(p peer.ID, tag string)
+
+	// TagInfo returns the tags of p, and their values.
+	TagInfo(p peer.ID) map[string]int
+}
+
+// NullConnManager is a ConnManager that never closes connections.
+type NullConnManager struct{}
+
+func (NullConnManager) TagPeer(peer.ID, string, int)   {}
+func (NullConnManager) UntagPeer(peer.ID, string)      {}
+func (NullConnManager) TagInfo(peer.ID) map[string]int { return nil }
+
+// DefaultGracePeriod is how long new connections are kept at least, so
+// they have a chance to be tagged.
+var DefaultGracePeriod = 20 * time.Second
+
+// BasicConnManager closes connections once there are more than its high
+// watermark of them, down to its low watermark. Connections to the peers
+// with the lowest tag values go first; connections younger than the grace
+// period are kept.
+type BasicConnManager struct {
+	net         inet.Network
+	lowWater    int
+	highWater   int
+	gracePeriod time.Duration
+	started     time.Time
+
+	lk       sync.Mutex
+	tags     map[peer.ID]map[string]int
+	opened   map[inet.Conn]time.Time
+	trimming bool
+	closed   bool
+}
+
+// NewConnManager creates a BasicConnManager keeping the connections of net
+// between lowWater and highWater.
+func NewConnManager(net inet.Network, lowWater, highWater int, grace time.Duration) *BasicConnManager {
+	cm := &BasicConnManager{
+		net:         net,
+		lowWater:    lowWater,
+		highWater:   highWater,
+		gracePeriod: grace,
+		started:     time.Now(),
+		tags:        make(map[peer.ID]map[string]int),
+		opened:      make(map[inet.Conn]time.Time),
+	}
+	net.Notify((*netNotifiee)(cm))
+	return cm
+}
+
+func (cm *BasicConnManager) TagPeer(p peer.ID, tag string, value int) {
+	cm.lk.Lock()
+	defer cm.lk.Unlock()
+
+	tags, found := cm.tags[p]
+	if !found {
+		tags = make(map[string]int)
+		cm.tags[p] = tags
+	}
+	tags[tag] = value
+}
+
+func (cm *BasicConnManager) UntagPeer(p peer.ID, tag string) {
+	cm.lk.Lock()
+	defer cm.lk.Unlock()
+
+	tags, found := cm.tags[p]
+	if !found {
+		return
+	}
+	delete(tags, tag)
+	if len(tags) == 0 {
+		delete(cm.tags, p)
+	}
+}
+
+func (cm *BasicConnManager) TagInfo(p peer.ID) map[string]int {
+	cm.lk.Lock()
+	defer cm.lk.Unlock()
+
+	out := make(map[string]int, len(cm.tags[p]))
+	for t, v := range cm.tags[p] {
+		out[t] = v
+	}
+	return out
+}
+
+// value returns the total value of the tags of p. The lock must be held.
+func (cm *BasicConnManager) value(p peer.ID) int {
+	v := 0
+	for _, tv := range cm.tags[p] {
+		v += tv
+	}
+	return v
+}
+
+// peerConns are the connections to one peer, as candidates for closing.
+type peerConns struct {
+	p      peer.ID
+	conns  []inet.Conn
+	value  int
+	opened time.Time // of the newest connection
+}
+
+type byValue []*peerConns
+
+func (s byValue) Len() int      { return len(s) }
+func (s byValue) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byValue) Less(i, j int) bool {
+	if s[i].value != s[j].value {
+		return s[i].value < s[j].value
+	}
+	// the newest connections have the least invested in them.
+	return s[i].opened.After(s[j].opened)
+}
+
+// TrimOpenConns closes the least valuable connections, until there are no
+// more than the low watermark of them, or only ones in their grace period.
+// It returns the number of connections closed.
+func (cm *BasicConnManager) TrimOpenConns() int {
+	conns := cm.net.Conns()
+	excess := len(conns) - cm.lowWater
+	if excess <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	byPeer := make(map[peer.ID]*peerConns)
+	var candidates []*peerConns
+	for _, c := range conns {
+		p := c.RemotePeer()
+		pc, found := byPeer[p]
+		if !found {
+			pc = &peerConns{p: p}
+			byPeer[p] = pc
+			candidates = append(candidates, pc)
+		}
+		pc.conns = append(pc.conns, c)
+	}
+
+	cm.lk.Lock()
+	for _, pc := range candidates {
+		pc.value = cm.value(pc.p)
+		for _, c := range pc.conns {
+			// notifications are asynchronous, so we may not have been told
+			// about a connection yet. it is no older than we are, then.
+			opened, found := cm.opened[c]
+			if !found {
+				opened = cm.started
+			}
+			if opened.After(pc.opened) {
+				pc.opened = opened
+			}
+		}
+	}
+	cm.lk.Unlock()
+	sort.Sort(byValue(candidates))
+
+	closed := 0
+	for _, pc := range candidates {
+		if closed >= excess {
+			break
+		}
+		if now.Sub(pc.opened) < cm.gracePeriod {
+			continue
+		}
+		log.Debugf("closing %d connections to %s (value %d)", len(pc.conns), pc.p, pc.value)
+		if err := cm.net.ClosePeer(pc.p); err != nil {
+			log.Debugf("error closing connections to %s: %s", pc.p, err)
+		}
+		closed += len(pc.conns)
+	}
+	return closed
+}
+
+// maybeTrim trims the connections in the background, if they passed the
+// high watermark and no trim is running yet.
+func (cm *BasicConnManager) maybeTrim() {
+	if len(cm.net.Conns()) <= cm.highWater {
+		return
+	}
+
+	cm.lk.Lock()
+	if cm.trimming || cm.closed {
+		cm.lk.Unlock()
+		return
+	}
+	cm.trimming = true
+	cm.lk.Unlock()
+
+	go func() {
+		n := cm.TrimOpenConns()
+		log.Infof("closed %d connections, past the high watermark of %d", n, cm.highWater)
+
+		cm.lk.Lock()
+		cm.trimming = false
+		cm.lk.Unlock()
+	}()
+}
+
+// Close stops managing the connections.
+func (cm *BasicConnManager) Close() error {
+	cm.lk.Lock()
+	closed := cm.closed
+	cm.closed = true
+	cm.lk.Unlock()
+
+	if !closed {
+		cm.net.StopNotify((*netNotifiee)(cm))
+	}
+	return nil
+}
+
+// netNotifiee lets the BasicConnManager know when connections open and
+// close.
+type netNotifiee BasicConnManager
+
+func (nn *netNotifiee) Connected(n inet.Network, c inet.Conn) {
+	cm := (*BasicConnManager)(nn)
+	cm.lk.Lock()
+	cm.opened[c] = time.Now()
+	cm.lk.Unlock()
+	cm.maybeTrim()
+}
+
+func (nn *netNotifiee) Disconnected(n inet.Network, c inet.Conn) {
+	cm := (*BasicConnManager)(nn)
+	p := c.RemotePeer()
+	last := len(n.ConnsToPeer(p)) == 0
+
+	cm.lk.Lock()
+	delete(cm.opened, c)
+	if last {
+		delete(cm.tags, p)
+	}
+	cm.lk.Unlock()
+}
+
+func (nn *netNotifiee) OpenedStream(n inet.Network, s inet.Stream) {}
+func (nn *netNotifiee) ClosedStream(n inet.Network, s inet.Stream) {}
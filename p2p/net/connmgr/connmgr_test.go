@@ -0,0 +1,167 @@
+package connmgr_test
+
+import (
+	"testing"
+	"time"
+
+	inet "github.com/jbenet/go-ipfs/p2p/net"
+	connmgr "github.com/jbenet/go-ipfs/p2p/net/connmgr"
+	mocknet "github.com/jbenet/go-ipfs/p2p/net/mock"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+)
+
+// connectFirst links n nets, and connects the first to all the others.
+func connectFirst(t *testing.T, n int) (mocknet.Mocknet, []inet.Network) {
+	mn, err := mocknet.FullMeshLinked(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nets := mn.Nets()
+	for _, n2 := range nets[1:] {
+		if _, err := mn.ConnectNets(nets[0], n2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mn, nets
+}
+
+func TestTagPeer(t *testing.T) {
+	_, nets := connectFirst(t, 2)
+	cm := connmgr.NewConnManager(nets[0], 1, 2, 0)
+	defer cm.Close()
+	p := nets[1].LocalPeer()
+
+	cm.TagPeer(p, "a", 1)
+	cm.TagPeer(p, "b", 2)
+	cm.TagPeer(p, "a", 3)
+	if tags := cm.TagInfo(p); len(tags) != 2 || tags["a"] != 3 || tags["b"] != 2 {
+		t.Fatalf("wrong tags: %v", tags)
+	}
+
+	cm.UntagPeer(p, "a")
+	cm.UntagPeer(p, "b")
+	if tags := cm.TagInfo(p); len(tags) != 0 {
+		t.Fatalf("tags left: %v", tags)
+	}
+}
+
+func TestTagsClearedOnDisconnect(t *testing.T) {
+	_, nets := connectFirst(t, 2)
+	cm := connmgr.NewConnManager(nets[0], 1, 2, 0)
+	defer cm.Close()
+	p := nets[1].LocalPeer()
+
+	cm.TagPeer(p, "a", 1)
+	if err := nets[0].ClosePeer(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// notifications are asynchronous.
+	for i := 0; len(cm.TagInfo(p)) != 0; i++ {
+		if i > 100 {
+			t.Fatalf("tags left after disconnecting: %v", cm.TagInfo(p))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTrimOpenConns(t *testing.T) {
+	_, nets := connectFirst(t, 6)
+
+	cm := connmgr.NewConnManager(nets[0], 2, 4, 0)
+	defer cm.Close()
+	keep := []inet.Network{nets[2], nets[4]}
+	for i, n := range keep {
+		cm.TagPeer(n.LocalPeer(), "test", 10+i)
+	}
+	cm.TagPeer(nets[1].LocalPeer(), "test", 1)
+
+	if closed := cm.TrimOpenConns(); closed != 3 {
+		t.Fatalf("expected 3 connections closed, got %d", closed)
+	}
+	if len(nets[0].Peers()) != 2 {
+		t.Fatalf("expected 2 peers left, got %d", len(nets[0].Peers()))
+	}
+	for _, n := range keep {
+		if nets[0].Connectedness(n.LocalPeer()) != inet.Connected {
+			t.Fatalf("tagged peer %s was disconnected", n.LocalPeer())
+		}
+	}
+}
+
+func TestTrimGracePeriod(t *testing.T) {
+	mn, err := mocknet.FullMeshLinked(context.Background(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nets := mn.Nets()
+
+	// the high watermark is not passed, so nothing closes on its own.
+	cm := connmgr.NewConnManager(nets[0], 1, 5, time.Hour)
+	defer cm.Close()
+	for _, n2 := range nets[1:] {
+		if _, err := mn.ConnectNets(nets[0], n2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if closed := cm.TrimOpenConns(); closed != 0 {
+		t.Fatalf("closed %d connections in their grace period", closed)
+	}
+	if len(nets[0].Peers()) != 3 {
+		t.Fatalf("expected 3 peers, got %d", len(nets[0].Peers()))
+	}
+}
+
+func TestTrimHighWater(t *testing.T) {
+	mn, err := mocknet.FullMeshLinked(context.Background(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nets := mn.Nets()
+
+	cm := connmgr.NewConnManager(nets[0], 1, 2, 0)
+	defer cm.Close()
+	for _, n2 := range nets[1:] {
+		if _, err := mn.ConnectNets(nets[0], n2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// passing the high watermark trims down to the low one.
+	deadline := time.After(5 * time.Second)
+	for len(nets[0].Conns()) > 1 {
+		select {
+		case <-deadline:
+			t.Fatalf("not trimmed, %d connections open", len(nets[0].Conns()))
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func TestCloseStopsTrimming(t *testing.T) {
+	mn, err := mocknet.FullMeshLinked(context.Background(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nets := mn.Nets()
+
+	cm := connmgr.NewConnManager(nets[0], 1, 2, 0)
+	if err := cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, n2 := range nets[1:] {
+		if _, err := mn.ConnectNets(nets[0], n2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := len(nets[0].Conns()); n != 3 {
+		t.Fatalf("%d connections open after closing the manager, expected 3", n)
+	}
+}
@@ -0,0 +1,226 @@
+// Package filter decides which peers, and which addresses, the swarm may
+// connect to.
+package filter
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+)
+
+// Filter matches either a peer, written "/ipfs/<id>", or a range of
+// addresses, written "/ip4/10.0.0.0/ipcidr/8" or "/ip6/fc00::/ipcidr/7".
+type Filter struct {
+	Peer  peer.ID
+	IPNet *net.IPNet
+}
+
+// ParseFilter parses s into a Filter.
+func ParseFilter(s string) (Filter, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 3 || parts[0] != "" {
+		return Filter{}, fmt.Errorf("invalid filter: %q", s)
+	}
+
+	switch parts[1] {
+	case "ipfs":
+		if len(parts) != 3 {
+			return Filter{}, fmt.Errorf("invalid peer filter: %q", s)
+		}
+		p, err := peer.IDB58Decode(parts[2])
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid peer filter: %q: %s", s, err)
+		}
+		return Filter{Peer: p}, nil
+
+	case "ip4", "ip6":
+		if len(parts) != 5 || parts[3] != "ipcidr" {
+			return Filter{}, fmt.Errorf("invalid address filter: %q", s)
+		}
+		ip := net.ParseIP(parts[2])
+		if ip == nil || (parts[1] == "ip4") != (ip.To4() != nil) {
+			return Filter{}, fmt.Errorf("invalid address filter: %q: bad %s address", s, parts[1])
+		}
+		bits := 8 * net.IPv6len
+		if parts[1] == "ip4" {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+		ones, err := strconv.Atoi(parts[4])
+		if err != nil || ones < 0 || ones > bits {
+			return Filter{}, fmt.Errorf("invalid address filter: %q: bad mask", s)
+		}
+		mask := net.CIDRMask(ones, bits)
+		return Filter{IPNet: &net.IPNet{IP: ip.Mask(mask), Mask: mask}}, nil
+	}
+	return Filter{}, fmt.Errorf("invalid filter: %q: must be /ipfs, /ip4 or /ip6", s)
+}
+
+func (f Filter) String() string {
+	if f.IPNet == nil {
+		return "/ipfs/" + f.Peer.Pretty()
+	}
+	ones, _ := f.IPNet.Mask.Size()
+	proto := "ip6"
+	if f.IPNet.IP.To4() != nil {
+		proto = "ip4"
+	}
+	return fmt.Sprintf("/%s/%s/ipcidr/%d", proto, f.IPNet.IP, ones)
+}
+
+// Action is what a Filter does to the connections it matches.
+type Action int
+
+const (
+	// Deny refuses the connections, always.
+	Deny Action = iota
+	// Allow lets the connections through, in allowlist-only mode.
+	Allow
+)
+
+func (a Action) String() string {
+	if a == Allow {
+		return "allow"
+	}
+	return "deny"
+}
+
+// Entry is a Filter and its Action.
+type Entry struct {
+	Filter Filter
+	Action Action
+}
+
+// Filters is a threadsafe set of filters. Denied peers and addresses are
+// never connected to. In allowlist-only mode, only the allowed ones are.
+type Filters struct {
+	lk            sync.RWMutex
+	entries       map[string]Entry
+	allowlistOnly bool
+}
+
+// NewFilters returns an empty set of filters, which blocks nothing.
+func NewFilters() *Filters {
+	return &Filters{entries: make(map[string]Entry)}
+}
+
+// Add adds f, or changes its action if it is there already.
+func (fs *Filters) Add(f Filter, a Action) {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	fs.entries[f.String()] = Entry{f, a}
+}
+
+// Remove removes f, and returns whether it was there.
+func (fs *Filters) Remove(f Filter) bool {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	_, found := fs.entries[f.String()]
+	delete(fs.entries, f.String())
+	return found
+}
+
+// Entries returns the filters, sorted.
+func (fs *Filters) Entries() []Entry {
+	fs.lk.RLock()
+	defer fs.lk.RUnlock()
+	keys := make([]string, 0, len(fs.entries))
+	for k := range fs.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]Entry, len(keys))
+	for i, k := range keys {
+		out[i] = fs.entries[k]
+	}
+	return out
+}
+
+// SetAllowlistOnly turns allowlist-only mode on or off.
+func (fs *Filters) SetAllowlistOnly(on bool) {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	fs.allowlistOnly = on
+}
+
+// AllowlistOnly returns whether only allowed peers and addresses pass.
+func (fs *Filters) AllowlistOnly() bool {
+	fs.lk.RLock()
+	defer fs.lk.RUnlock()
+	return fs.allowlistOnly
+}
+
+// PeerDenied returns whether p is denied, whatever its address.
+func (fs *Filters) PeerDenied(p peer.ID) bool {
+	fs.lk.RLock()
+	defer fs.lk.RUnlock()
+	e, found := fs.entries[Filter{Peer: p}.String()]
+	return found && e.Action == Deny
+}
+
+// AddrDenied returns whether addr is in a denied range, whatever the peer.
+// Listeners use it to refuse connections before their handshakes.
+func (fs *Filters) AddrDenied(addr ma.Multiaddr) bool {
+	ip := addrIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	fs.lk.RLock()
+	defer fs.lk.RUnlock()
+	for _, e := range fs.entries {
+		if e.Action == Deny && e.Filter.IPNet != nil && e.Filter.IPNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConnBlocked returns whether a connection to p at addr is refused. Deny
+// filters win over Allow ones. In allowlist-only mode, p or addr must be
+// allowed. addr may be nil, when it is not known yet.
+func (fs *Filters) ConnBlocked(p peer.ID, addr ma.Multiaddr) bool {
+	ip := addrIP(addr)
+
+	fs.lk.RLock()
+	defer fs.lk.RUnlock()
+
+	allowed := false
+	for _, e := range fs.entries {
+		if !e.Filter.matches(p, ip) {
+			continue
+		}
+		if e.Action == Deny {
+			return true
+		}
+		allowed = true
+	}
+	return fs.allowlistOnly && !allowed
+}
+
+func (f Filter) matches(p peer.ID, ip net.IP) bool {
+	if f.IPNet == nil {
+		return f.Peer == p
+	}
+	return ip != nil && f.IPNet.Contains(ip)
+}
+
+// addrIP returns the ip of the first hop of addr, or nil if it has none.
+// For relayed addresses, that is the relay's.
+func addrIP(addr ma.Multiaddr) net.IP {
+	if addr == nil {
+		return nil
+	}
+	parts := strings.SplitN(addr.String(), "/", 4)
+	if len(parts) < 3 || (parts[1] != "ip4" && parts[1] != "ip6") {
+		return nil
+	}
+	return net.ParseIP(parts[2])
+}
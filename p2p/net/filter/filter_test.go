@@ -0,0 +1,118 @@
+package filter
+
+import (
+	"testing"
+
+	_ "github.com/jbenet/go-ipfs/p2p/net/swarm/addr" // registers /ipfs-relay
+	testutil "github.com/jbenet/go-ipfs/util/testutil"
+
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+)
+
+func TestParseFilter(t *testing.T) {
+	p := testutil.RandPeerIDFatal(t)
+
+	good := map[string]string{
+		"/ip4/10.0.0.0/ipcidr/8": "/ip4/10.0.0.0/ipcidr/8",
+		"/ip4/10.1.2.3/ipcidr/8": "/ip4/10.0.0.0/ipcidr/8",
+		"/ip4/1.2.3.4/ipcidr/32": "/ip4/1.2.3.4/ipcidr/32",
+		"/ip6/fc00::/ipcidr/7":   "/ip6/fc00::/ipcidr/7",
+		"/ipfs/" + p.Pretty():    "/ipfs/" + p.Pretty(),
+	}
+	for s, canon := range good {
+		f, err := ParseFilter(s)
+		if err != nil {
+			t.Fatalf("%s: %s", s, err)
+		}
+		if f.String() != canon {
+			t.Fatalf("%s parsed as %s, expected %s", s, f, canon)
+		}
+	}
+
+	bad := []string{
+		"",
+		"10.0.0.0/8",
+		"/ip4/10.0.0.0",
+		"/ip4/10.0.0.0/ipcidr/33",
+		"/ip4/fc00::/ipcidr/7",
+		"/ip6/10.0.0.0/ipcidr/8",
+		"/ip4/10.0.0.0/tcp/8",
+		"/ipfs/notapeer",
+		"/dns/example.com",
+	}
+	for _, s := range bad {
+		if _, err := ParseFilter(s); err == nil {
+			t.Fatalf("%q should not parse", s)
+		}
+	}
+}
+
+func mustFilter(t *testing.T, s string) Filter {
+	f, err := ParseFilter(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestConnBlocked(t *testing.T) {
+	p1 := testutil.RandPeerIDFatal(t)
+	p2 := testutil.RandPeerIDFatal(t)
+	private := ma.StringCast("/ip4/192.168.1.5/tcp/4001")
+	public := ma.StringCast("/ip4/104.131.131.82/tcp/4001")
+	relayed := ma.StringCast("/ip4/192.168.1.5/tcp/4001/ipfs/" + p2.Pretty() + "/ipfs-relay/ipfs/" + p1.Pretty())
+
+	fs := NewFilters()
+	if fs.ConnBlocked(p1, private) || fs.ConnBlocked(p1, nil) {
+		t.Fatal("empty filters block")
+	}
+
+	fs.Add(mustFilter(t, "/ip4/192.168.0.0/ipcidr/16"), Deny)
+	if !fs.ConnBlocked(p1, private) || !fs.ConnBlocked(p1, relayed) {
+		t.Fatal("denied range not blocked")
+	}
+	if fs.ConnBlocked(p1, public) {
+		t.Fatal("public address blocked")
+	}
+	if !fs.AddrDenied(private) || fs.AddrDenied(public) || fs.AddrDenied(nil) {
+		t.Fatal("AddrDenied does not match the denied range")
+	}
+
+	fs.Add(mustFilter(t, "/ipfs/"+p2.Pretty()), Deny)
+	if !fs.PeerDenied(p2) || !fs.ConnBlocked(p2, public) {
+		t.Fatal("denied peer not blocked")
+	}
+
+	// allowlist-only mode lets through only the allowed, and deny still wins.
+	fs.SetAllowlistOnly(true)
+	if !fs.ConnBlocked(p1, public) {
+		t.Fatal("unlisted peer not blocked in allowlist mode")
+	}
+	fs.Add(mustFilter(t, "/ipfs/"+p1.Pretty()), Allow)
+	if fs.ConnBlocked(p1, public) || fs.ConnBlocked(p1, nil) {
+		t.Fatal("allowed peer blocked")
+	}
+	if !fs.ConnBlocked(p1, private) {
+		t.Fatal("allowed peer at denied address not blocked")
+	}
+	fs.Add(mustFilter(t, "/ip4/104.131.0.0/ipcidr/16"), Allow)
+	if fs.ConnBlocked(testutil.RandPeerIDFatal(t), public) {
+		t.Fatal("peer at allowed address blocked")
+	}
+
+	// adding a filter again changes its action.
+	fs.Add(mustFilter(t, "/ipfs/"+p2.Pretty()), Allow)
+	if fs.PeerDenied(p2) {
+		t.Fatal("peer still denied")
+	}
+
+	if !fs.Remove(mustFilter(t, "/ipfs/"+p1.Pretty())) {
+		t.Fatal("filter not removed")
+	}
+	if fs.Remove(mustFilter(t, "/ipfs/"+p1.Pretty())) {
+		t.Fatal("filter removed twice")
+	}
+	if len(fs.Entries()) != 3 {
+		t.Fatalf("expected 3 filters, got %d", len(fs.Entries()))
+	}
+}
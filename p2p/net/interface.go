@@ -2,6 +2,7 @@ package net
 
 import (
 	"io"
+	"time"
 
 	conn "github.com/jbenet/go-ipfs/p2p/net/conn"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
@@ -43,6 +44,42 @@ type Conn interface {
 
 	// NewStream constructs a new Stream over this conn.
 	NewStream() (Stream, error)
+
+	// Stat returns what is known of the connection, like who opened it.
+	Stat() Stat
+
+	// Close closes the connection, and the streams over it.
+	Close() error
+}
+
+// Direction tells which side opened a connection.
+type Direction int
+
+const (
+	// DirUnknown is for connections of unknown origin.
+	DirUnknown Direction = iota
+	// DirInbound connections were opened by the remote peer.
+	DirInbound
+	// DirOutbound connections were opened by us.
+	DirOutbound
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirInbound:
+		return "inbound"
+	case DirOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
+
+// Stat is information about a connection.
+type Stat struct {
+	Direction Direction
+	Opened    time.Time // zero if unknown
+	Streams   int       // open on the connection
 }
 
 // ConnHandler is the type of function used to listen for
@@ -13,6 +13,7 @@ import (
 	ic "github.com/jbenet/go-ipfs/p2p/crypto"
 	host "github.com/jbenet/go-ipfs/p2p/host"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 
 	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
@@ -27,6 +28,10 @@ type Mocknet interface {
 	// ID is derived from PrivKey
 	AddPeer(ic.PrivKey, ma.Multiaddr) (host.Host, error)
 
+	// AddPeerWithProtector adds a peer in a private network. It connects
+	// only to the peers whose Protector has the same fingerprint.
+	AddPeerWithProtector(ic.PrivKey, ma.Multiaddr, pnet.Protector) (host.Host, error)
+
 	// retrieve things (with randomized iteration order)
 	Peers() []peer.ID
 	Net(peer.ID) inet.Network
@@ -62,10 +67,15 @@ type Mocknet interface {
 }
 
 // LinkOptions are used to change aspects of the links.
-// Sorry but they dont work yet :(
+// They shape the streams over the link, as they are written.
 type LinkOptions struct {
-	Latency   time.Duration
-	Bandwidth int // in bytes-per-second
+	Latency   time.Duration // before writes reach the other side
+	Bandwidth int           // in bytes-per-second, each way. 0 is unlimited.
+
+	// Loss is the probability (0 to 1) that a write is lost. As streams
+	// are reliable, a loss breaks the stream instead, on both sides, with
+	// ErrStreamLost.
+	Loss float64
 	// we can make these values distributions down the road.
 }
 
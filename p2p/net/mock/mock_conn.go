@@ -3,6 +3,7 @@ package mocknet
 import (
 	"container/list"
 	"sync"
+	"time"
 
 	ic "github.com/jbenet/go-ipfs/p2p/crypto"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
@@ -29,6 +30,9 @@ type conn struct {
 	rconn   *conn // counterpart
 	streams list.List
 
+	dir    inet.Direction
+	opened time.Time
+
 	sync.RWMutex
 }
 
@@ -82,7 +86,7 @@ func (c *conn) remoteOpenedStream(s *stream) {
 }
 
 func (c *conn) openStream() *stream {
-	sl, sr := c.link.newStreamPair()
+	sl, sr := c.link.newStreamPair(c.net)
 	c.addStream(sl)
 	c.net.notifyAll(func(n inet.Notifiee) {
 		n.OpenedStream(c.net, sl)
@@ -98,6 +102,13 @@ func (c *conn) NewStream() (inet.Stream, error) {
 	return s, nil
 }
 
+// Stat returns who opened the connection, when, and its open streams.
+func (c *conn) Stat() inet.Stat {
+	c.RLock()
+	defer c.RUnlock()
+	return inet.Stat{Direction: c.dir, Opened: c.opened, Streams: c.streams.Len()}
+}
+
 // LocalMultiaddr is the Multiaddr on this side
 func (c *conn) LocalMultiaddr() ma.Multiaddr {
 	return c.localAddr
@@ -1,8 +1,9 @@
 package mocknet
 
 import (
-	"io"
+	"math/rand"
 	"sync"
+	"time"
 
 	inet "github.com/jbenet/go-ipfs/p2p/net"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
@@ -13,23 +14,32 @@ import (
 type link struct {
 	mock *mocknet
 	nets []*peernet
-	opts LinkOptions
+	opts *LinkOptions // nil until SetOptions: the mocknet's defaults apply
 
 	// this could have addresses on both sides.
 
 	sync.RWMutex
+
+	// shaping state, under its own lock, as it changes on every write.
+	// sent is when each side, indexed like nets, is done sending what it
+	// wrote so far. rng decides which writes are lost; it is seeded the
+	// same for every link, so a test sees the same losses on each run.
+	shapeLk sync.Mutex
+	sent    [2]time.Time
+	rng     *rand.Rand
 }
 
-func newLink(mn *mocknet, opts LinkOptions) *link {
-	return &link{mock: mn, opts: opts}
+func newLink(mn *mocknet) *link {
+	return &link{mock: mn, rng: rand.New(rand.NewSource(1))}
 }
 
 func (l *link) newConnPair(dialer *peernet) (*conn, *conn) {
 	l.RLock()
 	defer l.RUnlock()
 
+	now := time.Now()
 	mkconn := func(ln, rn *peernet) *conn {
-		c := &conn{net: ln, link: l}
+		c := &conn{net: ln, link: l, opened: now, dir: inet.DirInbound}
 		c.local = ln.peer
 		c.remote = rn.peer
 
@@ -47,21 +57,58 @@ func (l *link) newConnPair(dialer *peernet) (*conn, *conn) {
 	c1.rconn = c2
 	c2.rconn = c1
 
-	if dialer == c1.net {
-		return c1, c2
+	if dialer != c1.net {
+		c1, c2 = c2, c1
 	}
-	return c2, c1
+	c1.dir = inet.DirOutbound
+	return c1, c2
 }
 
-func (l *link) newStreamPair() (*stream, *stream) {
-	r1, w1 := io.Pipe()
-	r2, w2 := io.Pipe()
+// newStreamPair returns a stream for the side of opener, and the one for
+// the other side.
+func (l *link) newStreamPair(opener *peernet) (*stream, *stream) {
+	side := 0
+	if l.nets[1] == opener {
+		side = 1
+	}
+	out := newPipe(l, side)
+	in := newPipe(l, 1-side)
 
-	s1 := &stream{Reader: r1, Writer: w2}
-	s2 := &stream{Reader: r2, Writer: w1}
+	s1 := &stream{r: in, w: out}
+	s2 := &stream{r: out, w: in}
 	return s1, s2
 }
 
+// transmit reserves the link for n bytes sent by side, after what it sent
+// before, and returns when they are all sent. Each side has the whole
+// bandwidth; zero is unlimited.
+func (l *link) transmit(side, n, bandwidth int) time.Time {
+	l.shapeLk.Lock()
+	defer l.shapeLk.Unlock()
+
+	now := time.Now()
+	sent := l.sent[side]
+	if sent.Before(now) {
+		sent = now
+	}
+	if bandwidth > 0 {
+		sent = sent.Add(time.Duration(n) * time.Second / time.Duration(bandwidth))
+	}
+	l.sent[side] = sent
+	return sent
+}
+
+// lose tells whether the next write is lost, with the link's Loss.
+func (l *link) lose() bool {
+	loss := l.Options().Loss
+	if loss <= 0 {
+		return false
+	}
+	l.shapeLk.Lock()
+	defer l.shapeLk.Unlock()
+	return l.rng.Float64() < loss
+}
+
 func (l *link) Networks() []inet.Network {
 	l.RLock()
 	defer l.RUnlock()
@@ -84,10 +131,22 @@ func (l *link) Peers() []peer.ID {
 	return cp
 }
 
+// SetOptions changes the options of the link, from now on: streams already
+// open are shaped by them too.
 func (l *link) SetOptions(o LinkOptions) {
-	l.opts = o
+	l.Lock()
+	defer l.Unlock()
+	l.opts = &o
 }
 
+// Options returns the options of the link, or the mocknet's defaults if it
+// has none of its own.
 func (l *link) Options() LinkOptions {
-	return l.opts
+	l.RLock()
+	opts := l.opts
+	l.RUnlock()
+	if opts != nil {
+		return *opts
+	}
+	return l.mock.LinkDefaults()
 }
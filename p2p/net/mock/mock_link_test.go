@@ -0,0 +1,145 @@
+package mocknet
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	inet "github.com/jbenet/go-ipfs/p2p/net"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+)
+
+// linkedStream connects two peers over a link with opts, and returns the
+// link and both ends of a stream between them.
+func linkedStream(t *testing.T, opts LinkOptions) (Link, inet.Stream, inet.Stream) {
+	mn, err := WithNPeers(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nets := mn.Nets()
+	l, err := mn.LinkNets(nets[0], nets[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetOptions(opts)
+
+	remote := make(chan inet.Stream, 1)
+	nets[1].SetStreamHandler(func(s inet.Stream) {
+		remote <- s
+	})
+	if _, err := mn.ConnectNets(nets[0], nets[1]); err != nil {
+		t.Fatal(err)
+	}
+	s, err := nets[0].NewStream(nets[1].LocalPeer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, s, <-remote
+}
+
+// timeTransfer writes n bytes to w, in writes of 100, and returns how long
+// they take to arrive.
+func timeTransfer(t *testing.T, w, r inet.Stream, n int) time.Duration {
+	start := time.Now()
+	go func() {
+		b := make([]byte, 100)
+		for i := 0; i < n; i += len(b) {
+			if _, err := w.Write(b); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	if _, err := io.ReadFull(r, make([]byte, n)); err != nil {
+		t.Fatal(err)
+	}
+	return time.Since(start)
+}
+
+func TestLinkLatency(t *testing.T) {
+	_, s1, s2 := linkedStream(t, LinkOptions{Latency: 50 * time.Millisecond})
+
+	if d := timeTransfer(t, s1, s2, 100); d < 50*time.Millisecond {
+		t.Fatalf("write arrived after %s, before the latency", d)
+	}
+	// writes are pipelined: ten of them take one latency, not ten.
+	if d := timeTransfer(t, s2, s1, 1000); d < 50*time.Millisecond || d > 400*time.Millisecond {
+		t.Fatalf("writes arrived after %s", d)
+	}
+}
+
+func TestLinkBandwidth(t *testing.T) {
+	_, s1, s2 := linkedStream(t, LinkOptions{Bandwidth: 10000})
+
+	// 2000 bytes at 10000 bytes per second.
+	if d := timeTransfer(t, s1, s2, 2000); d < 200*time.Millisecond || d > 2*time.Second {
+		t.Fatalf("2000 bytes arrived after %s", d)
+	}
+}
+
+func TestLinkSetOptions(t *testing.T) {
+	l, s1, s2 := linkedStream(t, LinkOptions{})
+
+	if d := timeTransfer(t, s1, s2, 100); d > 50*time.Millisecond {
+		t.Fatalf("write arrived after %s on a fast link", d)
+	}
+	// open streams are slowed down too.
+	l.SetOptions(LinkOptions{Latency: 100 * time.Millisecond})
+	if d := timeTransfer(t, s1, s2, 100); d < 100*time.Millisecond {
+		t.Fatalf("write arrived after %s, before the new latency", d)
+	}
+}
+
+func TestLinkDefaults(t *testing.T) {
+	mn, err := FullMeshLinked(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nets := mn.Nets()
+	l := mn.LinksBetweenNets(nets[0], nets[1])[0]
+
+	// links without options of their own follow the defaults.
+	mn.SetLinkDefaults(LinkOptions{Latency: time.Second})
+	if l.Options().Latency != time.Second {
+		t.Fatal("link does not follow the defaults")
+	}
+	l.SetOptions(LinkOptions{Latency: time.Millisecond})
+	mn.SetLinkDefaults(LinkOptions{Latency: time.Minute})
+	if l.Options().Latency != time.Millisecond {
+		t.Fatal("defaults override the link's options")
+	}
+}
+
+func TestLinkLoss(t *testing.T) {
+	_, s1, s2 := linkedStream(t, LinkOptions{Loss: 1})
+
+	if _, err := s1.Write([]byte("beep")); err != ErrStreamLost {
+		t.Fatalf("expected ErrStreamLost, got %v", err)
+	}
+	if _, err := s2.Read(make([]byte, 4)); err != ErrStreamLost {
+		t.Fatalf("remote side: expected ErrStreamLost, got %v", err)
+	}
+	if _, err := s2.Write([]byte("boop")); err != ErrStreamLost {
+		t.Fatalf("remote side: expected ErrStreamLost, got %v", err)
+	}
+}
+
+func TestLinkLossDeterministic(t *testing.T) {
+	// the number of writes until one is lost, on a fresh link.
+	writes := func() int {
+		_, s, _ := linkedStream(t, LinkOptions{Loss: 0.1})
+		for i := 0; ; i++ {
+			if _, err := s.Write([]byte("beep")); err != nil {
+				return i
+			}
+		}
+	}
+
+	n := writes()
+	for i := 0; i < 3; i++ {
+		if m := writes(); m != n {
+			t.Fatalf("lost write %d, then write %d", n, m)
+		}
+	}
+}
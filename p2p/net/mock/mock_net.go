@@ -9,6 +9,7 @@ import (
 	host "github.com/jbenet/go-ipfs/p2p/host"
 	bhost "github.com/jbenet/go-ipfs/p2p/host/basic"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	p2putil "github.com/jbenet/go-ipfs/p2p/test/util"
 	testutil "github.com/jbenet/go-ipfs/util/testutil"
@@ -61,7 +62,11 @@ func (mn *mocknet) GenPeer() (host.Host, error) {
 }
 
 func (mn *mocknet) AddPeer(k ic.PrivKey, a ma.Multiaddr) (host.Host, error) {
-	n, err := newPeernet(mn.cg.Context(), mn, k, a)
+	return mn.AddPeerWithProtector(k, a, nil)
+}
+
+func (mn *mocknet) AddPeerWithProtector(k ic.PrivKey, a ma.Multiaddr, protec pnet.Protector) (host.Host, error) {
+	n, err := newPeernet(mn.cg.Context(), mn, k, a, protec)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +76,7 @@ func (mn *mocknet) AddPeer(k ic.PrivKey, a ma.Multiaddr) (host.Host, error) {
 	// make sure to add listening address!
 	// this makes debugging things simpler as remembering to register
 	// an address may cause unexpected failure.
-	n.Peerstore().AddAddress(n.LocalPeer(), a)
+	n.Peerstore().AddAddress(n.LocalPeer(), a, peer.PermanentAddrTTL)
 	log.Debugf("mocknet added listen addr for peer: %s -- %s", n.LocalPeer(), a)
 
 	mn.cg.AddChildGroup(n.cg)
@@ -109,6 +114,12 @@ func (mn *mocknet) Net(pid peer.ID) inet.Network {
 	return n
 }
 
+func (mn *mocknet) peernet(pid peer.ID) *peernet {
+	mn.RLock()
+	defer mn.RUnlock()
+	return mn.nets[pid]
+}
+
 func (mn *mocknet) Hosts() []host.Host {
 	mn.RLock()
 	defer mn.RUnlock()
@@ -203,7 +214,6 @@ func (mn *mocknet) LinkNets(n1, n2 inet.Network) (Link, error) {
 	mn.RLock()
 	n1r, err1 := mn.validate(n1)
 	n2r, err2 := mn.validate(n2)
-	ld := mn.linkDefaults
 	mn.RUnlock()
 
 	if err1 != nil {
@@ -213,7 +223,7 @@ func (mn *mocknet) LinkNets(n1, n2 inet.Network) (Link, error) {
 		return nil, err2
 	}
 
-	l := newLink(mn, ld)
+	l := newLink(mn)
 	l.nets = append(l.nets, n1r, n2r)
 	mn.addLink(l)
 	return l, nil
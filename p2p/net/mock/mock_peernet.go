@@ -1,12 +1,14 @@
 package mocknet
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
 	"sync"
 
 	ic "github.com/jbenet/go-ipfs/p2p/crypto"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
@@ -21,6 +23,9 @@ type peernet struct {
 	peer peer.ID
 	ps   peer.Peerstore
 
+	// protec is the private network of the peer, if any.
+	protec pnet.Protector
+
 	// conns are actual live connections between peers.
 	// many conns could run over each link.
 	// **conns are NOT shared between peers**
@@ -40,7 +45,7 @@ type peernet struct {
 
 // newPeernet constructs a new peernet
 func newPeernet(ctx context.Context, m *mocknet, k ic.PrivKey,
-	a ma.Multiaddr) (*peernet, error) {
+	a ma.Multiaddr, protec pnet.Protector) (*peernet, error) {
 
 	p, err := peer.IDFromPublicKey(k.GetPublic())
 	if err != nil {
@@ -49,7 +54,7 @@ func newPeernet(ctx context.Context, m *mocknet, k ic.PrivKey,
 
 	// create our own entirely, so that peers knowledge doesn't get shared
 	ps := peer.NewPeerstore()
-	ps.AddAddress(p, a)
+	ps.AddAddress(p, a, peer.PermanentAddrTTL)
 	ps.AddPrivKey(p, k)
 	ps.AddPubKey(p, k.GetPublic())
 
@@ -57,6 +62,7 @@ func newPeernet(ctx context.Context, m *mocknet, k ic.PrivKey,
 		mocknet: m,
 		peer:    p,
 		ps:      ps,
+		protec:  protec,
 		cg:      ctxgroup.WithContext(ctx),
 
 		connsByPeer: map[peer.ID]map[*conn]struct{}{},
@@ -158,12 +164,25 @@ func (pn *peernet) connect(p peer.ID) (*conn, error) {
 	// links (network interfaces) and select properly
 	l := links[rand.Intn(len(links))]
 
+	// a real handshake fails without the key of the private network.
+	if rn := pn.mocknet.peernet(p); rn != nil && !sameNetwork(pn.protec, rn.protec) {
+		return nil, fmt.Errorf("%s cannot connect to %s: %s", pn.peer, p, pnet.ErrWrongKey)
+	}
+
 	log.Debugf("%s dialing %s openingConn", pn.peer, p)
 	// create a new connection with link
 	c := pn.openConn(p, l.(*link))
 	return c, nil
 }
 
+// sameNetwork returns whether a and b protect the same network, or none.
+func sameNetwork(a, b pnet.Protector) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return bytes.Equal(a.Fingerprint(), b.Fingerprint())
+}
+
 func (pn *peernet) openConn(r peer.ID, l *link) *conn {
 	lc, rc := l.newConnPair(pn)
 	log.Debugf("%s opening connection to %s", pn.LocalPeer(), lc.RemotePeer())
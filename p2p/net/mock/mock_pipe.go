@@ -0,0 +1,159 @@
+package mocknet
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrStreamLost is returned by streams broken by the loss of a link.
+var ErrStreamLost = errors.New("mocknet: stream lost on a lossy link")
+
+// chunk is one write, readable from a pipe at a given time.
+type chunk struct {
+	data []byte
+	at   time.Time
+	eof  bool
+}
+
+// pipe carries one direction of a stream, like an io.Pipe, but through a
+// link: writes leave no faster than the link's bandwidth, and are read once
+// its latency has passed. side is the index of the writing net in the
+// link's nets.
+type pipe struct {
+	link *link
+	side int
+
+	wlk sync.Mutex // serializes writes, so chunks queue in order
+
+	lk    sync.Mutex
+	cond  *sync.Cond
+	queue []chunk
+	buf   []byte // what is left of the chunk being read
+	rerr  error  // returned by Read once set, and the queue drained
+	werr  error  // returned by Write once set
+}
+
+func newPipe(l *link, side int) *pipe {
+	p := &pipe{link: l, side: side}
+	p.cond = sync.NewCond(&p.lk)
+	return p
+}
+
+func (p *pipe) Read(b []byte) (int, error) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	for {
+		if len(p.buf) > 0 {
+			n := copy(b, p.buf)
+			p.buf = p.buf[n:]
+			return n, nil
+		}
+
+		if len(p.queue) == 0 {
+			if p.rerr != nil {
+				return 0, p.rerr
+			}
+			p.cond.Wait()
+			continue
+		}
+
+		c := p.queue[0]
+		if wait := c.at.Sub(time.Now()); wait > 0 {
+			// still on the wire. wake up when it arrives, or earlier if
+			// something else happens to the pipe.
+			t := time.AfterFunc(wait, p.wake)
+			p.cond.Wait()
+			t.Stop()
+			continue
+		}
+
+		p.queue = p.queue[1:]
+		if c.eof {
+			p.rerr = io.EOF
+			p.queue = nil
+			continue
+		}
+		p.buf = c.data
+	}
+}
+
+func (p *pipe) Write(b []byte) (int, error) {
+	p.wlk.Lock()
+	defer p.wlk.Unlock()
+
+	if err := p.writeErr(); err != nil {
+		return 0, err
+	}
+
+	opts := p.link.Options()
+	sent := p.link.transmit(p.side, len(b), opts.Bandwidth)
+	time.Sleep(sent.Sub(time.Now()))
+
+	data := make([]byte, len(b))
+	copy(data, b)
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.werr != nil {
+		return 0, p.werr
+	}
+	p.queue = append(p.queue, chunk{data: data, at: sent.Add(opts.Latency)})
+	p.cond.Broadcast()
+	return len(b), nil
+}
+
+func (p *pipe) writeErr() error {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return p.werr
+}
+
+func (p *pipe) wake() {
+	p.lk.Lock()
+	p.cond.Broadcast()
+	p.lk.Unlock()
+}
+
+// closeWrite ends the pipe: the reader gets io.EOF after what was written
+// so far, once it crosses the link.
+func (p *pipe) closeWrite() {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.werr != nil {
+		return
+	}
+	p.werr = io.ErrClosedPipe
+	at := time.Now().Add(p.link.Options().Latency)
+	p.queue = append(p.queue, chunk{at: at, eof: true})
+	p.cond.Broadcast()
+}
+
+// closeRead makes further writes fail, and drops what was not read.
+func (p *pipe) closeRead() {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.werr == nil {
+		p.werr = io.ErrClosedPipe
+	}
+	if p.rerr == nil {
+		p.rerr = io.ErrClosedPipe
+	}
+	p.queue = nil
+	p.buf = nil
+	p.cond.Broadcast()
+}
+
+// reset breaks the pipe right away, for both sides, with err. What was
+// still on the wire is lost.
+func (p *pipe) reset(err error) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.werr = err
+	p.rerr = err
+	p.queue = nil
+	p.buf = nil
+	p.cond.Broadcast()
+}
@@ -1,26 +1,35 @@
 package mocknet
 
 import (
-	"io"
-
 	inet "github.com/jbenet/go-ipfs/p2p/net"
 )
 
 // stream implements inet.Stream
 type stream struct {
-	io.Reader
-	io.Writer
+	r    *pipe // written by the remote stream
+	w    *pipe // read by the remote stream
 	conn *conn
 }
 
+func (s *stream) Read(b []byte) (int, error) {
+	return s.r.Read(b)
+}
+
+// Write sends b to the remote stream. On a lossy link, it may break the
+// stream instead, for both sides.
+func (s *stream) Write(b []byte) (int, error) {
+	if s.conn.link.lose() {
+		s.r.reset(ErrStreamLost)
+		s.w.reset(ErrStreamLost)
+		return 0, ErrStreamLost
+	}
+	return s.w.Write(b)
+}
+
 func (s *stream) Close() error {
 	s.conn.removeStream(s)
-	if r, ok := (s.Reader).(io.Closer); ok {
-		r.Close()
-	}
-	if w, ok := (s.Writer).(io.Closer); ok {
-		w.Close()
-	}
+	s.r.closeRead()
+	s.w.closeWrite()
 	s.conn.net.notifyAll(func(n inet.Notifiee) {
 		n.ClosedStream(s.conn.net, s)
 	})
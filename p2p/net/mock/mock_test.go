@@ -7,7 +7,9 @@ import (
 	"sync"
 	"testing"
 
+	host "github.com/jbenet/go-ipfs/p2p/host"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
 	testutil "github.com/jbenet/go-ipfs/util/testutil"
@@ -473,3 +475,51 @@ func TestAdding(t *testing.T) {
 	}
 
 }
+
+func TestPrivateNetwork(t *testing.T) {
+	ctx := context.Background()
+	mn := New(ctx)
+
+	protector := func(b byte) pnet.Protector {
+		var psk [pnet.KeyLength]byte
+		copy(psk[:], bytes.Repeat([]byte{b}, pnet.KeyLength))
+		return pnet.NewProtector(&psk)
+	}
+	key1 := protector(1)
+
+	var hosts []host.Host
+	for _, protec := range []pnet.Protector{key1, key1, protector(2), nil} {
+		sk, _, err := testutil.RandTestKeyPair(512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := mn.AddPeerWithProtector(sk, testutil.RandLocalTCPAddress(), protec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, h)
+	}
+	for _, h1 := range hosts {
+		for _, h2 := range hosts {
+			if _, err := mn.LinkPeers(h1.ID(), h2.ID()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if _, err := mn.ConnectPeers(hosts[0].ID(), hosts[1].ID()); err != nil {
+		t.Fatal("peers with the same key did not connect:", err)
+	}
+	for _, h := range hosts[2:] {
+		if _, err := mn.ConnectPeers(hosts[0].ID(), h.ID()); err == nil {
+			t.Fatal("connected to a peer outside the private network")
+		}
+		if _, err := mn.ConnectPeers(h.ID(), hosts[0].ID()); err == nil {
+			t.Fatal("connected from a peer outside the private network")
+		}
+	}
+	// the peers outside are in no network together either.
+	if _, err := mn.ConnectPeers(hosts[2].ID(), hosts[3].ID()); err == nil {
+		t.Fatal("connected peers in different networks")
+	}
+}
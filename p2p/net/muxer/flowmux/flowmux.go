@@ -0,0 +1,453 @@
+// Package flowmux implements a stream multiplexer with per-stream flow
+// control, as a go-peerstream transport.
+//
+// Every frame starts with a 10 byte header (big endian):
+//
+//   type (1) | flags (1) | stream id (4) | length (4)
+//
+// Data frames carry length bytes of payload. Window update frames carry
+// no payload; their length is the number of bytes the sender may now
+// write on top of its current window. Each side may send at most its
+// window before the reader consumes the data, so a stream nobody reads
+// stalls its writer instead of buffering without bounds.
+package flowmux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	pst "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-peerstream/transport"
+)
+
+const (
+	typeData         = 0
+	typeWindowUpdate = 1
+
+	flagSYN = 1 << 0 // opens a stream
+	flagFIN = 1 << 1 // the sender will write no more on this stream
+	flagRST = 1 << 2 // aborts the stream
+
+	headerLen = 10
+)
+
+var (
+	// ErrStreamReset is returned by streams aborted by the other side.
+	ErrStreamReset = errors.New("flowmux: stream reset")
+
+	// ErrStreamClosed is returned when writing on a closed stream.
+	ErrStreamClosed = errors.New("flowmux: stream closed")
+
+	// ErrSessionClosed is returned by operations on a closed session.
+	ErrSessionClosed = errors.New("flowmux: session closed")
+)
+
+// Transport is a go-peerstream transport that constructs flowmux
+// sessions.
+type Transport struct {
+	// WindowSize is the initial (and maximum) number of unread bytes a
+	// stream accepts.
+	WindowSize uint32
+
+	// MaxFrameSize bounds the payload of data frames.
+	MaxFrameSize uint32
+
+	// AcceptBacklog is the number of streams opened by the other side
+	// that may wait to be accepted. Streams beyond it are reset.
+	AcceptBacklog int
+}
+
+// DefaultTransport has default settings for flowmux.
+var DefaultTransport = &Transport{
+	WindowSize:    256 * 1024,
+	MaxFrameSize:  16 * 1024,
+	AcceptBacklog: 256,
+}
+
+// NewConn constructs a flowmux session over nc.
+func (t *Transport) NewConn(nc net.Conn, isServer bool) (pst.Conn, error) {
+	return newSession(t, nc, isServer), nil
+}
+
+// session multiplexes streams over one connection.
+type session struct {
+	t  *Transport
+	nc net.Conn
+
+	wlk sync.Mutex // serializes frame writes
+
+	lk      sync.Mutex
+	streams map[uint32]*stream
+	nextID  uint32
+	err     error // set once the session is closed
+
+	accept chan *stream
+	closed chan struct{}
+}
+
+func newSession(t *Transport, nc net.Conn, isServer bool) *session {
+	s := &session{
+		t:       t,
+		nc:      nc,
+		streams: make(map[uint32]*stream),
+		nextID:  1, // clients open odd streams, servers even ones.
+		accept:  make(chan *stream, t.AcceptBacklog),
+		closed:  make(chan struct{}),
+	}
+	if isServer {
+		s.nextID = 2
+	}
+	go s.recvLoop()
+	return s
+}
+
+// Close closes the session and all of its streams.
+func (s *session) Close() error {
+	s.fail(ErrSessionClosed)
+	return nil
+}
+
+// IsClosed returns whether the session is closed.
+func (s *session) IsClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// OpenStream opens a new stream to the other side.
+func (s *session) OpenStream() (pst.Stream, error) {
+	s.lk.Lock()
+	if s.err != nil {
+		s.lk.Unlock()
+		return nil, s.err
+	}
+	id := s.nextID
+	s.nextID += 2
+	st := newStream(s, id)
+	s.streams[id] = st
+	s.lk.Unlock()
+
+	if err := s.writeFrame(typeData, flagSYN, id, 0, nil); err != nil {
+		s.removeStream(id)
+		return nil, err
+	}
+	return st, nil
+}
+
+// Serve accepts streams opened by the other side, and handles each in
+// its own goroutine, until the session closes.
+func (s *session) Serve(handler pst.StreamHandler) {
+	for {
+		select {
+		case st := <-s.accept:
+			go handler(st)
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// fail closes the session with err, waking up all streams.
+func (s *session) fail(err error) {
+	s.lk.Lock()
+	if s.err != nil {
+		s.lk.Unlock()
+		return
+	}
+	s.err = err
+	streams := s.streams
+	s.streams = make(map[uint32]*stream)
+	close(s.closed)
+	s.lk.Unlock()
+
+	s.nc.Close()
+	for _, st := range streams {
+		st.abort(ErrSessionClosed)
+	}
+}
+
+func (s *session) getStream(id uint32) *stream {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.streams[id]
+}
+
+func (s *session) removeStream(id uint32) {
+	s.lk.Lock()
+	delete(s.streams, id)
+	s.lk.Unlock()
+}
+
+func (s *session) writeFrame(typ, flags byte, id, length uint32, data []byte) error {
+	buf := make([]byte, headerLen+len(data))
+	buf[0] = typ
+	buf[1] = flags
+	binary.BigEndian.PutUint32(buf[2:6], id)
+	binary.BigEndian.PutUint32(buf[6:10], length)
+	copy(buf[headerLen:], data)
+
+	s.wlk.Lock()
+	defer s.wlk.Unlock()
+	select {
+	case <-s.closed:
+		return ErrSessionClosed
+	default:
+	}
+	if _, err := s.nc.Write(buf); err != nil {
+		s.fail(err)
+		return err
+	}
+	return nil
+}
+
+// recvLoop reads frames and dispatches them to their streams. It never
+// blocks on a stream: data beyond a stream's window is a protocol error.
+func (s *session) recvLoop() {
+	hdr := make([]byte, headerLen)
+	for {
+		if _, err := io.ReadFull(s.nc, hdr); err != nil {
+			s.fail(err)
+			return
+		}
+		typ, flags := hdr[0], hdr[1]
+		id := binary.BigEndian.Uint32(hdr[2:6])
+		length := binary.BigEndian.Uint32(hdr[6:10])
+
+		if err := s.handleFrame(typ, flags, id, length); err != nil {
+			s.fail(err)
+			return
+		}
+	}
+}
+
+func (s *session) handleFrame(typ, flags byte, id, length uint32) error {
+	var data []byte
+	switch typ {
+	case typeData:
+		if length > s.t.MaxFrameSize {
+			return fmt.Errorf("flowmux: frame of %d bytes exceeds maximum %d", length, s.t.MaxFrameSize)
+		}
+		data = make([]byte, length)
+		if _, err := io.ReadFull(s.nc, data); err != nil {
+			return err
+		}
+	case typeWindowUpdate:
+	default:
+		return fmt.Errorf("flowmux: unknown frame type %d", typ)
+	}
+
+	st := s.getStream(id)
+	if flags&flagSYN != 0 {
+		if st != nil {
+			return fmt.Errorf("flowmux: stream %d opened twice", id)
+		}
+		st = s.incomingStream(id)
+	}
+	if st == nil {
+		return nil // closed on our side already.
+	}
+
+	if flags&flagRST != 0 {
+		st.reset()
+		s.removeStream(id)
+		return nil
+	}
+
+	if typ == typeWindowUpdate {
+		st.grow(length)
+		return nil
+	}
+	return st.push(data, flags&flagFIN != 0)
+}
+
+// incomingStream registers a stream opened by the other side, and queues
+// it for Serve. Streams past the accept backlog are reset.
+func (s *session) incomingStream(id uint32) *stream {
+	st := newStream(s, id)
+	s.lk.Lock()
+	if s.err != nil {
+		s.lk.Unlock()
+		return nil
+	}
+	s.streams[id] = st
+	s.lk.Unlock()
+
+	select {
+	case s.accept <- st:
+		return st
+	default:
+		s.removeStream(id)
+		go s.writeFrame(typeData, flagRST, id, 0, nil)
+		return nil
+	}
+}
+
+// stream is one flow controlled stream of a session.
+type stream struct {
+	s  *session
+	id uint32
+
+	lk   sync.Mutex
+	cond *sync.Cond
+
+	buf        bytes.Buffer
+	recvWindow uint32 // bytes the other side may still send us
+	unacked    uint32 // bytes read, but not yet granted back
+
+	sendWindow uint32 // bytes we may still send
+
+	localClosed  bool // we sent FIN
+	remoteClosed bool // we got FIN
+	err          error
+}
+
+func newStream(s *session, id uint32) *stream {
+	st := &stream{
+		s:          s,
+		id:         id,
+		recvWindow: s.t.WindowSize,
+		sendWindow: s.t.WindowSize,
+	}
+	st.cond = sync.NewCond(&st.lk)
+	return st
+}
+
+// Read reads data sent by the other side. Once half of the window has
+// been read, it is granted back with a window update.
+func (st *stream) Read(b []byte) (int, error) {
+	st.lk.Lock()
+	for st.buf.Len() == 0 && !st.remoteClosed && st.err == nil {
+		st.cond.Wait()
+	}
+	if st.buf.Len() == 0 {
+		defer st.lk.Unlock()
+		if st.err != nil {
+			return 0, st.err
+		}
+		return 0, io.EOF
+	}
+
+	n, _ := st.buf.Read(b)
+	st.unacked += uint32(n)
+	var grant uint32
+	if st.unacked >= st.s.t.WindowSize/2 && !st.remoteClosed {
+		grant = st.unacked
+		st.recvWindow += grant
+		st.unacked = 0
+	}
+	st.lk.Unlock()
+
+	if grant > 0 {
+		// a failure here closes the session; the data read is still good.
+		st.s.writeFrame(typeWindowUpdate, 0, st.id, grant, nil)
+	}
+	return n, nil
+}
+
+// Write writes b to the other side, blocking while the send window is
+// exhausted.
+func (st *stream) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		st.lk.Lock()
+		for st.sendWindow == 0 && !st.localClosed && st.err == nil {
+			st.cond.Wait()
+		}
+		if st.err != nil {
+			err := st.err
+			st.lk.Unlock()
+			return written, err
+		}
+		if st.localClosed {
+			st.lk.Unlock()
+			return written, ErrStreamClosed
+		}
+
+		n := uint32(len(b))
+		if n > st.sendWindow {
+			n = st.sendWindow
+		}
+		if n > st.s.t.MaxFrameSize {
+			n = st.s.t.MaxFrameSize
+		}
+		st.sendWindow -= n
+		st.lk.Unlock()
+
+		if err := st.s.writeFrame(typeData, 0, st.id, n, b[:n]); err != nil {
+			return written, err
+		}
+		written += int(n)
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// Close closes the stream for writing. The other side may still write,
+// until it closes too.
+func (st *stream) Close() error {
+	st.lk.Lock()
+	if st.localClosed || st.err != nil {
+		st.lk.Unlock()
+		return nil
+	}
+	st.localClosed = true
+	done := st.remoteClosed
+	st.cond.Broadcast()
+	st.lk.Unlock()
+
+	if done {
+		st.s.removeStream(st.id)
+	}
+	return st.s.writeFrame(typeData, flagFIN, st.id, 0, nil)
+}
+
+// push delivers data received for the stream.
+func (st *stream) push(data []byte, fin bool) error {
+	st.lk.Lock()
+	if uint32(len(data)) > st.recvWindow {
+		st.lk.Unlock()
+		return fmt.Errorf("flowmux: stream %d exceeded its window", st.id)
+	}
+	st.recvWindow -= uint32(len(data))
+	st.buf.Write(data)
+	done := false
+	if fin {
+		st.remoteClosed = true
+		done = st.localClosed
+	}
+	st.cond.Broadcast()
+	st.lk.Unlock()
+
+	if done {
+		st.s.removeStream(st.id)
+	}
+	return nil
+}
+
+// grow extends the send window by delta.
+func (st *stream) grow(delta uint32) {
+	st.lk.Lock()
+	st.sendWindow += delta
+	st.cond.Broadcast()
+	st.lk.Unlock()
+}
+
+func (st *stream) reset() {
+	st.abort(ErrStreamReset)
+}
+
+func (st *stream) abort(err error) {
+	st.lk.Lock()
+	if st.err == nil {
+		st.err = err
+	}
+	st.cond.Broadcast()
+	st.lk.Unlock()
+}
@@ -0,0 +1,131 @@
+package flowmux
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	pst "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-peerstream/transport"
+	psttest "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-peerstream/transport/test"
+)
+
+func TestFlowmuxTransport(t *testing.T) {
+	psttest.SubtestAll(t, DefaultTransport)
+}
+
+// sessionPair returns a client and server session over a pipe.
+func sessionPair(t *testing.T, tr *Transport) (pst.Conn, pst.Conn) {
+	a, b := net.Pipe()
+	client, err := tr.NewConn(a, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := tr.NewConn(b, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestWriteBlocksOnWindow(t *testing.T) {
+	tr := &Transport{WindowSize: 1024, MaxFrameSize: 256, AcceptBacklog: 1}
+	client, server := sessionPair(t, tr)
+	defer client.Close()
+	defer server.Close()
+
+	accepted := make(chan pst.Stream, 1)
+	go server.Serve(func(s pst.Stream) { accepted <- s })
+
+	cs, err := client.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nobody reads, so only a window's worth goes through.
+	written := make(chan int, 1)
+	go func() {
+		n, _ := cs.Write(make([]byte, 4096))
+		written <- n
+	}()
+
+	ss := <-accepted
+	select {
+	case n := <-written:
+		t.Fatalf("write of %d bytes should block on the window", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// reading grants the window back, and the write completes.
+	buf := make([]byte, 4096)
+	if _, err := io.ReadFull(ss, buf); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-written:
+		if n != 4096 {
+			t.Fatalf("wrote %d bytes", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write did not complete after reading")
+	}
+}
+
+func TestCloseIsHalfClose(t *testing.T) {
+	client, server := sessionPair(t, DefaultTransport)
+	defer client.Close()
+	defer server.Close()
+
+	go server.Serve(func(s pst.Stream) {
+		io.Copy(s, s) // echo until the client closes, then close.
+		s.Close()
+	})
+
+	cs, err := client.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		cs.Write([]byte("hello"))
+		cs.Close()
+	}()
+
+	out := make([]byte, 10)
+	n, err := io.ReadFull(cs, out)
+	if err != io.ErrUnexpectedEOF || string(out[:n]) != "hello" {
+		t.Fatalf("read %q, %v", out[:n], err)
+	}
+	if _, err := cs.Write([]byte("x")); err != ErrStreamClosed {
+		t.Fatalf("write after close should fail, got %v", err)
+	}
+}
+
+func TestSessionCloseWakesStreams(t *testing.T) {
+	client, server := sessionPair(t, DefaultTransport)
+	defer server.Close()
+	go server.Serve(func(s pst.Stream) {})
+
+	cs, err := client.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := cs.Read(make([]byte, 1))
+		errs <- err
+	}()
+
+	client.Close()
+	select {
+	case err := <-errs:
+		if err != ErrSessionClosed {
+			t.Fatalf("expected ErrSessionClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read not woken by session close")
+	}
+	if !client.IsClosed() {
+		t.Fatal("session should be closed")
+	}
+}
@@ -0,0 +1,168 @@
+// Package muxer negotiates which stream multiplexer a connection uses.
+//
+// Before any stream is opened, the two sides of a connection agree on a
+// muxer with protocol.SelectOneOf and protocol.Negotiate: the dialer
+// proposes the muxers it knows in order of preference, and the listener
+// picks the first one it supports.
+package muxer
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	flowmux "github.com/jbenet/go-ipfs/p2p/net/muxer/flowmux"
+	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
+	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+
+	pst "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-peerstream/transport"
+	psy "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-peerstream/transport/yamux"
+)
+
+var log = eventlog.Logger("p2p/net/muxer")
+
+// NegotiateTimeout bounds the muxer negotiation of a connection.
+var NegotiateTimeout = 30 * time.Second
+
+// Muxer is a stream multiplexer, and the protocol id it is negotiated as.
+type Muxer struct {
+	ID        protocol.ID
+	Transport pst.Transport
+}
+
+var (
+	// Yamux is hashicorp's yamux.
+	Yamux = Muxer{"/yamux/1.0.0", psy.DefaultTransport}
+
+	// Flowmux is our flow controlled muxer.
+	Flowmux = Muxer{"/flowmux/1.0.0", flowmux.DefaultTransport}
+)
+
+// DefaultTransport negotiates between yamux and flowmux, preferring yamux.
+var DefaultTransport = NewTransport(Yamux, Flowmux)
+
+// Transport is a go-peerstream transport that negotiates one of its
+// muxers on every connection.
+type Transport struct {
+	muxers []Muxer
+}
+
+// NewTransport constructs a Transport for muxers, in order of preference.
+func NewTransport(muxers ...Muxer) *Transport {
+	return &Transport{muxers: muxers}
+}
+
+// NewConn returns a connection that negotiates its muxer over nc. The
+// negotiation runs in the background, as go-peerstream constructs
+// connections under its lock: operations on the connection wait for it.
+func (t *Transport) NewConn(nc net.Conn, isServer bool) (pst.Conn, error) {
+	if len(t.muxers) == 0 {
+		return nil, errors.New("muxer: no muxers to negotiate")
+	}
+
+	c := &conn{nc: nc, ready: make(chan struct{})}
+	go c.negotiate(t, isServer)
+	return c, nil
+}
+
+func (t *Transport) ids() []protocol.ID {
+	ids := make([]protocol.ID, len(t.muxers))
+	for i, m := range t.muxers {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+func (t *Transport) find(id protocol.ID) (Muxer, bool) {
+	for _, m := range t.muxers {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Muxer{}, false
+}
+
+// conn is a connection whose muxer is being negotiated.
+type conn struct {
+	nc    net.Conn
+	ready chan struct{} // closed once negotiated (or failed)
+	id    protocol.ID
+	pc    pst.Conn
+	err   error
+}
+
+func (c *conn) negotiate(t *Transport, isServer bool) {
+	defer close(c.ready)
+
+	c.nc.SetDeadline(time.Now().Add(NegotiateTimeout))
+	var id protocol.ID
+	var err error
+	if isServer {
+		supported := func(p protocol.ID) bool {
+			_, ok := t.find(p)
+			return ok
+		}
+		id, err = protocol.Negotiate(c.nc, supported, t.ids)
+	} else {
+		id, err = protocol.SelectOneOf(c.nc, t.ids()...)
+	}
+	c.nc.SetDeadline(time.Time{})
+
+	if err != nil {
+		log.Debugf("muxer negotiation with %s failed: %s", c.nc.RemoteAddr(), err)
+		c.err = err
+		c.nc.Close()
+		return
+	}
+
+	m, _ := t.find(id)
+	c.id = id
+	log.Debugf("negotiated muxer %s with %s", id, c.nc.RemoteAddr())
+	c.pc, c.err = m.Transport.NewConn(c.nc, isServer)
+	if c.err != nil {
+		c.nc.Close()
+	}
+}
+
+// Close closes the connection, interrupting a pending negotiation.
+func (c *conn) Close() error {
+	select {
+	case <-c.ready:
+	default:
+		c.nc.Close()
+		<-c.ready
+	}
+	if c.pc == nil {
+		return c.err
+	}
+	return c.pc.Close()
+}
+
+// IsClosed returns whether the connection is closed. Connections still
+// negotiating are not.
+func (c *conn) IsClosed() bool {
+	select {
+	case <-c.ready:
+	default:
+		return false
+	}
+	return c.pc == nil || c.pc.IsClosed()
+}
+
+// OpenStream waits for the negotiation, then opens a stream.
+func (c *conn) OpenStream() (pst.Stream, error) {
+	<-c.ready
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.pc.OpenStream()
+}
+
+// Serve waits for the negotiation, then serves incoming streams.
+func (c *conn) Serve(handler pst.StreamHandler) {
+	<-c.ready
+	if c.err != nil {
+		return
+	}
+	c.pc.Serve(handler)
+}
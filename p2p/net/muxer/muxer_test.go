@@ -0,0 +1,75 @@
+package muxer
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
+
+	pst "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-peerstream/transport"
+	psttest "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-peerstream/transport/test"
+)
+
+func TestNegotiatingTransport(t *testing.T) {
+	psttest.SubtestAll(t, DefaultTransport)
+}
+
+func connPair(t *testing.T, client, server *Transport) (pst.Conn, pst.Conn) {
+	a, b := net.Pipe()
+	cc, err := client.NewConn(a, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := server.NewConn(b, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cc, sc
+}
+
+func TestFallbackToSecondMuxer(t *testing.T) {
+	cc, sc := connPair(t, NewTransport(Yamux, Flowmux), NewTransport(Flowmux))
+	defer cc.Close()
+	defer sc.Close()
+
+	go sc.Serve(func(s pst.Stream) {
+		io.Copy(s, s)
+		s.Close()
+	})
+
+	s, err := cc.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		s.Write([]byte("beep"))
+		s.Close()
+	}()
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(s, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "beep" {
+		t.Fatalf("echoed %q", buf)
+	}
+
+	for _, c := range []pst.Conn{cc, sc} {
+		if id := c.(*conn).id; id != Flowmux.ID {
+			t.Fatalf("negotiated %s, expected %s", id, Flowmux.ID)
+		}
+	}
+}
+
+func TestNoCommonMuxer(t *testing.T) {
+	cc, sc := connPair(t, NewTransport(Yamux), NewTransport(Flowmux))
+	defer sc.Close()
+
+	if _, err := cc.OpenStream(); err != protocol.ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if !cc.IsClosed() {
+		t.Fatal("connection should be closed after failing to negotiate")
+	}
+}
@@ -0,0 +1,188 @@
+// Package pnet makes private networks, whose peers share a key. Every raw
+// connection is encrypted with the key, below secio, so peers that do not
+// hold it cannot even begin a handshake.
+package pnet
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+)
+
+// KeyLength is the length of pre-shared keys, in bytes.
+const KeyLength = 32
+
+// PSKHeader starts the files pre-shared keys are kept in, like swarm.key.
+const PSKHeader = "/key/swarm/psk/1.0.0/"
+
+// ErrWrongKey signals the remote peer does not hold our key.
+var ErrWrongKey = errors.New("peer is not in our private network")
+
+// magic is sent, encrypted, at the start of each direction, so a wrong key
+// is caught before anything else is read.
+var magic = []byte("/ipfs/pnet/1.0.0")
+
+// Protector protects the raw connections of a private network.
+type Protector interface {
+	// Protect wraps c so all that goes through it is encrypted.
+	Protect(c manet.Conn) (manet.Conn, error)
+
+	// Fingerprint identifies the network, without revealing its key.
+	Fingerprint() []byte
+}
+
+// DecodeV1PSK reads a pre-shared key from r, in the format of swarm.key:
+//
+//  /key/swarm/psk/1.0.0/
+//  /base16/
+//  <64 hex digits>
+//
+// The key may be in /base64/ instead.
+func DecodeV1PSK(r io.Reader) (*[KeyLength]byte, error) {
+	s := bufio.NewScanner(r)
+	var lines []string
+	for s.Scan() {
+		if l := strings.TrimSpace(s.Text()); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) != 3 || lines[0] != PSKHeader {
+		return nil, fmt.Errorf("pre-shared key must start with %s, then an encoding and the key", PSKHeader)
+	}
+
+	var b []byte
+	var err error
+	switch lines[1] {
+	case "/base16/":
+		b, err = hex.DecodeString(lines[2])
+	case "/base64/":
+		b, err = base64.StdEncoding.DecodeString(lines[2])
+	default:
+		return nil, fmt.Errorf("unknown pre-shared key encoding: %s", lines[1])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bad pre-shared key: %s", err)
+	}
+	if len(b) != KeyLength {
+		return nil, fmt.Errorf("pre-shared key must be %d bytes, not %d", KeyLength, len(b))
+	}
+
+	var psk [KeyLength]byte
+	copy(psk[:], b)
+	return &psk, nil
+}
+
+// EncodeV1PSK writes psk to w, in the format DecodeV1PSK reads.
+func EncodeV1PSK(w io.Writer, psk *[KeyLength]byte) error {
+	_, err := fmt.Fprintf(w, "%s\n/base16/\n%s\n", PSKHeader, hex.EncodeToString(psk[:]))
+	return err
+}
+
+// NewProtector returns a Protector for the network of psk.
+func NewProtector(psk *[KeyLength]byte) Protector {
+	return &protector{psk: psk}
+}
+
+type protector struct {
+	psk *[KeyLength]byte
+}
+
+func (p *protector) Protect(c manet.Conn) (manet.Conn, error) {
+	block, err := aes.NewCipher(p.psk[:])
+	if err != nil {
+		return nil, err
+	}
+	return &pskConn{Conn: c, block: block}, nil
+}
+
+func (p *protector) Fingerprint() []byte {
+	h := sha256.Sum256(p.psk[:])
+	return h[:16]
+}
+
+// pskConn encrypts each direction with AES-CTR, from a random iv sent
+// first, followed by the encrypted magic. Nothing is sent or read before
+// the first Write or Read, so Protect does not block.
+type pskConn struct {
+	manet.Conn
+	block cipher.Block
+
+	rlk  sync.Mutex
+	rerr error
+	r    cipher.Stream
+
+	wlk sync.Mutex
+	w   cipher.Stream
+}
+
+func (c *pskConn) Read(out []byte) (int, error) {
+	c.rlk.Lock()
+	defer c.rlk.Unlock()
+
+	if c.r == nil && c.rerr == nil {
+		c.rerr = c.readHeader()
+	}
+	if c.rerr != nil {
+		return 0, c.rerr
+	}
+
+	n, err := c.Conn.Read(out)
+	c.r.XORKeyStream(out[:n], out[:n])
+	return n, err
+}
+
+func (c *pskConn) readHeader() error {
+	header := make([]byte, aes.BlockSize+len(magic))
+	if _, err := io.ReadFull(c.Conn, header); err != nil {
+		return err
+	}
+	r := cipher.NewCTR(c.block, header[:aes.BlockSize])
+	m := header[aes.BlockSize:]
+	r.XORKeyStream(m, m)
+	if !bytes.Equal(m, magic) {
+		return ErrWrongKey
+	}
+	c.r = r
+	return nil
+}
+
+func (c *pskConn) Write(in []byte) (int, error) {
+	c.wlk.Lock()
+	defer c.wlk.Unlock()
+
+	var header []byte
+	if c.w == nil {
+		header = make([]byte, aes.BlockSize+len(magic))
+		if _, err := io.ReadFull(rand.Reader, header[:aes.BlockSize]); err != nil {
+			return 0, err
+		}
+		c.w = cipher.NewCTR(c.block, header[:aes.BlockSize])
+		c.w.XORKeyStream(header[aes.BlockSize:], magic)
+	}
+
+	out := make([]byte, len(header)+len(in))
+	copy(out, header)
+	c.w.XORKeyStream(out[len(header):], in)
+	// a short write leaves the keystream ahead of the connection, but the
+	// connection is broken by then anyway.
+	n, err := c.Conn.Write(out)
+	if n -= len(header); n < 0 {
+		n = 0
+	}
+	return n, err
+}
@@ -0,0 +1,168 @@
+package pnet
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+)
+
+func testKey(b byte) *[KeyLength]byte {
+	var psk [KeyLength]byte
+	for i := range psk {
+		psk[i] = b
+	}
+	return &psk
+}
+
+func TestDecodeV1PSK(t *testing.T) {
+	psk := testKey(7)
+	var buf bytes.Buffer
+	if err := EncodeV1PSK(&buf, psk); err != nil {
+		t.Fatal(err)
+	}
+	psk2, err := DecodeV1PSK(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *psk2 != *psk {
+		t.Fatal("key did not round trip")
+	}
+
+	b64 := PSKHeader + "\n/base64/\nBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwc=\n"
+	if psk2, err = DecodeV1PSK(strings.NewReader(b64)); err != nil {
+		t.Fatal(err)
+	}
+	if *psk2 != *psk {
+		t.Fatal("base64 key decoded wrong")
+	}
+
+	bad := []string{
+		"",
+		"/base16/\n" + strings.Repeat("07", KeyLength),
+		PSKHeader + "\n/base16/\n" + strings.Repeat("07", KeyLength-1),
+		PSKHeader + "\n/base16/\n" + strings.Repeat("zz", KeyLength),
+		PSKHeader + "\n/base32/\n" + strings.Repeat("07", KeyLength),
+	}
+	for _, s := range bad {
+		if _, err := DecodeV1PSK(strings.NewReader(s)); err == nil {
+			t.Fatalf("decoded bad key %q", s)
+		}
+	}
+}
+
+// protectedPair returns the two ends of a tcp connection, protected by a
+// and b.
+func protectedPair(t *testing.T, a, b Protector) (manet.Conn, manet.Conn) {
+	l, err := manet.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan manet.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+	c1, err := manet.Dial(l.Multiaddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2 := <-accepted
+	if c2 == nil {
+		t.FailNow()
+	}
+
+	if c1, err = a.Protect(c1); err != nil {
+		t.Fatal(err)
+	}
+	if c2, err = b.Protect(c2); err != nil {
+		t.Fatal(err)
+	}
+	return c1, c2
+}
+
+func TestProtectSameKey(t *testing.T) {
+	p := NewProtector(testKey(1))
+	c1, c2 := protectedPair(t, p, p)
+	defer c1.Close()
+	defer c2.Close()
+
+	msgs := [][]byte{[]byte("hello"), []byte("private"), []byte("world")}
+	go func() {
+		for _, m := range msgs {
+			c1.Write(m)
+		}
+	}()
+	for _, m := range msgs {
+		buf := make([]byte, len(m))
+		if _, err := io.ReadFull(c2, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, m) {
+			t.Fatalf("read %q, expected %q", buf, m)
+		}
+	}
+}
+
+func TestProtectWrongKey(t *testing.T) {
+	c1, c2 := protectedPair(t, NewProtector(testKey(1)), NewProtector(testKey(2)))
+	defer c1.Close()
+	defer c2.Close()
+
+	go c1.Write([]byte("hello"))
+	buf := make([]byte, 5)
+	if _, err := c2.Read(buf); err != ErrWrongKey {
+		t.Fatalf("expected ErrWrongKey, got %v", err)
+	}
+
+	if bytes.Equal(NewProtector(testKey(1)).Fingerprint(), NewProtector(testKey(2)).Fingerprint()) {
+		t.Fatal("different keys have the same fingerprint")
+	}
+}
+
+func TestProtectedWireIsEncrypted(t *testing.T) {
+	l, err := manet.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	raw := make(chan []byte, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+			raw <- nil
+			return
+		}
+		defer c.Close()
+		buf := make([]byte, 64)
+		n, _ := io.ReadAtLeast(c, buf, 40)
+		raw <- buf[:n]
+	}()
+
+	c, err := manet.Dial(l.Multiaddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := NewProtector(testKey(3)).Protect(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	msg := []byte("a secret handshake message")
+	if _, err := pc.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if b := <-raw; bytes.Contains(b, msg) || bytes.Contains(b, magic) {
+		t.Fatal("protected connection sent plaintext")
+	}
+}
@@ -18,8 +18,10 @@ var log = eventlog.Logger("p2p/net/swarm/addr")
 var SupportedTransportStrings = []string{
 	"/ip4/tcp",
 	"/ip6/tcp",
-	// "/ip4/udp/utp", disabled because the lib is broken
-	// "/ip6/udp/utp", disabled because the lib is broken
+	"/ip4/udp/utp",
+	"/ip6/udp/utp",
+	"/ip4/tcp/ws",
+	"/ip6/tcp/ws",
 	// "/ip4/udp/udt", disabled because the lib doesnt work on arm
 	// "/ip6/udp/udt", disabled because the lib doesnt work on arm
 }
@@ -75,8 +77,8 @@ func AddrOverNonLocalIP(a ma.Multiaddr) bool {
 }
 
 // AddrUsable returns whether our network can use this addr.
-// We only use the transports in SupportedTransportStrings,
-// and we do not link local addresses. Loopback is ok
+// We only use the transports in SupportedTransportStrings, and relayed
+// addresses, and we do not link local addresses. Loopback is ok
 // as we need to be able to connect to multiple ipfs nodes
 // in the same machine.
 func AddrUsable(a ma.Multiaddr, partial bool) bool {
@@ -84,6 +86,14 @@ func AddrUsable(a ma.Multiaddr, partial bool) bool {
 		return false
 	}
 
+	if IsRelayAddr(a) {
+		// the bare relay address is only usable to listen on.
+		if a.Equal(RelayListenAddr) {
+			return partial
+		}
+		return relayAddrUsable(a)
+	}
+
 	if !AddrOverNonLocalIP(a) {
 		return false
 	}
@@ -94,8 +104,9 @@ func AddrUsable(a ma.Multiaddr, partial bool) bool {
 			return false
 		}
 
-		// when partial, it's ok if test < supported.
-		if !partial && len(supported) != len(test) {
+		// when partial, it's ok if test is only the ip of supported.
+		// (e.g. /ip4/udp is not usable, even if /ip4/udp/utp is.)
+		if len(supported) != len(test) && (!partial || len(test) != 1) {
 			return false
 		}
 
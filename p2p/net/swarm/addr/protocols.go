@@ -0,0 +1,50 @@
+package addrutil
+
+import (
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	mh "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+)
+
+// Multiaddr protocols of our transports which go-multiaddr does not know.
+// They are added to ma.Protocols when this package is initialized.
+const (
+	P_IPFS_RELAY = 290
+	P_IPFS       = 421
+	P_WS         = 477
+)
+
+// WSAddr is the multiaddr suffix of websocket addresses.
+var WSAddr = addProtocol(P_WS, "ws")
+
+// /ipfs/<peer id> names a peer, as in relayed addresses.
+func init() {
+	p := ma.Protocol{Code: P_IPFS, Size: ma.LengthPrefixedVarSize, Name: "ipfs"}
+	if err := ma.AddProtocol(p, peerIDTranscoder{}); err != nil {
+		panic(err)
+	}
+}
+
+// addProtocol registers the protocol code, without address, with
+// go-multiaddr, and returns its multiaddr.
+func addProtocol(code int, name string) ma.Multiaddr {
+	if err := ma.AddProtocol(ma.Protocol{Code: code, Name: name}, nil); err != nil {
+		panic(err)
+	}
+	return ma.StringCast("/" + name)
+}
+
+// peerIDTranscoder converts the peer ids of /ipfs addresses: b58 strings,
+// multihash bytes.
+type peerIDTranscoder struct{}
+
+func (peerIDTranscoder) StringToBytes(s string) ([]byte, error) {
+	return mh.FromB58String(s)
+}
+
+func (peerIDTranscoder) BytesToString(b []byte) (string, error) {
+	m, err := mh.Cast(b)
+	if err != nil {
+		return "", err
+	}
+	return m.B58String(), nil
+}
@@ -0,0 +1,38 @@
+package addrutil
+
+import (
+	"testing"
+
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+)
+
+func TestIPFSAddrs(t *testing.T) {
+	for _, s := range []string{
+		"/ipfs/" + relayID,
+		"/ip4/1.2.3.4/tcp/4001/ipfs/" + relayID,
+		"/ipfs/" + relayID + "/ipfs-relay/ipfs/" + targetID,
+	} {
+		m := newMultiaddr(t, s)
+		if m.String() != s {
+			t.Errorf("%s != %s", m, s)
+		}
+		m2, err := ma.NewMultiaddrBytes(m.Bytes())
+		if err != nil || !m2.Equal(m) {
+			t.Errorf("%s does not round trip through bytes: %v", s, err)
+		}
+		if len(ma.Split(m)) != len(m.Protocols()) {
+			t.Errorf("%s splits into %d parts", s, len(ma.Split(m)))
+		}
+	}
+
+	if _, err := ma.NewMultiaddr("/ipfs/notbase58!"); err == nil {
+		t.Error("should fail to parse a bad peer id")
+	}
+
+	b := newMultiaddr(t, "/ipfs/"+relayID).Bytes()
+	for _, bad := range [][]byte{b[:len(b)-1], b[:2], append(b[:2:2], 0xff)} {
+		if _, err := ma.NewMultiaddrBytes(bad); err == nil {
+			t.Errorf("should fail to parse truncated bytes %x", bad)
+		}
+	}
+}
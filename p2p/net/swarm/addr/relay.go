@@ -0,0 +1,106 @@
+package addrutil
+
+import (
+	"fmt"
+	"strings"
+
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+)
+
+// Relayed addresses reach a peer through a relay. They are the address of
+// the relay (ending with its /ipfs/<id>), then /ipfs-relay, then the
+// target peer:
+//
+//   /ipfs/<relay>/ipfs-relay/ipfs/<target>
+//   /ip4/1.2.3.4/tcp/4001/ipfs/<relay>/ipfs-relay/ipfs/<target>
+//
+// The target may be left out where it is implied, as in the addresses a
+// peer advertises for itself.
+
+// RelayListenAddr is the address the swarm accepts relayed connections on.
+var RelayListenAddr = addProtocol(P_IPFS_RELAY, "ipfs-relay")
+
+// IsRelayAddr returns whether a is a relayed address.
+func IsRelayAddr(a ma.Multiaddr) bool {
+	for _, p := range a.Protocols() {
+		if p.Code == P_IPFS_RELAY {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitRelayAddr splits a relayed address into the address of the relay,
+// and the id of the relay and target peers. target is empty if a does not
+// name it.
+func SplitRelayAddr(a ma.Multiaddr) (raddr ma.Multiaddr, relay, target peer.ID, err error) {
+	parts := ma.Split(a)
+	split := -1
+	for i, p := range parts {
+		if p.Protocols()[0].Code == P_IPFS_RELAY {
+			split = i
+			break
+		}
+	}
+	if split < 1 {
+		return nil, "", "", fmt.Errorf("not a relayed address: %s", a)
+	}
+
+	relay, err = peerIDFromPart(parts[split-1])
+	if err != nil {
+		return nil, "", "", fmt.Errorf("relay of %s: %s", a, err)
+	}
+	raddr = ma.Join(parts[:split]...)
+
+	switch rest := parts[split+1:]; len(rest) {
+	case 0:
+	case 1:
+		target, err = peerIDFromPart(rest[0])
+		if err != nil {
+			return nil, "", "", fmt.Errorf("target of %s: %s", a, err)
+		}
+	default:
+		return nil, "", "", fmt.Errorf("relayed address with trailing parts: %s", a)
+	}
+	return raddr, relay, target, nil
+}
+
+// RelayAddrTo returns the relayed address of target through relay addr a,
+// adding the target if a does not name it already.
+func RelayAddrTo(a ma.Multiaddr, target peer.ID) (ma.Multiaddr, error) {
+	_, _, t, err := SplitRelayAddr(a)
+	if err != nil {
+		return nil, err
+	}
+	if t == target {
+		return a, nil
+	}
+	if t != "" {
+		return nil, fmt.Errorf("relayed address %s is not for %s", a, target)
+	}
+	return ma.NewMultiaddr(a.String() + "/ipfs/" + target.Pretty())
+}
+
+// relayAddrUsable returns whether we can dial the relayed address a:
+// its relay part must be /ipfs/<relay>, optionally after a usable address.
+func relayAddrUsable(a ma.Multiaddr) bool {
+	raddr, _, _, err := SplitRelayAddr(a)
+	if err != nil {
+		return false
+	}
+	parts := ma.Split(raddr)
+	if len(parts) == 1 {
+		return true
+	}
+	return AddrUsable(ma.Join(parts[:len(parts)-1]...), false)
+}
+
+func peerIDFromPart(m ma.Multiaddr) (peer.ID, error) {
+	s := m.String()
+	if !strings.HasPrefix(s, "/ipfs/") {
+		return "", fmt.Errorf("expected /ipfs/<peer id>, got %s", s)
+	}
+	return peer.IDB58Decode(strings.TrimPrefix(s, "/ipfs/"))
+}
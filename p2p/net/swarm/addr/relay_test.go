@@ -0,0 +1,96 @@
+package addrutil
+
+import (
+	"testing"
+
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+)
+
+const (
+	relayID  = "QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC"
+	targetID = "QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z"
+)
+
+func TestSplitRelayAddr(t *testing.T) {
+	relay, _ := peer.IDB58Decode(relayID)
+	target, _ := peer.IDB58Decode(targetID)
+
+	cases := []struct {
+		addr   string
+		raddr  string
+		target peer.ID
+	}{
+		{"/ipfs/" + relayID + "/ipfs-relay", "/ipfs/" + relayID, ""},
+		{"/ipfs/" + relayID + "/ipfs-relay/ipfs/" + targetID, "/ipfs/" + relayID, target},
+		{"/ip4/1.2.3.4/tcp/4001/ipfs/" + relayID + "/ipfs-relay/ipfs/" + targetID,
+			"/ip4/1.2.3.4/tcp/4001/ipfs/" + relayID, target},
+	}
+	for _, c := range cases {
+		a := newMultiaddr(t, c.addr)
+		if !IsRelayAddr(a) {
+			t.Errorf("%s should be a relayed address", a)
+		}
+		raddr, r, tgt, err := SplitRelayAddr(a)
+		if err != nil {
+			t.Errorf("%s: %s", a, err)
+			continue
+		}
+		if raddr.String() != c.raddr || r != relay || tgt != c.target {
+			t.Errorf("%s split into %s, %s, %s", a, raddr, r, tgt)
+		}
+	}
+
+	bad := []string{
+		"/ip4/1.2.3.4/tcp/4001",
+		"/ipfs-relay",
+		"/ip4/1.2.3.4/tcp/4001/ipfs-relay",
+		"/ipfs/" + relayID + "/ipfs-relay/ip4/1.2.3.4",
+		"/ipfs/" + relayID + "/ipfs-relay/ipfs/" + targetID + "/ipfs/" + relayID,
+	}
+	for _, s := range bad {
+		if _, _, _, err := SplitRelayAddr(newMultiaddr(t, s)); err == nil {
+			t.Errorf("%s should not split", s)
+		}
+	}
+}
+
+func TestRelayAddrTo(t *testing.T) {
+	target, _ := peer.IDB58Decode(targetID)
+	relay, _ := peer.IDB58Decode(relayID)
+	full := "/ipfs/" + relayID + "/ipfs-relay/ipfs/" + targetID
+
+	a, err := RelayAddrTo(newMultiaddr(t, "/ipfs/"+relayID+"/ipfs-relay"), target)
+	if err != nil || a.String() != full {
+		t.Fatalf("got %s, %v", a, err)
+	}
+	if a, err = RelayAddrTo(newMultiaddr(t, full), target); err != nil || a.String() != full {
+		t.Fatalf("got %s, %v", a, err)
+	}
+	if _, err := RelayAddrTo(newMultiaddr(t, full), relay); err == nil {
+		t.Fatal("should not retarget a relayed address")
+	}
+}
+
+func TestRelayAddrUsable(t *testing.T) {
+	good := []string{
+		"/ipfs/" + relayID + "/ipfs-relay/ipfs/" + targetID,
+		"/ip4/1.2.3.4/tcp/4001/ipfs/" + relayID + "/ipfs-relay/ipfs/" + targetID,
+	}
+	bad := []string{
+		"/ip4/1.2.3.4/udp/4001/ipfs/" + relayID + "/ipfs-relay/ipfs/" + targetID,
+		"/ipfs-relay",
+	}
+	for _, s := range good {
+		if !AddrUsable(newMultiaddr(t, s), false) {
+			t.Errorf("%s should be usable", s)
+		}
+	}
+	for _, s := range bad {
+		if AddrUsable(newMultiaddr(t, s), false) {
+			t.Errorf("%s should not be usable", s)
+		}
+	}
+	if !AddrUsable(RelayListenAddr, true) {
+		t.Error("should listen on the relay listen address")
+	}
+}
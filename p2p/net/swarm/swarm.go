@@ -8,7 +8,11 @@ import (
 	"time"
 
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	filter "github.com/jbenet/go-ipfs/p2p/net/filter"
+	muxer "github.com/jbenet/go-ipfs/p2p/net/muxer"
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
 	addrutil "github.com/jbenet/go-ipfs/p2p/net/swarm/addr"
+	transport "github.com/jbenet/go-ipfs/p2p/net/transport"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
 
@@ -16,12 +20,12 @@ import (
 	ctxgroup "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-ctxgroup"
 	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 	ps "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-peerstream"
-	psy "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-peerstream/transport/yamux"
 )
 
 var log = eventlog.Logger("swarm2")
 
-var PSTransport = psy.DefaultTransport
+// PSTransport negotiates the stream muxer of each connection.
+var PSTransport = muxer.DefaultTransport
 
 // Swarm is a connection muxer, allowing connections to other peers to
 // be opened and closed, while still using the same Chan for all
@@ -39,6 +43,19 @@ type Swarm struct {
 	backf dialbackoff
 	dialT time.Duration // mainly for tests
 
+	// transports the swarm dials and listens with, by address.
+	transports []transport.Transport
+	translk    sync.RWMutex
+
+	// filters decide which peers and addresses we connect to.
+	filters *filter.Filters
+
+	// protec protects all raw connections, in private networks.
+	protec pnet.Protector
+
+	// dialTLS secures the connections we dial with TLS, not secio.
+	dialTLS bool
+
 	notifmu sync.RWMutex
 	notifs  map[inet.Notifiee]ps.Notifiee
 
@@ -48,6 +65,13 @@ type Swarm struct {
 // NewSwarm constructs a Swarm, with a Chan.
 func NewSwarm(ctx context.Context, listenAddrs []ma.Multiaddr,
 	local peer.ID, peers peer.Peerstore) (*Swarm, error) {
+	return NewSwarmWithProtector(ctx, listenAddrs, local, peers, nil)
+}
+
+// NewSwarmWithProtector constructs a Swarm in the private network of
+// protec. It only connects to peers holding the same key.
+func NewSwarmWithProtector(ctx context.Context, listenAddrs []ma.Multiaddr,
+	local peer.ID, peers peer.Peerstore, protec pnet.Protector) (*Swarm, error) {
 
 	if len(listenAddrs) > 0 {
 		filtered := addrutil.FilterUsableAddrs(listenAddrs)
@@ -64,6 +88,11 @@ func NewSwarm(ctx context.Context, listenAddrs []ma.Multiaddr,
 		cg:     ctxgroup.WithContext(ctx),
 		dialT:  DialTimeout,
 		notifs: make(map[inet.Notifiee]ps.Notifiee),
+
+		filters: filter.NewFilters(),
+		protec:  protec,
+
+		transports: transport.Default(),
 	}
 
 	// configure Swarm
@@ -73,6 +102,42 @@ func NewSwarm(ctx context.Context, listenAddrs []ma.Multiaddr,
 	return s, s.listen(listenAddrs)
 }
 
+// AddTransport makes the swarm dial and listen with t, for the addresses
+// it matches.
+func (s *Swarm) AddTransport(t transport.Transport) {
+	s.translk.Lock()
+	ts := make([]transport.Transport, len(s.transports), len(s.transports)+1)
+	copy(ts, s.transports)
+	s.transports = append(ts, t)
+	s.translk.Unlock()
+}
+
+// Transports returns the transports the swarm dials and listens with.
+func (s *Swarm) Transports() []transport.Transport {
+	s.translk.RLock()
+	defer s.translk.RUnlock()
+	return s.transports
+}
+
+// SetDialTLS makes the swarm negotiate TLS 1.3 on the connections it dials,
+// falling back to secio with peers which do not support it. Connections are
+// accepted either way. It must be called before dialing.
+func (s *Swarm) SetDialTLS(on bool) {
+	s.dialTLS = on
+}
+
+// Filters returns the filters of the peers and addresses the swarm dials
+// and accepts connections from. Changes apply to new connections.
+func (s *Swarm) Filters() *filter.Filters {
+	return s.filters
+}
+
+// Listen makes the swarm listen on addrs, in addition to the addresses
+// it was constructed with.
+func (s *Swarm) Listen(addrs ...ma.Multiaddr) error {
+	return s.listen(addrs)
+}
+
 func (s *Swarm) teardown() error {
 	return s.swarm.Close()
 }
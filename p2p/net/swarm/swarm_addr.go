@@ -12,9 +12,16 @@ func (s *Swarm) ListenAddresses() []ma.Multiaddr {
 	listeners := s.swarm.Listeners()
 	addrs := make([]ma.Multiaddr, 0, len(listeners))
 	for _, l := range listeners {
-		if l2, ok := l.NetListener().(conn.Listener); ok {
-			addrs = append(addrs, l2.Multiaddr())
+		l2, ok := l.NetListener().(conn.Listener)
+		if !ok {
+			continue
 		}
+		// relayed connections are accepted on an address no one can
+		// dial; peers reach us through the relays themselves.
+		if l2.Multiaddr().Equal(addrutil.RelayListenAddr) {
+			continue
+		}
+		addrs = append(addrs, l2.Multiaddr())
 	}
 	return addrs
 }
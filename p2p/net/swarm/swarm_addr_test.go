@@ -24,7 +24,6 @@ func TestFilterAddrs(t *testing.T) {
 	bad := []ma.Multiaddr{
 		m("/ip4/1.2.3.4/udp/1234"),           // unreliable
 		m("/ip4/1.2.3.4/udp/1234/sctp/1234"), // not in manet
-		m("/ip4/1.2.3.4/udp/1234/utp"),       // utp is broken
 		m("/ip4/1.2.3.4/udp/1234/udt"),       // udt is broken on arm
 		m("/ip6/fe80::1/tcp/0"),              // link local
 		m("/ip6/fe80::100/tcp/1234"),         // link local
@@ -33,6 +32,8 @@ func TestFilterAddrs(t *testing.T) {
 	good := []ma.Multiaddr{
 		m("/ip4/127.0.0.1/tcp/0"),
 		m("/ip6/::1/tcp/0"),
+		m("/ip4/127.0.0.1/udp/0/utp"),
+		m("/ip4/127.0.0.1/tcp/0/ws"),
 	}
 
 	goodAndBad := append(good, bad...)
@@ -110,13 +111,13 @@ func TestDialBadAddrs(t *testing.T) {
 
 	test := func(a ma.Multiaddr) {
 		p := testutil.RandPeerIDFatal(t)
-		s.peers.AddAddress(p, a)
+		s.peers.AddAddress(p, a, peer.PermanentAddrTTL)
 		if _, err := s.Dial(ctx, p); err == nil {
 			t.Error("swarm should not dial: %s", m)
 		}
 	}
 
-	test(m("/ip6/fe80::1"))                // link local
-	test(m("/ip6/fe80::100"))              // link local
-	test(m("/ip4/127.0.0.1/udp/1234/utp")) // utp
+	test(m("/ip6/fe80::1"))            // link local
+	test(m("/ip6/fe80::100"))          // link local
+	test(m("/ip4/127.0.0.1/udp/1234")) // unreliable
 }
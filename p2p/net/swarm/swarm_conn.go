@@ -1,7 +1,10 @@
 package swarm
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"time"
 
 	ic "github.com/jbenet/go-ipfs/p2p/crypto"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
@@ -87,10 +90,49 @@ func (c *Conn) NewStream() (inet.Stream, error) {
 	return inet.Stream(s), err
 }
 
+// Stat returns who opened the connection, when, and its open streams.
+func (c *Conn) Stat() inet.Stat {
+	st := inet.Stat{Streams: len(c.StreamConn().Streams())}
+	if sc, ok := c.RawConn().(*statConn); ok {
+		st.Direction = sc.dir
+		st.Opened = sc.opened
+	}
+	return st
+}
+
 func (c *Conn) Close() error {
 	return c.StreamConn().Close()
 }
 
+// statConn is a conn.Conn, and which side opened it, when. The swarm adds
+// statConns to the StreamSwarm, so Conn.Stat can tell.
+type statConn struct {
+	conn.Conn
+
+	dir    inet.Direction
+	opened time.Time
+}
+
+func newStatConn(c conn.Conn, dir inet.Direction) *statConn {
+	return &statConn{Conn: c, dir: dir, opened: time.Now()}
+}
+
+// statListener marks the connections it accepts as inbound.
+type statListener struct {
+	conn.Listener
+}
+
+func (l *statListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if cc, ok := c.(conn.Conn); ok {
+		return newStatConn(cc, inet.DirInbound), nil
+	}
+	return c, nil
+}
+
 func wrapConn(psc *ps.Conn) (*Conn, error) {
 	// grab the underlying connection.
 	if _, ok := psc.NetConn().(conn.Conn); !ok {
@@ -112,6 +154,10 @@ func wrapConns(conns1 []*ps.Conn) []*Conn {
 	return conns2
 }
 
+// errConnFiltered is returned by newConnSetup for connections the filters
+// refuse.
+var errConnFiltered = errors.New("connection filtered")
+
 // newConnSetup does the swarm's "setup" for a connection. returns the underlying
 // conn.Conn this method is used by both swarm.Dial and ps.Swarm connHandler
 func (s *Swarm) newConnSetup(ctx context.Context, psConn *ps.Conn) (*Conn, error) {
@@ -122,6 +168,13 @@ func (s *Swarm) newConnSetup(ctx context.Context, psConn *ps.Conn) (*Conn, error
 		return nil, err
 	}
 
+	// dialed connections were filtered already. accepted ones were only
+	// checked against the denied ranges, before we knew their peer.
+	if s.filters.ConnBlocked(sc.RemotePeer(), sc.RemoteMultiaddr()) {
+		log.Debugf("connection from %s at %s is filtered", sc.RemotePeer(), sc.RemoteMultiaddr())
+		return nil, errConnFiltered
+	}
+
 	// if we have a public key, make sure we add it to our peerstore!
 	// This is an important detail. Otherwise we must fetch the public
 	// key from the DHT or some other system.
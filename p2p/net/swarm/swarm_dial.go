@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	inet "github.com/jbenet/go-ipfs/p2p/net"
 	conn "github.com/jbenet/go-ipfs/p2p/net/conn"
 	addrutil "github.com/jbenet/go-ipfs/p2p/net/swarm/addr"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
@@ -31,6 +32,10 @@ import (
 // dialAttempts governs how many times a goroutine will try to dial a given peer.
 const dialAttempts = 3
 
+// RelayDialDelay is how long we try to reach a peer directly before
+// dialing it through relays as well.
+var RelayDialDelay = time.Second * 2
+
 // DialTimeout is the amount of time each dial attempt has. We can think about making
 // this larger down the road, or putting more granular timeouts (i.e. within each
 // subcomponent of Dial)
@@ -182,6 +187,9 @@ func (s *Swarm) Dial(ctx context.Context, p peer.ID) (*Conn, error) {
 	if p == s.local {
 		return nil, errors.New("Attempted connection to self!")
 	}
+	if s.filters.PeerDenied(p) {
+		return nil, fmt.Errorf("%s is filtered", p)
+	}
 
 	// this loop is here because dials take time, and we should not be dialing
 	// the same peer concurrently (silly waste). Additonally, it's structured
@@ -267,8 +275,10 @@ func (s *Swarm) dial(ctx context.Context, p peer.ID) (*Conn, error) {
 	ila, _ := s.InterfaceListenAddresses()
 	remoteAddrs = addrutil.Subtract(remoteAddrs, ila)
 	remoteAddrs = addrutil.Subtract(remoteAddrs, s.peers.Addresses(s.local))
-	log.Debugf("%s swarm dialing %s -- remote:%s local:%s", s.local, p, remoteAddrs, s.ListenAddresses())
-	if len(remoteAddrs) == 0 {
+	remoteAddrs = s.filterAddrs(p, remoteAddrs)
+	directAddrs, relayAddrs := s.splitRelayAddrs(p, remoteAddrs)
+	log.Debugf("%s swarm dialing %s -- remote:%s relayed:%s local:%s", s.local, p, directAddrs, relayAddrs, s.ListenAddresses())
+	if len(directAddrs) == 0 && len(relayAddrs) == 0 {
 		return nil, errors.New("peer has no addresses")
 	}
 
@@ -282,10 +292,13 @@ func (s *Swarm) dial(ctx context.Context, p peer.ID) (*Conn, error) {
 		LocalPeer:  s.local,
 		LocalAddrs: localAddrs,
 		PrivateKey: sk,
+		Transports: s.Transports(),
+		Protector:  s.protec,
+		TLS:        s.dialTLS,
 	}
 
 	// try to get a connection to any addr
-	connC, err := s.dialAddrs(ctx, d, p, remoteAddrs)
+	connC, err := s.dialDirectThenRelayed(ctx, d, p, directAddrs, relayAddrs)
 	if err != nil {
 		return nil, err
 	}
@@ -303,6 +316,67 @@ func (s *Swarm) dial(ctx context.Context, p peer.ID) (*Conn, error) {
 	return swarmC, nil
 }
 
+// dialDirectThenRelayed dials the direct addresses of p, and falls back to
+// the relayed ones once they fail, or take longer than RelayDialDelay.
+// (direct dials to NAT'd peers often hang until they time out.)
+func (s *Swarm) dialDirectThenRelayed(ctx context.Context, d *conn.Dialer, p peer.ID, direct, relayed []ma.Multiaddr) (conn.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		c   conn.Conn
+		err error
+	}
+	results := make(chan dialResult, 2)
+	pending := 0
+	start := func(addrs []ma.Multiaddr) {
+		pending++
+		go func() {
+			c, err := s.dialAddrs(ctx, d, p, addrs)
+			results <- dialResult{c, err}
+		}()
+	}
+
+	var relayDelay <-chan time.Time
+	switch {
+	case len(direct) == 0:
+		start(relayed)
+	case len(relayed) == 0:
+		start(direct)
+	default:
+		start(direct)
+		relayDelay = time.After(RelayDialDelay)
+	}
+
+	var err error
+	for pending > 0 {
+		select {
+		case <-relayDelay:
+			relayDelay = nil
+			start(relayed)
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// close the connection the other dial may still make.
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.c != nil {
+							r.c.Close()
+						}
+					}
+				}(pending)
+				return r.c, nil
+			}
+			err = r.err
+			if relayDelay != nil { // direct dials failed, no need to wait.
+				relayDelay = nil
+				start(relayed)
+			}
+		}
+	}
+	return nil, err
+}
+
 func (s *Swarm) dialAddrs(ctx context.Context, d *conn.Dialer, p peer.ID, remoteAddrs []ma.Multiaddr) (conn.Conn, error) {
 
 	// try to connect to one of the peer's known addresses.
@@ -385,11 +459,48 @@ func (s *Swarm) dialAddr(ctx context.Context, d *conn.Dialer, p peer.ID, addr ma
 	return connC, nil
 }
 
+// filterAddrs drops the addresses of p our filters block.
+func (s *Swarm) filterAddrs(p peer.ID, addrs []ma.Multiaddr) []ma.Multiaddr {
+	var out []ma.Multiaddr
+	for _, a := range addrs {
+		if s.filters.ConnBlocked(p, a) {
+			log.Debugf("%s not dialing filtered address %s of %s", s.local, a, p)
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// splitRelayAddrs separates the relayed addresses of p from its direct
+// ones. Relayed addresses get p as their target, and those relaying
+// through p itself, or through us, are dropped.
+func (s *Swarm) splitRelayAddrs(p peer.ID, addrs []ma.Multiaddr) (direct, relayed []ma.Multiaddr) {
+	for _, a := range addrs {
+		if !addrutil.IsRelayAddr(a) {
+			direct = append(direct, a)
+			continue
+		}
+
+		_, relay, _, err := addrutil.SplitRelayAddr(a)
+		if err != nil || relay == p || relay == s.local {
+			continue
+		}
+		ra, err := addrutil.RelayAddrTo(a, p)
+		if err != nil {
+			log.Debug(err)
+			continue
+		}
+		relayed = append(relayed, ra)
+	}
+	return direct, relayed
+}
+
 // dialConnSetup is the setup logic for a connection from the dial side. it
 // needs to add the Conn to the StreamSwarm, then run newConnSetup
 func dialConnSetup(ctx context.Context, s *Swarm, connC conn.Conn) (*Conn, error) {
 
-	psC, err := s.swarm.AddConn(connC)
+	psC, err := s.swarm.AddConn(newStatConn(connC, inet.DirOutbound))
 	if err != nil {
 		// connC is closed by caller if we fail.
 		return nil, fmt.Errorf("failed to add conn to ps.Swarm: %s", err)
@@ -0,0 +1,89 @@
+package swarm
+
+import (
+	"testing"
+	"time"
+
+	filter "github.com/jbenet/go-ipfs/p2p/net/filter"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+)
+
+func TestFilteredDial(t *testing.T) {
+	ctx := context.Background()
+	swarms := makeSwarms(ctx, t, 2)
+	s1, s2 := swarms[0], swarms[1]
+	defer s1.Close()
+	defer s2.Close()
+
+	loopback, err := filter.ParseFilter("/ip4/127.0.0.0/ipcidr/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1.Filters().Add(loopback, filter.Deny)
+
+	s1.peers.AddAddresses(s2.local, s2.ListenAddresses(), peer.PermanentAddrTTL)
+	if _, err := s1.Dial(ctx, s2.local); err == nil {
+		t.Fatal("dialed a filtered address")
+	}
+
+	// in allowlist-only mode, allowing the peer is not enough to get past
+	// the denied range.
+	s1.Filters().SetAllowlistOnly(true)
+	s1.Filters().Add(filter.Filter{Peer: s2.local}, filter.Allow)
+	s1.backf.Clear(s2.local)
+	if _, err := s1.Dial(ctx, s2.local); err == nil {
+		t.Fatal("dialed a filtered address")
+	}
+
+	s1.Filters().Remove(loopback)
+	s1.backf.Clear(s2.local)
+	if _, err := s1.Dial(ctx, s2.local); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFilteredAccept(t *testing.T) {
+	ctx := context.Background()
+	swarms := makeSwarms(ctx, t, 2)
+	s1, s2 := swarms[0], swarms[1]
+	defer s1.Close()
+	defer s2.Close()
+
+	s1.Filters().Add(filter.Filter{Peer: s2.local}, filter.Deny)
+
+	// s2 may think it connected, but s1 must refuse the connection.
+	s2.peers.AddAddresses(s1.local, s1.ListenAddresses(), peer.PermanentAddrTTL)
+	s2.Dial(ctx, s1.local)
+	time.Sleep(100 * time.Millisecond)
+	if len(s1.ConnectionsToPeer(s2.local)) != 0 {
+		t.Fatal("accepted a connection from a denied peer")
+	}
+	if _, err := s1.Dial(ctx, s2.local); err == nil {
+		t.Fatal("dialed a denied peer")
+	}
+}
+
+func TestFilteredAcceptRange(t *testing.T) {
+	ctx := context.Background()
+	swarms := makeSwarms(ctx, t, 2)
+	s1, s2 := swarms[0], swarms[1]
+	defer s1.Close()
+	defer s2.Close()
+
+	loopback, err := filter.ParseFilter("/ip4/127.0.0.0/ipcidr/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1.Filters().Add(loopback, filter.Deny)
+
+	// s1 closes the connection before the handshake, so s2 fails to dial.
+	s2.peers.AddAddresses(s1.local, s1.ListenAddresses(), peer.PermanentAddrTTL)
+	if _, err := s2.Dial(ctx, s1.local); err == nil {
+		t.Fatal("connected from a denied range")
+	}
+	if len(s1.ConnectionsToPeer(s2.local)) != 0 {
+		t.Fatal("accepted a connection from a denied range")
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	conn "github.com/jbenet/go-ipfs/p2p/net/conn"
 	addrutil "github.com/jbenet/go-ipfs/p2p/net/swarm/addr"
+	transport "github.com/jbenet/go-ipfs/p2p/net/transport"
 	lgbl "github.com/jbenet/go-ipfs/util/eventlog/loggables"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
@@ -60,15 +61,20 @@ func (s *Swarm) setupListener(maddr ma.Multiaddr) error {
 		// may be fine for sk to be nil, just log a warning.
 		log.Warning("Listener not given PrivateKey, so WILL NOT SECURE conns.")
 	}
+	t, err := transport.Find(s.Transports(), maddr)
+	if err != nil {
+		return err
+	}
+
 	log.Infof("Swarm Listening at %s", maddr)
-	list, err := conn.Listen(s.cg.Context(), maddr, s.local, sk)
+	list, err := conn.ListenTransport(s.cg.Context(), t, maddr, s.local, sk, s.protec, s.filters.AddrDenied)
 	if err != nil {
 		return err
 	}
 
 	// AddListener to the peerstream Listener. this will begin accepting connections
 	// and streams!
-	sl, err := s.swarm.AddListener(list)
+	sl, err := s.swarm.AddListener(&statListener{list})
 	if err != nil {
 		return err
 	}
@@ -110,7 +116,9 @@ func (s *Swarm) connHandler(c *ps.Conn) *Conn {
 
 	sc, err := s.newConnSetup(ctx, c)
 	if err != nil {
-		log.Error(err)
+		if err != errConnFiltered {
+			log.Error(err)
+		}
 		log.Event(ctx, "newConnHandlerDisconnect", lgbl.NetConn(c.NetConn()), lgbl.Error(err))
 		c.Close() // boom. close it.
 		return nil
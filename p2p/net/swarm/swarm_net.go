@@ -6,6 +6,8 @@ import (
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
+	transport "github.com/jbenet/go-ipfs/p2p/net/transport"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	ctxgroup "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-ctxgroup"
@@ -21,7 +23,15 @@ type Network Swarm
 func NewNetwork(ctx context.Context, listen []ma.Multiaddr, local peer.ID,
 	peers peer.Peerstore) (*Network, error) {
 
-	s, err := NewSwarm(ctx, listen, local, peers)
+	return NewNetworkWithProtector(ctx, listen, local, peers, nil)
+}
+
+// NewNetworkWithProtector constructs a new network in the private network
+// of protec. See NewSwarmWithProtector.
+func NewNetworkWithProtector(ctx context.Context, listen []ma.Multiaddr, local peer.ID,
+	peers peer.Peerstore, protec pnet.Protector) (*Network, error) {
+
+	s, err := NewSwarmWithProtector(ctx, listen, local, peers, protec)
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +52,13 @@ func (n *Network) DialPeer(ctx context.Context, p peer.ID) (inet.Conn, error) {
 	return inet.Conn(sc), nil
 }
 
+// AddTransport makes the network dial with t, and listen on laddrs with
+// it. See Swarm.AddTransport.
+func (n *Network) AddTransport(t transport.Transport, laddrs ...ma.Multiaddr) error {
+	n.Swarm().AddTransport(t)
+	return n.Swarm().Listen(laddrs...)
+}
+
 // CtxGroup returns the network's ContextGroup
 func (n *Network) CtxGroup() ctxgroup.ContextGroup {
 	return n.cg
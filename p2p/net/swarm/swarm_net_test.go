@@ -76,3 +76,44 @@ func printConns(n inet.Network) string {
 	}
 	return s
 }
+
+func TestConnStat(t *testing.T) {
+	ctx := context.Background()
+	a := testutil.GenSwarmNetwork(t, ctx)
+	b := testutil.GenSwarmNetwork(t, ctx)
+	defer a.Close()
+	defer b.Close()
+
+	testutil.DivulgeAddresses(b, a)
+	before := time.Now()
+	if _, err := a.DialPeer(ctx, b.LocalPeer()); err != nil {
+		t.Fatal(err)
+	}
+	s, err := a.NewStream(b.LocalPeer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	st := s.Conn().Stat()
+	if st.Direction != inet.DirOutbound {
+		t.Errorf("dialed conn is %s", st.Direction)
+	}
+	if st.Opened.Before(before) || st.Opened.After(time.Now()) {
+		t.Errorf("wrong open time: %s", st.Opened)
+	}
+	if st.Streams != 1 {
+		t.Errorf("expected 1 stream, got %d", st.Streams)
+	}
+
+	// the accepting side may not have added the conn just yet.
+	deadline := time.Now().Add(time.Second)
+	for len(b.ConnsToPeer(a.LocalPeer())) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, c := range b.ConnsToPeer(a.LocalPeer()) {
+		if d := c.Stat().Direction; d != inet.DirInbound {
+			t.Errorf("accepted conn is %s", d)
+		}
+	}
+}
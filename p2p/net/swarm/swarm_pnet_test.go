@@ -0,0 +1,71 @@
+package swarm
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	pnet "github.com/jbenet/go-ipfs/p2p/net/pnet"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	testutil "github.com/jbenet/go-ipfs/util/testutil"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+)
+
+func makeProtectedSwarm(ctx context.Context, t *testing.T, protec pnet.Protector) *Swarm {
+	p := testutil.RandPeerNetParamsOrFatal(t)
+	ps := peer.NewPeerstore()
+	ps.AddPubKey(p.ID, p.PubKey)
+	ps.AddPrivKey(p.ID, p.PrivKey)
+
+	s, err := NewSwarmWithProtector(ctx, []ma.Multiaddr{p.Addr}, p.ID, ps, protec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetStreamHandler(EchoStreamHandler)
+	return s
+}
+
+func pskProtector(b byte) pnet.Protector {
+	var psk [pnet.KeyLength]byte
+	copy(psk[:], bytes.Repeat([]byte{b}, pnet.KeyLength))
+	return pnet.NewProtector(&psk)
+}
+
+func TestPrivateNetwork(t *testing.T) {
+	ctx := context.Background()
+	key1 := pskProtector(1)
+
+	s1 := makeProtectedSwarm(ctx, t, key1)
+	defer s1.Close()
+
+	connects := func(s2 *Swarm) bool {
+		defer s2.Close()
+		s1.peers.AddAddresses(s2.local, s2.ListenAddresses(), peer.PermanentAddrTTL)
+		stream, err := s1.NewStreamWithPeer(s2.local)
+		if err != nil {
+			return false
+		}
+		defer stream.Close()
+
+		if _, err := stream.Write([]byte("ping")); err != nil {
+			return false
+		}
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(stream, buf); err != nil {
+			return false
+		}
+		return bytes.Equal(buf, []byte("pong"))
+	}
+
+	if !connects(makeProtectedSwarm(ctx, t, key1)) {
+		t.Fatal("peers with the same key did not connect")
+	}
+	if connects(makeProtectedSwarm(ctx, t, pskProtector(2))) {
+		t.Fatal("peer with another key connected")
+	}
+	if connects(makeProtectedSwarm(ctx, t, nil)) {
+		t.Fatal("peer in the public network connected")
+	}
+}
@@ -0,0 +1,74 @@
+package swarm
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	conn "github.com/jbenet/go-ipfs/p2p/net/conn"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	testutil "github.com/jbenet/go-ipfs/util/testutil"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+)
+
+// subtestTransport connects two swarms listening on laddr, and pings
+// over a stream between them.
+func subtestTransport(t *testing.T, laddr string) {
+	ctx := context.Background()
+
+	swarms := make([]*Swarm, 2)
+	for i := range swarms {
+		p := testutil.RandPeerNetParamsOrFatal(t)
+		ps := peer.NewPeerstore()
+		ps.AddPubKey(p.ID, p.PubKey)
+		ps.AddPrivKey(p.ID, p.PrivKey)
+
+		s, err := NewSwarm(ctx, []ma.Multiaddr{ma.StringCast(laddr)}, p.ID, ps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		s.SetStreamHandler(EchoStreamHandler)
+		swarms[i] = s
+	}
+
+	s1, s2 := swarms[0], swarms[1]
+	raddr := s2.ListenAddresses()[0]
+	if !conn.MultiaddrProtocolsMatch(raddr, ma.StringCast(laddr)) {
+		t.Fatalf("swarm listening on the wrong transport: %s", raddr)
+	}
+	s1.peers.AddAddress(s2.LocalPeer(), raddr, peer.PermanentAddrTTL)
+
+	stream, err := s1.NewStreamWithPeer(s2.LocalPeer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	if !stream.Conn().RemoteMultiaddr().Equal(raddr) {
+		t.Errorf("connected through %s, not %s", stream.Conn().RemoteMultiaddr(), raddr)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := stream.Write([]byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(stream, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, []byte("pong")) {
+			t.Fatalf("expected pong, got %s", buf)
+		}
+	}
+}
+
+func TestSwarmUTP(t *testing.T) {
+	subtestTransport(t, "/ip4/127.0.0.1/udp/0/utp")
+}
+
+func TestSwarmWebsocket(t *testing.T) {
+	subtestTransport(t, "/ip4/127.0.0.1/tcp/0/ws")
+}
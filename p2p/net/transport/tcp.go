@@ -0,0 +1,131 @@
+package transport
+
+import (
+	"net"
+	"syscall"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+	reuseport "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-reuseport"
+)
+
+// TCP is the transport for /ip4/tcp and /ip6/tcp addresses. Where the
+// platform supports it, it listens and dials with SO_REUSEPORT so that
+// outgoing connections use our listen port, which helps NAT traversal.
+type TCP struct{}
+
+// NewTCP constructs the TCP transport.
+func NewTCP() *TCP {
+	return &TCP{}
+}
+
+// Matches returns whether a is a tcp address.
+func (t *TCP) Matches(a ma.Multiaddr) bool {
+	return matchesIPStack(a, ma.P_TCP)
+}
+
+// Dial connects to raddr. If d has a LocalAddr, dialing from it is tried
+// first, by reusing its port.
+func (t *TCP) Dial(ctx context.Context, raddr ma.Multiaddr, d manet.Dialer) (manet.Conn, error) {
+	laddr := d.LocalAddr
+	d.LocalAddr = nil
+
+	if laddr != nil && reuseport.Available() {
+		// we're perhaps going to dial twice. half the timeout, so we can afford to.
+		// otherwise our context would expire right after the first dial.
+		d.Dialer.Timeout = (d.Dialer.Timeout / 2)
+
+		// dial using reuseport.Dialer, because we're probably reusing addrs.
+		// this is optimistic, as the reuseDial may fail to bind the port.
+		if nconn, retry, reuseErr := reuseDial(d.Dialer, laddr, raddr); reuseErr == nil {
+			// if it worked, wrap the raw net.Conn with our manet.Conn
+			log.Debugf("reuse worked! %s %s %s", laddr, nconn.RemoteAddr(), nconn)
+			return manet.WrapNetConn(nconn)
+		} else if !retry {
+			// reuseDial is sure this is a legitimate dial failure, not a reuseport failure.
+			return nil, reuseErr
+		} else {
+			// this is a failure to reuse port. log it.
+			log.Debugf("port reuse failed: %s --> %s -- %s", laddr, raddr, reuseErr)
+		}
+	}
+
+	return d.Dial(raddr)
+}
+
+// Listen listens on laddr, with SO_REUSEPORT if available.
+func (t *TCP) Listen(laddr ma.Multiaddr) (manet.Listener, error) {
+	network, naddr, err := manet.DialArgs(laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if reuseport.Available() {
+		nl, err := reuseport.Listen(network, naddr)
+		if err == nil {
+			// hey, it worked!
+			return manet.WrapNetListener(nl)
+		}
+		// reuseport is available, but we failed to listen. log debug, and retry normally.
+		log.Debugf("reuseport available, but failed to listen: %s %s, %s", network, naddr, err)
+	}
+
+	// either reuseport not available, or it failed. try normally.
+	return manet.Listen(laddr)
+}
+
+func reuseDial(dialer net.Dialer, laddr, raddr ma.Multiaddr) (conn net.Conn, retry bool, err error) {
+	if laddr == nil {
+		// if we're given no local address no sense in using reuseport to dial, dial out as usual.
+		return nil, true, reuseport.ErrReuseFailed
+	}
+
+	// give reuse.Dialer the manet.Dialer's Dialer.
+	// (wow, Dialer should've so been an interface...)
+	rd := reuseport.Dialer{D: dialer}
+
+	// get the local net.Addr manually
+	rd.D.LocalAddr, err = manet.ToNetAddr(laddr)
+	if err != nil {
+		return nil, true, err // something wrong with laddr. retry without.
+	}
+
+	// get the raddr dial args for rd.dial
+	network, netraddr, err := manet.DialArgs(raddr)
+	if err != nil {
+		return nil, true, err // something wrong with laddr. retry without.
+	}
+
+	// rd.Dial gets us a net.Conn with SO_REUSEPORT and SO_REUSEADDR set.
+	conn, err = rd.Dial(network, netraddr)
+	return conn, reuseErrShouldRetry(err), err // hey! it worked!
+}
+
+// reuseErrShouldRetry diagnoses whether to retry after a reuse error.
+// if we failed to bind, we should retry. if bind worked and this is a
+// real dial error (remote end didnt answer) then we should not retry.
+func reuseErrShouldRetry(err error) bool {
+	if err == nil {
+		return false // hey, it worked! no need to retry.
+	}
+
+	// if it's a network timeout error, it's a legitimate failure.
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		return true
+	}
+
+	errno, ok := err.(syscall.Errno)
+	if !ok { // not an errno? who knows what this is. retry.
+		return true
+	}
+
+	switch errno {
+	case syscall.EADDRINUSE, syscall.EADDRNOTAVAIL:
+		return true // failure to bind. retry.
+	case syscall.ECONNREFUSED:
+		return false // real dial error
+	default:
+		return true // optimistically default to retry.
+	}
+}
@@ -0,0 +1,65 @@
+// Package transport holds the network transports the swarm dials and
+// listens with. Each Transport handles the multiaddrs of one protocol
+// stack, e.g. /ip4/tcp, /ip4/udp/utp or /ip4/tcp/ws, and the swarm picks
+// the one matching the address it is given.
+package transport
+
+import (
+	"fmt"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+
+	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+)
+
+var log = eventlog.Logger("p2p/net/transport")
+
+// Transport dials and listens on the multiaddrs of one protocol stack.
+type Transport interface {
+
+	// Matches returns whether this transport can dial and listen on a.
+	Matches(a ma.Multiaddr) bool
+
+	// Dial connects to raddr. The options of d (timeout, local address)
+	// are used where the transport supports them.
+	Dial(ctx context.Context, raddr ma.Multiaddr, d manet.Dialer) (manet.Conn, error)
+
+	// Listen listens for connections on laddr.
+	Listen(laddr ma.Multiaddr) (manet.Listener, error)
+}
+
+// Default returns the transports the swarm uses by default: TCP, uTP
+// (a reliable transport over UDP) and WebSocket.
+func Default() []Transport {
+	return []Transport{NewTCP(), NewUTP(), NewWebsocket()}
+}
+
+// Find returns the first of ts that matches a.
+func Find(ts []Transport, a ma.Multiaddr) (Transport, error) {
+	for _, t := range ts {
+		if t.Matches(a) {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("no transport for address: %s", a)
+}
+
+// matchesIPStack returns whether a is an ip4 or ip6 address, followed
+// by exactly the protocols in codes.
+func matchesIPStack(a ma.Multiaddr, codes ...int) bool {
+	ps := a.Protocols()
+	if len(ps) != len(codes)+1 {
+		return false
+	}
+	if ps[0].Code != ma.P_IP4 && ps[0].Code != ma.P_IP6 {
+		return false
+	}
+	for i, c := range codes {
+		if ps[i+1].Code != c {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,308 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+)
+
+func testDialer() manet.Dialer {
+	return manet.Dialer{Dialer: net.Dialer{Timeout: 5 * time.Second}}
+}
+
+// testLoopback listens on laddr with t, dials it, and echoes data both ways.
+func testLoopback(t *testing.T, tpt Transport, laddr string) {
+	a := ma.StringCast(laddr)
+	if !tpt.Matches(a) {
+		t.Fatalf("transport does not match %s", a)
+	}
+
+	l, err := tpt.Listen(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if !tpt.Matches(l.Multiaddr()) {
+		t.Fatalf("listener addr %s does not match its transport", l.Multiaddr())
+	}
+
+	// a large message spans many frames/packets.
+	msg := bytes.Repeat([]byte("loopback "), 20000)
+
+	errs := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer c.Close()
+
+		if !tpt.Matches(c.RemoteMultiaddr()) {
+			t.Errorf("accepted conn raddr %s does not match", c.RemoteMultiaddr())
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(c, buf); err != nil {
+			errs <- err
+			return
+		}
+		_, err = c.Write(buf)
+		errs <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := tpt.Dial(ctx, l.Multiaddr(), testDialer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if !c.RemoteMultiaddr().Equal(l.Multiaddr()) {
+		t.Errorf("dialed conn raddr %s != %s", c.RemoteMultiaddr(), l.Multiaddr())
+	}
+	if !tpt.Matches(c.LocalMultiaddr()) {
+		t.Errorf("dialed conn laddr %s does not match", c.LocalMultiaddr())
+	}
+
+	if _, err := c.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	echo := make([]byte, len(msg))
+	if _, err := io.ReadFull(c, echo); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(echo, msg) {
+		t.Fatal("echoed data differs")
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTCPLoopback(t *testing.T) {
+	testLoopback(t, NewTCP(), "/ip4/127.0.0.1/tcp/0")
+}
+
+func TestUTPLoopback(t *testing.T) {
+	testLoopback(t, NewUTP(), "/ip4/127.0.0.1/udp/0/utp")
+}
+
+func TestWebsocketLoopback(t *testing.T) {
+	testLoopback(t, NewWebsocket(), "/ip4/127.0.0.1/tcp/0/ws")
+}
+
+func TestFind(t *testing.T) {
+	ts := Default()
+	cases := map[string]Transport{
+		"/ip4/1.2.3.4/tcp/4001":     ts[0],
+		"/ip6/::1/tcp/4001":         ts[0],
+		"/ip4/1.2.3.4/udp/4001/utp": ts[1],
+		"/ip4/1.2.3.4/tcp/80/ws":    ts[2],
+		"/ip6/::1/tcp/80/ws":        ts[2],
+	}
+	for s, want := range cases {
+		got, err := Find(ts, ma.StringCast(s))
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: found the wrong transport %T", s, got)
+		}
+	}
+
+	if _, err := Find(ts, ma.StringCast("/ip4/1.2.3.4/udp/4001")); err == nil {
+		t.Error("found a transport for a bare udp addr")
+	}
+}
+
+func TestWebsocketRefusesPlainHTTP(t *testing.T) {
+	l, err := NewWebsocket().Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/ws"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	resp, err := http.Get("http://" + l.Addr().String() + wsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a plain request, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebsocketPingAndClose(t *testing.T) {
+	client, server := net.Pipe()
+	cc := newWsConn(client, bufio.NewReader(client), true, nil, nil)
+	sc := newWsConn(server, bufio.NewReader(server), false, nil, nil)
+
+	// the client pings, the server's Read answers with a pong, and then
+	// returns EOF on the client's close frame.
+	done := make(chan error, 1)
+	go func() {
+		_, err := sc.Read(make([]byte, 10))
+		done <- err
+	}()
+
+	if err := cc.writeFrame(opPing, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	op, err := cc.nextFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pong := make([]byte, cc.remaining)
+	if _, err := cc.readPayload(pong); err != nil {
+		t.Fatal(err)
+	}
+	if op != opPong || string(pong) != "hi" {
+		t.Fatalf("expected pong 'hi', got op %x %q", op, pong)
+	}
+
+	go cc.Close()
+	if err := <-done; err != io.EOF {
+		t.Fatalf("expected EOF after close frame, got %v", err)
+	}
+}
+
+// maskedFrame returns a final client frame of op with payload.
+func maskedFrame(op byte, payload string) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | op, 0x80 | byte(len(payload))}, mask...)
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	return frame
+}
+
+func TestWebsocketShortReads(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	// the frames arrive one byte at a time.
+	frames := append(maskedFrame(opPing, "hello"), maskedFrame(opBinary, "data")...)
+	br := bufio.NewReader(iotest.OneByteReader(bytes.NewReader(frames)))
+	sc := newWsConn(server, br, false, nil, nil)
+
+	pong := make(chan []byte, 1)
+	go func() {
+		cc := newWsConn(client, bufio.NewReader(client), true, nil, nil)
+		if _, err := cc.nextFrame(); err != nil {
+			pong <- nil
+			return
+		}
+		payload := make([]byte, cc.remaining)
+		io.ReadFull(payloadReader{cc}, payload)
+		pong <- payload
+	}()
+
+	buf := make([]byte, 10)
+	n, err := io.ReadAtLeast(sc, buf, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "data" {
+		t.Fatalf("read %q, expected %q", buf[:n], "data")
+	}
+	if p := <-pong; string(p) != "hello" {
+		t.Fatalf("got pong %q, expected %q", p, "hello")
+	}
+}
+
+func TestWebsocketRefusesUnmaskedFrames(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	sc := newWsConn(server, bufio.NewReader(server), false, nil, nil)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := sc.Read(make([]byte, 10))
+		done <- err
+	}()
+
+	// a client frame, unmasked.
+	if _, err := client.Write([]byte{0x80 | opBinary, 4, 'd', 'a', 't', 'a'}); err != nil {
+		t.Fatal(err)
+	}
+
+	cc := newWsConn(client, bufio.NewReader(client), true, nil, nil)
+	op, err := cc.nextFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := make([]byte, cc.remaining)
+	if _, err := io.ReadFull(payloadReader{cc}, status); err != nil {
+		t.Fatal(err)
+	}
+	if op != opClose || !bytes.Equal(status, []byte{0x03, 0xea}) {
+		t.Fatalf("expected a close frame with status 1002, got op %x %v", op, status)
+	}
+	if err := <-done; err != errBadFrame {
+		t.Fatalf("expected a bad frame error, got %v", err)
+	}
+}
+
+func TestUTPReadDeadline(t *testing.T) {
+	tpt := NewUTP()
+	l, err := tpt.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/utp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan manet.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := tpt.Dial(ctx, l.Multiaddr(), testDialer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c2 := <-accepted; c2 != nil {
+		defer c2.Close()
+	}
+
+	// a read blocked without a deadline times out once one is set.
+	errs := make(chan error, 1)
+	go func() {
+		_, err := c.Read(make([]byte, 1))
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := c.SetDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Fatalf("expected a timeout, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read not woken by the new deadline")
+	}
+
+	// and reads past the deadline fail right away.
+	if _, err := c.Read(make([]byte, 1)); err != errTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
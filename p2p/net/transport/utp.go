@@ -0,0 +1,235 @@
+package transport
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	utp "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/h2so5/utp"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+)
+
+// UTP is the transport for /ip4/udp/utp and /ip6/udp/utp addresses. uTP
+// gives reliable, ordered streams over UDP, with delay based congestion
+// control, so it gets through where only UDP does.
+type UTP struct{}
+
+// NewUTP constructs the uTP transport.
+func NewUTP() *UTP {
+	return &UTP{}
+}
+
+// Matches returns whether a is a utp address.
+func (t *UTP) Matches(a ma.Multiaddr) bool {
+	return matchesIPStack(a, ma.P_UDP, ma.P_UTP)
+}
+
+// Dial connects to raddr. Dialing always uses a new udp port: the listen
+// port is owned by the listener, so d.LocalAddr is ignored.
+func (t *UTP) Dial(ctx context.Context, raddr ma.Multiaddr, d manet.Dialer) (manet.Conn, error) {
+	network, host, err := manet.DialArgs(raddr)
+	if err != nil {
+		return nil, err
+	}
+	ura, err := utp.ResolveUTPAddr(network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	uc, err := utp.DialUTPTimeout(network, nil, ura, dialTimeout(ctx, d))
+	if err != nil {
+		return nil, err
+	}
+	c, err := manet.WrapNetConn(uc)
+	if err != nil {
+		uc.Close()
+		return nil, err
+	}
+	return newUTPConn(c), nil
+}
+
+// Listen listens on laddr.
+func (t *UTP) Listen(laddr ma.Multiaddr) (manet.Listener, error) {
+	network, host, err := manet.DialArgs(laddr)
+	if err != nil {
+		return nil, err
+	}
+	ula, err := utp.ResolveUTPAddr(network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	ul, err := utp.ListenUTP(network, ula)
+	if err != nil {
+		return nil, err
+	}
+	ml, err := manet.WrapNetListener(ul)
+	if err != nil {
+		ul.Close()
+		return nil, err
+	}
+	return &utpListener{ml}, nil
+}
+
+// utpListener wraps the conns it accepts in utpConns.
+type utpListener struct {
+	manet.Listener
+}
+
+func (l *utpListener) Accept() (manet.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newUTPConn(c), nil
+}
+
+// utpReadBuffer is the number of chunks read ahead of the reader.
+const utpReadBuffer = 64
+
+// utpConn keeps reading from a uTP conn, into a buffer. The uTP library
+// stops processing packets -- acks for our writes included -- while
+// received data is not read, so two peers writing at once (as in the
+// secio handshake) would deadlock. Like tcp, we let the other side send
+// a window's worth of data we have not asked for yet.
+type utpConn struct {
+	manet.Conn
+
+	chunks chan []byte
+	cur    []byte
+	err    error // set before chunks is closed.
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	lk        sync.Mutex
+	rdeadline time.Time
+	rchanged  chan struct{} // closed when rdeadline changes.
+}
+
+func newUTPConn(c manet.Conn) *utpConn {
+	uc := &utpConn{
+		Conn:     c,
+		chunks:   make(chan []byte, utpReadBuffer),
+		closed:   make(chan struct{}),
+		rchanged: make(chan struct{}),
+	}
+	go uc.readLoop()
+	return uc
+}
+
+func (c *utpConn) readLoop() {
+	defer close(c.chunks)
+	c.err = io.EOF
+	for {
+		buf := make([]byte, 4096)
+		n, err := c.Conn.Read(buf)
+		if n > 0 {
+			select {
+			case c.chunks <- buf[:n]:
+			case <-c.closed:
+				return
+			}
+		}
+		if err != nil {
+			c.err = err
+			return
+		}
+	}
+}
+
+func (c *utpConn) Read(b []byte) (int, error) {
+	for len(c.cur) == 0 {
+		c.lk.Lock()
+		deadline, changed := c.rdeadline, c.rchanged
+		c.lk.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			left := deadline.Sub(time.Now())
+			if left <= 0 {
+				return 0, errTimeout
+			}
+			timer = time.NewTimer(left)
+			timeout = timer.C
+		}
+
+		var err error
+		select {
+		case chunk, ok := <-c.chunks:
+			if ok {
+				c.cur = chunk
+			} else {
+				err = c.err
+			}
+		case <-timeout:
+			err = errTimeout
+		case <-changed:
+			// wait again, until the new deadline.
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(b, c.cur)
+	c.cur = c.cur[n:]
+	return n, nil
+}
+
+func (c *utpConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+func (c *utpConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the deadline of reads, those blocked included.
+func (c *utpConn) SetReadDeadline(t time.Time) error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.rdeadline = t
+	close(c.rchanged)
+	c.rchanged = make(chan struct{})
+	return nil
+}
+
+// errTimeout is returned by reads past the read deadline.
+var errTimeout net.Error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// dialTimeout returns the time a dial may take: the dialer's timeout,
+// shortened to the context's deadline. Zero means no timeout.
+func dialTimeout(ctx context.Context, d manet.Dialer) time.Duration {
+	timeout := d.Dialer.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		left := deadline.Sub(time.Now())
+		if left <= 0 {
+			left = time.Nanosecond // zero would mean no timeout.
+		}
+		if timeout == 0 || left < timeout {
+			timeout = left
+		}
+	}
+	return timeout
+}
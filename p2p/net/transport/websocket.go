@@ -0,0 +1,487 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+	addrutil "github.com/jbenet/go-ipfs/p2p/net/swarm/addr"
+)
+
+// wsProtocol is the multiaddr suffix of websocket addresses.
+var wsProtocol = addrutil.WSAddr
+
+// wsPath is the http path websocket connections are opened on.
+const wsPath = "/"
+
+// wsGUID is the magic string of the handshake, see RFC 6455 section 1.3.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxControlPayload is the largest payload of a control frame.
+const maxControlPayload = 125
+
+// closeProtocolError is the status of the close frames sent to peers
+// breaking the protocol, see RFC 6455 section 7.4.1.
+const closeProtocolError = 1002
+
+// frame opcodes, see RFC 6455 section 5.2.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+var (
+	errBadHandshake = errors.New("websocket: bad handshake")
+	errBadFrame     = errors.New("websocket: bad frame")
+	errWsClosed     = errors.New("websocket: listener closed")
+)
+
+// Websocket is the transport for /ip4/tcp/ws and /ip6/tcp/ws addresses.
+// It wraps the swarm's byte stream in binary websocket frames, so
+// connections get through http proxies and firewalls which only let
+// http traffic out.
+type Websocket struct{}
+
+// NewWebsocket constructs the websocket transport.
+func NewWebsocket() *Websocket {
+	return &Websocket{}
+}
+
+// Matches returns whether a is a websocket address.
+func (t *Websocket) Matches(a ma.Multiaddr) bool {
+	return matchesIPStack(a, ma.P_TCP, addrutil.P_WS)
+}
+
+// Dial opens a tcp connection to raddr and performs the websocket
+// handshake over it. d.LocalAddr is ignored.
+func (t *Websocket) Dial(ctx context.Context, raddr ma.Multiaddr, d manet.Dialer) (manet.Conn, error) {
+	tcpaddr := raddr.Decapsulate(wsProtocol)
+	_, host, err := manet.DialArgs(tcpaddr)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := dialTimeout(ctx, d)
+	d.LocalAddr = nil
+	d.Dialer.Timeout = timeout
+	nc, err := d.Dial(tcpaddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// the handshake is part of the dial.
+	if timeout > 0 {
+		nc.SetDeadline(time.Now().Add(timeout))
+	}
+	br, err := wsClientHandshake(nc, host)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+
+	laddr := nc.LocalMultiaddr().Encapsulate(wsProtocol)
+	return newWsConn(nc, br, true, laddr, raddr), nil
+}
+
+// Listen listens for websocket connections on laddr.
+func (t *Websocket) Listen(laddr ma.Multiaddr) (manet.Listener, error) {
+	ml, err := manet.Listen(laddr.Decapsulate(wsProtocol))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &wsListener{
+		nl:       ml.NetListener(),
+		laddr:    ml.Multiaddr().Encapsulate(wsProtocol),
+		incoming: make(chan manet.Conn),
+		closed:   make(chan struct{}),
+	}
+	go http.Serve(l.nl, l)
+	return l, nil
+}
+
+// wsListener accepts websocket connections. It runs an http server, and
+// hands out the connections which it upgraded.
+type wsListener struct {
+	nl    net.Listener
+	laddr ma.Multiaddr
+
+	incoming  chan manet.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// ServeHTTP upgrades websocket requests, and refuses all others.
+func (l *wsListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != "GET" || key == "" ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!headerContains(r.Header, "Connection", "upgrade") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot upgrade connection", http.StatusInternalServerError)
+		return
+	}
+	nc, brw, err := hj.Hijack()
+	if err != nil {
+		log.Debugf("websocket hijack failed: %s", err)
+		return
+	}
+
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", wsAcceptKey(key))
+	if err := brw.Flush(); err != nil {
+		nc.Close()
+		return
+	}
+
+	raddr, err := manet.FromNetAddr(nc.RemoteAddr())
+	if err != nil {
+		nc.Close()
+		return
+	}
+	c := newWsConn(nc, brw.Reader, false, l.laddr, raddr.Encapsulate(wsProtocol))
+
+	select {
+	case l.incoming <- c:
+	case <-l.closed:
+		c.Close()
+	}
+}
+
+func (l *wsListener) NetListener() net.Listener {
+	return l.nl
+}
+
+func (l *wsListener) Accept() (manet.Conn, error) {
+	select {
+	case c := <-l.incoming:
+		return c, nil
+	case <-l.closed:
+		return nil, errWsClosed
+	}
+}
+
+func (l *wsListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.nl.Close()
+	})
+	return err
+}
+
+func (l *wsListener) Multiaddr() ma.Multiaddr {
+	return l.laddr
+}
+
+func (l *wsListener) Addr() net.Addr {
+	return l.nl.Addr()
+}
+
+// wsClientHandshake sends the upgrade request for host on nc, and checks
+// the server's answer. It returns the reader to read frames from, as it
+// may have buffered some.
+func wsClientHandshake(nc net.Conn, host string) (*bufio.Reader, error) {
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method:     "GET",
+		URL:        &url.URL{Scheme: "http", Host: host, Path: wsPath},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       host,
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(nc); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(nc)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("%s: %s", errBadHandshake, resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return nil, errBadHandshake
+	}
+	return br, nil
+}
+
+// wsAcceptKey computes the Sec-WebSocket-Accept answer to key.
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+wsGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains returns whether the comma separated header values of
+// name contain token.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsConn is a manet.Conn whose bytes travel in binary websocket frames.
+type wsConn struct {
+	net.Conn
+	br     *bufio.Reader
+	client bool // clients mask the frames they send.
+
+	laddr ma.Multiaddr
+	raddr ma.Multiaddr
+
+	rlk       sync.Mutex
+	remaining uint64 // unread payload bytes of the current frame.
+	masked    bool
+	mask      [4]byte
+	maskPos   int
+
+	wlk       sync.Mutex
+	closeOnce sync.Once
+}
+
+func newWsConn(nc net.Conn, br *bufio.Reader, client bool, laddr, raddr ma.Multiaddr) *wsConn {
+	return &wsConn{
+		Conn:   nc,
+		br:     br,
+		client: client,
+		laddr:  laddr,
+		raddr:  raddr,
+	}
+}
+
+func (c *wsConn) LocalMultiaddr() ma.Multiaddr {
+	return c.laddr
+}
+
+func (c *wsConn) RemoteMultiaddr() ma.Multiaddr {
+	return c.raddr
+}
+
+// Read reads the payload of data frames, answering control frames on
+// the way.
+func (c *wsConn) Read(b []byte) (int, error) {
+	c.rlk.Lock()
+	defer c.rlk.Unlock()
+
+	for c.remaining == 0 {
+		op, err := c.nextFrame()
+		if err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case opBinary, opText, opContinuation:
+			// the payload is read below.
+		case opPing, opPong, opClose:
+			if c.remaining > maxControlPayload {
+				return 0, errBadFrame
+			}
+			payload := make([]byte, c.remaining)
+			if _, err := io.ReadFull(payloadReader{c}, payload); err != nil {
+				return 0, err
+			}
+			switch op {
+			case opPing:
+				if err := c.writeFrame(opPong, payload); err != nil {
+					return 0, err
+				}
+			case opClose:
+				c.closeOnce.Do(func() {
+					c.writeFrame(opClose, payload)
+				})
+				return 0, io.EOF
+			}
+		default:
+			return 0, errBadFrame
+		}
+	}
+
+	if uint64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+	return c.readPayload(b)
+}
+
+// nextFrame reads a frame header, and returns the frame's opcode.
+func (c *wsConn) nextFrame() (byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return 0, err
+	}
+	op := hdr[0] & 0x0f
+	c.masked = hdr[1]&0x80 != 0
+	if c.masked == c.client {
+		// clients must mask the frames they send, and servers must not,
+		// see RFC 6455 section 5.1.
+		c.fail(closeProtocolError)
+		return 0, errBadFrame
+	}
+
+	switch n := hdr[1] & 0x7f; n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return 0, err
+		}
+		c.remaining = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return 0, err
+		}
+		c.remaining = binary.BigEndian.Uint64(ext[:])
+	default:
+		c.remaining = uint64(n)
+	}
+
+	if c.masked {
+		if _, err := io.ReadFull(c.br, c.mask[:]); err != nil {
+			return 0, err
+		}
+		c.maskPos = 0
+	}
+	return op, nil
+}
+
+// readPayload reads into b from the current frame's payload, unmasking it.
+func (c *wsConn) readPayload(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	n, err := c.br.Read(b)
+	if c.masked {
+		for i := 0; i < n; i++ {
+			b[i] ^= c.mask[c.maskPos%4]
+			c.maskPos++
+		}
+	}
+	c.remaining -= uint64(n)
+	if err == io.EOF && c.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// payloadReader reads the current frame's payload of a wsConn.
+type payloadReader struct {
+	c *wsConn
+}
+
+func (r payloadReader) Read(b []byte) (int, error) {
+	return r.c.readPayload(b)
+}
+
+// fail sends a close frame with status, for the peer to know why the
+// connection ends.
+func (c *wsConn) fail(status uint16) {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], status)
+	c.closeOnce.Do(func() {
+		c.writeFrame(opClose, payload[:])
+	})
+}
+
+// Write sends b in one binary frame.
+func (c *wsConn) Write(b []byte) (int, error) {
+	if err := c.writeFrame(opBinary, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// writeFrame sends a single, final frame.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wlk.Lock()
+	defer c.wlk.Unlock()
+
+	hdr := make([]byte, 2, 14)
+	hdr[0] = 0x80 | op // FIN
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xffff:
+		hdr[1] = 126
+		hdr = hdr[:4]
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = hdr[:10]
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+
+	frame := payload
+	if c.client {
+		var mask [4]byte
+		if _, err := io.ReadFull(rand.Reader, mask[:]); err != nil {
+			return err
+		}
+		hdr[1] |= 0x80
+		hdr = append(hdr, mask[:]...)
+
+		frame = make([]byte, len(payload))
+		for i, v := range payload {
+			frame[i] = v ^ mask[i%4]
+		}
+	}
+
+	_, err := c.Conn.Write(append(hdr, frame...))
+	return err
+}
+
+// Close sends a close frame, and closes the connection.
+func (c *wsConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.writeFrame(opClose, nil)
+	})
+	return c.Conn.Close()
+}
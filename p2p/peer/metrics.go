@@ -3,6 +3,8 @@ package peer
 import (
 	"sync"
 	"time"
+
+	gometrics "github.com/jbenet/go-ipfs/thirdparty/metrics"
 )
 
 // LatencyEWMASmooting governs the decay of the EWMA (the speed
@@ -10,6 +12,9 @@ import (
 // 1 is 100% change, 0 is no change.
 var LatencyEWMASmoothing = 0.1
 
+// latencies are the latency measurements of all peers, in seconds.
+var latencies = gometrics.NewHistogram("ipfs_peer_latency_seconds", "Latency measurements of peers.", gometrics.DefaultBuckets)
+
 // Metrics is just an object that tracks metrics
 // across a set of peers.
 type Metrics interface {
@@ -35,6 +40,8 @@ func NewMetrics() Metrics {
 
 // RecordLatency records a new latency measurement
 func (m *metrics) RecordLatency(p ID, next time.Duration) {
+	latencies.Observe(next.Seconds())
+
 	nextf := float64(next)
 	s := LatencyEWMASmoothing
 	if s > 1 || s < 0 {
@@ -4,6 +4,7 @@ package peer
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	b58 "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-base58"
@@ -149,14 +150,27 @@ func (pi *PeerInfo) UnmarshalJSON(b []byte) error {
 	if err != nil {
 		return err
 	}
-	pid, err := IDB58Decode(data["ID"].(string))
+	id, ok := data["ID"].(string)
+	if !ok {
+		return errors.New("peer info: missing or invalid ID")
+	}
+	pid, err := IDB58Decode(id)
 	if err != nil {
 		return err
 	}
 	pi.ID = pid
-	addrs := data["Addrs"].([]interface{})
+	// Addrs is null when the peer info carries no addresses.
+	addrs, _ := data["Addrs"].([]interface{})
 	for _, a := range addrs {
-		pi.Addrs = append(pi.Addrs, ma.StringCast(a.(string)))
+		s, ok := a.(string)
+		if !ok {
+			return errors.New("peer info: invalid address")
+		}
+		maddr, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return err
+		}
+		pi.Addrs = append(pi.Addrs, maddr)
 	}
 	return nil
 }
@@ -2,7 +2,9 @@ package peer
 
 import (
 	"errors"
+	"math"
 	"sync"
+	"time"
 
 	ic "github.com/jbenet/go-ipfs/p2p/crypto"
 
@@ -17,6 +19,7 @@ type Peerstore interface {
 	KeyBook
 	AddressBook
 	Metrics
+	ProtoBook
 
 	// Peers returns a list of all peer.IDs in this Peerstore
 	Peers() []ID
@@ -26,8 +29,9 @@ type Peerstore interface {
 	// that peer, useful to other services.
 	PeerInfo(ID) PeerInfo
 
-	// AddPeerInfo absorbs the information listed in given PeerInfo.
-	AddPeerInfo(PeerInfo)
+	// AddPeerInfo absorbs the information listed in given PeerInfo,
+	// keeping its addresses for ttl.
+	AddPeerInfo(PeerInfo, time.Duration)
 
 	// Get/Put is a simple registry for other peer-related key/value pairs.
 	// if we find something we use often, it should become its own set of
@@ -36,18 +40,75 @@ type Peerstore interface {
 	Put(id ID, key string, val interface{}) error
 }
 
-// AddressBook tracks the addresses of Peers
+// AddressBook tracks the addresses of Peers. Each address is kept for a
+// TTL, after which it is forgotten: addresses we learned from others are
+// worth less than the ones we connected to.
 type AddressBook interface {
 	Addresses(ID) []ma.Multiaddr
-	AddAddress(ID, ma.Multiaddr)
-	AddAddresses(ID, []ma.Multiaddr)
+
+	// AddAddress adds an address, for ttl. Adding a known address only
+	// ever extends the time it is kept.
+	AddAddress(ID, ma.Multiaddr, time.Duration)
+	AddAddresses(ID, []ma.Multiaddr, time.Duration)
+
+	// SetAddress sets the ttl of an address, even if it is shorter than
+	// before. A ttl of zero removes the address.
+	SetAddress(ID, ma.Multiaddr, time.Duration)
+
+	// UpdateAddresses sets the ttl of the addresses of a peer that have
+	// oldTTL to newTTL. It marks connected addresses as recently
+	// connected, once we disconnect.
+	UpdateAddresses(p ID, oldTTL, newTTL time.Duration)
 }
 
-type addressMap map[string]ma.Multiaddr
+const (
+	// TempAddrTTL is the ttl of addresses we were told about, and may not
+	// even be able to dial.
+	TempAddrTTL = 10 * time.Minute
+
+	// ProviderAddrTTL is the ttl of the addresses of providers, which
+	// are kept long enough to fetch from them.
+	ProviderAddrTTL = 10 * time.Minute
+
+	// RecentlyConnectedAddrTTL is the ttl of addresses we were connected
+	// to, and are likely to reach the peer at again.
+	RecentlyConnectedAddrTTL = time.Hour
+
+	// OwnObservedAddrTTL is the ttl of the addresses other peers observe
+	// us at.
+	OwnObservedAddrTTL = 10 * time.Minute
+
+	// PermanentAddrTTL is the ttl of addresses we are given to keep, like
+	// the bootstrap peers, or our own.
+	PermanentAddrTTL = time.Duration(math.MaxInt64 - iota)
+
+	// ConnectedAddrTTL is the ttl of the addresses of connected peers.
+	// They are kept while connected, then become recently connected.
+	ConnectedAddrTTL
+)
+
+// expiringAddr is an address kept until it expires.
+type expiringAddr struct {
+	Addr    ma.Multiaddr
+	TTL     time.Duration
+	Expires time.Time
+}
+
+func (e *expiringAddr) expired(now time.Time) bool {
+	return !now.Before(e.Expires)
+}
+
+type addressMap map[string]*expiringAddr
 
 type addressbook struct {
 	addrs map[ID]addressMap
 	sync.RWMutex
+
+	// changed is called, with the lock held, when the addresses of a peer
+	// change. it lets persistent peerstores save them: the func it returns
+	// is run once the lock is released.
+	changed func(ID, addressMap) func()
+	after   []func()
 }
 
 func newAddressbook() *addressbook {
@@ -55,15 +116,33 @@ func newAddressbook() *addressbook {
 }
 
 func (ab *addressbook) Peers() []ID {
-	ab.RLock()
+	ab.Lock()
+	defer ab.Unlock()
+
+	now := time.Now()
 	ps := make([]ID, 0, len(ab.addrs))
-	for p := range ab.addrs {
-		ps = append(ps, p)
+	for p, amap := range ab.addrs {
+		if ab.gc(p, amap, now) {
+			ps = append(ps, p)
+		}
 	}
-	ab.RUnlock()
 	return ps
 }
 
+// gc forgets the expired addresses of p, and returns whether any are left.
+func (ab *addressbook) gc(p ID, amap addressMap, now time.Time) bool {
+	for k, e := range amap {
+		if e.expired(now) {
+			delete(amap, k)
+		}
+	}
+	if len(amap) == 0 {
+		delete(ab.addrs, p)
+		return false
+	}
+	return true
+}
+
 func (ab *addressbook) Addresses(p ID) []ma.Multiaddr {
 	ab.RLock()
 	defer ab.RUnlock()
@@ -73,35 +152,162 @@ func (ab *addressbook) Addresses(p ID) []ma.Multiaddr {
 		return nil
 	}
 
+	now := time.Now()
 	maddrs2 := make([]ma.Multiaddr, 0, len(maddrs))
-	for _, m := range maddrs {
-		maddrs2 = append(maddrs2, m)
+	for _, e := range maddrs {
+		if !e.expired(now) {
+			maddrs2 = append(maddrs2, e.Addr)
+		}
 	}
 	return maddrs2
 }
 
-func (ab *addressbook) AddAddress(p ID, m ma.Multiaddr) {
+func (ab *addressbook) AddAddress(p ID, m ma.Multiaddr, ttl time.Duration) {
+	ab.AddAddresses(p, []ma.Multiaddr{m}, ttl)
+}
+
+func (ab *addressbook) AddAddresses(p ID, ms []ma.Multiaddr, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
 	ab.Lock()
-	defer ab.Unlock()
+	defer ab.unlock()
 
-	_, found := ab.addrs[p]
+	amap, found := ab.addrs[p]
 	if !found {
-		ab.addrs[p] = addressMap{}
+		amap = addressMap{}
+		ab.addrs[p] = amap
+	}
+
+	exp := time.Now().Add(ttl)
+	changed := false
+	for _, m := range ms {
+		e, found := amap[m.String()]
+		if found && !e.Expires.Before(exp) {
+			continue
+		}
+		amap[m.String()] = &expiringAddr{Addr: m, TTL: ttl, Expires: exp}
+		changed = true
+	}
+	if changed {
+		ab.onChange(p, amap)
 	}
-	ab.addrs[p][m.String()] = m
 }
 
-func (ab *addressbook) AddAddresses(p ID, ms []ma.Multiaddr) {
+func (ab *addressbook) SetAddress(p ID, m ma.Multiaddr, ttl time.Duration) {
 	ab.Lock()
-	defer ab.Unlock()
+	defer ab.unlock()
 
-	for _, m := range ms {
-		_, found := ab.addrs[p]
-		if !found {
-			ab.addrs[p] = addressMap{}
+	amap, found := ab.addrs[p]
+	if !found {
+		if ttl <= 0 {
+			return
+		}
+		amap = addressMap{}
+		ab.addrs[p] = amap
+	}
+
+	if ttl <= 0 {
+		delete(amap, m.String())
+	} else {
+		amap[m.String()] = &expiringAddr{Addr: m, TTL: ttl, Expires: time.Now().Add(ttl)}
+	}
+	ab.onChange(p, amap)
+}
+
+func (ab *addressbook) UpdateAddresses(p ID, oldTTL, newTTL time.Duration) {
+	ab.Lock()
+	defer ab.unlock()
+
+	amap, found := ab.addrs[p]
+	if !found {
+		return
+	}
+
+	exp := time.Now().Add(newTTL)
+	changed := false
+	for k, e := range amap {
+		if e.TTL != oldTTL {
+			continue
+		}
+		if newTTL <= 0 {
+			delete(amap, k)
+		} else {
+			amap[k] = &expiringAddr{Addr: e.Addr, TTL: newTTL, Expires: exp}
+		}
+		changed = true
+	}
+	if changed {
+		ab.onChange(p, amap)
+	}
+}
+
+func (ab *addressbook) onChange(p ID, amap addressMap) {
+	ab.gc(p, amap, time.Now())
+	if ab.changed != nil {
+		if f := ab.changed(p, amap); f != nil {
+			ab.after = append(ab.after, f)
+		}
+	}
+}
+
+// unlock releases the lock, then runs what changed returned.
+func (ab *addressbook) unlock() {
+	after := ab.after
+	ab.after = nil
+	ab.Unlock()
+	for _, f := range after {
+		f()
+	}
+}
+
+// ProtoBook tracks what peers tell us of themselves, when identified.
+type ProtoBook interface {
+	// SetVersion records what p told us of itself.
+	SetVersion(ID, Version)
+
+	// Version returns what p told us of itself, and whether it did.
+	Version(ID) (Version, bool)
+}
+
+// Version is what a peer tells us of itself, when identified.
+type Version struct {
+	AgentVersion    string   // the client, e.g. go-ipfs/0.3.0
+	ProtocolVersion string   // e.g. ipfs/0.1.0
+	Protocols       []string // the protocols it handles
+}
+
+// SupportsProtocol returns whether v lists protocol proto.
+func (v Version) SupportsProtocol(proto string) bool {
+	for _, p := range v.Protocols {
+		if p == proto {
+			return true
 		}
-		ab.addrs[p][m.String()] = m
 	}
+	return false
+}
+
+type protobook struct {
+	versions map[ID]Version
+	sync.RWMutex
+}
+
+func newProtobook() *protobook {
+	return &protobook{versions: map[ID]Version{}}
+}
+
+func (pb *protobook) SetVersion(p ID, v Version) {
+	pb.Lock()
+	pb.versions[p] = v
+	pb.Unlock()
+}
+
+func (pb *protobook) Version(p ID) (Version, bool) {
+	pb.RLock()
+	defer pb.RUnlock()
+	v, found := pb.versions[p]
+	return v, found
 }
 
 // KeyBook tracks the Public keys of Peers.
@@ -118,6 +324,10 @@ type keybook struct {
 	sks map[ID]ic.PrivKey
 
 	sync.RWMutex // same lock. wont happen a ton.
+
+	// added is called, once the lock is released, when a public key is
+	// added.
+	added func(ID, ic.PubKey)
 }
 
 func newKeybook() *keybook {
@@ -157,8 +367,16 @@ func (kb *keybook) AddPubKey(p ID, pk ic.PubKey) error {
 	}
 
 	kb.Lock()
+	if _, found := kb.pks[p]; found {
+		kb.Unlock()
+		return nil
+	}
 	kb.pks[p] = pk
 	kb.Unlock()
+
+	if kb.added != nil {
+		kb.added(p, pk)
+	}
 	return nil
 }
 
@@ -190,6 +408,7 @@ type peerstore struct {
 	keybook
 	addressbook
 	metrics
+	protobook
 
 	// store other data, like versions
 	ds ds.ThreadSafeDatastore
@@ -201,6 +420,7 @@ func NewPeerstore() Peerstore {
 		keybook:     *newKeybook(),
 		addressbook: *newAddressbook(),
 		metrics:     *(NewMetrics()).(*metrics),
+		protobook:   *newProtobook(),
 		ds:          dssync.MutexWrap(ds.NewMapDatastore()),
 	}
 }
@@ -238,8 +458,8 @@ func (ps *peerstore) PeerInfo(p ID) PeerInfo {
 	}
 }
 
-func (ps *peerstore) AddPeerInfo(pi PeerInfo) {
-	ps.AddAddresses(pi.ID, pi.Addrs)
+func (ps *peerstore) AddPeerInfo(pi PeerInfo, ttl time.Duration) {
+	ps.AddAddresses(pi.ID, pi.Addrs, ttl)
 }
 
 func PeerInfos(ps Peerstore, peers []ID) []PeerInfo {
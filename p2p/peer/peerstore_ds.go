@@ -0,0 +1,291 @@
+package peer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	ic "github.com/jbenet/go-ipfs/p2p/crypto"
+
+	ds "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+)
+
+// PeerstorePrefix namespaces the peers saved in a datastore.
+var PeerstorePrefix = ds.NewKey("/peers")
+
+const (
+	dsAddrsKey  = "addrs"
+	dsPubKeyKey = "pubkey"
+)
+
+// NewPeerstoreDatastore creates a threadsafe collection of peers, which
+// saves the addresses we connected to them at, and their public keys, to
+// d. They are known again after a restart. A public key is only saved
+// while addresses of its peer are.
+func NewPeerstoreDatastore(d ds.ThreadSafeDatastore) (Peerstore, error) {
+	ps := NewPeerstore().(*peerstore)
+	as := &addrSaver{
+		d:      d,
+		kb:     &ps.keybook,
+		saved:  make(map[ID][]byte),
+		stored: make(map[ID]*storedPeer),
+	}
+	if err := loadPeers(d, ps, as); err != nil {
+		return nil, err
+	}
+
+	ps.addressbook.changed = as.changed
+	ps.keybook.added = func(p ID, pk ic.PubKey) {
+		as.keyAdded(p)
+	}
+	return ps, nil
+}
+
+func peerKey(p ID, k string) ds.Key {
+	return PeerstorePrefix.ChildString(p.Pretty()).ChildString(k)
+}
+
+// savedAddr is an expiringAddr as saved in the datastore. The addresses of
+// connected peers are saved without their expiry: they do not expire while
+// connected, and are only recently connected after a restart.
+type savedAddr struct {
+	Addr    string
+	TTL     time.Duration
+	Expires time.Time
+}
+
+// saveAddr returns whether e is worth saving. Addresses learned from others
+// are short lived, and permanent ones are given to us again on start, like
+// the bootstrap peers and our own.
+func saveAddr(e *expiringAddr) bool {
+	return e.TTL >= RecentlyConnectedAddrTTL && e.TTL != PermanentAddrTTL
+}
+
+// savedForm returns the addresses of amap worth saving, as saved, or nil if
+// there are none.
+func savedForm(amap addressMap) ([]byte, error) {
+	var saved []savedAddr
+	for _, e := range amap {
+		if !saveAddr(e) {
+			continue
+		}
+		s := savedAddr{Addr: e.Addr.String(), TTL: e.TTL}
+		if e.TTL != ConnectedAddrTTL {
+			s.Expires = e.Expires
+		}
+		saved = append(saved, s)
+	}
+	if len(saved) == 0 {
+		return nil, nil
+	}
+	sort.Sort(savedAddrs(saved))
+	return json.Marshal(saved)
+}
+
+// addrSaver saves the addresses of peers, when their saved form changes,
+// with their public keys. The datastore is written to without holding the
+// addressbook lock.
+type addrSaver struct {
+	d  ds.Datastore
+	kb *keybook
+
+	// saved is the saved form of the addresses of each peer, and seq
+	// numbers the changes to it, so they are written in order. both are
+	// guarded by the addressbook lock.
+	saved map[ID][]byte
+	seq   uint64
+
+	// lk serializes the writes, and guards stored.
+	lk     sync.Mutex
+	stored map[ID]*storedPeer
+}
+
+// storedPeer is what the datastore holds for a peer.
+type storedPeer struct {
+	seq   uint64 // of the change last written.
+	addrs bool
+	key   bool
+}
+
+// changed is the addressbook hook. It returns the write of the addresses
+// of p, if their saved form changed.
+func (as *addrSaver) changed(p ID, amap addressMap) func() {
+	b, err := savedForm(amap)
+	if err != nil {
+		log.Errorf("failed to save addresses of %s: %s", p, err)
+		return nil
+	}
+	if bytes.Equal(b, as.saved[p]) {
+		return nil
+	}
+	if b == nil {
+		delete(as.saved, p)
+	} else {
+		as.saved[p] = b
+	}
+	as.seq++
+	seq := as.seq
+
+	return func() {
+		if err := as.store(p, b, seq); err != nil {
+			log.Errorf("failed to save addresses of %s: %s", p, err)
+		}
+	}
+}
+
+// store writes the saved form b of the addresses of p, and its public key,
+// or deletes both if b is nil. Writes of older changes are dropped.
+func (as *addrSaver) store(p ID, b []byte, seq uint64) error {
+	as.lk.Lock()
+	defer as.lk.Unlock()
+
+	sp := as.stored[p]
+	if sp == nil {
+		sp = &storedPeer{}
+		as.stored[p] = sp
+	}
+	if seq < sp.seq {
+		return nil
+	}
+	sp.seq = seq
+
+	if b == nil {
+		sp.addrs, sp.key = false, false
+		for _, k := range []ds.Key{peerKey(p, dsAddrsKey), peerKey(p, dsPubKeyKey)} {
+			if err := as.d.Delete(k); err != nil && err != ds.ErrNotFound {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := as.d.Put(peerKey(p, dsAddrsKey), b); err != nil {
+		return err
+	}
+	sp.addrs = true
+	return as.storeKey(p, sp)
+}
+
+// keyAdded is the keybook hook. It saves the public key of p, if the
+// addresses of p are saved.
+func (as *addrSaver) keyAdded(p ID) {
+	as.lk.Lock()
+	defer as.lk.Unlock()
+
+	if sp := as.stored[p]; sp != nil && sp.addrs {
+		if err := as.storeKey(p, sp); err != nil {
+			log.Errorf("failed to save public key of %s: %s", p, err)
+		}
+	}
+}
+
+// storeKey saves the public key of p, if we have it and it is not saved
+// yet. as.lk must be held.
+func (as *addrSaver) storeKey(p ID, sp *storedPeer) error {
+	if sp.key {
+		return nil
+	}
+	pk := as.kb.PubKey(p)
+	if pk == nil {
+		return nil // saved once added.
+	}
+	b, err := pk.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := as.d.Put(peerKey(p, dsPubKeyKey), b); err != nil {
+		return err
+	}
+	sp.key = true
+	return nil
+}
+
+type savedAddrs []savedAddr
+
+func (s savedAddrs) Len() int           { return len(s) }
+func (s savedAddrs) Less(i, j int) bool { return s[i].Addr < s[j].Addr }
+func (s savedAddrs) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// loadPeers reads the peers saved in d into ps. We cannot be connected to
+// any peer yet, so the addresses saved while connected are now only
+// recently connected. Public keys of peers without addresses left are
+// deleted.
+func loadPeers(d ds.Datastore, ps *peerstore, as *addrSaver) error {
+	res, err := d.Query(dsq.Query{Prefix: PeerstorePrefix.String() + "/"})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pks := make(map[ID][]byte)
+	for _, e := range entries {
+		k := ds.NewKey(e.Key)
+		p, err := IDB58Decode(k.Parent().BaseNamespace())
+		if err != nil {
+			log.Debugf("ignoring peerstore entry %s: %s", e.Key, err)
+			continue
+		}
+		b, ok := e.Value.([]byte)
+		if !ok {
+			return fmt.Errorf("peerstore entry %s is not []byte", e.Key)
+		}
+
+		switch k.BaseNamespace() {
+		case dsPubKeyKey:
+			pks[p] = b // once we know which peers have addresses.
+
+		case dsAddrsKey:
+			var saved []savedAddr
+			if err := json.Unmarshal(b, &saved); err != nil {
+				log.Debugf("ignoring addresses of %s: %s", p, err)
+				continue
+			}
+			amap := addressMap{}
+			for _, s := range saved {
+				m, err := ma.NewMultiaddr(s.Addr)
+				if err != nil {
+					continue
+				}
+				e := &expiringAddr{Addr: m, TTL: s.TTL, Expires: s.Expires}
+				if s.TTL == ConnectedAddrTTL {
+					e.TTL = RecentlyConnectedAddrTTL
+					e.Expires = now.Add(RecentlyConnectedAddrTTL)
+				}
+				if !e.expired(now) {
+					amap[s.Addr] = e
+				}
+			}
+			if len(amap) == 0 { // all expired.
+				d.Delete(k)
+				continue
+			}
+			ps.addressbook.addrs[p] = amap
+			as.saved[p] = b
+			as.stored[p] = &storedPeer{addrs: true}
+		}
+	}
+
+	for p, b := range pks {
+		if as.saved[p] == nil {
+			d.Delete(peerKey(p, dsPubKeyKey))
+			continue
+		}
+		pk, err := ic.UnmarshalPublicKey(b)
+		if err != nil {
+			log.Debugf("ignoring public key of %s: %s", p, err)
+			continue
+		}
+		ps.keybook.AddPubKey(p, pk)
+		as.stored[p].key = true
+	}
+	return nil
+}
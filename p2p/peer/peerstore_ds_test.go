@@ -0,0 +1,202 @@
+package peer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	ic "github.com/jbenet/go-ipfs/p2p/crypto"
+
+	ds "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+)
+
+func TestPeerstoreDatastore(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	ps, err := NewPeerstoreDatastore(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, pk1, err := ic.GenerateKeyPair(ic.RSA, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id1, err := IDFromPublicKey(pk1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2 := IDS(t, "QmRmPL3FDZKE3Qiwv1RosLdwdvbvg17b2hB39QPScgWKKZ")
+	connected := MA(t, "/ip4/1.2.3.1/tcp/1111")
+	recent := MA(t, "/ip4/1.2.3.1/tcp/2222")
+	temp := MA(t, "/ip4/1.2.3.1/tcp/3333")
+	bootstrap := MA(t, "/ip4/1.2.3.2/tcp/1111")
+
+	if err := ps.AddPubKey(id1, pk1); err != nil {
+		t.Fatal(err)
+	}
+	ps.AddAddress(id1, connected, ConnectedAddrTTL)
+	ps.AddAddress(id1, recent, RecentlyConnectedAddrTTL)
+	ps.AddAddress(id1, temp, TempAddrTTL)
+	ps.AddAddress(id2, bootstrap, PermanentAddrTTL)
+
+	// restart.
+	ps, err = NewPeerstoreDatastore(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pk := ps.PubKey(id1)
+	if pk == nil || !pk.Equals(pk1) {
+		t.Fatal("public key was not saved")
+	}
+	addrs := ps.Addresses(id1)
+	if len(addrs) != 2 {
+		t.Fatalf("expected the connected addresses to be saved, got %s", addrs)
+	}
+	for _, a := range addrs {
+		if a.Equal(temp) {
+			t.Fatalf("temporary address %s should not be saved", a)
+		}
+	}
+	if addrs := ps.Addresses(id2); len(addrs) != 0 {
+		t.Fatalf("permanent addresses are given again on start, got %s", addrs)
+	}
+
+	// we are not connected anymore.
+	ps.UpdateAddresses(id1, ConnectedAddrTTL, 0)
+	if len(ps.Addresses(id1)) != 2 {
+		t.Fatal("connected addresses should be loaded as recently connected")
+	}
+
+	// forgotten addresses are not loaded again.
+	ps.SetAddress(id1, connected, 0)
+	ps.SetAddress(id1, recent, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	ps, err = NewPeerstoreDatastore(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addrs := ps.Addresses(id1); len(addrs) != 0 {
+		t.Fatalf("expected no addresses, got %s", addrs)
+	}
+
+	// and neither is the public key of their peer.
+	if ps.PubKey(id1) != nil {
+		t.Fatal("public key was kept without addresses")
+	}
+	if has, _ := d.Has(peerKey(id1, dsPubKeyKey)); has {
+		t.Fatal("public key was not deleted with the last addresses")
+	}
+}
+
+func TestPeerstoreDatastorePubKeyExpires(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	ps, err := NewPeerstoreDatastore(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, pk1, err := ic.GenerateKeyPair(ic.RSA, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id1, err := IDFromPublicKey(pk1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := MA(t, "/ip4/1.2.3.1/tcp/1111")
+
+	if err := ps.AddPubKey(id1, pk1); err != nil {
+		t.Fatal(err)
+	}
+	ps.AddAddress(id1, addr, time.Millisecond) // not worth saving.
+
+	// a key without saved addresses is not kept.
+	ps, err = NewPeerstoreDatastore(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.PubKey(id1) != nil {
+		t.Fatal("public key was kept without addresses")
+	}
+	if has, _ := d.Has(peerKey(id1, dsPubKeyKey)); has {
+		t.Fatal("public key without addresses was not deleted")
+	}
+
+	// the key is saved again with new addresses.
+	if err := ps.AddPubKey(id1, pk1); err != nil {
+		t.Fatal(err)
+	}
+	ps.AddAddress(id1, addr, RecentlyConnectedAddrTTL)
+	ps.SetAddress(id1, addr, 0)
+	ps.AddAddress(id1, addr, RecentlyConnectedAddrTTL)
+	ps, err = NewPeerstoreDatastore(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk := ps.PubKey(id1); pk == nil || !pk.Equals(pk1) {
+		t.Fatal("public key was not saved again with new addresses")
+	}
+}
+
+// countingDatastore counts the writes to it.
+type countingDatastore struct {
+	ds.ThreadSafeDatastore
+	sync.Mutex
+	puts int
+}
+
+func (d *countingDatastore) Put(k ds.Key, v interface{}) error {
+	d.Lock()
+	d.puts++
+	d.Unlock()
+	return d.ThreadSafeDatastore.Put(k, v)
+}
+
+func (d *countingDatastore) Puts() int {
+	d.Lock()
+	defer d.Unlock()
+	return d.puts
+}
+
+func TestPeerstoreDatastoreWritesChanges(t *testing.T) {
+	d := &countingDatastore{ThreadSafeDatastore: dssync.MutexWrap(ds.NewMapDatastore())}
+	ps, err := NewPeerstoreDatastore(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, pk1, err := ic.GenerateKeyPair(ic.RSA, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id1, err := IDFromPublicKey(pk1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connected := MA(t, "/ip4/1.2.3.1/tcp/1111")
+
+	// a key is not saved without addresses.
+	if err := ps.AddPubKey(id1, pk1); err != nil {
+		t.Fatal(err)
+	}
+	if d.Puts() != 0 {
+		t.Fatal("public key saved without addresses")
+	}
+
+	// but with them.
+	ps.AddAddress(id1, connected, ConnectedAddrTTL)
+	if has, _ := d.Has(peerKey(id1, dsPubKeyKey)); !has {
+		t.Fatal("public key not saved with the addresses")
+	}
+
+	// adding the connected addresses again, as identify does, does not
+	// change what is saved.
+	puts := d.Puts()
+	ps.AddAddress(id1, connected, ConnectedAddrTTL)
+	ps.AddAddress(id1, MA(t, "/ip4/1.2.3.1/tcp/3333"), TempAddrTTL)
+	if d.Puts() != puts {
+		t.Fatalf("%d writes for unchanged saved addresses", d.Puts()-puts)
+	}
+}
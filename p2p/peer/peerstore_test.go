@@ -2,6 +2,7 @@ package peer
 
 import (
 	"testing"
+	"time"
 
 	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 )
@@ -37,12 +38,12 @@ func TestAddresses(t *testing.T) {
 	ma32 := MA(t, "/ip4/1.2.3.3/tcp/2222")
 	ma33 := MA(t, "/ip4/1.2.3.3/tcp/3333")
 
-	ps.AddAddress(id1, ma11)
-	ps.AddAddress(id2, ma21)
-	ps.AddAddress(id2, ma22)
-	ps.AddAddress(id3, ma31)
-	ps.AddAddress(id3, ma32)
-	ps.AddAddress(id3, ma33)
+	ps.AddAddress(id1, ma11, PermanentAddrTTL)
+	ps.AddAddress(id2, ma21, PermanentAddrTTL)
+	ps.AddAddress(id2, ma22, PermanentAddrTTL)
+	ps.AddAddress(id3, ma31, PermanentAddrTTL)
+	ps.AddAddress(id3, ma32, PermanentAddrTTL)
+	ps.AddAddress(id3, ma33, PermanentAddrTTL)
 
 	test := func(exp, act []ma.Multiaddr) {
 		if len(exp) != len(act) {
@@ -75,3 +76,60 @@ func TestAddresses(t *testing.T) {
 	test([]ma.Multiaddr{ma21, ma22}, ps.PeerInfo(id2).Addrs)
 	test([]ma.Multiaddr{ma31, ma32, ma33}, ps.PeerInfo(id3).Addrs)
 }
+
+func TestAddressTTLs(t *testing.T) {
+	ps := NewPeerstore()
+	id1 := IDS(t, "QmcNstKuwBBoVTpSCSDrwzjgrRcaYXK833Psuz2EMHwyQN")
+	ma11 := MA(t, "/ip4/1.2.3.1/tcp/1111")
+	ma12 := MA(t, "/ip4/1.2.3.1/tcp/2222")
+
+	// adding only extends the ttl.
+	ps.AddAddress(id1, ma11, 50*time.Millisecond)
+	ps.AddAddress(id1, ma11, time.Millisecond)
+	ps.AddAddress(id1, ma12, time.Hour)
+	time.Sleep(20 * time.Millisecond)
+	if len(ps.Addresses(id1)) != 2 {
+		t.Fatalf("expected 2 addresses, got %s", ps.Addresses(id1))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if addrs := ps.Addresses(id1); len(addrs) != 1 || !addrs[0].Equal(ma12) {
+		t.Fatalf("expected %s to expire, got %s", ma11, addrs)
+	}
+
+	// setting does not.
+	ps.SetAddress(id1, ma12, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if addrs := ps.Addresses(id1); len(addrs) != 0 {
+		t.Fatalf("expected no addresses, got %s", addrs)
+	}
+	if len(ps.Peers()) != 0 {
+		t.Fatal("peers without addresses or keys should be forgotten")
+	}
+
+	// connected addresses become recently connected ones.
+	ps.AddAddress(id1, ma11, ConnectedAddrTTL)
+	ps.AddAddress(id1, ma12, TempAddrTTL)
+	ps.UpdateAddresses(id1, ConnectedAddrTTL, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if addrs := ps.Addresses(id1); len(addrs) != 1 || !addrs[0].Equal(ma12) {
+		t.Fatalf("expected only %s, got %s", ma12, addrs)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	ps := NewPeerstore()
+	p := IDS(t, "QmcNstKuwBBoVTpSCSDrwzjgrRcaYXK833Psuz2EMHwyQN")
+
+	if _, found := ps.Version(p); found {
+		t.Fatal("version of unidentified peer")
+	}
+
+	ps.SetVersion(p, Version{AgentVersion: "go-ipfs/0.3.0", Protocols: []string{"/ipfs/dht", "/ipfs/identify"}})
+	v, found := ps.Version(p)
+	if !found || v.AgentVersion != "go-ipfs/0.3.0" {
+		t.Fatalf("wrong version: %v %v", v, found)
+	}
+	if !v.SupportsProtocol("/ipfs/dht") || v.SupportsProtocol("/ipfs/relay/hop") {
+		t.Fatalf("wrong protocols: %v", v.Protocols)
+	}
+}
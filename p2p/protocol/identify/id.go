@@ -11,6 +11,7 @@ import (
 
 	host "github.com/jbenet/go-ipfs/p2p/host"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
 	pb "github.com/jbenet/go-ipfs/p2p/protocol/identify/pb"
 	config "github.com/jbenet/go-ipfs/repo/config"
@@ -57,6 +58,7 @@ func NewIDService(h host.Host) *IDService {
 		currid: make(map[inet.Conn]chan struct{}),
 	}
 	h.SetStreamHandler(ID, s.RequestHandler)
+	h.Network().Notify((*netNotifiee)(s))
 	return s
 }
 
@@ -78,11 +80,13 @@ func (ids *IDService) IdentifyConn(c inet.Conn) {
 	} else {
 
 		// ok give the response to our handler.
-		if err := protocol.WriteHeader(s, ID); err != nil {
-			log.Error("error writing stream header for %s", ID)
+		if err := protocol.SelectProto(s, ID); err != nil {
+			log.Errorf("error negotiating %s: %s", ID, err)
 			log.Event(context.TODO(), "IdentifyOpenFailed", c.RemotePeer())
+			s.Close()
+		} else {
+			ids.ResponseHandler(s)
 		}
-		ids.ResponseHandler(s)
 	}
 
 	ids.currmu.Lock()
@@ -141,17 +145,13 @@ func (ids *IDService) populateMessage(mes *pb.Identify, c inet.Conn) {
 	// "public" address, at least in relation to us.
 	mes.ObservedAddr = c.RemoteMultiaddr().Bytes()
 
-	// set listen addrs
-	laddrs, err := ids.Host.Network().InterfaceListenAddresses()
-	if err != nil {
-		log.Error(err)
-	} else {
-		mes.ListenAddrs = make([][]byte, len(laddrs))
-		for i, addr := range laddrs {
-			mes.ListenAddrs[i] = addr.Bytes()
-		}
-		log.Debugf("%s sent listen addrs to %s: %s", c.LocalPeer(), c.RemotePeer(), laddrs)
+	// set listen addrs, including NAT mapped and observed ones.
+	laddrs := ids.Host.Addrs()
+	mes.ListenAddrs = make([][]byte, len(laddrs))
+	for i, addr := range laddrs {
+		mes.ListenAddrs[i] = addr.Bytes()
 	}
+	log.Debugf("%s sent listen addrs to %s: %s", c.LocalPeer(), c.RemotePeer(), laddrs)
 
 	// set protocol versions
 	s := IpfsVersion.String()
@@ -162,8 +162,6 @@ func (ids *IDService) populateMessage(mes *pb.Identify, c inet.Conn) {
 func (ids *IDService) consumeMessage(mes *pb.Identify, c inet.Conn) {
 	p := c.RemotePeer()
 
-	// mes.Protocols
-
 	// mes.ObservedAddr
 	ids.consumeObservedAddress(mes.GetObservedAddr(), c)
 
@@ -181,14 +179,15 @@ func (ids *IDService) consumeMessage(mes *pb.Identify, c inet.Conn) {
 	}
 
 	// update our peerstore with the addresses.
-	ids.Host.Peerstore().AddAddresses(p, lmaddrs)
+	ids.Host.Peerstore().AddAddresses(p, lmaddrs, peer.ConnectedAddrTTL)
 	log.Debugf("%s received listen addrs for %s: %s", c.LocalPeer(), c.RemotePeer(), lmaddrs)
 
-	// get protocol versions
-	pv := *mes.ProtocolVersion
-	av := *mes.AgentVersion
-	ids.Host.Peerstore().Put(p, "ProtocolVersion", pv)
-	ids.Host.Peerstore().Put(p, "AgentVersion", av)
+	// get protocol versions, and the protocols handled.
+	ids.Host.Peerstore().SetVersion(p, peer.Version{
+		AgentVersion:    mes.GetAgentVersion(),
+		ProtocolVersion: mes.GetProtocolVersion(),
+		Protocols:       mes.GetProtocols(),
+	})
 }
 
 // IdentifyWait returns a channel which will be closed once
@@ -240,7 +239,7 @@ func (ids *IDService) consumeObservedAddress(observed []byte, c inet.Conn) {
 
 	// ok! we have the observed version of one of our ListenAddresses!
 	log.Debugf("added own observed listen addr: %s --> %s", c.LocalMultiaddr(), maddr)
-	ids.Host.Peerstore().AddAddress(ids.Host.ID(), maddr)
+	ids.Host.Peerstore().AddAddress(ids.Host.ID(), maddr, peer.OwnObservedAddrTTL)
 }
 
 func addrInAddrs(a ma.Multiaddr, as []ma.Multiaddr) bool {
@@ -251,3 +250,19 @@ func addrInAddrs(a ma.Multiaddr, as []ma.Multiaddr) bool {
 	}
 	return false
 }
+
+// netNotifiee lets the IDService know about disconnections, to stop
+// keeping the addresses of peers we are no longer connected to.
+type netNotifiee IDService
+
+func (nn *netNotifiee) Disconnected(n inet.Network, c inet.Conn) {
+	p := c.RemotePeer()
+	if n.Connectedness(p) != inet.Connected {
+		ids := (*IDService)(nn)
+		ids.Host.Peerstore().UpdateAddresses(p, peer.ConnectedAddrTTL, peer.RecentlyConnectedAddrTTL)
+	}
+}
+
+func (nn *netNotifiee) Connected(n inet.Network, c inet.Conn)      {}
+func (nn *netNotifiee) OpenedStream(n inet.Network, s inet.Stream) {}
+func (nn *netNotifiee) ClosedStream(n inet.Network, s inet.Stream) {}
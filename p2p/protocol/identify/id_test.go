@@ -74,17 +74,19 @@ func testKnowsAddrs(t *testing.T, h host.Host, p peer.ID, expected []ma.Multiadd
 }
 
 func testHasProtocolVersions(t *testing.T, h host.Host, p peer.ID) {
-	v, err := h.Peerstore().Get(p, "ProtocolVersion")
-	if v == nil {
+	v, found := h.Peerstore().Version(p)
+	if !found {
 		t.Error("no protocol version")
 		return
 	}
-	if v.(string) != identify.IpfsVersion.String() {
-		t.Error("protocol mismatch", err)
+	if v.ProtocolVersion != identify.IpfsVersion.String() {
+		t.Error("protocol mismatch", v.ProtocolVersion)
 	}
-	v, err = h.Peerstore().Get(p, "AgentVersion")
-	if v.(string) != identify.ClientVersion {
-		t.Error("agent version mismatch", err)
+	if v.AgentVersion != identify.ClientVersion {
+		t.Error("agent version mismatch", v.AgentVersion)
+	}
+	if !v.SupportsProtocol(string(identify.ID)) {
+		t.Error("identify not in protocols", v.Protocols)
 	}
 }
 
@@ -0,0 +1,249 @@
+// Package multistream negotiates the protocol spoken on a stream or a
+// connection. It does not depend on p2p/net, so p2p/net/conn can use it to
+// negotiate connection security; streams use it through p2p/protocol.
+package multistream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	msgio "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-msgio"
+)
+
+// Protocol negotiation, multistream style. Both sides first send the
+// ProtocolID header. The dialer then proposes protocols, one header
+// each; the listener echoes the proposal it accepts, or answers NA and
+// waits for the next proposal. "ls" asks the listener for its protocols:
+//
+//   dialer                        listener
+//   /multistream/1.0.0\n  ---->
+//                         <----  /multistream/1.0.0\n
+//   /ipfs/dht/2.0.0\n     ---->
+//                         <----  na\n
+//   /ipfs/dht/1.0.0\n     ---->
+//                         <----  /ipfs/dht/1.0.0\n
+//   <protocol data>
+//
+// Every message is a header, as written by WriteHeader.
+const (
+	// ProtocolID starts every negotiation.
+	ProtocolID = "/multistream/1.0.0"
+
+	// NA is the listener's answer to a protocol it does not support.
+	NA = "na"
+
+	// LS asks the listener for the list of protocols it supports.
+	LS = "ls"
+)
+
+// maxHeaderLen bounds the size of negotiation messages we accept.
+const maxHeaderLen = 1024
+
+// ErrNotSupported is returned when the other side answered NA to all of
+// the protocols proposed.
+var ErrNotSupported = errors.New("protocol not supported")
+
+// ErrIncorrectVersion is returned when the other side does not speak our
+// version of multistream.
+var ErrIncorrectVersion = errors.New("client connected with incorrect multistream version")
+
+// SelectProto negotiates protocol id on rw, as the dialer. It returns
+// ErrNotSupported if the other side does not handle it.
+func SelectProto(rw io.ReadWriter, id string) error {
+	_, err := SelectOneOf(rw, id)
+	return err
+}
+
+// SelectOneOf negotiates one of ids on rw, as the dialer. ids are proposed
+// in order, so the first one supported by the other side is returned.
+// This is how peers agree on a protocol version.
+func SelectOneOf(rw io.ReadWriter, ids ...string) (string, error) {
+	if len(ids) == 0 {
+		return "", ErrNotSupported
+	}
+
+	// send our multistream header together with the first proposal, so
+	// the common case takes a single round trip.
+	if err := WriteHeader(rw, ProtocolID); err != nil {
+		return "", err
+	}
+	if err := WriteHeader(rw, ids[0]); err != nil {
+		return "", err
+	}
+
+	vr := msgio.NewVarintReader(rw)
+	if err := readMultistreamHeader(vr); err != nil {
+		return "", err
+	}
+
+	for i, id := range ids {
+		if i > 0 {
+			if err := WriteHeader(rw, id); err != nil {
+				return "", err
+			}
+		}
+
+		reply, err := readNegotiationHeader(vr)
+		if err != nil {
+			return "", err
+		}
+		switch reply {
+		case id:
+			return id, nil
+		case NA:
+			continue
+		default:
+			return "", fmt.Errorf("unexpected answer to protocol %s: %s", id, reply)
+		}
+	}
+	return "", ErrNotSupported
+}
+
+// Ls asks the other side of rw for the protocols it handles, as the
+// dialer.
+func Ls(rw io.ReadWriter) ([]string, error) {
+	if err := WriteHeader(rw, ProtocolID); err != nil {
+		return nil, err
+	}
+	if err := WriteHeader(rw, LS); err != nil {
+		return nil, err
+	}
+
+	vr := msgio.NewVarintReader(rw)
+	if err := readMultistreamHeader(vr); err != nil {
+		return nil, err
+	}
+
+	// the list is one message, itself holding one header per protocol.
+	msg, err := vr.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+	lr := msgio.NewVarintReader(bytes.NewReader(msg))
+	var ids []string
+	for {
+		id, err := readNegotiationHeader(lr)
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+}
+
+// Negotiate runs the listener side of the negotiation on rw. supported
+// reports whether a proposed protocol is handled; list returns all of the
+// handled protocols, for "ls". Negotiate returns the protocol agreed on,
+// or an error once the dialer gives up (closes the stream).
+func Negotiate(rw io.ReadWriter, supported func(string) bool, list func() []string) (string, error) {
+	// write our header while reading the dialer's: on unbuffered streams,
+	// the dialer's pipelined header and proposal must be read first.
+	headerErr := make(chan error, 1)
+	go func() {
+		headerErr <- WriteHeader(rw, ProtocolID)
+	}()
+	headerSent := false
+	waitHeader := func() error {
+		if headerSent {
+			return nil
+		}
+		headerSent = true
+		return <-headerErr
+	}
+	reply := func(id string) error {
+		if err := waitHeader(); err != nil {
+			return err
+		}
+		return WriteHeader(rw, id)
+	}
+
+	vr := msgio.NewVarintReader(rw)
+	if err := readMultistreamHeader(vr); err != nil {
+		return "", err
+	}
+
+	for {
+		proposal, err := readNegotiationHeader(vr)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case proposal == LS:
+			if err := waitHeader(); err != nil {
+				return "", err
+			}
+			if err := writeList(rw, list()); err != nil {
+				return "", err
+			}
+		case supported(proposal):
+			if err := reply(proposal); err != nil {
+				return "", err
+			}
+			return proposal, nil
+		default:
+			if err := reply(NA); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+func readMultistreamHeader(vr msgio.Reader) error {
+	h, err := readNegotiationHeader(vr)
+	if err != nil {
+		return err
+	}
+	if h != ProtocolID {
+		return ErrIncorrectVersion
+	}
+	return nil
+}
+
+// readNegotiationHeader reads a header like ReadHeader, but refuses
+// oversized ones.
+func readNegotiationHeader(vr msgio.Reader) (string, error) {
+	l, err := vr.NextMsgLen()
+	if err != nil {
+		return "", err
+	}
+	if l > maxHeaderLen {
+		return "", fmt.Errorf("negotiation message too long: %d", l)
+	}
+
+	msg, err := vr.ReadMsg()
+	if err != nil {
+		return "", err
+	}
+	if len(msg) == 0 || msg[len(msg)-1] != '\n' {
+		return "", errors.New("negotiation message not terminated by newline")
+	}
+	return string(msg[:len(msg)-1]), nil
+}
+
+func writeList(w io.Writer, ids []string) error {
+	var buf bytes.Buffer
+	for _, id := range ids {
+		if err := WriteHeader(&buf, id); err != nil {
+			return err
+		}
+	}
+	return msgio.NewVarintWriter(w).WriteMsg(buf.Bytes())
+}
+
+// WriteHeader writes a negotiation header for id to w:
+//   <varint length><id>\n
+// (the varint includes the \n)
+func WriteHeader(w io.Writer, id string) error {
+	vw := msgio.NewVarintWriter(w)
+	return vw.WriteMsg([]byte(id + "\n"))
+}
+
+// ReadHeader reads a header written by WriteHeader.
+func ReadHeader(r io.Reader) (string, error) {
+	return readNegotiationHeader(msgio.NewVarintReader(r))
+}
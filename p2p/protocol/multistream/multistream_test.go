@@ -0,0 +1,119 @@
+package multistream
+
+import (
+	"net"
+	"testing"
+)
+
+// negotiatePipe runs Negotiate for the given protocols on one end of a
+// pipe, and returns the other end, and the listener's result.
+func negotiatePipe(protos ...string) (net.Conn, <-chan string, <-chan error) {
+	a, b := net.Pipe()
+	supported := func(p string) bool {
+		for _, s := range protos {
+			if s == p {
+				return true
+			}
+		}
+		return false
+	}
+	list := func() []string { return protos }
+
+	agreed := make(chan string, 1)
+	errs := make(chan error, 1)
+	go func() {
+		p, err := Negotiate(b, supported, list)
+		if err != nil {
+			errs <- err
+			b.Close()
+			return
+		}
+		agreed <- p
+	}()
+	return a, agreed, errs
+}
+
+func TestSelectProto(t *testing.T) {
+	c, agreed, errs := negotiatePipe("/a", "/b")
+	defer c.Close()
+
+	if err := SelectProto(c, "/b"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-agreed:
+		if p != "/b" {
+			t.Fatalf("listener agreed on %s", p)
+		}
+	case err := <-errs:
+		t.Fatal(err)
+	}
+
+	// the stream is the protocol's now.
+	go c.Write([]byte("data"))
+}
+
+func TestSelectProtoNotSupported(t *testing.T) {
+	c, _, errs := negotiatePipe("/a")
+
+	if err := SelectProto(c, "/nope"); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+
+	// the listener waits for another proposal, until we give up.
+	c.Close()
+	if err := <-errs; err == nil {
+		t.Fatal("listener should fail once the dialer closes")
+	}
+}
+
+func TestSelectOneOfVersions(t *testing.T) {
+	c, agreed, _ := negotiatePipe("/dht/1.0.0")
+	defer c.Close()
+
+	p, err := SelectOneOf(c, "/dht/2.0.0", "/dht/1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != "/dht/1.0.0" {
+		t.Fatalf("selected %s", p)
+	}
+	if p2 := <-agreed; p2 != p {
+		t.Fatalf("listener agreed on %s, dialer on %s", p2, p)
+	}
+}
+
+func TestLs(t *testing.T) {
+	c, _, _ := negotiatePipe("/a", "/b", "/c")
+	defer c.Close()
+
+	ids, err := Ls(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != "/a" || ids[1] != "/b" || ids[2] != "/c" {
+		t.Fatalf("wrong protocol list: %v", ids)
+	}
+}
+
+func TestNegotiateWrongVersion(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := Negotiate(b, func(string) bool { return true }, nil)
+		errs <- err
+		b.Close()
+	}()
+
+	go func() {
+		ReadHeader(a) // the listener's multistream header.
+	}()
+	if err := WriteHeader(a, "/multistream/0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != ErrIncorrectVersion {
+		t.Fatalf("expected ErrIncorrectVersion, got %v", err)
+	}
+}
@@ -48,28 +48,34 @@ func (m *Mux) Protocols() []ID {
 	return l
 }
 
-// readHeader reads the stream and returns the next Handler function
-// according to the muxer encoding.
-func (m *Mux) readHeader(s io.Reader) (ID, inet.StreamHandler, error) {
-	// log.Error("ReadProtocolHeader")
-	p, err := ReadHeader(s)
+// negotiate agrees with the other side of the stream on a protocol we
+// have a handler for, and returns that handler. Unknown protocols are
+// answered with NA, so the other side fails fast or tries another one.
+func (m *Mux) negotiate(s io.ReadWriter) (ID, inet.StreamHandler, error) {
+	p, err := Negotiate(s, m.supports, m.Protocols)
 	if err != nil {
 		return "", nil, err
 	}
 
-	// log.Debug("readHeader got:", p)
 	m.lock.RLock()
 	h, found := m.handlers[p]
+	if !found {
+		h = m.defaultHandler
+	}
 	m.lock.RUnlock()
 
-	switch {
-	case !found && m.defaultHandler != nil:
-		return p, m.defaultHandler, nil
-	case !found && m.defaultHandler == nil:
+	if h == nil { // handler removed while negotiating.
 		return p, nil, fmt.Errorf("%s no handler with name: %s (%d)", m, p, len(p))
-	default:
-		return p, h, nil
 	}
+	return p, h, nil
+}
+
+// supports returns whether the mux handles protocol p.
+func (m *Mux) supports(p ID) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	_, found := m.handlers[p]
+	return found || m.defaultHandler != nil
 }
 
 // String returns the muxer's printing representation
@@ -94,19 +100,19 @@ func (m *Mux) SetHandler(p ID, h inet.StreamHandler) {
 	m.lock.Unlock()
 }
 
-// Handle reads the next name off the Stream, and calls a handler function
+// Handle negotiates the protocol of the Stream, and calls its handler function
 // This is done in its own goroutine, to avoid blocking the caller.
 func (m *Mux) Handle(s inet.Stream) {
 	go m.HandleSync(s)
 }
 
-// HandleSync reads the next name off the Stream, and calls a handler function
-// This is done synchronously. The handler function will return before
-// HandleSync returns.
+// HandleSync negotiates the protocol of the Stream, and calls its handler
+// function. This is done synchronously. The handler function will return
+// before HandleSync returns.
 func (m *Mux) HandleSync(s inet.Stream) {
 	ctx := context.Background()
 
-	name, handler, err := m.readHeader(s)
+	name, handler, err := m.negotiate(s)
 	if err != nil {
 		err = fmt.Errorf("protocol mux error: %s", err)
 		log.Error(err)
@@ -0,0 +1,74 @@
+package protocol
+
+import (
+	"io"
+
+	ms "github.com/jbenet/go-ipfs/p2p/protocol/multistream"
+)
+
+// Protocol negotiation, multistream style. See package multistream.
+const (
+	// MultistreamID starts every negotiation.
+	MultistreamID ID = ms.ProtocolID
+
+	// NA is the listener's answer to a protocol it does not support.
+	NA ID = ms.NA
+
+	// LS asks the listener for the list of protocols it supports.
+	LS ID = ms.LS
+)
+
+// ErrNotSupported is returned when the other side answered NA to all of
+// the protocols proposed.
+var ErrNotSupported = ms.ErrNotSupported
+
+// ErrIncorrectVersion is returned when the other side does not speak our
+// version of multistream.
+var ErrIncorrectVersion = ms.ErrIncorrectVersion
+
+// SelectProto negotiates protocol id on rw, as the dialer. It returns
+// ErrNotSupported if the other side does not handle it.
+func SelectProto(rw io.ReadWriter, id ID) error {
+	return ms.SelectProto(rw, string(id))
+}
+
+// SelectOneOf negotiates one of ids on rw, as the dialer. ids are proposed
+// in order, so the first one supported by the other side is returned.
+// This is how peers agree on a protocol version.
+func SelectOneOf(rw io.ReadWriter, ids ...ID) (ID, error) {
+	id, err := ms.SelectOneOf(rw, idStrings(ids)...)
+	return ID(id), err
+}
+
+// Ls asks the other side of rw for the protocols it handles, as the
+// dialer.
+func Ls(rw io.ReadWriter) ([]ID, error) {
+	ss, err := ms.Ls(rw)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]ID, len(ss))
+	for i, s := range ss {
+		ids[i] = ID(s)
+	}
+	return ids, nil
+}
+
+// Negotiate runs the listener side of the negotiation on rw. supported
+// reports whether a proposed protocol is handled; list returns all of the
+// handled protocols, for "ls". Negotiate returns the protocol agreed on,
+// or an error once the dialer gives up (closes the stream).
+func Negotiate(rw io.ReadWriter, supported func(ID) bool, list func() []ID) (ID, error) {
+	id, err := ms.Negotiate(rw,
+		func(s string) bool { return supported(ID(s)) },
+		func() []string { return idStrings(list()) })
+	return ID(id), err
+}
+
+func idStrings(ids []ID) []string {
+	ss := make([]string, len(ids))
+	for i, id := range ids {
+		ss[i] = string(id)
+	}
+	return ss
+}
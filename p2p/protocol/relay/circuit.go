@@ -0,0 +1,303 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+
+	host "github.com/jbenet/go-ipfs/p2p/host"
+	inet "github.com/jbenet/go-ipfs/p2p/net"
+	addrutil "github.com/jbenet/go-ipfs/p2p/net/swarm/addr"
+	transport "github.com/jbenet/go-ipfs/p2p/net/transport"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
+)
+
+// Relayed connections (circuits) carry a whole connection between two
+// peers through a relay, so the swarm can secure and multiplex it like
+// any other. The source asks the relay to hop to the target:
+//
+//   source --> relay   /ipfs/relay/hop/1.0.0
+//                      <multihash src id>
+//                      <multihash dst id>
+//   relay --> target   /ipfs/relay/stop/1.0.0
+//                      <multihash src id>
+//                      <multihash dst id>
+//   relay <-- target   ok
+//   source <-- relay   ok
+//   <connection data>
+//
+// Failures are answered with an error message instead of ok. Only relays
+// register HopID, so identify tells us which peers are relays.
+const (
+	// HopID is the protocol.ID peers use to ask a relay for a circuit.
+	HopID protocol.ID = "/ipfs/relay/hop/1.0.0"
+
+	// StopID is the protocol.ID relays use to deliver a circuit.
+	StopID protocol.ID = "/ipfs/relay/stop/1.0.0"
+)
+
+// statusOK answers circuit requests that succeeded.
+const statusOK protocol.ID = "ok"
+
+// CircuitTimeout bounds the setup of a circuit.
+var CircuitTimeout = 30 * time.Second
+
+func writeStatus(s inet.Stream, err error) error {
+	if err == nil {
+		return protocol.WriteHeader(s, statusOK)
+	}
+	return protocol.WriteHeader(s, protocol.ID(err.Error()))
+}
+
+func readStatus(s inet.Stream) error {
+	st, err := protocol.ReadHeader(s)
+	if err != nil {
+		return err
+	}
+	if st != statusOK {
+		return errors.New(string(st))
+	}
+	return nil
+}
+
+// transportNetwork is implemented by networks that can dial and listen
+// with more transports, like the swarm.
+type transportNetwork interface {
+	AddTransport(t transport.Transport, laddrs ...ma.Multiaddr) error
+}
+
+// Transport dials and accepts relayed connections. It is a
+// transport.Transport for relayed addresses, see addrutil.IsRelayAddr.
+type Transport struct {
+	host host.Host
+
+	lk   sync.Mutex
+	list *circuitListener
+}
+
+func newTransport(h host.Host) *Transport {
+	t := &Transport{host: h}
+	h.SetStreamHandler(StopID, t.stopHandler)
+	return t
+}
+
+// Matches returns whether a is a relayed address.
+func (t *Transport) Matches(a ma.Multiaddr) bool {
+	return addrutil.IsRelayAddr(a)
+}
+
+// Dial opens a circuit to the target of raddr, through its relay.
+func (t *Transport) Dial(ctx context.Context, raddr ma.Multiaddr, d manet.Dialer) (manet.Conn, error) {
+	relayAddr, relay, target, err := addrutil.SplitRelayAddr(raddr)
+	if err != nil {
+		return nil, err
+	}
+	if target == "" {
+		return nil, fmt.Errorf("relayed address without a target: %s", raddr)
+	}
+
+	// the relay may come with the address to reach it at.
+	if parts := ma.Split(relayAddr); len(parts) > 1 {
+		t.host.Peerstore().AddAddress(relay, ma.Join(parts[:len(parts)-1]...), peer.TempAddrTTL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, CircuitTimeout)
+	defer cancel()
+
+	type result struct {
+		s   inet.Stream
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		s, err := t.openCircuit(relay, target)
+		done <- result{s, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, fmt.Errorf("relay %s: %s", relay, r.err)
+		}
+		laddr, _ := addrutil.RelayAddrTo(relayAddr.Encapsulate(addrutil.RelayListenAddr), t.host.ID())
+		return newCircuitConn(r.s, laddr, raddr), nil
+	case <-ctx.Done():
+		go func() { // do not leak the circuit.
+			if r := <-done; r.s != nil {
+				r.s.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *Transport) openCircuit(relay, target peer.ID) (inet.Stream, error) {
+	s, err := t.host.NewStream(HopID, relay)
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteHeader(s, t.host.ID(), target); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := readStatus(s); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Listen accepts relayed connections. laddr must be
+// addrutil.RelayListenAddr.
+func (t *Transport) Listen(laddr ma.Multiaddr) (manet.Listener, error) {
+	if !laddr.Equal(addrutil.RelayListenAddr) {
+		return nil, fmt.Errorf("relay transport cannot listen on %s", laddr)
+	}
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	if t.list != nil {
+		return nil, errors.New("relay transport is already listening")
+	}
+	t.list = &circuitListener{
+		t:        t,
+		incoming: make(chan manet.Conn),
+		closed:   make(chan struct{}),
+	}
+	return t.list, nil
+}
+
+// stopHandler handles circuits relays deliver to us.
+func (t *Transport) stopHandler(s inet.Stream) {
+	relay := s.Conn().RemotePeer()
+	src, dst, err := ReadHeader(s)
+	if err != nil {
+		log.Debugf("bad circuit header from %s: %s", relay, err)
+		s.Close()
+		return
+	}
+
+	t.lk.Lock()
+	l := t.list
+	t.lk.Unlock()
+
+	switch {
+	case dst != t.host.ID():
+		err = fmt.Errorf("circuit for %s delivered to %s", dst, t.host.ID())
+	case l == nil:
+		err = errors.New("not accepting relayed connections")
+	}
+	if err != nil {
+		writeStatus(s, err)
+		s.Close()
+		return
+	}
+	if err := writeStatus(s, nil); err != nil {
+		s.Close()
+		return
+	}
+
+	raddr, err := ma.NewMultiaddr("/ipfs/" + relay.Pretty() + "/ipfs-relay/ipfs/" + src.Pretty())
+	if err != nil {
+		s.Close()
+		return
+	}
+	laddr, _ := ma.NewMultiaddr("/ipfs/" + relay.Pretty() + "/ipfs-relay/ipfs/" + dst.Pretty())
+	log.Debugf("%s accepted circuit from %s through %s", dst, src, relay)
+
+	select {
+	case l.incoming <- newCircuitConn(s, laddr, raddr):
+	case <-l.closed:
+		s.Close()
+	}
+}
+
+// circuitListener hands out the circuits delivered to the Transport.
+type circuitListener struct {
+	t         *Transport
+	incoming  chan manet.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+var errListenerClosed = errors.New("relay listener closed")
+
+func (l *circuitListener) Accept() (manet.Conn, error) {
+	select {
+	case c := <-l.incoming:
+		return c, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *circuitListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		l.t.lk.Lock()
+		if l.t.list == l {
+			l.t.list = nil
+		}
+		l.t.lk.Unlock()
+	})
+	return nil
+}
+
+func (l *circuitListener) Multiaddr() ma.Multiaddr {
+	return addrutil.RelayListenAddr
+}
+
+func (l *circuitListener) Addr() net.Addr {
+	return circuitAddr{addrutil.RelayListenAddr}
+}
+
+func (l *circuitListener) NetListener() net.Listener {
+	return netListener{l}
+}
+
+// netListener is a circuitListener as a net.Listener.
+type netListener struct {
+	*circuitListener
+}
+
+func (l netListener) Accept() (net.Conn, error) {
+	return l.circuitListener.Accept()
+}
+
+// circuitAddr is a relayed address as a net.Addr.
+type circuitAddr struct {
+	ma.Multiaddr
+}
+
+func (a circuitAddr) Network() string {
+	return "ipfs-relay"
+}
+
+// circuitConn is a connection carried by a relayed stream.
+type circuitConn struct {
+	inet.Stream
+	laddr ma.Multiaddr
+	raddr ma.Multiaddr
+}
+
+func newCircuitConn(s inet.Stream, laddr, raddr ma.Multiaddr) *circuitConn {
+	return &circuitConn{Stream: s, laddr: laddr, raddr: raddr}
+}
+
+func (c *circuitConn) LocalMultiaddr() ma.Multiaddr  { return c.laddr }
+func (c *circuitConn) RemoteMultiaddr() ma.Multiaddr { return c.raddr }
+func (c *circuitConn) LocalAddr() net.Addr           { return circuitAddr{c.laddr} }
+func (c *circuitConn) RemoteAddr() net.Addr          { return circuitAddr{c.raddr} }
+
+// Streams have no deadlines: circuits are bounded by the connection to
+// the relay, and by the contexts of the protocols running over them.
+func (c *circuitConn) SetDeadline(t time.Time) error      { return nil }
+func (c *circuitConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *circuitConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,166 @@
+package relay_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	bhost "github.com/jbenet/go-ipfs/p2p/host/basic"
+	inet "github.com/jbenet/go-ipfs/p2p/net"
+	addrutil "github.com/jbenet/go-ipfs/p2p/net/swarm/addr"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
+	relay "github.com/jbenet/go-ipfs/p2p/protocol/relay"
+	testutil "github.com/jbenet/go-ipfs/p2p/test/util"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+)
+
+// relayedTarget connects target to the relay, and returns the addresses
+// it advertises through it.
+func relayedTarget(t *testing.T, ctx context.Context, target, rel *bhost.BasicHost) []ma.Multiaddr {
+	if err := target.Connect(ctx, rel.Peerstore().PeerInfo(rel.ID())); err != nil {
+		t.Fatal(err)
+	}
+
+	var addrs []ma.Multiaddr
+	for _, a := range target.Addrs() {
+		if addrutil.IsRelayAddr(a) {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+func echoHandler(s inet.Stream) {
+	io.Copy(s, s)
+	s.Close()
+}
+
+func TestCircuitDial(t *testing.T) {
+	ctx := context.Background()
+	n1 := testutil.GenHostSwarm(t, ctx)
+	n2 := testutil.GenHostSwarm(t, ctx)
+	n3 := testutil.GenHostSwarm(t, ctx)
+	n2.Relay().EnableHop(relay.Limits{})
+	n3.SetStreamHandler(protocol.TestingID, echoHandler)
+
+	// n3 learns n2 is a relay through identify.
+	raddrs := relayedTarget(t, ctx, n3, n2)
+	if len(raddrs) != 1 || raddrs[0].String() != "/ipfs/"+n2.ID().Pretty()+"/ipfs-relay" {
+		t.Fatalf("n3 should advertise a relayed address through n2, got %s", raddrs)
+	}
+	if err := n1.Connect(ctx, n2.Peerstore().PeerInfo(n2.ID())); err != nil {
+		t.Fatal(err)
+	}
+
+	// n1 knows n3 only by its relayed address.
+	if err := n1.Connect(ctx, peer.PeerInfo{ID: n3.ID(), Addrs: raddrs}); err != nil {
+		t.Fatal(err)
+	}
+	conns := n1.Network().ConnsToPeer(n3.ID())
+	if len(conns) != 1 || !addrutil.IsRelayAddr(conns[0].RemoteMultiaddr()) {
+		t.Fatalf("expected a relayed connection, got %v", conns)
+	}
+
+	s, err := n1.NewStream(protocol.TestingID, n3.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	msg := []byte("relayed, but secured end to end")
+	if _, err := s.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(s, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, msg) {
+		t.Fatalf("echoed %q", buf)
+	}
+
+	if n3.Network().Connectedness(n1.ID()) != inet.Connected {
+		t.Fatal("n3 should see the relayed connection from n1")
+	}
+}
+
+func TestCircuitNeedsHop(t *testing.T) {
+	ctx := context.Background()
+	n1 := testutil.GenHostSwarm(t, ctx)
+	n2 := testutil.GenHostSwarm(t, ctx)
+	n3 := testutil.GenHostSwarm(t, ctx)
+
+	if raddrs := relayedTarget(t, ctx, n3, n2); len(raddrs) != 0 {
+		t.Fatalf("n2 is not a relay, but n3 advertises %s", raddrs)
+	}
+
+	a, err := ma.NewMultiaddr("/ipfs/" + n2.ID().Pretty() + "/ipfs-relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n1.Connect(ctx, n2.Peerstore().PeerInfo(n2.ID()))
+	if err := n1.Connect(ctx, peer.PeerInfo{ID: n3.ID(), Addrs: []ma.Multiaddr{a}}); err == nil {
+		t.Fatal("should not relay through a peer without hop enabled")
+	}
+}
+
+func TestCircuitLimits(t *testing.T) {
+	ctx := context.Background()
+	n1 := testutil.GenHostSwarm(t, ctx)
+	n2 := testutil.GenHostSwarm(t, ctx)
+	n3 := testutil.GenHostSwarm(t, ctx)
+	n4 := testutil.GenHostSwarm(t, ctx)
+	n2.Relay().EnableHop(relay.Limits{MaxCircuits: 1})
+
+	raddrs := relayedTarget(t, ctx, n3, n2)
+	for _, n := range []*bhost.BasicHost{n1, n4} {
+		if err := n.Connect(ctx, n2.Peerstore().PeerInfo(n2.ID())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := n1.Connect(ctx, peer.PeerInfo{ID: n3.ID(), Addrs: raddrs}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n4.Connect(ctx, peer.PeerInfo{ID: n3.ID(), Addrs: raddrs}); err == nil {
+		t.Fatal("relay should refuse circuits past its limit")
+	}
+}
+
+func TestCircuitBandwidth(t *testing.T) {
+	ctx := context.Background()
+	n1 := testutil.GenHostSwarm(t, ctx)
+	n2 := testutil.GenHostSwarm(t, ctx)
+	n3 := testutil.GenHostSwarm(t, ctx)
+	n2.Relay().EnableHop(relay.Limits{MaxBandwidth: 64 * 1024})
+	n3.SetStreamHandler(protocol.TestingID, echoHandler)
+
+	raddrs := relayedTarget(t, ctx, n3, n2)
+	if err := n1.Connect(ctx, n2.Peerstore().PeerInfo(n2.ID())); err != nil {
+		t.Fatal(err)
+	}
+	if err := n1.Connect(ctx, peer.PeerInfo{ID: n3.ID(), Addrs: raddrs}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := n1.NewStream(protocol.TestingID, n3.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// 64KB at 64KB/s should take about a second, on top of the overhead.
+	data := make([]byte, 64*1024)
+	start := time.Now()
+	go s.Write(data)
+	if _, err := io.ReadFull(s, make([]byte, len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took < 500*time.Millisecond {
+		t.Fatalf("relay should limit bandwidth, but echo took %s", took)
+	}
+}
@@ -0,0 +1,111 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+)
+
+// Limits bound the connections a relay carries for other peers.
+type Limits struct {
+	// MaxCircuits is the number of connections relayed at once.
+	MaxCircuits int
+
+	// MaxCircuitsPerPeer is the number of connections relayed at once
+	// from or to any one peer.
+	MaxCircuitsPerPeer int
+
+	// MaxBandwidth is the rate, in bytes per second, at which each relayed
+	// connection may carry data, in each direction. Zero means unlimited.
+	MaxBandwidth int
+}
+
+// DefaultLimits are the limits of relays not configured otherwise.
+var DefaultLimits = Limits{
+	MaxCircuits:        64,
+	MaxCircuitsPerPeer: 4,
+	MaxBandwidth:       128 * 1024,
+}
+
+// ErrTooManyCircuits is returned when relaying a connection would exceed
+// the relay's Limits.
+var ErrTooManyCircuits = errors.New("relay: too many relayed connections")
+
+// circuits counts the connections being relayed, by peer.
+type circuits struct {
+	total  int
+	byPeer map[peer.ID]int
+}
+
+// acquire reserves a circuit between src and dst, within l.
+func (c *circuits) acquire(l Limits, src, dst peer.ID) error {
+	if c.byPeer == nil {
+		c.byPeer = make(map[peer.ID]int)
+	}
+	if l.MaxCircuits > 0 && c.total >= l.MaxCircuits {
+		return ErrTooManyCircuits
+	}
+	if l.MaxCircuitsPerPeer > 0 {
+		for _, p := range []peer.ID{src, dst} {
+			if c.byPeer[p] >= l.MaxCircuitsPerPeer {
+				return fmt.Errorf("%s for peer %s", ErrTooManyCircuits, p)
+			}
+		}
+	}
+	c.total++
+	c.byPeer[src]++
+	c.byPeer[dst]++
+	return nil
+}
+
+func (c *circuits) release(src, dst peer.ID) {
+	c.total--
+	for _, p := range []peer.ID{src, dst} {
+		if c.byPeer[p]--; c.byPeer[p] <= 0 {
+			delete(c.byPeer, p)
+		}
+	}
+}
+
+// limitedCopy copies from src to dst like io.Copy, at no more than rate
+// bytes per second. A rate of zero does not limit.
+func limitedCopy(dst io.Writer, src io.Reader, rate int) (int64, error) {
+	if rate <= 0 {
+		return io.Copy(dst, src)
+	}
+
+	// chunks of at most a tenth of a second worth of data, so the rate is
+	// smooth rather than bursty.
+	size := rate / 10
+	if size < 512 {
+		size = 512
+	}
+	buf := make([]byte, size)
+
+	start := time.Now()
+	var total int64
+	for {
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return total, err
+			}
+			total += int64(n)
+
+			// wait until the data sent so far is within the rate.
+			due := start.Add(time.Duration(total) * time.Second / time.Duration(rate))
+			if wait := due.Sub(time.Now()); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		if rerr == io.EOF {
+			return total, nil
+		}
+		if rerr != nil {
+			return total, rerr
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package relay
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"sync"
 
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 	mh "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 
 	host "github.com/jbenet/go-ipfs/p2p/host"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
+	addrutil "github.com/jbenet/go-ipfs/p2p/net/swarm/addr"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	protocol "github.com/jbenet/go-ipfs/p2p/protocol"
 	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
@@ -29,9 +33,18 @@ const ID protocol.ID = "/ipfs/relay"
 //   <multihash dst id>
 //   <data stream>
 //
+// Streams are only relayed for others once EnableHop is called. The
+// service also dials and accepts relayed connections (see circuit.go),
+// if the host's network supports more transports.
 type RelayService struct {
-	host    host.Host
-	handler inet.StreamHandler // for streams sent to us locally.
+	host      host.Host
+	handler   inet.StreamHandler // for streams sent to us locally.
+	transport *Transport
+
+	lk       sync.Mutex
+	hop      bool
+	limits   Limits
+	circuits circuits
 }
 
 func NewRelayService(h host.Host, sh inet.StreamHandler) *RelayService {
@@ -40,9 +53,72 @@ func NewRelayService(h host.Host, sh inet.StreamHandler) *RelayService {
 		handler: sh,
 	}
 	h.SetStreamHandler(ID, s.requestHandler)
+
+	if tn, ok := h.Network().(transportNetwork); ok {
+		s.transport = newTransport(h)
+		if err := tn.AddTransport(s.transport, addrutil.RelayListenAddr); err != nil {
+			log.Errorf("failed to listen for relayed connections: %s", err)
+		}
+	}
 	return s
 }
 
+// ErrNotRelay is returned when asking a peer that is not a relay to relay.
+var ErrNotRelay = errors.New("relay: peer does not relay for others")
+
+// EnableHop makes the service relay streams and connections between
+// other peers, within limits.
+func (rs *RelayService) EnableHop(limits Limits) {
+	rs.lk.Lock()
+	rs.hop = true
+	rs.limits = limits
+	rs.lk.Unlock()
+	rs.host.SetStreamHandler(HopID, rs.hopHandler)
+}
+
+// RelayAddrs returns the relayed addresses we can be reached at: one for
+// each connected peer which identified itself as a relay.
+func (rs *RelayService) RelayAddrs() []ma.Multiaddr {
+	if rs.transport == nil {
+		return nil
+	}
+
+	var addrs []ma.Multiaddr
+	for _, p := range rs.host.Network().Peers() {
+		if !isRelay(rs.host.Peerstore(), p) {
+			continue
+		}
+		a, err := ma.NewMultiaddr("/ipfs/" + p.Pretty() + "/ipfs-relay")
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, a)
+	}
+	return addrs
+}
+
+// isRelay returns whether identify told us p relays for others.
+func isRelay(ps peer.Peerstore, p peer.ID) bool {
+	v, _ := ps.Version(p)
+	return v.SupportsProtocol(string(HopID))
+}
+
+// acquireCircuit reserves a relayed connection between src and dst.
+func (rs *RelayService) acquireCircuit(src, dst peer.ID) (Limits, error) {
+	rs.lk.Lock()
+	defer rs.lk.Unlock()
+	if !rs.hop {
+		return Limits{}, ErrNotRelay
+	}
+	return rs.limits, rs.circuits.acquire(rs.limits, src, dst)
+}
+
+func (rs *RelayService) releaseCircuit(src, dst peer.ID) {
+	rs.lk.Lock()
+	rs.circuits.release(src, dst)
+	rs.lk.Unlock()
+}
+
 // requestHandler is the function called by clients
 func (rs *RelayService) requestHandler(s inet.Stream) {
 	if err := rs.handleStream(s); err != nil {
@@ -83,23 +159,96 @@ func (rs *RelayService) consumeStream(s inet.Stream) error {
 
 // pipeStream relays over a stream to a remote peer. It's like `cat`
 func (rs *RelayService) pipeStream(src, dst peer.ID, s inet.Stream) error {
+	limits, err := rs.acquireCircuit(src, dst)
+	if err != nil {
+		return err
+	}
+	defer rs.releaseCircuit(src, dst)
+
 	s2, err := rs.openStreamToPeer(dst)
 	if err != nil {
 		return fmt.Errorf("failed to open stream to peer: %s -- %s", dst, err)
 	}
+	defer s2.Close()
+
+	if err := WriteHeader(s2, src, dst); err != nil {
+		return err
+	}
+	return rs.pipe(s, s2, src, dst, limits)
+}
+
+// hopHandler relays circuits to their target, see circuit.go.
+func (rs *RelayService) hopHandler(s inet.Stream) {
+	defer s.Close()
+	if err := rs.hopCircuit(s); err != nil {
+		log.Debugf("%s failed to relay circuit: %s", rs.host.ID(), err)
+	}
+}
+
+func (rs *RelayService) hopCircuit(s inet.Stream) error {
+	src, dst, err := ReadHeader(s)
+	if err != nil {
+		return fmt.Errorf("circuit with bad header: %s", err)
+	}
+
+	local := rs.host.ID()
+	switch {
+	case src != s.Conn().RemotePeer():
+		err = fmt.Errorf("circuit from %s claims to be from %s", s.Conn().RemotePeer(), src)
+	case dst == local || src == dst:
+		err = fmt.Errorf("circuit to %s is not for relaying", dst)
+	case rs.host.Network().Connectedness(dst) != inet.Connected:
+		// we only relay to peers connected to us, which is how NAT'd
+		// peers stay reachable.
+		err = fmt.Errorf("relay has no connection to %s", dst)
+	}
+	if err != nil {
+		writeStatus(s, err)
+		return err
+	}
+
+	limits, err := rs.acquireCircuit(src, dst)
+	if err != nil {
+		writeStatus(s, err)
+		return err
+	}
+	defer rs.releaseCircuit(src, dst)
+
+	s2, err := rs.host.NewStream(StopID, dst)
+	if err != nil {
+		writeStatus(s, err)
+		return err
+	}
+	defer s2.Close()
 
 	if err := WriteHeader(s2, src, dst); err != nil {
+		writeStatus(s, err)
+		return err
+	}
+	if err := readStatus(s2); err != nil {
+		writeStatus(s, err)
 		return err
 	}
+	if err := writeStatus(s, nil); err != nil {
+		return err
+	}
+
+	log.Debugf("%s relaying circuit %s <--> %s", local, src, dst)
+	return rs.pipe(s, s2, src, dst, limits)
+}
 
+// pipe copies between s and s2 until both are done, within limits.
+func (rs *RelayService) pipe(s, s2 inet.Stream, src, dst peer.ID, limits Limits) error {
 	// connect the series of tubes.
 	done := make(chan retio, 2)
 	go func() {
-		n, err := io.Copy(s2, s)
+		n, err := limitedCopy(s2, s, limits.MaxBandwidth)
+		s2.Close() // let dst know src is done.
 		done <- retio{n, err}
 	}()
 	go func() {
-		n, err := io.Copy(s, s2)
+		n, err := limitedCopy(s, s2, limits.MaxBandwidth)
+		s.Close()
 		done <- retio{n, err}
 	}()
 
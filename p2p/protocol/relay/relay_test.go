@@ -23,6 +23,7 @@ func TestRelaySimple(t *testing.T) {
 	n1 := testutil.GenHostSwarm(t, ctx)
 	n2 := testutil.GenHostSwarm(t, ctx)
 	n3 := testutil.GenHostSwarm(t, ctx)
+	n2.Relay().EnableHop(relay.Limits{})
 
 	n1p := n1.ID()
 	n2p := n2.ID()
@@ -60,9 +61,9 @@ func TestRelaySimple(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// ok now the header's there, we can write the next protocol header.
+	// ok now the header's there, we can negotiate the next protocol.
 	log.Debug("write testing header")
-	if err := protocol.WriteHeader(s, protocol.TestingID); err != nil {
+	if err := protocol.SelectProto(s, protocol.TestingID); err != nil {
 		t.Fatal(err)
 	}
 
@@ -108,6 +109,9 @@ func TestRelayAcrossFour(t *testing.T) {
 	n3 := testutil.GenHostSwarm(t, ctx)
 	n4 := testutil.GenHostSwarm(t, ctx)
 	n5 := testutil.GenHostSwarm(t, ctx)
+	n2.Relay().EnableHop(relay.Limits{})
+	n3.Relay().EnableHop(relay.Limits{})
+	n4.Relay().EnableHop(relay.Limits{})
 
 	n1p := n1.ID()
 	n2p := n2.ID()
@@ -155,7 +159,7 @@ func TestRelayAcrossFour(t *testing.T) {
 	}
 
 	log.Debugf("write relay header n1->n4 (%s -> %s)", n1p, n4p)
-	if err := protocol.WriteHeader(s, relay.ID); err != nil {
+	if err := protocol.SelectProto(s, relay.ID); err != nil {
 		t.Fatal(err)
 	}
 	if err := relay.WriteHeader(s, n1p, n4p); err != nil {
@@ -163,16 +167,16 @@ func TestRelayAcrossFour(t *testing.T) {
 	}
 
 	log.Debugf("write relay header n1->n5 (%s -> %s)", n1p, n5p)
-	if err := protocol.WriteHeader(s, relay.ID); err != nil {
+	if err := protocol.SelectProto(s, relay.ID); err != nil {
 		t.Fatal(err)
 	}
 	if err := relay.WriteHeader(s, n1p, n5p); err != nil {
 		t.Fatal(err)
 	}
 
-	// ok now the header's there, we can write the next protocol header.
+	// ok now the header's there, we can negotiate the next protocol.
 	log.Debug("write testing header")
-	if err := protocol.WriteHeader(s, protocol.TestingID); err != nil {
+	if err := protocol.SelectProto(s, protocol.TestingID); err != nil {
 		t.Fatal(err)
 	}
 
@@ -218,6 +222,7 @@ func TestRelayStress(t *testing.T) {
 	n1 := testutil.GenHostSwarm(t, ctx)
 	n2 := testutil.GenHostSwarm(t, ctx)
 	n3 := testutil.GenHostSwarm(t, ctx)
+	n2.Relay().EnableHop(relay.Limits{})
 
 	n1p := n1.ID()
 	n2p := n2.ID()
@@ -255,9 +260,9 @@ func TestRelayStress(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// ok now the header's there, we can write the next protocol header.
+	// ok now the header's there, we can negotiate the next protocol.
 	log.Debug("write testing header")
-	if err := protocol.WriteHeader(s, protocol.TestingID); err != nil {
+	if err := protocol.SelectProto(s, protocol.TestingID); err != nil {
 		t.Fatal(err)
 	}
 
@@ -6,6 +6,7 @@ import (
 	"path"
 	"strings"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	mh "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 	merkledag "github.com/jbenet/go-ipfs/merkledag"
 	u "github.com/jbenet/go-ipfs/util"
@@ -21,8 +22,9 @@ type Resolver struct {
 
 // ResolvePath fetches the node for given path. It uses the first
 // path component as a hash (key) of the first node, then resolves
-// all other components walking the links, with ResolveLinks.
-func (s *Resolver) ResolvePath(fpath string) (*merkledag.Node, error) {
+// all other components walking the links, with ResolveLinks. It fetches
+// the nodes until ctx is done.
+func (s *Resolver) ResolvePath(ctx context.Context, fpath string) (*merkledag.Node, error) {
 	log.Debugf("Resolve: '%s'", fpath)
 	fpath = path.Clean(fpath)
 
@@ -50,21 +52,21 @@ func (s *Resolver) ResolvePath(fpath string) (*merkledag.Node, error) {
 	}
 
 	log.Debug("Resolve dag get.\n")
-	nd, err := s.DAG.Get(u.Key(h))
+	nd, err := s.DAG.Get(ctx, u.Key(h))
 	if err != nil {
 		return nil, err
 	}
 
-	return s.ResolveLinks(nd, parts[1:])
+	return s.ResolveLinks(ctx, nd, parts[1:])
 }
 
 // ResolveLinks iteratively resolves names by walking the link hierarchy.
 // Every node is fetched from the DAGService, resolving the next name.
 // Returns the last node found.
 //
-// ResolveLinks(nd, []string{"foo", "bar", "baz"})
+// ResolveLinks(ctx, nd, []string{"foo", "bar", "baz"})
 // would retrieve "baz" in ("bar" in ("foo" in nd.Links).Links).Links
-func (s *Resolver) ResolveLinks(ndd *merkledag.Node, names []string) (
+func (s *Resolver) ResolveLinks(ctx context.Context, ndd *merkledag.Node, names []string) (
 	nd *merkledag.Node, err error) {
 
 	nd = ndd // dup arg workaround
@@ -91,7 +93,7 @@ func (s *Resolver) ResolveLinks(ndd *merkledag.Node, names []string) (
 
 		if nlink.Node == nil {
 			// fetch object for link and assign to nd
-			nd, err = s.DAG.Get(next)
+			nd, err = s.DAG.Get(ctx, next)
 			if err != nil {
 				return nd, err
 			}
@@ -3,6 +3,7 @@ package pin
 import (
 	"testing"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	ds "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	dssync "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
 	"github.com/jbenet/go-ipfs/blocks/blockstore"
@@ -21,6 +22,8 @@ func randNode() (*mdag.Node, util.Key) {
 }
 
 func TestPinnerBasic(t *testing.T) {
+	ctx := context.Background()
+
 	dstore := dssync.MutexWrap(ds.NewMapDatastore())
 	bstore := blockstore.NewBlockstore(dstore)
 	bserv, err := bs.New(bstore, offline.Exchange(bstore))
@@ -36,7 +39,7 @@ func TestPinnerBasic(t *testing.T) {
 	a, ak := randNode()
 
 	// Pin A{}
-	err = p.Pin(a, false)
+	err = p.Pin(ctx, a, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -58,7 +61,7 @@ func TestPinnerBasic(t *testing.T) {
 	}
 
 	// recursively pin B{A,C}
-	err = p.Pin(b, true)
+	err = p.Pin(ctx, b, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -86,7 +89,7 @@ func TestPinnerBasic(t *testing.T) {
 	}
 
 	// Add D{A,C,E}
-	err = p.Pin(d, true)
+	err = p.Pin(ctx, d, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -101,7 +104,7 @@ func TestPinnerBasic(t *testing.T) {
 	}
 
 	// Test recursive unpin
-	err = p.Unpin(dk, true)
+	err = p.Unpin(ctx, dk, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,42 @@
+package config
+
+// API configures the HTTP API.
+type API struct {
+	// Tokens let clients call the API, with the header
+	// "Authorization: Bearer <Secret>". Local clients need none; once there
+	// are Tokens, remote clients do. Commands that expose the node's
+	// identity, like config, are refused to remote clients all the same.
+	Tokens []APIToken
+
+	// HTTPHeaders are set on the API's responses, e.g.
+	// "Access-Control-Allow-Methods": ["POST"]. Access-Control-Allow-Origin
+	// lists the origins whose pages may call the API, besides the API's own
+	// (like the webui); requests from other pages are refused.
+	HTTPHeaders map[string][]string
+
+	// Hosts are the hosts, like "node.example.com" or "192.168.1.2:5001",
+	// the API answers to besides localhost and the host of Addresses.API.
+	// Requests to others are refused, not to be called by pages resolving
+	// their own names to the API's address. An API listening on 0.0.0.0
+	// needs the hosts remote clients reach it at.
+	Hosts []string
+
+	// Profiling serves the profiles of the daemon, and a dump of its
+	// goroutines, at /debug/pprof and with "ipfs diag profile". Profiles
+	// are costly to take, so they are off by default, and only served to
+	// local clients.
+	Profiling bool
+
+	// Metrics serves the metrics of the daemon at /debug/metrics, to local
+	// clients. Collecting them subscribes to the event log, so they are off
+	// by default.
+	Metrics bool
+}
+
+// APIToken grants the commands of its Scopes: "read", "pin", "name" or
+// "admin", which grants them all.
+type APIToken struct {
+	Name   string // to tell tokens apart in logs
+	Secret string
+	Scopes []string
+}
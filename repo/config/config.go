@@ -19,10 +19,15 @@ type Config struct {
 	Identity  Identity        // local node's peer identity
 	Datastore Datastore       // local node's storage
 	Addresses Addresses       // local node's addresses
+	Routing   Routing         // local node's routing settings
 	Mounts    Mounts          // local node's mount points
 	Version   Version         // local node's version management
 	Bootstrap []BootstrapPeer // local nodes's bootstrap peers
+	Discovery Discovery       // local node's peer discovery mechanisms
+	Swarm     SwarmConfig     // local node's p2p network settings
 	Tour      Tour            // local node's tour position
+	API       API             // local node's HTTP API settings
+	Gateway   Gateway         // local node's HTTP gateway settings
 }
 
 const (
@@ -0,0 +1,15 @@
+package config
+
+// Discovery configures the ways the node finds peers besides bootstrap
+// peers and routing.
+type Discovery struct {
+	MDNS MDNS // local network discovery
+}
+
+// MDNS configures discovery of peers on the local network through mDNS.
+type MDNS struct {
+	Enabled bool
+
+	// Interval is the time in seconds between local network queries.
+	Interval int
+}
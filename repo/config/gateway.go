@@ -0,0 +1,10 @@
+package config
+
+// Gateway configures the HTTP gateway.
+type Gateway struct {
+	// HTTPHeaders are set on the gateway's responses, like in
+	// API.HTTPHeaders. Pages of any origin may load the gateway's content;
+	// Access-Control-Allow-Origin lists the ones whose scripts may read it,
+	// or upload to it.
+	HTTPHeaders map[string][]string
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Profile is a set of changes to a config, for one kind of use of the node.
+type Profile struct {
+	Description string
+	Apply       func(*Config) error
+}
+
+// Profiles are the profiles configs may be changed with, by name.
+var Profiles = map[string]Profile{
+	"server": {
+		Description: "For nodes in data centers: no local network discovery, no NAT port mapping, and no connections to private and link-local addresses.",
+		Apply: func(c *Config) error {
+			c.Discovery.MDNS.Enabled = false
+			c.Swarm.DisableNatPortMap = true
+			c.Swarm.Filters.Deny = appendMissing(c.Swarm.Filters.Deny, privateAddrFilters...)
+			return nil
+		},
+	},
+
+	"lowpower": {
+		Description: "For devices short of CPU, memory or bandwidth: fewer connections, and a DHT client that does not serve other peers.",
+		Apply: func(c *Config) error {
+			if c.Routing.Type == "" || c.Routing.Type == RoutingTypeDHT {
+				c.Routing.Type = RoutingTypeDHTClient
+			}
+			c.Swarm.ConnMgr = ConnMgr{
+				LowWater:    20,
+				HighWater:   40,
+				GracePeriod: 60,
+			}
+			return nil
+		},
+	},
+
+	"test": {
+		Description: "For tests: a swarm on a random local port, no bootstrap peers, no gateway and no local network discovery.",
+		Apply: func(c *Config) error {
+			c.Addresses.Swarm = []string{"/ip4/127.0.0.1/tcp/0"}
+			c.Addresses.Gateway = ""
+			c.Bootstrap = []BootstrapPeer{}
+			c.Discovery.MDNS.Enabled = false
+			return nil
+		},
+	},
+}
+
+// privateAddrFilters are the address ranges not routed on the internet,
+// which servers have no peers in.
+var privateAddrFilters = []string{
+	"/ip4/10.0.0.0/ipcidr/8",
+	"/ip4/100.64.0.0/ipcidr/10",
+	"/ip4/169.254.0.0/ipcidr/16",
+	"/ip4/172.16.0.0/ipcidr/12",
+	"/ip4/192.168.0.0/ipcidr/16",
+	"/ip6/fc00::/ipcidr/7",
+	"/ip6/fe80::/ipcidr/10",
+}
+
+// ApplyProfiles changes c with the named profiles, in order.
+func ApplyProfiles(c *Config, names ...string) error {
+	for _, name := range names {
+		p, ok := Profiles[name]
+		if !ok {
+			return fmt.Errorf("unknown config profile %q, expected one of: %v", name, ProfileNames())
+		}
+		if err := p.Apply(c); err != nil {
+			return fmt.Errorf("applying config profile %q: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// ProfileNames returns the names of the Profiles, in order.
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// appendMissing appends the values not in s yet to s.
+func appendMissing(s []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, e := range s {
+			if e == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s = append(s, v)
+		}
+	}
+	return s
+}
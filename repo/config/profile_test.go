@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+func TestApplyProfiles(t *testing.T) {
+	c := &Config{
+		Routing:   Routing{Type: RoutingTypeDHT},
+		Discovery: Discovery{MDNS: MDNS{Enabled: true}},
+		Swarm: SwarmConfig{
+			Filters: SwarmFilters{Deny: []string{"/ip4/10.0.0.0/ipcidr/8", "/ipfs/QmPeer"}},
+		},
+	}
+	if err := ApplyProfiles(c, "server", "lowpower", "server"); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Discovery.MDNS.Enabled || !c.Swarm.DisableNatPortMap {
+		t.Error("server left mDNS or NAT port mapping on")
+	}
+	if len(c.Swarm.Filters.Deny) != len(privateAddrFilters)+1 || c.Swarm.Filters.Deny[1] != "/ipfs/QmPeer" {
+		t.Errorf("got deny filters %v", c.Swarm.Filters.Deny)
+	}
+	if c.Routing.Type != RoutingTypeDHTClient || c.Swarm.ConnMgr.HighWater != 40 {
+		t.Errorf("lowpower left routing %q and high water %d", c.Routing.Type, c.Swarm.ConnMgr.HighWater)
+	}
+
+	c.Routing.Type = RoutingTypeDelegated
+	if err := ApplyProfiles(c, "lowpower"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Routing.Type != RoutingTypeDelegated {
+		t.Error("lowpower changed delegated routing")
+	}
+
+	if err := ApplyProfiles(c, "bogus"); err == nil {
+		t.Error("applied an unknown profile")
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+// Routing defines configuration options for the node's routing system.
+type Routing struct {
+	// Type sets the routing mode the node runs with:
+	// - "dht" for a full dht node that answers requests from other peers
+	// - "dhtclient" for a node that only queries the dht and never serves it
+	// - "delegated" for a node that sends all routing requests to the
+	//   delegated routing service at DelegateAddress
+	// - "tiered" for a node that queries all the Routers in parallel
+	// An empty Type is treated as "dht".
+	Type string
+
+	// Routers lists the routing types combined by the "tiered" routing
+	// type, in order of preference, e.g. ["delegated", "dht"].
+	Routers []string
+
+	// DelegateAddress is the multiaddr of the delegated routing service
+	// used by the "delegated" routing type. The service is usually another
+	// node running cmd/ipfsrouting.
+	DelegateAddress string
+
+	// DelegateToken is the secret of the API token the delegated routing
+	// service grants us, if it needs one. Finding providers, peers and
+	// values needs the "read" scope, providing the "pin" scope, and
+	// publishing values the "name" scope.
+	DelegateToken string
+}
+
+// supported Routing.Type values
+const (
+	// RoutingTypeDHT runs a full dht node.
+	RoutingTypeDHT = "dht"
+
+	// RoutingTypeDHTClient runs the dht in client-only mode.
+	RoutingTypeDHTClient = "dhtclient"
+
+	// RoutingTypeDelegated delegates routing to another node over HTTP.
+	RoutingTypeDelegated = "delegated"
+
+	// RoutingTypeTiered combines several routing types.
+	RoutingTypeTiered = "tiered"
+)
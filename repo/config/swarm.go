@@ -0,0 +1,66 @@
+package config
+
+// SwarmConfig configures the node's p2p network.
+type SwarmConfig struct {
+	// DisableNatPortMap stops the node from asking the NAT gateway to
+	// forward its swarm ports.
+	DisableNatPortMap bool
+
+	// EnableRelayHop makes the node relay connections for other peers,
+	// within RelayLimits.
+	EnableRelayHop bool
+
+	// RelayLimits bound the connections the node relays. Left empty, the
+	// defaults apply.
+	RelayLimits RelayLimits
+
+	// ConnMgr keeps the number of open connections in check.
+	ConnMgr ConnMgr
+
+	// Filters decide which peers and addresses the node connects to.
+	Filters SwarmFilters
+
+	// PrivateNetwork applies when the repo has a swarm.key, and the node is
+	// in the private network of the key.
+	PrivateNetwork PrivateNetwork
+
+	// DialTLS makes the node negotiate TLS 1.3 rather than secio on the
+	// connections it dials. Peers running older versions do not negotiate,
+	// and are dialed again with secio. Connections are accepted either way.
+	// Nodes built with go releases before 1.17 only speak secio.
+	DialTLS bool
+}
+
+// PrivateNetwork configures nodes in a private network.
+type PrivateNetwork struct {
+	// DisablePublicBootstrap skips the default bootstrap peers, which are
+	// in the public network, and cannot be reached.
+	DisablePublicBootstrap bool
+}
+
+// SwarmFilters list peers, like "/ipfs/<peer-id>", and address ranges, like
+// "/ip4/10.0.0.0/ipcidr/8". The node neither dials nor accepts connections
+// from the denied ones. In AllowlistOnly mode, it only connects to the
+// allowed ones; Deny still wins over Allow.
+type SwarmFilters struct {
+	Deny          []string
+	Allow         []string
+	AllowlistOnly bool
+}
+
+// ConnMgr configures the connection manager. Once there are more than
+// HighWater connections, the least valuable ones are closed, down to
+// LowWater. A HighWater of zero keeps all connections.
+type ConnMgr struct {
+	LowWater    int
+	HighWater   int
+	GracePeriod int // in seconds, during which new connections are kept
+}
+
+// RelayLimits bound the connections a relay carries for other peers.
+// Zero fields of a non-empty RelayLimits mean unlimited.
+type RelayLimits struct {
+	MaxCircuits        int // connections relayed at once
+	MaxCircuitsPerPeer int // connections relayed at once for any one peer
+	MaxBandwidth       int // bytes per second, per connection and direction
+}
@@ -91,12 +91,7 @@ func (c *ConfigComponent) SetConfigKey(key string, value interface{}) error {
 	if err := common.MapSetKV(mapconf, key, value); err != nil {
 		return err
 	}
-	if err := serialize.WriteConfigFile(filename, mapconf); err != nil {
-		return err
-	}
-	// in order to get the updated values, read updated config from the
-	// file-system.
-	conf, err := config.FromMap(mapconf)
+	conf, err := writeConfigMap(filename, mapconf)
 	if err != nil {
 		return err
 	}
@@ -140,9 +135,22 @@ func (r *ConfigComponent) setConfigUnsynced(updated *config.Config) error {
 	for k, v := range m {
 		mapconf[k] = v
 	}
-	if err := serialize.WriteConfigFile(configFilename, mapconf); err != nil {
+	if _, err := writeConfigMap(configFilename, mapconf); err != nil {
 		return err
 	}
 	*r.config = *updated // copy so caller cannot modify this private config
 	return nil
 }
+
+// writeConfigMap writes mapconf to the config file, once it is known to
+// decode into a config, and returns the config.
+func writeConfigMap(filename string, mapconf map[string]interface{}) (*config.Config, error) {
+	conf, err := config.FromMap(mapconf)
+	if err != nil {
+		return nil, err
+	}
+	if err := serialize.WriteConfigFile(filename, mapconf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"sync"
 
 	ds "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
@@ -245,6 +247,41 @@ func (r *FSRepo) Datastore() ds.ThreadSafeDatastore {
 	return d
 }
 
+// SwarmKeyFile is the name of the file the key of a private network is
+// kept in, in the repo.
+const SwarmKeyFile = "swarm.key"
+
+// SwarmKey returns the contents of the swarm.key file, or nil if there is
+// none.
+func (r *FSRepo) SwarmKey() ([]byte, error) {
+	b, err := ioutil.ReadFile(path.Join(r.path, SwarmKeyFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// DiskUsage returns the size in bytes of the files of the datastore, as
+// told by the file system: blocks are not read to be measured.
+func (r *FSRepo) DiskUsage() (uint64, error) {
+	var size uint64
+	root := path.Join(r.path, component.DefaultDataStoreDirectory)
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// removed by a compaction since listed.
+				return nil
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += uint64(fi.Size())
+		}
+		return nil
+	})
+	return size, err
+}
+
 var _ io.Closer = &FSRepo{}
 var _ repo.Repo = &FSRepo{}
 
@@ -139,3 +139,47 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+func TestSetConfigKeyValidates(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("", t)
+	assert.Nil(Init(path, &config.Config{}), t)
+
+	r := At(path)
+	assert.Nil(r.Open(), t)
+	defer r.Close()
+
+	assert.Nil(r.SetConfigKey("Swarm.ConnMgr.HighWater", 100), t, "an int should be set")
+	assert.Err(r.SetConfigKey("Swarm.ConnMgr.HighWater", "many"), t, "a string should not be set on an int")
+	assert.Err(r.SetConfigKey("Swarm", []interface{}{}), t, "an array should not be set on a struct")
+	assert.Nil(r.SetConfigKey("Unknown.Key", "kept"), t, "unknown keys should be set")
+
+	v, err := r.GetConfigKey("Swarm.ConnMgr.HighWater")
+	assert.Nil(err, t)
+	assert.True(v == 100.0, t, "the refused values should not be written")
+	assert.True(r.Config().Swarm.ConnMgr.HighWater == 100, t, "the config should be updated")
+
+	assert.Nil(r.SetConfigKey("Swarm.ConnMgr.HighWater", 200), t, "writes should work next to unknown keys")
+	v, err = r.GetConfigKey("Unknown.Key")
+	assert.Nil(err, t)
+	assert.True(v == "kept", t, "unknown keys should be kept across writes")
+}
+
+func TestDiskUsage(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("", t)
+	assert.Nil(Init(path, &config.Config{}), t)
+	r := At(path)
+	assert.Nil(r.Open(), t)
+	defer r.Close()
+
+	before, err := r.DiskUsage()
+	assert.Nil(err, t)
+	value := bytes.Repeat([]byte{'x'}, 1<<16)
+	assert.Nil(r.Datastore().Put(datastore.NewKey("foo"), value), t)
+	after, err := r.DiskUsage()
+	assert.Nil(err, t)
+	if after < before+uint64(len(value)) {
+		t.Fatalf("disk usage went from %d to %d after putting %d bytes", before, after, len(value))
+	}
+}
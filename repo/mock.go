@@ -13,6 +13,7 @@ var errTODO = errors.New("TODO")
 type Mock struct {
 	C config.Config
 	D ds.ThreadSafeDatastore
+	K []byte
 }
 
 func (m *Mock) Config() *config.Config {
@@ -34,4 +35,6 @@ func (m *Mock) GetConfigKey(key string) (interface{}, error) {
 
 func (m *Mock) Datastore() ds.ThreadSafeDatastore { return m.D }
 
+func (m *Mock) SwarmKey() ([]byte, error) { return m.K, nil }
+
 func (m *Mock) Close() error { return errTODO }
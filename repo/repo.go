@@ -17,6 +17,10 @@ type Repo interface {
 
 	Datastore() datastore.ThreadSafeDatastore
 
+	// SwarmKey returns the key of the private network the node is in, as
+	// kept in swarm.key, or nil if it is in the public one.
+	SwarmKey() ([]byte, error)
+
 	io.Closer
 }
 
@@ -0,0 +1,269 @@
+package delegated
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	proto "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
+
+	ci "github.com/jbenet/go-ipfs/p2p/crypto"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	routing "github.com/jbenet/go-ipfs/routing"
+	pb "github.com/jbenet/go-ipfs/routing/dht/pb"
+	record "github.com/jbenet/go-ipfs/routing/record"
+	u "github.com/jbenet/go-ipfs/util"
+)
+
+// client implements IpfsRouting by forwarding requests to a remote
+// delegated routing handler.
+type client struct {
+	url       string
+	token     string
+	self      peer.ID
+	peerstore peer.Peerstore
+	validator record.Validator
+
+	transport *http.Transport
+	http      *http.Client
+}
+
+// NewClient returns an IpfsRouting that delegates to the routing service
+// at address (host:port), with the API token of the service if it is not
+// empty. The provider records and values of the local peer are signed
+// with its private key in ps, and the values found are checked with v.
+// The peers it finds are added to ps.
+func NewClient(address, token string, local peer.ID, ps peer.Peerstore, v record.Validator) routing.IpfsRouting {
+	tr := &http.Transport{}
+	return &client{
+		url:       "http://" + address + RoutingPath,
+		token:     token,
+		self:      local,
+		peerstore: ps,
+		validator: v,
+		transport: tr,
+		http:      &http.Client{Transport: tr},
+	}
+}
+
+// FindProvidersAsync asks the routing service for providers of key and
+// returns them as they are streamed back.
+func (c *client) FindProvidersAsync(ctx context.Context, key u.Key, count int) <-chan peer.PeerInfo {
+	out := make(chan peer.PeerInfo)
+	go func() {
+		defer close(out)
+
+		url := c.url + providersPath + key.B58String() + "?count=" + strconv.Itoa(count)
+		res, err := c.do(ctx, "GET", url, nil)
+		if err != nil {
+			log.Debugf("delegated findProviders %s failed: %s", key, err)
+			return
+		}
+		defer res.Body.Close()
+
+		dec := json.NewDecoder(res.Body)
+		for i := 0; i < count; i++ {
+			var pi peer.PeerInfo
+			if err := dec.Decode(&pi); err != nil {
+				if err != io.EOF {
+					log.Debugf("delegated findProviders %s: bad response: %s", key, err)
+				}
+				return
+			}
+			c.peerstore.AddPeerInfo(pi, peer.ProviderAddrTTL)
+
+			select {
+			case out <- pi:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Provide announces the local peer as a provider of key to the routing
+// service, with a provider record it signs, for the service to announce it
+// on our behalf.
+func (c *client) Provide(ctx context.Context, key u.Key) error {
+	sk, err := c.privateKey()
+	if err != nil {
+		return err
+	}
+	rec, err := record.MakeProviderRecord(sk, key)
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(&providerRecord{
+		Provider: c.peerstore.PeerInfo(c.self),
+		Record:   data,
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := c.do(ctx, "POST", c.url+providePath+key.B58String(), bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// FindPeer asks the routing service for the addresses of peer p.
+func (c *client) FindPeer(ctx context.Context, p peer.ID) (peer.PeerInfo, error) {
+	res, err := c.do(ctx, "GET", c.url+peerPath+peer.IDB58Encode(p), nil)
+	if err != nil {
+		return peer.PeerInfo{}, err
+	}
+	defer res.Body.Close()
+
+	var pi peer.PeerInfo
+	if err := json.NewDecoder(res.Body).Decode(&pi); err != nil {
+		return peer.PeerInfo{}, err
+	}
+	if pi.ID != p {
+		return peer.PeerInfo{}, fmt.Errorf("delegated routing returned wrong peer: %s", pi.ID)
+	}
+	c.peerstore.AddPeerInfo(pi, peer.TempAddrTTL)
+	return pi, nil
+}
+
+// GetValue asks the routing service for the value of key. Its record is
+// checked, as the dht does, before the value is returned.
+func (c *client) GetValue(ctx context.Context, key u.Key) ([]byte, error) {
+	res, err := c.do(ctx, "GET", c.url+valuePath+key.B58String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var sr signedRecord
+	if err := json.NewDecoder(res.Body).Decode(&sr); err != nil {
+		return nil, err
+	}
+	rec := new(pb.Record)
+	if err := proto.Unmarshal(sr.Record, rec); err != nil {
+		return nil, err
+	}
+	pk, err := ci.UnmarshalPublicKey(sr.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Key(rec.GetKey()) != key {
+		return nil, fmt.Errorf("delegated routing returned a record of the wrong key: %s", u.Key(rec.GetKey()))
+	}
+	if !peer.ID(rec.GetAuthor()).MatchesPublicKey(pk) {
+		return nil, record.ErrBadRecord
+	}
+	if err := c.validator.VerifyRecord(rec, pk); err != nil {
+		return nil, err
+	}
+	return rec.GetValue(), nil
+}
+
+// PutValue asks the routing service to store value under key, in a record
+// the local peer signs.
+func (c *client) PutValue(ctx context.Context, key u.Key, value []byte) error {
+	sk, err := c.privateKey()
+	if err != nil {
+		return err
+	}
+	rec, err := record.MakePutRecord(sk, key, value)
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	pkb, err := sk.GetPublic().Bytes()
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(&signedRecord{Record: data, PubKey: pkb})
+	if err != nil {
+		return err
+	}
+
+	res, err := c.do(ctx, "PUT", c.url+valuePath+key.B58String(), bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// privateKey returns the private key of the local peer, to sign records.
+func (c *client) privateKey() (ci.PrivKey, error) {
+	sk := c.peerstore.PrivKey(c.self)
+	if sk == nil {
+		return nil, fmt.Errorf("delegated routing: no private key for %s to sign records", c.self)
+	}
+	return sk, nil
+}
+
+// Ping is not supported: the routing service cannot measure the latency
+// between us and p.
+func (c *client) Ping(ctx context.Context, p peer.ID) (time.Duration, error) {
+	return 0, ErrNotSupported
+}
+
+// do sends a request to the routing service, and turns non-2xx responses
+// into errors. The request is canceled when ctx is done.
+func (c *client) do(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	type result struct {
+		res *http.Response
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := c.http.Do(req)
+		done <- result{res, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		c.transport.CancelRequest(req)
+		return nil, ctx.Err()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	switch {
+	case r.res.StatusCode == http.StatusNotFound:
+		r.res.Body.Close()
+		return nil, routing.ErrNotFound
+	case r.res.StatusCode >= 300:
+		msg, _ := ioutil.ReadAll(r.res.Body)
+		r.res.Body.Close()
+		return nil, fmt.Errorf("delegated routing: %s: %s", r.res.Status, bytes.TrimSpace(msg))
+	}
+	return r.res, nil
+}
+
+var _ routing.IpfsRouting = &client{}
@@ -0,0 +1,57 @@
+// Package delegated implements an IpfsRouting that forwards all routing
+// requests to a remote node over HTTP, and the handler that serves them.
+// It lets devices that cannot run a full dht (browsers, embedded devices)
+// use the routing system of a trusted node.
+//
+// The protocol is a small set of HTTP endpoints under RoutingPath:
+//
+//	GET  /providers/<key>?count=<n>  stream of json PeerInfos, one per line
+//	POST /provide/<key>              json providerRecord
+//	GET  /peer/<peer id>             json PeerInfo
+//	GET  /value/<key>                json signedRecord of the value
+//	PUT  /value/<key>                json signedRecord
+//
+// Keys and peer ids are b58 encoded. Missing values and peers are signaled
+// with a 404 status. Clients sign their provider records and values, which
+// the service checks before publishing them, and check the signature of
+// the values they get, as dht nodes do.
+package delegated
+
+import (
+	"errors"
+
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+)
+
+var log = eventlog.Logger("routing/delegated")
+
+// RoutingPath is the path prefix of the delegated routing endpoints.
+const RoutingPath = "/routing/v0"
+
+// ErrNotSupported is returned by operations the delegated router cannot
+// perform on behalf of the local node.
+var ErrNotSupported = errors.New("delegated routing: operation not supported")
+
+const (
+	providersPath = "/providers/"
+	providePath   = "/provide/"
+	peerPath      = "/peer/"
+	valuePath     = "/value/"
+)
+
+// providerRecord is the body of provide requests: the provider, and the
+// record it signed to announce itself, made with
+// record.MakeProviderRecord.
+type providerRecord struct {
+	Provider peer.PeerInfo
+	Record   []byte // protobuf encoded
+}
+
+// signedRecord is the body of put value requests, and of the answers to
+// get value ones: the record of the value, and the public key of its
+// author, which signed it.
+type signedRecord struct {
+	Record []byte // protobuf encoded
+	PubKey []byte
+}
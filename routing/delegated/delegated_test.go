@@ -0,0 +1,330 @@
+package delegated
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	ma "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+
+	ci "github.com/jbenet/go-ipfs/p2p/crypto"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	routing "github.com/jbenet/go-ipfs/routing"
+	pb "github.com/jbenet/go-ipfs/routing/dht/pb"
+	record "github.com/jbenet/go-ipfs/routing/record"
+	u "github.com/jbenet/go-ipfs/util"
+	testutil "github.com/jbenet/go-ipfs/util/testutil"
+)
+
+// standIn is an in-memory router standing in for the dht of the node
+// serving delegated routing requests.
+type standIn struct {
+	sync.Mutex
+	records   map[u.Key]*pb.Record
+	pubkeys   map[peer.ID]ci.PubKey
+	providers map[u.Key][]peer.PeerInfo
+	peers     map[peer.ID]peer.PeerInfo
+}
+
+func newStandIn() *standIn {
+	return &standIn{
+		records:   make(map[u.Key]*pb.Record),
+		pubkeys:   make(map[peer.ID]ci.PubKey),
+		providers: make(map[u.Key][]peer.PeerInfo),
+		peers:     make(map[peer.ID]peer.PeerInfo),
+	}
+}
+
+func (s *standIn) FindProvidersAsync(ctx context.Context, k u.Key, count int) <-chan peer.PeerInfo {
+	s.Lock()
+	provs := s.providers[k]
+	s.Unlock()
+
+	out := make(chan peer.PeerInfo, len(provs))
+	for i, pi := range provs {
+		if i >= count {
+			break
+		}
+		out <- pi
+	}
+	close(out)
+	return out
+}
+
+func (s *standIn) PutValue(ctx context.Context, k u.Key, v []byte) error {
+	return ErrNotSupported
+}
+
+func (s *standIn) GetValue(ctx context.Context, k u.Key) ([]byte, error) {
+	rec, err := s.GetRecord(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	return rec.GetValue(), nil
+}
+
+func (s *standIn) PutRecord(ctx context.Context, rec *pb.Record, pk ci.PubKey) error {
+	if err := testValidator.VerifyRecord(rec, pk); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.records[u.Key(rec.GetKey())] = rec
+	s.pubkeys[peer.ID(rec.GetAuthor())] = pk
+	return nil
+}
+
+func (s *standIn) GetRecord(ctx context.Context, k u.Key) (*pb.Record, error) {
+	s.Lock()
+	defer s.Unlock()
+	rec, ok := s.records[k]
+	if !ok {
+		return nil, routing.ErrNotFound
+	}
+	return rec, nil
+}
+
+func (s *standIn) GetPublicKey(ctx context.Context, p peer.ID) (ci.PubKey, error) {
+	s.Lock()
+	defer s.Unlock()
+	pk, ok := s.pubkeys[p]
+	if !ok {
+		return nil, routing.ErrNotFound
+	}
+	return pk, nil
+}
+
+func (s *standIn) Provide(ctx context.Context, k u.Key) error {
+	return ErrNotSupported
+}
+
+func (s *standIn) AddProvider(ctx context.Context, k u.Key, pi peer.PeerInfo, rec *pb.Record) error {
+	if err := record.VerifyProviderRecord(rec, k, pi.ID); err != nil {
+		return err
+	}
+	s.addProvider(k, pi)
+	return nil
+}
+
+func (s *standIn) addProvider(k u.Key, pi peer.PeerInfo) {
+	s.Lock()
+	defer s.Unlock()
+	s.providers[k] = append(s.providers[k], pi)
+}
+
+func (s *standIn) FindPeer(ctx context.Context, p peer.ID) (peer.PeerInfo, error) {
+	s.Lock()
+	defer s.Unlock()
+	pi, ok := s.peers[p]
+	if !ok {
+		return peer.PeerInfo{}, routing.ErrNotFound
+	}
+	return pi, nil
+}
+
+func (s *standIn) Ping(ctx context.Context, p peer.ID) (time.Duration, error) {
+	return 0, nil
+}
+
+var testValidator = record.Validator{
+	"pk": record.ValidatePublicKeyRecord,
+	"v":  func(u.Key, []byte) error { return nil },
+}
+
+// setupClient returns a client of the routing service serving r, and the
+// identity of the client's peer.
+func setupClient(t *testing.T, r *standIn) (routing.IpfsRouting, peer.PeerInfo, *httptest.Server) {
+	srv := httptest.NewServer(NewHandler(context.Background(), r, r))
+
+	sk, pk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	self := peer.PeerInfo{ID: id, Addrs: []ma.Multiaddr{testutil.RandLocalTCPAddress()}}
+	ps := peer.NewPeerstore()
+	ps.AddPrivKey(id, sk)
+	ps.AddPubKey(id, pk)
+	ps.AddPeerInfo(self, peer.PermanentAddrTTL)
+
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	return NewClient(addr, "", id, ps, testValidator), self, srv
+}
+
+func TestValues(t *testing.T) {
+	ctx := context.Background()
+	r := newStandIn()
+	c, _, srv := setupClient(t, r)
+	defer srv.Close()
+
+	k := u.Key("/v/hello")
+	if _, err := c.GetValue(ctx, k); err != routing.ErrNotFound {
+		t.Fatalf("expected routing.ErrNotFound, got %v", err)
+	}
+
+	if err := c.PutValue(ctx, k, []byte("world")); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := c.GetValue(ctx, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, []byte("world")) {
+		t.Fatalf("expected 'world', got '%s'", v)
+	}
+}
+
+func TestGetValueChecked(t *testing.T) {
+	ctx := context.Background()
+	r := newStandIn()
+	c, _, srv := setupClient(t, r)
+	defer srv.Close()
+
+	k := u.Key("/v/hello")
+	if err := c.PutValue(ctx, k, []byte("world")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a service forging the value of a record.
+	r.Lock()
+	r.records[k].Value = []byte("forged")
+	r.Unlock()
+	if _, err := c.GetValue(ctx, k); err == nil {
+		t.Fatal("got a forged value")
+	}
+
+	// or answering with the record of another key.
+	r.Lock()
+	r.records[u.Key("/v/other")] = r.records[k]
+	r.Unlock()
+	if _, err := c.GetValue(ctx, u.Key("/v/other")); err == nil {
+		t.Fatal("got the value of another key")
+	}
+}
+
+func TestProvide(t *testing.T) {
+	ctx := context.Background()
+	c, self, srv := setupClient(t, newStandIn())
+	defer srv.Close()
+
+	k := u.Key("providedkey")
+	if err := c.Provide(ctx, k); err != nil {
+		t.Fatal(err)
+	}
+
+	var provs []peer.PeerInfo
+	for pi := range c.FindProvidersAsync(ctx, k, 10) {
+		provs = append(provs, pi)
+	}
+	if len(provs) != 1 {
+		t.Fatalf("expected 1 provider, got %d", len(provs))
+	}
+	if provs[0].ID != self.ID {
+		t.Fatal("got back wrong provider")
+	}
+	if len(provs[0].Addrs) != 1 || !provs[0].Addrs[0].Equal(self.Addrs[0]) {
+		t.Fatalf("got back wrong provider addresses: %s", provs[0].Addrs)
+	}
+}
+
+func TestFindProvidersCount(t *testing.T) {
+	ctx := context.Background()
+	r := newStandIn()
+	c, _, srv := setupClient(t, r)
+	defer srv.Close()
+
+	k := u.Key("manyproviders")
+	for i := 0; i < 5; i++ {
+		pi := peer.PeerInfo{
+			ID:    testutil.RandPeerIDFatal(t),
+			Addrs: []ma.Multiaddr{testutil.RandLocalTCPAddress()},
+		}
+		r.addProvider(k, pi)
+	}
+
+	n := 0
+	for _ = range c.FindProvidersAsync(ctx, k, 3) {
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 providers, got %d", n)
+	}
+}
+
+func TestFindPeer(t *testing.T) {
+	ctx := context.Background()
+	r := newStandIn()
+	c, _, srv := setupClient(t, r)
+	defer srv.Close()
+
+	p := testutil.RandPeerIDFatal(t)
+	if _, err := c.FindPeer(ctx, p); err != routing.ErrNotFound {
+		t.Fatalf("expected routing.ErrNotFound, got %v", err)
+	}
+
+	addr := testutil.RandLocalTCPAddress()
+	r.peers[p] = peer.PeerInfo{ID: p, Addrs: []ma.Multiaddr{addr}}
+
+	pi, err := c.FindPeer(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pi.ID != p || len(pi.Addrs) != 1 || !pi.Addrs[0].Equal(addr) {
+		t.Fatalf("got back wrong peer info: %s %s", pi.ID, pi.Addrs)
+	}
+}
+
+func TestUnsignedRecordsRefused(t *testing.T) {
+	r := newStandIn()
+	_, _, srv := setupClient(t, r)
+	defer srv.Close()
+
+	// a provider record without the signature of the provider.
+	k := u.Key("key")
+	body := strings.NewReader(`{"Provider": {"ID": "QmRmPL3FDZKE3Qiwv1RosLdwdvbvg17b2hB39QPScgWKKZ"}}`)
+	res, err := http.Post(srv.URL+RoutingPath+providePath+k.B58String(), "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("provide got status %d", res.StatusCode)
+	}
+
+	req, err := http.NewRequest("PUT", srv.URL+RoutingPath+valuePath+k.B58String(), strings.NewReader(`{"Record": "dmFsdWU="}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("put value got status %d", res.StatusCode)
+	}
+	if len(r.records) != 0 || len(r.providers) != 0 {
+		t.Fatal("the service changed the routing system")
+	}
+}
+
+func TestNoRecordRouter(t *testing.T) {
+	ctx := context.Background()
+	r := newStandIn()
+	srv := httptest.NewServer(NewHandler(context.Background(), r, nil))
+	defer srv.Close()
+
+	c := NewClient(strings.TrimPrefix(srv.URL, "http://"), "", "", peer.NewPeerstore(), testValidator)
+	if _, err := c.GetValue(ctx, u.Key("key")); err == nil {
+		t.Fatal("expected get value to fail")
+	}
+}
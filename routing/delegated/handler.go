@@ -0,0 +1,227 @@
+package delegated
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	proto "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
+
+	ci "github.com/jbenet/go-ipfs/p2p/crypto"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	routing "github.com/jbenet/go-ipfs/routing"
+	pb "github.com/jbenet/go-ipfs/routing/dht/pb"
+	record "github.com/jbenet/go-ipfs/routing/record"
+	u "github.com/jbenet/go-ipfs/util"
+)
+
+// defaultProviderCount is the number of providers returned when the
+// request does not ask for a specific count.
+const defaultProviderCount = 20
+
+// requestTimeout bounds the time spent serving a single request.
+const requestTimeout = time.Minute
+
+// maxRecordSize bounds the size of the bodies of provide and put value
+// requests.
+const maxRecordSize = 1 << 16
+
+// RecordRouter is implemented by routers which can publish and find
+// records signed by other peers than the local node, such as the dht. The
+// handler needs it to serve values, and to publish the provider records
+// and values of its clients.
+type RecordRouter interface {
+	AddProvider(context.Context, u.Key, peer.PeerInfo, *pb.Record) error
+	PutRecord(context.Context, *pb.Record, ci.PubKey) error
+	GetRecord(context.Context, u.Key) (*pb.Record, error)
+	GetPublicKey(context.Context, peer.ID) (ci.PubKey, error)
+}
+
+// handler serves delegated routing requests with a local IpfsRouting.
+type handler struct {
+	ctx     context.Context
+	routing routing.IpfsRouting
+	records RecordRouter
+}
+
+// NewHandler returns an http.Handler exposing r as a delegated routing
+// service. It expects to be mounted at RoutingPath. Requests are bounded
+// by ctx.
+//
+// Values, and the records clients publish, are served with records. Without
+// one, such requests fail with ErrNotSupported. The handler does not
+// authenticate its clients, see commands/http.Guard.
+func NewHandler(ctx context.Context, r routing.IpfsRouting, records RecordRouter) http.Handler {
+	return &handler{ctx: ctx, routing: r, records: records}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, RoutingPath)
+
+	ctx, cancel := context.WithTimeout(h.ctx, requestTimeout)
+	defer cancel()
+
+	switch {
+	case strings.HasPrefix(path, providersPath) && r.Method == "GET":
+		h.findProviders(ctx, w, r, strings.TrimPrefix(path, providersPath))
+	case strings.HasPrefix(path, providePath) && r.Method == "POST":
+		h.provide(ctx, w, r, strings.TrimPrefix(path, providePath))
+	case strings.HasPrefix(path, peerPath) && r.Method == "GET":
+		h.findPeer(ctx, w, r, strings.TrimPrefix(path, peerPath))
+	case strings.HasPrefix(path, valuePath) && r.Method == "GET":
+		h.getValue(ctx, w, r, strings.TrimPrefix(path, valuePath))
+	case strings.HasPrefix(path, valuePath) && r.Method == "PUT":
+		h.putValue(ctx, w, r, strings.TrimPrefix(path, valuePath))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *handler) findProviders(ctx context.Context, w http.ResponseWriter, r *http.Request, k string) {
+	count := defaultProviderCount
+	if c := r.URL.Query().Get("count"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid count: "+c, http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for pi := range h.routing.FindProvidersAsync(ctx, u.B58KeyDecode(k), count) {
+		if err := enc.Encode(&pi); err != nil {
+			log.Debugf("delegated findProviders: write failed: %s", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *handler) provide(ctx context.Context, w http.ResponseWriter, r *http.Request, k string) {
+	if h.records == nil {
+		http.Error(w, ErrNotSupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	var pr providerRecord
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRecordSize)).Decode(&pr); err != nil {
+		http.Error(w, "invalid provider record: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rec := new(pb.Record)
+	if err := proto.Unmarshal(pr.Record, rec); err != nil {
+		http.Error(w, "invalid provider record: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := u.B58KeyDecode(k)
+	if err := record.VerifyProviderRecord(rec, key, pr.Provider.ID); err != nil {
+		http.Error(w, "invalid provider record: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.records.AddProvider(ctx, key, pr.Provider, rec); err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func (h *handler) findPeer(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) {
+	p, err := peer.IDB58Decode(id)
+	if err != nil {
+		http.Error(w, "invalid peer id: "+id, http.StatusBadRequest)
+		return
+	}
+
+	pi, err := h.routing.FindPeer(ctx, p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&pi)
+}
+
+func (h *handler) getValue(ctx context.Context, w http.ResponseWriter, r *http.Request, k string) {
+	if h.records == nil {
+		http.Error(w, ErrNotSupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	rec, err := h.records.GetRecord(ctx, u.B58KeyDecode(k))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// the public key of the author, for the client to check the record.
+	pk, err := h.records.GetPublicKey(ctx, peer.ID(rec.GetAuthor()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var sr signedRecord
+	if sr.Record, err = proto.Marshal(rec); err != nil {
+		writeError(w, err)
+		return
+	}
+	if sr.PubKey, err = pk.Bytes(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&sr)
+}
+
+func (h *handler) putValue(ctx context.Context, w http.ResponseWriter, r *http.Request, k string) {
+	if h.records == nil {
+		http.Error(w, ErrNotSupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	var sr signedRecord
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRecordSize)).Decode(&sr); err != nil {
+		http.Error(w, "invalid record: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rec := new(pb.Record)
+	if err := proto.Unmarshal(sr.Record, rec); err != nil {
+		http.Error(w, "invalid record: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	pk, err := ci.UnmarshalPublicKey(sr.PubKey)
+	if err != nil {
+		http.Error(w, "invalid public key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if u.Key(rec.GetKey()) != u.B58KeyDecode(k) {
+		http.Error(w, "invalid record: not of key "+k, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.records.PutRecord(ctx, rec, pk); err != nil {
+		writeError(w, err)
+		return
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch err {
+	case routing.ErrNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case record.ErrBadRecord, record.ErrInvalidRecordType:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
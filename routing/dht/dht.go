@@ -56,11 +56,36 @@ type IpfsDHT struct {
 
 	Validator record.Validator // record validator funcs
 
+	clientOnly bool // whether we only query the dht, without serving it
+
 	ctxgroup.ContextGroup
 }
 
+// kbucketTag tags the connections to the peers in the routing table, for
+// the connection manager.
+const (
+	kbucketTag      = "kbucket"
+	kbucketTagValue = 5
+)
+
 // NewDHT creates a new DHT object with the given peer as the 'local' host
 func NewDHT(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *IpfsDHT {
+	dht := newDHT(ctx, h, dstore)
+	h.SetStreamHandler(ProtocolDHT, dht.handleNewStream)
+	return dht
+}
+
+// NewDHTClient creates a new DHT object in client mode. A client queries
+// the dht like any other node, but does not register the dht protocol
+// handler: it neither answers requests nor stores records for other peers,
+// and so it does not end up in other peers' routing tables.
+func NewDHTClient(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *IpfsDHT {
+	dht := newDHT(ctx, h, dstore)
+	dht.clientOnly = true
+	return dht
+}
+
+func newDHT(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *IpfsDHT {
 	dht := new(IpfsDHT)
 	dht.datastore = dstore
 	dht.self = h.ID()
@@ -81,11 +106,18 @@ func NewDHT(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *Ip
 		panic("attempt to initialize dht without addresses for self")
 	}
 
-	h.SetStreamHandler(ProtocolDHT, dht.handleNewStream)
 	dht.providers = NewProviderManager(dht.Context(), dht.self)
 	dht.AddChildGroup(dht.providers)
 
 	dht.routingTable = kb.NewRoutingTable(20, kb.ConvertPeerID(dht.self), time.Minute, dht.peerstore)
+	// keep the connections to the peers in our routing table.
+	cmgr := h.ConnManager()
+	dht.routingTable.PeerAdded = func(p peer.ID) {
+		cmgr.TagPeer(p, kbucketTag, kbucketTagValue)
+	}
+	dht.routingTable.PeerRemoved = func(p peer.ID) {
+		cmgr.UntagPeer(p, kbucketTag)
+	}
 	dht.birth = time.Now()
 
 	dht.Validator = make(record.Validator)
@@ -98,6 +130,11 @@ func NewDHT(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *Ip
 	return dht
 }
 
+// ClientOnly returns whether the dht runs in client mode.
+func (dht *IpfsDHT) ClientOnly() bool {
+	return dht.clientOnly
+}
+
 // LocalPeer returns the peer.Peer of the dht.
 func (dht *IpfsDHT) LocalPeer() peer.ID {
 	return dht.self
@@ -155,23 +192,31 @@ func (dht *IpfsDHT) putProvider(ctx context.Context, p peer.ID, key string) erro
 		return fmt.Errorf("no known addresses for self. cannot put provider.")
 	}
 
+	return dht.sendProvider(ctx, p, key, pi, nil)
+}
+
+// sendProvider tells peer p that pi is a provider for key. Peers other
+// than the local node are announced with their signed provider record,
+// rec, for p to check the announcement.
+func (dht *IpfsDHT) sendProvider(ctx context.Context, p peer.ID, key string, pi peer.PeerInfo, rec *pb.Record) error {
 	pmes := pb.NewMessage(pb.Message_ADD_PROVIDER, string(key), 0)
 	pmes.ProviderPeers = pb.PeerInfosToPBPeers(dht.host.Network(), []peer.PeerInfo{pi})
+	pmes.Record = rec
 	err := dht.sendMessage(ctx, p, pmes)
 	if err != nil {
 		return err
 	}
 
-	log.Debugf("%s putProvider: %s for %s (%s)", dht.self, p, u.Key(key), pi.Addrs)
+	log.Debugf("%s sendProvider: %s for %s (%s)", dht.self, p, u.Key(key), pi.Addrs)
 	return nil
 }
 
 // getValueOrPeers queries a particular peer p for the value for
-// key. It returns either the value or a list of closer peers.
+// key. It returns either the record of the value or a list of closer peers.
 // NOTE: it will update the dht's peerstore with any new addresses
 // it finds for the given peer.
 func (dht *IpfsDHT) getValueOrPeers(ctx context.Context, p peer.ID,
-	key u.Key) ([]byte, []peer.PeerInfo, error) {
+	key u.Key) (*pb.Record, []peer.PeerInfo, error) {
 
 	pmes, err := dht.getValueSingle(ctx, p, key)
 	if err != nil {
@@ -188,7 +233,7 @@ func (dht *IpfsDHT) getValueOrPeers(ctx context.Context, p peer.ID,
 			log.Error("Received invalid record!")
 			return nil, nil, err
 		}
-		return record.GetValue(), nil, nil
+		return record, nil, nil
 	}
 
 	// Perhaps we were given closer peers
@@ -213,6 +258,15 @@ func (dht *IpfsDHT) getValueSingle(ctx context.Context, p peer.ID,
 
 // getLocal attempts to retrieve the value from the datastore
 func (dht *IpfsDHT) getLocal(key u.Key) ([]byte, error) {
+	rec, err := dht.getLocalRecord(key)
+	if err != nil {
+		return nil, err
+	}
+	return rec.GetValue(), nil
+}
+
+// getLocalRecord attempts to retrieve the record of key from the datastore
+func (dht *IpfsDHT) getLocalRecord(key u.Key) (*pb.Record, error) {
 
 	log.Debug("getLocal %s", key)
 	v, err := dht.datastore.Get(key.DsKey())
@@ -240,7 +294,7 @@ func (dht *IpfsDHT) getLocal(key u.Key) ([]byte, error) {
 		}
 	}
 
-	return rec.GetValue(), nil
+	return rec, nil
 }
 
 // getOwnPrivateKey attempts to load the local peers private
@@ -6,6 +6,7 @@ import (
 
 	inet "github.com/jbenet/go-ipfs/p2p/net"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	identify "github.com/jbenet/go-ipfs/p2p/protocol/identify"
 	pb "github.com/jbenet/go-ipfs/routing/dht/pb"
 	ctxutil "github.com/jbenet/go-ipfs/util/ctx"
 
@@ -35,8 +36,11 @@ func (dht *IpfsDHT) handleNewMessage(s inet.Stream) {
 		return
 	}
 
-	// update the peer (on valid msgs only)
-	dht.updateFromMessage(ctx, mPeer, pmes)
+	// update the peer (on valid msgs only). dht clients send requests
+	// too, but they do not answer them, so they stay out of our table.
+	if dht.waitPeerIsServer(ctx, s.Conn()) {
+		dht.updateFromMessage(ctx, mPeer, pmes)
+	}
 
 	// get handler for this msg type.
 	handler := dht.handlerForMsgType(pmes.GetType())
@@ -130,3 +134,32 @@ func (dht *IpfsDHT) updateFromMessage(ctx context.Context, p peer.ID, mes *pb.Me
 	dht.Update(ctx, p)
 	return nil
 }
+
+// identifier is implemented by hosts that run the identify service.
+type identifier interface {
+	IDService() *identify.IDService
+}
+
+// waitPeerIsServer waits for the remote side of c to be identified (if the
+// host runs identify) and returns whether it serves the dht.
+func (dht *IpfsDHT) waitPeerIsServer(ctx context.Context, c inet.Conn) bool {
+	if h, ok := dht.host.(identifier); ok {
+		select {
+		case <-h.IDService().IdentifyWait(c):
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return dht.peerIsServer(c.RemotePeer())
+}
+
+// peerIsServer returns whether p answers dht requests, according to the
+// protocols it announced through identify. Peers we know nothing about
+// (not identified yet, or older nodes) are assumed to be servers.
+func (dht *IpfsDHT) peerIsServer(p peer.ID) bool {
+	v, found := dht.peerstore.Version(p)
+	if !found {
+		return true
+	}
+	return v.SupportsProtocol(string(ProtocolDHT))
+}
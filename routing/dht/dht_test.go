@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -18,7 +19,9 @@ import (
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	netutil "github.com/jbenet/go-ipfs/p2p/test/util"
 	routing "github.com/jbenet/go-ipfs/routing"
+	record "github.com/jbenet/go-ipfs/routing/record"
 	u "github.com/jbenet/go-ipfs/util"
+	testutil "github.com/jbenet/go-ipfs/util/testutil"
 
 	ci "github.com/jbenet/go-ipfs/util/testutil/ci"
 	travisci "github.com/jbenet/go-ipfs/util/testutil/ci/travis"
@@ -47,6 +50,18 @@ func setupDHT(ctx context.Context, t *testing.T) *IpfsDHT {
 	return d
 }
 
+func setupDHTClient(ctx context.Context, t *testing.T) *IpfsDHT {
+	h := netutil.GenHostSwarm(t, ctx)
+
+	dss := dssync.MutexWrap(ds.NewMapDatastore())
+	d := NewDHTClient(ctx, h, dss)
+
+	d.Validator["v"] = func(u.Key, []byte) error {
+		return nil
+	}
+	return d
+}
+
 func setupDHTS(ctx context.Context, n int, t *testing.T) ([]ma.Multiaddr, []peer.ID, []*IpfsDHT) {
 	addrs := make([]ma.Multiaddr, n)
 	dhts := make([]*IpfsDHT, n)
@@ -69,7 +84,7 @@ func connect(t *testing.T, ctx context.Context, a, b *IpfsDHT) {
 		t.Fatal("peers setup incorrectly: no local address")
 	}
 
-	a.peerstore.AddAddresses(idB, addrB)
+	a.peerstore.AddAddresses(idB, addrB, peer.PermanentAddrTTL)
 	if err := a.Connect(ctx, idB); err != nil {
 		t.Fatal(err)
 	}
@@ -126,6 +141,50 @@ func TestPing(t *testing.T) {
 	}
 }
 
+func TestClientMode(t *testing.T) {
+	ctx := context.Background()
+
+	server := setupDHT(ctx, t)
+	client := setupDHTClient(ctx, t)
+
+	defer server.Close()
+	defer client.Close()
+	defer server.host.Close()
+	defer client.host.Close()
+
+	connect(t, ctx, client, server)
+
+	// the client learned about the server from its response.
+	if client.routingTable.Find(server.self) == "" {
+		t.Fatal("client should have the server in its routing table")
+	}
+
+	// the server heard from the client, but must not route to it.
+	if server.routingTable.Find(client.self) != "" {
+		t.Fatal("server should not have the client in its routing table")
+	}
+
+	// the client does not answer dht requests.
+	ctxT, _ := context.WithTimeout(ctx, 100*time.Millisecond)
+	if _, err := server.Ping(ctxT, client.self); err == nil {
+		t.Fatal("client should not answer dht requests")
+	}
+
+	// but it can still use the dht.
+	k := u.Key("hello")
+	if err := server.putLocal(k, []byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	ctxT, _ = context.WithTimeout(ctx, time.Second)
+	val, err := client.GetValue(ctxT, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "world" {
+		t.Fatalf("expected 'world', got '%s'", val)
+	}
+}
+
 func TestValueGetSet(t *testing.T) {
 	// t.Skip("skipping test to debug another")
 
@@ -171,6 +230,159 @@ func TestValueGetSet(t *testing.T) {
 	}
 }
 
+func TestAddProvider(t *testing.T) {
+	ctx := context.Background()
+
+	_, _, dhts := setupDHTS(ctx, 4, t)
+	defer func() {
+		for i := 0; i < 4; i++ {
+			dhts[i].Close()
+			defer dhts[i].host.Close()
+		}
+	}()
+
+	for _, d := range dhts[:3] {
+		connect(t, ctx, dhts[3], d)
+	}
+
+	// a peer outside the dht, which dhts[3] provides for.
+	sk, pk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := peer.PeerInfo{ID: pid, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/4001")}}
+
+	k := u.Key("hello")
+	if err := dhts[3].AddProvider(ctx, k, client, nil); err == nil {
+		t.Fatal("added a provider without its record")
+	}
+	rec, err := record.MakeProviderRecord(sk, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dhts[3].AddProvider(ctx, u.Key("other"), client, rec); err == nil {
+		t.Fatal("added a provider with the record of another key")
+	}
+	if err := dhts[3].AddProvider(ctx, k, client, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	// the messages are sent without waiting for answers.
+	for i, d := range dhts {
+		provs := d.providers.GetProviders(ctx, k)
+		for j := 0; len(provs) == 0 && j < 100; j++ {
+			time.Sleep(10 * time.Millisecond)
+			provs = d.providers.GetProviders(ctx, k)
+		}
+		if len(provs) != 1 || provs[0] != client.ID {
+			t.Fatalf("dht %d has providers %v, not %s", i, provs, client.ID)
+		}
+	}
+}
+
+func TestRelayedProviderChecked(t *testing.T) {
+	ctx := context.Background()
+
+	_, _, dhts := setupDHTS(ctx, 2, t)
+	defer func() {
+		for i := 0; i < 2; i++ {
+			dhts[i].Close()
+			defer dhts[i].host.Close()
+		}
+	}()
+	connect(t, ctx, dhts[0], dhts[1])
+
+	// dhts[0] announces another peer without its signed record.
+	pid := testutil.RandPeerIDFatal(t)
+	pi := peer.PeerInfo{ID: pid, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/4001")}}
+	k := u.Key("hello")
+	if err := dhts[0].sendProvider(ctx, dhts[1].self, string(k), pi, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if provs := dhts[1].providers.GetProviders(ctx, k); len(provs) != 0 {
+		t.Fatalf("unsigned provider record accepted: %v", provs)
+	}
+}
+
+func TestPutRecord(t *testing.T) {
+	ctx := context.Background()
+
+	dhtA := setupDHT(ctx, t)
+	dhtB := setupDHT(ctx, t)
+	defer dhtA.Close()
+	defer dhtB.Close()
+	defer dhtA.host.Close()
+	defer dhtB.host.Close()
+
+	vf := func(u.Key, []byte) error {
+		return nil
+	}
+	dhtA.Validator["v"] = vf
+	dhtB.Validator["v"] = vf
+
+	connect(t, ctx, dhtA, dhtB)
+
+	// a record of a peer outside the dht, which dhtA stores for it.
+	sk, pk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := record.MakePutRecord(sk, "/v/hello", []byte("world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctxT, _ := context.WithTimeout(ctx, time.Second)
+	_, otherpk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dhtA.PutRecord(ctxT, rec, otherpk); err == nil {
+		t.Fatal("stored a record with the public key of another peer")
+	}
+	if err := dhtA.PutRecord(ctxT, rec, pk); err != nil {
+		t.Fatal(err)
+	}
+
+	// dhtB got it, and checked it with the public key sent before.
+	got, err := dhtB.getLocalRecord("/v/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.GetValue()) != "world" || got.GetAuthor() != rec.GetAuthor() {
+		t.Fatalf("dhtB has the wrong record: %v", got)
+	}
+}
+
+func TestPublicKeyRecordKeys(t *testing.T) {
+	// peer IDs are raw hashes, which may hold '/' bytes.
+	for found := false; !found; {
+		_, pk, err := testutil.RandTestKeyPair(512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := peer.IDFromPublicKey(pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = strings.Contains(string(id), "/")
+
+		pkb, err := pk.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := record.ValidatePublicKeyRecord(KeyForPublicKey(id), pkb); err != nil {
+			t.Fatalf("public key record of %s refused: %s", id, err)
+		}
+	}
+}
+
 func TestProvides(t *testing.T) {
 	// t.Skip("skipping test to debug another")
 	ctx := context.Background()
@@ -762,12 +974,12 @@ func TestConnectCollision(t *testing.T) {
 
 		errs := make(chan error)
 		go func() {
-			dhtA.peerstore.AddAddress(peerB, addrB)
+			dhtA.peerstore.AddAddress(peerB, addrB, peer.PermanentAddrTTL)
 			err := dhtA.Connect(ctx, peerB)
 			errs <- err
 		}()
 		go func() {
-			dhtB.peerstore.AddAddress(peerA, addrA)
+			dhtB.peerstore.AddAddress(peerA, addrA, peer.PermanentAddrTTL)
 			err := dhtB.Connect(ctx, peerA)
 			errs <- err
 		}()
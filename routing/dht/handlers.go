@@ -10,6 +10,7 @@ import (
 	ds "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	pb "github.com/jbenet/go-ipfs/routing/dht/pb"
+	record "github.com/jbenet/go-ipfs/routing/record"
 	u "github.com/jbenet/go-ipfs/util"
 )
 
@@ -224,23 +225,26 @@ func (dht *IpfsDHT) handleAddProvider(ctx context.Context, p peer.ID, pmes *pb.M
 	pinfos := pb.PBPeersToPeerInfos(pmes.GetProviderPeers())
 	for _, pi := range pinfos {
 		if pi.ID != p {
-			// we should ignore this provider reccord! not from originator.
-			// (we chould sign them and check signature later...)
-			log.Errorf("handleAddProvider received provider %s from %s. Ignore.", pi.ID, p)
-			continue
+			// a provider record relayed for a peer outside the dht, like
+			// the clients of delegated routing: it must be signed by the
+			// provider.
+			if err := record.VerifyProviderRecord(pmes.GetRecord(), key, pi.ID); err != nil {
+				log.Errorf("handleAddProvider received provider %s from %s: %s. Ignore.", pi.ID, p, err)
+				continue
+			}
 		}
 
 		if len(pi.Addrs) < 1 {
-			log.Errorf("%s got no valid addresses for provider %s. Ignore.", dht.self, p)
+			log.Errorf("%s got no valid addresses for provider %s. Ignore.", dht.self, pi.ID)
 			continue
 		}
 
-		log.Infof("received provider %s for %s (addrs: %s)", p, key, pi.Addrs)
+		log.Infof("received provider %s for %s (addrs: %s)", pi.ID, key, pi.Addrs)
 		if pi.ID != dht.self { // dont add own addrs.
 			// add the received addresses to our peerstore.
-			dht.peerstore.AddPeerInfo(pi)
+			dht.peerstore.AddPeerInfo(pi, peer.ProviderAddrTTL)
 		}
-		dht.providers.AddProvider(key, p)
+		dht.providers.AddProvider(key, pi.ID)
 	}
 
 	return pmes, nil // send back same msg as confirmation.
@@ -7,6 +7,7 @@ import (
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	queue "github.com/jbenet/go-ipfs/p2p/peer/queue"
 	"github.com/jbenet/go-ipfs/routing"
+	pb "github.com/jbenet/go-ipfs/routing/dht/pb"
 	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
 	u "github.com/jbenet/go-ipfs/util"
 	pset "github.com/jbenet/go-ipfs/util/peerset"
@@ -26,7 +27,7 @@ type dhtQuery struct {
 }
 
 type dhtQueryResult struct {
-	value         []byte          // GetValue
+	record        *pb.Record      // GetValue
 	peer          peer.PeerInfo   // FindPeer
 	providerPeers []peer.PeerInfo // GetProviders
 	closerPeers   []peer.PeerInfo // *
@@ -266,7 +267,7 @@ func (r *dhtQueryRunner) queryPeer(cg ctxgroup.ContextGroup, p peer.ID) {
 			}
 
 			// add their addresses to the dialer's peerstore
-			r.query.dht.peerstore.AddPeerInfo(next)
+			r.query.dht.peerstore.AddPeerInfo(next, peer.TempAddrTTL)
 			r.addPeerToQuery(cg.Context(), next.ID)
 			log.Debugf("PEERS CLOSER -- worker for: %v added %v (%v)", p, next.ID, next.Addrs)
 		}
@@ -1,9 +1,11 @@
 package dht
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	proto "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
 
 	ci "github.com/jbenet/go-ipfs/p2p/crypto"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
@@ -18,6 +20,12 @@ func KeyForPublicKey(id peer.ID) u.Key {
 	return u.Key("/pk/" + string(id))
 }
 
+// GetPublicKey returns the public key of p, asking p and then the dht for
+// it if it is not known yet.
+func (dht *IpfsDHT) GetPublicKey(ctx context.Context, p peer.ID) (ci.PubKey, error) {
+	return dht.getPublicKeyOnline(ctx, p)
+}
+
 func (dht *IpfsDHT) getPublicKeyOnline(ctx context.Context, p peer.ID) (ci.PubKey, error) {
 	log.Debugf("getPublicKey for: %s", p)
 
@@ -104,12 +112,45 @@ func (dht *IpfsDHT) verifyRecordLocally(r *pb.Record) error {
 	p := peer.ID(r.GetAuthor())
 	pk := dht.peerstore.PubKey(p)
 	if pk == nil {
-		return fmt.Errorf("do not have public key for %s", p)
+		// records relayed for peers outside the dht come after the public
+		// key record of their author.
+		var err error
+		pk, err = dht.getLocalPublicKey(p)
+		if err != nil {
+			return fmt.Errorf("do not have public key for %s", p)
+		}
 	}
 
 	return dht.Validator.VerifyRecord(r, pk)
 }
 
+// getLocalPublicKey returns the public key of p from the public key record
+// in the datastore, if any. The record's signature is not checked: the key
+// is known to be p's by its hash.
+func (dht *IpfsDHT) getLocalPublicKey(p peer.ID) (ci.PubKey, error) {
+	v, err := dht.datastore.Get(KeyForPublicKey(p).DsKey())
+	if err != nil {
+		return nil, err
+	}
+	byt, ok := v.([]byte)
+	if !ok {
+		return nil, errors.New("value stored in datastore not []byte")
+	}
+	rec := new(pb.Record)
+	if err := proto.Unmarshal(byt, rec); err != nil {
+		return nil, err
+	}
+
+	pk, err := ci.UnmarshalPublicKey(rec.GetValue())
+	if err != nil {
+		return nil, err
+	}
+	if !p.MatchesPublicKey(pk) {
+		return nil, fmt.Errorf("public key does not match id: %s", p)
+	}
+	return pk, nil
+}
+
 // verifyRecordOnline verifies a record, searching the DHT for the public key
 // if necessary. The reason there is a distinction in the functions is that
 // retrieving arbitrary public keys from the DHT as a result of passively
@@ -6,8 +6,10 @@ import (
 	"time"
 
 	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	proto "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
 
 	notif "github.com/jbenet/go-ipfs/notifications"
+	ci "github.com/jbenet/go-ipfs/p2p/crypto"
 	inet "github.com/jbenet/go-ipfs/p2p/net"
 	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	"github.com/jbenet/go-ipfs/routing"
@@ -73,11 +75,21 @@ func (dht *IpfsDHT) PutValue(ctx context.Context, key u.Key, value []byte) error
 // If the search does not succeed, a multiaddr string of a closer peer is
 // returned along with util.ErrSearchIncomplete
 func (dht *IpfsDHT) GetValue(ctx context.Context, key u.Key) ([]byte, error) {
+	rec, err := dht.GetRecord(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return rec.GetValue(), nil
+}
+
+// GetRecord searches for the record of the value of key, signed by its
+// author, as GetValue does for the value.
+func (dht *IpfsDHT) GetRecord(ctx context.Context, key u.Key) (*pb.Record, error) {
 	// If we have it local, dont bother doing an RPC!
-	val, err := dht.getLocal(key)
+	rec, err := dht.getLocalRecord(key)
 	if err == nil {
 		log.Debug("have it locally")
-		return val, nil
+		return rec, nil
 	} else {
 		log.Debug("failed to get value locally: %s", err)
 	}
@@ -92,13 +104,13 @@ func (dht *IpfsDHT) GetValue(ctx context.Context, key u.Key) ([]byte, error) {
 
 	// setup the Query
 	query := dht.newQuery(key, func(ctx context.Context, p peer.ID) (*dhtQueryResult, error) {
-		val, peers, err := dht.getValueOrPeers(ctx, p, key)
+		rec, peers, err := dht.getValueOrPeers(ctx, p, key)
 		if err != nil {
 			return nil, err
 		}
 
-		res := &dhtQueryResult{value: val, closerPeers: peers}
-		if val != nil {
+		res := &dhtQueryResult{record: rec, closerPeers: peers}
+		if rec != nil {
 			res.success = true
 		}
 
@@ -111,12 +123,93 @@ func (dht *IpfsDHT) GetValue(ctx context.Context, key u.Key) ([]byte, error) {
 		return nil, err
 	}
 
-	log.Debugf("GetValue %v %v", key, result.value)
-	if result.value == nil {
+	log.Debugf("GetValue %v %v", key, result.record.GetValue())
+	if result.record == nil {
 		return nil, routing.ErrNotFound
 	}
 
-	return result.value, nil
+	return result.record, nil
+}
+
+// PutRecord stores rec, signed by another peer than the local node (e.g. a
+// delegated routing client) with pk, as PutValue does for the values of
+// the local node. rec is checked first. The peers it is sent to learn pk
+// with it, from a public key record sent before.
+func (dht *IpfsDHT) PutRecord(ctx context.Context, rec *pb.Record, pk ci.PubKey) error {
+	key := u.Key(rec.GetKey())
+	log.Debugf("PutRecord %s", key)
+
+	author := peer.ID(rec.GetAuthor())
+	if !author.MatchesPublicKey(pk) {
+		return record.ErrBadRecord
+	}
+	if err := dht.Validator.VerifyRecord(rec, pk); err != nil {
+		return err
+	}
+
+	pkb, err := pk.Bytes()
+	if err != nil {
+		return err
+	}
+	pkkey := KeyForPublicKey(author)
+	if err := dht.putLocal(pkkey, pkb); err != nil {
+		return err
+	}
+	sk, err := dht.getOwnPrivateKey()
+	if err != nil {
+		return err
+	}
+	pkrec, err := record.MakePutRecord(sk, pkkey, pkb)
+	if err != nil {
+		return err
+	}
+
+	data, err := proto.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := dht.datastore.Put(key.DsKey(), data); err != nil {
+		return err
+	}
+
+	pchan, err := dht.GetClosestPeers(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var lk sync.Mutex
+	var stored int
+	var lastErr error
+	wg := sync.WaitGroup{}
+	for p := range pchan {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			err := dht.putValueToPeer(ctx, p, pkkey, pkrec)
+			if err != nil {
+				log.Errorf("failed putting public key to peer: %s", err)
+			} else if err = dht.putValueToPeer(ctx, p, key, rec); err != nil {
+				log.Errorf("failed putting record to peer: %s", err)
+			}
+
+			lk.Lock()
+			defer lk.Unlock()
+			if err != nil {
+				lastErr = err
+			} else {
+				stored++
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	if stored == 0 {
+		if lastErr == nil {
+			lastErr = kb.ErrLookupFailure
+		}
+		return errors.Errorf("no peer stored the record: %s", lastErr)
+	}
+	return nil
 }
 
 // Value provider layer of indirection.
@@ -150,6 +243,46 @@ func (dht *IpfsDHT) Provide(ctx context.Context, key u.Key) error {
 	return nil
 }
 
+// AddProvider announces pi as a provider for key, on behalf of a peer that
+// does not run the dht itself (e.g. a delegated routing client), as
+// Provide does for the local node. rec is the provider record pi signed,
+// which is checked, and sent along for the peers closest to key to check.
+func (dht *IpfsDHT) AddProvider(ctx context.Context, key u.Key, pi peer.PeerInfo, rec *pb.Record) error {
+	defer log.EventBegin(ctx, "addProvider", &key, pi.ID).Done()
+
+	if err := record.VerifyProviderRecord(rec, key, pi.ID); err != nil {
+		return err
+	}
+	if len(pi.Addrs) < 1 {
+		return errors.Errorf("no addresses for provider %s", pi.ID)
+	}
+
+	if pi.ID != dht.self { // dont add own addrs.
+		dht.peerstore.AddPeerInfo(pi, peer.ProviderAddrTTL)
+	}
+	dht.providers.AddProvider(key, pi.ID)
+
+	peers, err := dht.GetClosestPeers(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	wg := sync.WaitGroup{}
+	for p := range peers {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			log.Debugf("sendProvider(%s, %s, %s)", key, p, pi.ID)
+			err := dht.sendProvider(ctx, p, string(key), pi, rec)
+			if err != nil {
+				log.Error(err)
+			}
+		}(p)
+	}
+	wg.Wait()
+	return nil
+}
+
 // FindProviders searches until the context expires.
 func (dht *IpfsDHT) FindProviders(ctx context.Context, key u.Key) ([]peer.PeerInfo, error) {
 	var providers []peer.PeerInfo
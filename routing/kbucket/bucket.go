@@ -41,14 +41,16 @@ func (b *Bucket) find(id peer.ID) *list.Element {
 	return nil
 }
 
-func (b *Bucket) remove(id peer.ID) {
+func (b *Bucket) remove(id peer.ID) bool {
 	b.lk.RLock()
 	defer b.lk.RUnlock()
 	for e := b.list.Front(); e != nil; e = e.Next() {
 		if e.Value.(peer.ID) == id {
 			b.list.Remove(e)
+			return true
 		}
 	}
+	return false
 }
 
 func (b *Bucket) moveToFront(e *list.Element) {
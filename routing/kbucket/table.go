@@ -31,6 +31,11 @@ type RoutingTable struct {
 	// kBuckets define all the fingers to other nodes.
 	Buckets    []*Bucket
 	bucketsize int
+
+	// PeerAdded and PeerRemoved, if set, are called as peers enter and
+	// leave the table, with the table locked.
+	PeerAdded   func(peer.ID)
+	PeerRemoved func(peer.ID)
 }
 
 // NewRoutingTable creates a new routing table with a given bucketsize, local ID, and latency tolerance.
@@ -66,17 +71,21 @@ func (rt *RoutingTable) Update(p peer.ID) peer.ID {
 			return ""
 		}
 		bucket.pushFront(p)
+		rt.peerAdded(p)
 
 		// Are we past the max bucket size?
 		if bucket.len() > rt.bucketsize {
+			var removed peer.ID
 			// If this bucket is the rightmost bucket, and its full
 			// we need to split it and create a new bucket
 			if bucketID == len(rt.Buckets)-1 {
-				return rt.nextBucket()
+				removed = rt.nextBucket()
 			} else {
 				// If the bucket cant split kick out least active node
-				return bucket.popBack()
+				removed = bucket.popBack()
 			}
+			rt.peerRemoved(removed)
+			return removed
 		}
 		return ""
 	}
@@ -101,7 +110,21 @@ func (rt *RoutingTable) Remove(p peer.ID) {
 	}
 
 	bucket := rt.Buckets[bucketID]
-	bucket.remove(p)
+	if bucket.remove(p) {
+		rt.peerRemoved(p)
+	}
+}
+
+func (rt *RoutingTable) peerAdded(p peer.ID) {
+	if rt.PeerAdded != nil {
+		rt.PeerAdded(p)
+	}
+}
+
+func (rt *RoutingTable) peerRemoved(p peer.ID) {
+	if p != "" && rt.PeerRemoved != nil {
+		rt.PeerRemoved(p)
+	}
 }
 
 func (rt *RoutingTable) nextBucket() peer.ID {
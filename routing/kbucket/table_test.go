@@ -77,6 +77,42 @@ func TestTableUpdate(t *testing.T) {
 	}
 }
 
+func TestTableCallbacks(t *testing.T) {
+	local := tu.RandPeerIDFatal(t)
+	m := peer.NewMetrics()
+	rt := NewRoutingTable(10, ConvertPeerID(local), time.Hour, m)
+
+	members := make(map[peer.ID]bool)
+	rt.PeerAdded = func(p peer.ID) { members[p] = true }
+	rt.PeerRemoved = func(p peer.ID) { delete(members, p) }
+
+	peers := make([]peer.ID, 100)
+	for i := range peers {
+		peers[i] = tu.RandPeerIDFatal(t)
+		rt.Update(peers[i])
+	}
+	// updating a known peer adds nothing.
+	rt.Update(peers[0])
+
+	check := func() {
+		listed := rt.ListPeers()
+		if len(listed) != len(members) {
+			t.Fatalf("table has %d peers, callbacks saw %d", len(listed), len(members))
+		}
+		for _, p := range listed {
+			if !members[p] {
+				t.Fatalf("callbacks missed %s", p)
+			}
+		}
+	}
+	check()
+
+	for _, p := range peers[:50] {
+		rt.Remove(p)
+	}
+	check()
+}
+
 func TestTableFind(t *testing.T) {
 	local := tu.RandPeerIDFatal(t)
 	m := peer.NewMetrics()
@@ -44,3 +44,14 @@ func RecordBlobForSig(r *pb.Record) []byte {
 	a := []byte(r.GetAuthor())
 	return bytes.Join([][]byte{k, v, a}, []byte{})
 }
+
+// MakeProviderRecord creates and signs a record announcing the owner of sk
+// as a provider of key. Its value is the public key of the provider, so
+// the peers it is relayed to can check it without looking the key up.
+func MakeProviderRecord(sk ci.PrivKey, key u.Key) (*pb.Record, error) {
+	pkb, err := sk.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return MakePutRecord(sk, key, pkb)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	ci "github.com/jbenet/go-ipfs/p2p/crypto"
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
 	pb "github.com/jbenet/go-ipfs/routing/dht/pb"
 	u "github.com/jbenet/go-ipfs/util"
 )
@@ -43,9 +44,16 @@ func (v Validator) VerifyRecord(r *pb.Record, pk ci.PubKey) error {
 	}
 
 	// Now, check validity func
-	parts := strings.Split(r.GetKey(), "/")
+	return v.VerifyValue(u.Key(r.GetKey()), r.GetValue())
+}
+
+// VerifyValue checks that val is a valid value for key k, running the
+// validator registered for the key's prefix. It does not check signatures,
+// so it is meant for values whose record was verified by someone else.
+func (v Validator) VerifyValue(k u.Key, val []byte) error {
+	parts := strings.Split(string(k), "/")
 	if len(parts) < 3 {
-		log.Infof("Record key does not have validator: %s", u.Key(r.GetKey()))
+		log.Infof("Record key does not have validator: %s", k)
 		return nil
 	}
 
@@ -55,14 +63,15 @@ func (v Validator) VerifyRecord(r *pb.Record, pk ci.PubKey) error {
 		return ErrInvalidRecordType
 	}
 
-	return fnc(u.Key(r.GetKey()), r.GetValue())
+	return fnc(k, val)
 }
 
 // ValidatePublicKeyRecord implements ValidatorFunc and
 // verifies that the passed in record value is the PublicKey
 // that matches the passed in key.
 func ValidatePublicKeyRecord(k u.Key, val []byte) error {
-	keyparts := bytes.Split([]byte(k), []byte("/"))
+	// the hash may hold '/' bytes itself.
+	keyparts := bytes.SplitN([]byte(k), []byte("/"), 3)
 	if len(keyparts) < 3 {
 		return errors.New("invalid key")
 	}
@@ -73,3 +82,28 @@ func ValidatePublicKeyRecord(k u.Key, val []byte) error {
 	}
 	return nil
 }
+
+// VerifyProviderRecord checks that r was made by p with MakeProviderRecord,
+// to announce itself as a provider of key.
+func VerifyProviderRecord(r *pb.Record, key u.Key, p peer.ID) error {
+	if r == nil || u.Key(r.GetKey()) != key || peer.ID(r.GetAuthor()) != p {
+		return ErrBadRecord
+	}
+
+	pk, err := ci.UnmarshalPublicKey(r.GetValue())
+	if err != nil {
+		return err
+	}
+	if !p.MatchesPublicKey(pk) {
+		return ErrBadRecord
+	}
+
+	ok, err := pk.Verify(RecordBlobForSig(r), r.GetSignature())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrBadRecord
+	}
+	return nil
+}
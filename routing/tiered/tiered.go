@@ -0,0 +1,242 @@
+// Package tiered implements an IpfsRouting that combines several routing
+// systems, e.g. a LAN router, a delegated router and the public dht. All
+// routers are queried in parallel, so the fast (local) ones answer first
+// while the slow ones still serve as a fallback.
+package tiered
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	routing "github.com/jbenet/go-ipfs/routing"
+	dht "github.com/jbenet/go-ipfs/routing/dht"
+	record "github.com/jbenet/go-ipfs/routing/record"
+	eventlog "github.com/jbenet/go-ipfs/thirdparty/eventlog"
+	u "github.com/jbenet/go-ipfs/util"
+	pset "github.com/jbenet/go-ipfs/util/peerset"
+)
+
+var log = eventlog.Logger("routing/tiered")
+
+// ErrNoRouters is returned when a Tiered router is built without routers.
+var ErrNoRouters = errors.New("tiered routing: no routers")
+
+// Tiered is an IpfsRouting which dispatches each request to all of its
+// Routers, and combines their answers.
+type Tiered struct {
+	// Routers are the routing systems queried, in order of preference.
+	Routers []routing.IpfsRouting
+
+	// Validator is used to check values before returning them.
+	Validator record.Validator
+}
+
+// New returns a Tiered router querying all of routers. Values returned by
+// the routers are checked with v.
+func New(v record.Validator, routers ...routing.IpfsRouting) (*Tiered, error) {
+	if len(routers) < 1 {
+		return nil, ErrNoRouters
+	}
+	return &Tiered{Routers: routers, Validator: v}, nil
+}
+
+// DHT returns the dht among the Routers, or nil if there is none.
+func (t *Tiered) DHT() *dht.IpfsDHT {
+	for _, r := range t.Routers {
+		if d, ok := r.(*dht.IpfsDHT); ok {
+			return d
+		}
+	}
+	return nil
+}
+
+// FindProvidersAsync merges the providers found by all routers, returning
+// each peer at most once.
+func (t *Tiered) FindProvidersAsync(ctx context.Context, key u.Key, count int) <-chan peer.PeerInfo {
+	out := make(chan peer.PeerInfo, count)
+	ctx, cancel := context.WithCancel(ctx)
+
+	ps := pset.NewLimited(count)
+	var wg sync.WaitGroup
+	for _, r := range t.Routers {
+		wg.Add(1)
+		go func(r routing.IpfsRouting) {
+			defer wg.Done()
+			for pi := range r.FindProvidersAsync(ctx, key, count) {
+				if !ps.TryAdd(pi.ID) {
+					continue // seen already, or enough peers.
+				}
+
+				select {
+				case out <- pi:
+				case <-ctx.Done():
+					return
+				}
+
+				// found enough peers, stop all the other searches.
+				if ps.Size() >= count {
+					cancel()
+					return
+				}
+			}
+		}(r)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(out)
+	}()
+	return out
+}
+
+// GetValue races all routers, and returns the first value which passes
+// validation. If no router finds a valid value, it returns the first error.
+func (t *Tiered) GetValue(ctx context.Context, key u.Key) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		val []byte
+		err error
+	}
+	results := make(chan result, len(t.Routers))
+	for _, r := range t.Routers {
+		go func(r routing.IpfsRouting) {
+			val, err := r.GetValue(ctx, key)
+			if err == nil {
+				err = t.Validator.VerifyValue(key, val)
+				if err != nil {
+					log.Debugf("tiered GetValue: invalid value for %s: %s", key, err)
+				}
+			}
+			results <- result{val, err}
+		}(r)
+	}
+
+	var firstErr error
+	for _ = range t.Routers {
+		res := <-results
+		if res.err == nil {
+			return res.val, nil
+		}
+		if firstErr == nil {
+			firstErr = res.err
+		}
+	}
+	return nil, firstErr
+}
+
+// PutValue stores the value with all routers. It fails only if all of
+// them fail.
+func (t *Tiered) PutValue(ctx context.Context, key u.Key, val []byte) error {
+	return t.fanOut(func(r routing.IpfsRouting) error {
+		return r.PutValue(ctx, key, val)
+	})
+}
+
+// Provide announces the local node with all routers. It fails only if all
+// of them fail.
+func (t *Tiered) Provide(ctx context.Context, key u.Key) error {
+	return t.fanOut(func(r routing.IpfsRouting) error {
+		return r.Provide(ctx, key)
+	})
+}
+
+// FindPeer races all routers, and returns the first peer found.
+func (t *Tiered) FindPeer(ctx context.Context, p peer.ID) (peer.PeerInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		pi  peer.PeerInfo
+		err error
+	}
+	results := make(chan result, len(t.Routers))
+	for _, r := range t.Routers {
+		go func(r routing.IpfsRouting) {
+			pi, err := r.FindPeer(ctx, p)
+			if err == nil && pi.ID != p {
+				err = routing.ErrNotFound
+			}
+			results <- result{pi, err}
+		}(r)
+	}
+
+	var firstErr error
+	for _ = range t.Routers {
+		res := <-results
+		if res.err == nil {
+			return res.pi, nil
+		}
+		if firstErr == nil {
+			firstErr = res.err
+		}
+	}
+	return peer.PeerInfo{}, firstErr
+}
+
+// Ping pings p with the first router which succeeds, in order of preference.
+func (t *Tiered) Ping(ctx context.Context, p peer.ID) (time.Duration, error) {
+	var firstErr error
+	for _, r := range t.Routers {
+		d, err := r.Ping(ctx, p)
+		if err == nil {
+			return d, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return 0, firstErr
+}
+
+// Close closes all routers that need closing, and returns the first error.
+func (t *Tiered) Close() error {
+	var firstErr error
+	for _, r := range t.Routers {
+		if c, ok := r.(io.Closer); ok {
+			if err := c.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// fanOut runs f on all routers in parallel. It returns nil if any of them
+// succeeded, and the first error otherwise.
+func (t *Tiered) fanOut(f func(routing.IpfsRouting) error) error {
+	errs := make(chan error, len(t.Routers))
+	for _, r := range t.Routers {
+		go func(r routing.IpfsRouting) {
+			errs <- f(r)
+		}(r)
+	}
+
+	var firstErr error
+	success := false
+	for _ = range t.Routers {
+		err := <-errs
+		switch {
+		case err == nil:
+			success = true
+		case firstErr == nil:
+			firstErr = err
+		}
+		if err != nil {
+			log.Debugf("tiered routing: router failed: %s", err)
+		}
+	}
+	if success {
+		return nil
+	}
+	return firstErr
+}
+
+var _ routing.IpfsRouting = &Tiered{}
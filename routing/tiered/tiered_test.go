@@ -0,0 +1,280 @@
+package tiered
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+
+	peer "github.com/jbenet/go-ipfs/p2p/peer"
+	routing "github.com/jbenet/go-ipfs/routing"
+	record "github.com/jbenet/go-ipfs/routing/record"
+	u "github.com/jbenet/go-ipfs/util"
+	testutil "github.com/jbenet/go-ipfs/util/testutil"
+)
+
+var errFailed = errors.New("stub router failed")
+
+// stub is a canned IpfsRouting, answering after delay.
+type stub struct {
+	delay     time.Duration
+	providers []peer.PeerInfo
+	values    map[u.Key][]byte
+	peers     map[peer.ID]peer.PeerInfo
+	fail      bool
+
+	provided []u.Key
+}
+
+func (s *stub) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *stub) FindProvidersAsync(ctx context.Context, k u.Key, count int) <-chan peer.PeerInfo {
+	out := make(chan peer.PeerInfo)
+	go func() {
+		defer close(out)
+		if s.wait(ctx) != nil {
+			return
+		}
+		for _, pi := range s.providers {
+			select {
+			case out <- pi:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (s *stub) PutValue(ctx context.Context, k u.Key, v []byte) error {
+	if s.fail {
+		return errFailed
+	}
+	s.values[k] = v
+	return nil
+}
+
+func (s *stub) GetValue(ctx context.Context, k u.Key) ([]byte, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	v, ok := s.values[k]
+	if !ok {
+		return nil, routing.ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *stub) Provide(ctx context.Context, k u.Key) error {
+	if s.fail {
+		return errFailed
+	}
+	s.provided = append(s.provided, k)
+	return nil
+}
+
+func (s *stub) FindPeer(ctx context.Context, p peer.ID) (peer.PeerInfo, error) {
+	if err := s.wait(ctx); err != nil {
+		return peer.PeerInfo{}, err
+	}
+	pi, ok := s.peers[p]
+	if !ok {
+		return peer.PeerInfo{}, routing.ErrNotFound
+	}
+	return pi, nil
+}
+
+func (s *stub) Ping(ctx context.Context, p peer.ID) (time.Duration, error) {
+	return 0, nil
+}
+
+func newStub(delay time.Duration) *stub {
+	return &stub{
+		delay:  delay,
+		values: make(map[u.Key][]byte),
+		peers:  make(map[peer.ID]peer.PeerInfo),
+	}
+}
+
+func randPeerInfos(t *testing.T, n int) []peer.PeerInfo {
+	pis := make([]peer.PeerInfo, n)
+	for i := range pis {
+		pis[i].ID = testutil.RandPeerIDFatal(t)
+	}
+	return pis
+}
+
+func TestFindProvidersMerged(t *testing.T) {
+	pis := randPeerInfos(t, 4)
+
+	local := newStub(0)
+	local.providers = pis[:2]
+	remote := newStub(10 * time.Millisecond)
+	remote.providers = pis[1:] // overlaps with local.
+
+	tr, err := New(nil, local, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[peer.ID]int)
+	for pi := range tr.FindProvidersAsync(context.Background(), u.Key("k"), 10) {
+		seen[pi.ID]++
+	}
+	if len(seen) != 4 {
+		t.Fatalf("expected 4 providers, got %d", len(seen))
+	}
+	for p, n := range seen {
+		if n != 1 {
+			t.Fatalf("provider %s returned %d times", p, n)
+		}
+	}
+}
+
+func TestFindProvidersCount(t *testing.T) {
+	local := newStub(0)
+	local.providers = randPeerInfos(t, 3)
+	remote := newStub(0)
+	remote.providers = randPeerInfos(t, 3)
+
+	tr, err := New(nil, local, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for _ = range tr.FindProvidersAsync(context.Background(), u.Key("k"), 4) {
+		n++
+	}
+	if n != 4 {
+		t.Fatalf("expected 4 providers, got %d", n)
+	}
+}
+
+func TestGetValueFirstValid(t *testing.T) {
+	k := u.Key("/v/key")
+	v := record.Validator{
+		"v": func(k u.Key, val []byte) error {
+			if string(val) != "good" {
+				return errors.New("bad value")
+			}
+			return nil
+		},
+	}
+
+	// the fastest router returns an invalid value, the slowest a valid one.
+	fast := newStub(0)
+	fast.values[k] = []byte("bad")
+	slow := newStub(20 * time.Millisecond)
+	slow.values[k] = []byte("good")
+	missing := newStub(0)
+
+	tr, err := New(v, fast, missing, slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := tr.GetValue(context.Background(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "good" {
+		t.Fatalf("expected valid value, got %s", val)
+	}
+
+	// no router has a valid value.
+	tr, err = New(v, fast, missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.GetValue(context.Background(), k); err == nil {
+		t.Fatal("expected GetValue to fail")
+	}
+}
+
+func TestGetValueRace(t *testing.T) {
+	k := u.Key("/v/key")
+	v := record.Validator{"v": func(u.Key, []byte) error { return nil }}
+
+	fast := newStub(0)
+	fast.values[k] = []byte("fast")
+	slow := newStub(time.Hour)
+	slow.values[k] = []byte("slow")
+
+	tr, err := New(v, slow, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, _ := context.WithTimeout(context.Background(), time.Second)
+	val, err := tr.GetValue(ctx, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "fast" {
+		t.Fatalf("expected the fastest value, got %s", val)
+	}
+}
+
+func TestProvideFanOut(t *testing.T) {
+	a := newStub(0)
+	b := newStub(0)
+	failing := newStub(0)
+	failing.fail = true
+
+	tr, err := New(nil, a, failing, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := u.Key("k")
+	if err := tr.Provide(context.Background(), k); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.provided) != 1 || len(b.provided) != 1 {
+		t.Fatal("expected all routers to provide")
+	}
+
+	tr, err = New(nil, failing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Provide(context.Background(), k); err != errFailed {
+		t.Fatalf("expected %s, got %v", errFailed, err)
+	}
+}
+
+func TestFindPeer(t *testing.T) {
+	pi := randPeerInfos(t, 1)[0]
+
+	missing := newStub(0)
+	found := newStub(10 * time.Millisecond)
+	found.peers[pi.ID] = pi
+
+	tr, err := New(nil, missing, found)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := tr.FindPeer(context.Background(), pi.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ID != pi.ID {
+		t.Fatal("found wrong peer")
+	}
+}
+
+func TestNoRouters(t *testing.T) {
+	if _, err := New(nil); err != ErrNoRouters {
+		t.Fatalf("expected %s, got %v", ErrNoRouters, err)
+	}
+}
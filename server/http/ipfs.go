@@ -2,6 +2,9 @@ package http
 
 import (
 	"io"
+	"time"
+
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 
 	core "github.com/jbenet/go-ipfs/core"
 	"github.com/jbenet/go-ipfs/importer"
@@ -23,7 +26,9 @@ type ipfsHandler struct {
 }
 
 func (i *ipfsHandler) ResolvePath(path string) (*dag.Node, error) {
-	return i.node.Resolver.ResolvePath(path)
+	ctx, cancel := context.WithTimeout(i.node.Context(), time.Minute)
+	defer cancel()
+	return i.node.Resolver.ResolvePath(ctx, path)
 }
 
 func (i *ipfsHandler) NewDagFromReader(r io.Reader) (*dag.Node, error) {
@@ -130,7 +130,7 @@ func DirectAddCat(data []byte, conf testutil.LatencyConfig) error {
 		return err
 	}
 
-	readerCatted, err := coreunix.Cat(catter, keyAdded.String())
+	readerCatted, err := coreunix.Cat(catter, ctx, keyAdded.String())
 	if err != nil {
 		return err
 	}
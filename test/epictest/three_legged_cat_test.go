@@ -77,7 +77,7 @@ func RunThreeLeggedCat(data []byte, conf testutil.LatencyConfig) error {
 		return err
 	}
 
-	readerCatted, err := coreunix.Cat(catter, keyAdded.String())
+	readerCatted, err := coreunix.Cat(catter, ctx, keyAdded.String())
 	if err != nil {
 		return err
 	}
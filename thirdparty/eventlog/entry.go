@@ -29,11 +29,14 @@ func (e *entry) log() {
 	}
 
 	// apply final attributes to reserved keys
-	// TODO accum["level"] = level
-	accum["event"] = e.event
-	accum["system"] = e.system
-	accum["time"] = util.FormatRFC3339(time.Now())
+	accum[KeyEvent] = e.event
+	accum[KeySystem] = e.system
+	accum[KeyTime] = util.FormatRFC3339(time.Now())
 
 	// TODO roll our own event logger
 	logrus.WithFields(map[string]interface{}(accum)).Info(e.event)
+
+	if subscribed() {
+		publish(DeepMerge(accum, Metadata{KeyLevel: eventLevel}))
+	}
 }
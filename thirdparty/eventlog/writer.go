@@ -0,0 +1,81 @@
+package eventlog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jbenet/go-ipfs/util"
+)
+
+// Reserved keys of the entries streamed to subscribers.
+const (
+	KeyEvent   = "event"
+	KeySystem  = "system"
+	KeyTime    = "time"
+	KeyLevel   = "level"
+	KeyMessage = "message"
+)
+
+// the level of event entries, which are logged at info by convention.
+const eventLevel = "info"
+
+// subscribers receive the entries as they are logged.
+var subscribers = struct {
+	sync.RWMutex
+	chans map[chan Metadata]struct{}
+}{chans: make(map[chan Metadata]struct{})}
+
+func init() {
+	// messages of the standard loggers are entries too.
+	util.SetLogHook(func(system, level, message string) {
+		if !subscribed() {
+			return
+		}
+		publish(Metadata{
+			KeySystem:  system,
+			KeyLevel:   level,
+			KeyMessage: message,
+			KeyTime:    util.FormatRFC3339(time.Now()),
+		})
+	})
+}
+
+// Subscribe returns a channel of the entries logged from now on: events,
+// whatever the level of the event log, and messages at the levels enabled.
+// Entries are dropped while the channel is full, not to block the loggers.
+// cancel ends the subscription, and closes the channel.
+func Subscribe(buffer int) (entries <-chan Metadata, cancel func()) {
+	ch := make(chan Metadata, buffer)
+
+	subscribers.Lock()
+	subscribers.chans[ch] = struct{}{}
+	subscribers.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			subscribers.Lock()
+			delete(subscribers.chans, ch)
+			subscribers.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func subscribed() bool {
+	subscribers.RLock()
+	defer subscribers.RUnlock()
+	return len(subscribers.chans) > 0
+}
+
+// publish sends m to the subscribers, which must not modify it.
+func publish(m Metadata) {
+	subscribers.RLock()
+	defer subscribers.RUnlock()
+	for ch := range subscribers.chans {
+		select {
+		case ch <- m:
+		default:
+		}
+	}
+}
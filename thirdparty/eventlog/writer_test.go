@@ -0,0 +1,40 @@
+package eventlog
+
+import (
+	"testing"
+
+	"github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
+	"github.com/jbenet/go-ipfs/util"
+)
+
+func TestSubscribe(t *testing.T) {
+	entries, cancel := Subscribe(10)
+
+	log := Logger("subscribetest")
+	log.Event(context.Background(), "thing", Metadata{"count": 3})
+	if err := util.SetLogLevel("subscribetest", "warning"); err != nil {
+		t.Fatal(err)
+	}
+	log.Info("not at a level enabled")
+	log.Warning("careful")
+	cancel()
+
+	var got []Metadata
+	for e := range entries {
+		if e[KeySystem] == "subscribetest" {
+			got = append(got, e)
+		}
+	}
+	if len(got) != 2 {
+		t.Fatalf("got entries %v, expected 2", got)
+	}
+	if got[0][KeyEvent] != "thing" || got[0][KeyLevel] != "info" || got[0]["count"] != 3 {
+		t.Errorf("unexpected event entry %v", got[0])
+	}
+	if got[1][KeyMessage] != "careful" || got[1][KeyLevel] != "warning" {
+		t.Errorf("unexpected message entry %v", got[1])
+	}
+	if subscribed() {
+		t.Error("still subscribed after cancel")
+	}
+}
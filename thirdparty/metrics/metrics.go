@@ -0,0 +1,393 @@
+// Package metrics keeps counters, gauges and histograms, and writes them in
+// the Prometheus text format, for monitoring systems to scrape.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of the histogram buckets of durations
+// in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry of the metrics made with the package functions.
+var Default = NewRegistry()
+
+// collector is a metric family, written under one name.
+type collector interface {
+	writeText(w *bufio.Writer, name string)
+}
+
+type family struct {
+	name, help, typ string
+	c               collector
+}
+
+// Registry keeps metrics by name.
+type Registry struct {
+	lk       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// get returns the collector named name, or registers the one newMetric
+// returns. Metrics are made once: later calls return the first one, so
+// packages and tests may ask for them again.
+func (r *Registry) get(name, help, typ string, newMetric func() collector) collector {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, typ: typ, c: newMetric()}
+		r.families[name] = f
+	}
+	if f.typ != typ {
+		panic(fmt.Sprintf("metrics: %s is a %s, not a %s", name, f.typ, typ))
+	}
+	return f.c
+}
+
+// Counter returns the counter named name.
+func (r *Registry) Counter(name, help string) *Counter {
+	return r.get(name, help, "counter", func() collector { return &Counter{} }).(*Counter)
+}
+
+// CounterVec returns the counters named name, by the values of labels.
+func (r *Registry) CounterVec(name, help string, labels ...string) *CounterVec {
+	return r.get(name, help, "counter", func() collector {
+		return &CounterVec{vec: newVec(labels, func() collector { return &Counter{} })}
+	}).(*CounterVec)
+}
+
+// Gauge returns the gauge named name.
+func (r *Registry) Gauge(name, help string) *Gauge {
+	return r.get(name, help, "gauge", func() collector { return &Gauge{} }).(*Gauge)
+}
+
+// GaugeFunc sets the gauge named name to the value f returns when written.
+// Setting it again replaces f.
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	g := r.get(name, help, "gauge", func() collector { return &gaugeFunc{} }).(*gaugeFunc)
+	g.lk.Lock()
+	g.f = f
+	g.lk.Unlock()
+}
+
+// Histogram returns the histogram named name, with buckets of the given
+// upper bounds, in increasing order.
+func (r *Registry) Histogram(name, help string, buckets []float64) *Histogram {
+	return r.get(name, help, "histogram", func() collector { return newHistogram(buckets) }).(*Histogram)
+}
+
+// HistogramVec returns the histograms named name, by the values of labels.
+func (r *Registry) HistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return r.get(name, help, "histogram", func() collector {
+		return &HistogramVec{vec: newVec(labels, func() collector { return newHistogram(buckets) })}
+	}).(*HistogramVec)
+}
+
+// WriteText writes the metrics in the Prometheus text format, by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lk.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.lk.Unlock()
+	sort.Sort(byName(families))
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+		f.c.writeText(bw, f.name)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteText(w)
+}
+
+type byName []*family
+
+func (f byName) Len() int           { return len(f) }
+func (f byName) Less(i, j int) bool { return f[i].name < f[j].name }
+func (f byName) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// NewCounter returns the counter named name in the Default registry.
+func NewCounter(name, help string) *Counter {
+	return Default.Counter(name, help)
+}
+
+// NewCounterVec returns the counters named name in the Default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.CounterVec(name, help, labels...)
+}
+
+// NewGauge returns the gauge named name in the Default registry.
+func NewGauge(name, help string) *Gauge {
+	return Default.Gauge(name, help)
+}
+
+// NewHistogram returns the histogram named name in the Default registry.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return Default.Histogram(name, help, buckets)
+}
+
+// NewHistogramVec returns the histograms named name in the Default
+// registry.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.HistogramVec(name, help, buckets, labels...)
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	lk sync.Mutex
+	v  float64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds v, which must not be negative, to the counter.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.lk.Lock()
+	c.v += v
+	c.lk.Unlock()
+}
+
+// Value returns the count.
+func (c *Counter) Value() float64 {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.v
+}
+
+func (c *Counter) writeText(w *bufio.Writer, name string) {
+	writeSample(w, name, "", c.Value())
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	lk sync.Mutex
+	v  float64
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.lk.Lock()
+	g.v = v
+	g.lk.Unlock()
+}
+
+// Add adds v to the gauge.
+func (g *Gauge) Add(v float64) {
+	g.lk.Lock()
+	g.v += v
+	g.lk.Unlock()
+}
+
+// Value returns the value of the gauge.
+func (g *Gauge) Value() float64 {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	return g.v
+}
+
+func (g *Gauge) writeText(w *bufio.Writer, name string) {
+	writeSample(w, name, "", g.Value())
+}
+
+type gaugeFunc struct {
+	lk sync.Mutex
+	f  func() float64
+}
+
+func (g *gaugeFunc) writeText(w *bufio.Writer, name string) {
+	g.lk.Lock()
+	f := g.f
+	g.lk.Unlock()
+	writeSample(w, name, "", f())
+}
+
+// Histogram counts observations in buckets, by upper bound.
+type Histogram struct {
+	lk      sync.Mutex
+	bounds  []float64
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, buckets: make([]uint64, len(bounds))}
+}
+
+// Observe counts v.
+func (h *Histogram) Observe(v float64) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	for i, b := range h.bounds {
+		if v <= b {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return h.count
+}
+
+func (h *Histogram) writeText(w *bufio.Writer, name string) {
+	h.writeLabeled(w, name, "")
+}
+
+func (h *Histogram) writeLabeled(w *bufio.Writer, name, labels string) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, b := range h.bounds {
+		writeSample(w, name+"_bucket", labels+sep+`le="`+formatFloat(b)+`"`, float64(h.buckets[i]))
+	}
+	writeSample(w, name+"_bucket", labels+sep+`le="+Inf"`, float64(h.count))
+	writeSample(w, name+"_sum", labels, h.sum)
+	writeSample(w, name+"_count", labels, float64(h.count))
+}
+
+// vec keeps a metric by the values of its labels.
+type vec struct {
+	lk        sync.Mutex
+	labels    []string
+	metrics   map[string]collector
+	values    map[string][]string
+	newMetric func() collector
+}
+
+func newVec(labels []string, newMetric func() collector) *vec {
+	return &vec{
+		labels:    labels,
+		metrics:   make(map[string]collector),
+		values:    make(map[string][]string),
+		newMetric: newMetric,
+	}
+}
+
+func (v *vec) with(values []string) collector {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(values), v.labels))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	m, ok := v.metrics[key]
+	if !ok {
+		m = v.newMetric()
+		v.metrics[key] = m
+		v.values[key] = values
+	}
+	return m
+}
+
+func (v *vec) writeText(w *bufio.Writer, name string) {
+	v.lk.Lock()
+	keys := make([]string, 0, len(v.metrics))
+	for k := range v.metrics {
+		keys = append(keys, k)
+	}
+	v.lk.Unlock()
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v.lk.Lock()
+		m, values := v.metrics[k], v.values[k]
+		v.lk.Unlock()
+
+		pairs := make([]string, len(values))
+		for i, val := range values {
+			pairs[i] = v.labels[i] + `="` + escapeLabel(val) + `"`
+		}
+		labels := strings.Join(pairs, ",")
+
+		switch m := m.(type) {
+		case *Counter:
+			writeSample(w, name, labels, m.Value())
+		case *Histogram:
+			m.writeLabeled(w, name, labels)
+		}
+	}
+}
+
+// CounterVec is a set of counters, by the values of their labels.
+type CounterVec struct {
+	*vec
+}
+
+// With returns the counter of the label values, in the order of the labels.
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.with(values).(*Counter)
+}
+
+// HistogramVec is a set of histograms, by the values of their labels.
+type HistogramVec struct {
+	*vec
+}
+
+// With returns the histogram of the label values, in the order of the
+// labels.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.with(values).(*Histogram)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, v float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
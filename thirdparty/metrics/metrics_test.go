@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("blocks_total", "Blocks seen.").Add(3)
+	r.Counter("blocks_total", "asked again").Inc()
+	r.Gauge("peers", "Connected peers.").Set(2)
+	r.GaugeFunc("size", "Size\nin bytes.", func() float64 { return 1.5 })
+
+	reqs := r.CounterVec("requests_total", "Requests.", "method", "path")
+	reqs.With("GET", `/a"b`).Inc()
+	reqs.With("GET", "/").Add(2)
+
+	h := r.HistogramVec("duration_seconds", "Durations.", []float64{.1, 1}, "status")
+	h.With("200").Observe(.05)
+	h.With("200").Observe(.5)
+	h.With("200").Observe(5)
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP blocks_total Blocks seen.
+# TYPE blocks_total counter
+blocks_total 4
+# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{status="200",le="0.1"} 1
+duration_seconds_bucket{status="200",le="1"} 2
+duration_seconds_bucket{status="200",le="+Inf"} 3
+duration_seconds_sum{status="200"} 5.55
+duration_seconds_count{status="200"} 3
+# HELP peers Connected peers.
+# TYPE peers gauge
+peers 2
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{method="GET",path="/"} 2
+requests_total{method="GET",path="/a\"b"} 1
+# HELP size Size\nin bytes.
+# TYPE size gauge
+size 1.5
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestTypeMismatch(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("x", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("made a gauge of the counter")
+		}
+	}()
+	r.Gauge("x", "")
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	proto "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
 
 	chunk "github.com/jbenet/go-ipfs/importer/chunk"
@@ -21,12 +22,13 @@ var log = u.Logger("dagio")
 type DagModifier struct {
 	dagserv mdag.DAGService
 	curNode *mdag.Node
+	ctx     context.Context // bounds the fetching of the blocks to modify
 
 	pbdata   *ftpb.Data
 	splitter chunk.BlockSplitter
 }
 
-func NewDagModifier(from *mdag.Node, serv mdag.DAGService, spl chunk.BlockSplitter) (*DagModifier, error) {
+func NewDagModifier(ctx context.Context, from *mdag.Node, serv mdag.DAGService, spl chunk.BlockSplitter) (*DagModifier, error) {
 	pbd, err := ft.FromBytes(from.Data)
 	if err != nil {
 		return nil, err
@@ -34,6 +36,7 @@ func NewDagModifier(from *mdag.Node, serv mdag.DAGService, spl chunk.BlockSplitt
 
 	return &DagModifier{
 		curNode:  from.Copy(),
+		ctx:      ctx,
 		dagserv:  serv,
 		pbdata:   pbd,
 		splitter: spl,
@@ -82,7 +85,7 @@ func (dm *DagModifier) WriteAt(b []byte, offset uint64) (int, error) {
 				// Here is where we start
 				startsubblk = i
 				lnk := dm.curNode.Links[i]
-				node, err := dm.dagserv.Get(u.Key(lnk.Hash))
+				node, err := dm.dagserv.Get(dm.ctx, u.Key(lnk.Hash))
 				if err != nil {
 					return 0, err
 				}
@@ -125,7 +128,7 @@ func (dm *DagModifier) WriteAt(b []byte, offset uint64) (int, error) {
 	var midlnk *mdag.Link
 	if mid >= 0 {
 		midlnk = dm.curNode.Links[mid]
-		midnode, err := dm.dagserv.Get(u.Key(midlnk.Hash))
+		midnode, err := dm.dagserv.Get(dm.ctx, u.Key(midlnk.Hash))
 		if err != nil {
 			return 0, err
 		}
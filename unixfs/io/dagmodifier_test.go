@@ -16,6 +16,7 @@ import (
 	ft "github.com/jbenet/go-ipfs/unixfs"
 	u "github.com/jbenet/go-ipfs/util"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	ds "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	logging "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-logging"
 )
@@ -99,7 +100,7 @@ func TestDagModifierBasic(t *testing.T) {
 	dserv := getMockDagServ(t)
 	b, n := getNode(t, dserv, 50000)
 
-	dagmod, err := NewDagModifier(n, dserv, &chunk.SizeSplitter{Size: 512})
+	dagmod, err := NewDagModifier(context.Background(), n, dserv, &chunk.SizeSplitter{Size: 512})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -151,7 +152,7 @@ func TestMultiWrite(t *testing.T) {
 	dserv := getMockDagServ(t)
 	_, n := getNode(t, dserv, 0)
 
-	dagmod, err := NewDagModifier(n, dserv, &chunk.SizeSplitter{Size: 512})
+	dagmod, err := NewDagModifier(context.Background(), n, dserv, &chunk.SizeSplitter{Size: 512})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -193,7 +194,7 @@ func TestMultiWriteCoal(t *testing.T) {
 	dserv := getMockDagServ(t)
 	_, n := getNode(t, dserv, 0)
 
-	dagmod, err := NewDagModifier(n, dserv, &chunk.SizeSplitter{Size: 512})
+	dagmod, err := NewDagModifier(context.Background(), n, dserv, &chunk.SizeSplitter{Size: 512})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -12,6 +12,7 @@ import (
 	uio "github.com/jbenet/go-ipfs/unixfs/io"
 	upb "github.com/jbenet/go-ipfs/unixfs/pb"
 
+	context "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/go.net/context"
 	proto "github.com/jbenet/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
 )
 
@@ -19,6 +20,7 @@ type Reader struct {
 	buf        bytes.Buffer
 	closed     bool
 	signalChan chan struct{}
+	ctx        context.Context
 	dag        mdag.DAGService
 	resolver   *path.Resolver
 	writer     *tar.Writer
@@ -26,9 +28,10 @@ type Reader struct {
 	err        error
 }
 
-func NewReader(path string, dag mdag.DAGService, resolver *path.Resolver, compression int) (*Reader, error) {
+func NewReader(ctx context.Context, path string, dag mdag.DAGService, resolver *path.Resolver, compression int) (*Reader, error) {
 	reader := &Reader{
 		signalChan: make(chan struct{}),
+		ctx:        ctx,
 		dag:        dag,
 		resolver:   resolver,
 	}
@@ -44,7 +47,7 @@ func NewReader(path string, dag mdag.DAGService, resolver *path.Resolver, compre
 		reader.writer = tar.NewWriter(&reader.buf)
 	}
 
-	dagnode, err := resolver.ResolvePath(path)
+	dagnode, err := resolver.ResolvePath(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +85,7 @@ func (i *Reader) writeToBuf(dagnode *mdag.Node, path string, depth int) {
 		i.flush()
 
 		for _, link := range dagnode.Links {
-			childNode, err := link.GetNode(i.dag)
+			childNode, err := link.GetNode(i.ctx, i.dag)
 			if err != nil {
 				i.emitError(err)
 				return
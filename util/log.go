@@ -3,6 +3,8 @@ package util
 import (
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 
 	logging "github.com/jbenet/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-logging"
 )
@@ -47,7 +49,7 @@ func SetupLogging() {
 	}
 
 	backend := logging.NewLogBackend(os.Stderr, "", 0)
-	logging.SetBackend(backend)
+	logging.SetBackend(backend, hookBackend{})
 	logging.SetFormatter(logging.MustStringFormatter(fmt))
 
 	lvl := logging.ERROR
@@ -115,3 +117,31 @@ func SetLogLevel(name, level string) error {
 
 	return nil
 }
+
+// logHook is called with the records logged, if set.
+var logHook struct {
+	sync.RWMutex
+	f func(module, level, message string)
+}
+
+// SetLogHook sets f to be called with the module, level and message of the
+// records logged at the levels enabled, besides printing them. nil unsets
+// it.
+func SetLogHook(f func(module, level, message string)) {
+	logHook.Lock()
+	logHook.f = f
+	logHook.Unlock()
+}
+
+// hookBackend passes the records it logs to the logHook.
+type hookBackend struct{}
+
+func (hookBackend) Log(lvl logging.Level, calldepth int, rec *logging.Record) error {
+	logHook.RLock()
+	f := logHook.f
+	logHook.RUnlock()
+	if f != nil {
+		f(rec.Module, strings.ToLower(lvl.String()), rec.Message())
+	}
+	return nil
+}